@@ -0,0 +1,191 @@
+// Package authenticode computes the Authenticode hash of PE/COFF images
+// like EFI binaries and extracts the signatures embedded in them, which
+// is what firmware checks against db and dbx and what shim compares
+// with the hashes enrolled in MokList.
+package authenticode
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrMalformedImage is returned for files that aren't valid PE/COFF images
+var ErrMalformedImage = errors.New("malformed PE/COFF image")
+
+const (
+	// winCertTypePKCSSignedData is the WIN_CERTIFICATE type of Authenticode signatures
+	winCertTypePKCSSignedData = 0x0002
+	// certTableIndex is the index of the certificate table data directory
+	certTableIndex = 4
+)
+
+// Image is a parsed PE/COFF image, holding the offsets needed for
+// hashing.
+type Image struct {
+	data []byte
+	// checksumOff is the file offset of the CheckSum field
+	checksumOff int
+	// certDirOff is the file offset of the certificate table data directory
+	certDirOff int
+	// sizeOfHeaders is the size of all headers including the section table
+	sizeOfHeaders int
+	// certOff and certSize locate the certificate table
+	certOff  int
+	certSize int
+	sections []section
+}
+
+type section struct {
+	name   string
+	offset int
+	size   int
+}
+
+// Parse extracts the layout information of a PE/COFF image. It fails
+// with an error wrapping ErrMalformedImage if any offset is out of
+// bounds, so the methods of the Image don't have to check them.
+func Parse(data []byte) (*Image, error) {
+	if len(data) < 0x40 || data[0] != 'M' || data[1] != 'Z' {
+		return nil, fmt.Errorf("%w: missing MZ header", ErrMalformedImage)
+	}
+	peOff := binary.LittleEndian.Uint32(data[0x3c:])
+	if !inBounds(peOff, 24, len(data)) || string(data[peOff:peOff+4]) != "PE\x00\x00" {
+		return nil, fmt.Errorf("%w: missing PE signature", ErrMalformedImage)
+	}
+	coff := int(peOff) + 4
+	numSections := int(binary.LittleEndian.Uint16(data[coff+2:]))
+	optSize := int(binary.LittleEndian.Uint16(data[coff+16:]))
+	opt := coff + 20
+	if opt+optSize > len(data) || optSize < 2 {
+		return nil, fmt.Errorf("%w: truncated optional header", ErrMalformedImage)
+	}
+
+	var numDirsOff, dirsOff int
+	switch binary.LittleEndian.Uint16(data[opt:]) {
+	case 0x10b: // PE32
+		numDirsOff, dirsOff = opt+92, opt+96
+	case 0x20b: // PE32+
+		numDirsOff, dirsOff = opt+108, opt+112
+	default:
+		return nil, fmt.Errorf("%w: unknown optional header magic", ErrMalformedImage)
+	}
+	if dirsOff > opt+optSize {
+		return nil, fmt.Errorf("%w: truncated optional header", ErrMalformedImage)
+	}
+
+	img := &Image{
+		data:        data,
+		checksumOff: opt + 64,
+		certDirOff:  dirsOff + certTableIndex*8,
+	}
+	if binary.LittleEndian.Uint32(data[numDirsOff:]) > certTableIndex && img.certDirOff+8 <= opt+optSize {
+		certOff := binary.LittleEndian.Uint32(data[img.certDirOff:])
+		certSize := binary.LittleEndian.Uint32(data[img.certDirOff+4:])
+		if certSize > 0 && !inBounds(certOff, certSize, len(data)) {
+			return nil, fmt.Errorf("%w: certificate table out of bounds", ErrMalformedImage)
+		}
+		img.certOff, img.certSize = int(certOff), int(certSize)
+	}
+	sizeOfHeaders := binary.LittleEndian.Uint32(data[opt+60:])
+	if !inBounds(sizeOfHeaders, 0, len(data)) || img.certDirOff+8 > int(sizeOfHeaders) {
+		return nil, fmt.Errorf("%w: invalid SizeOfHeaders", ErrMalformedImage)
+	}
+	img.sizeOfHeaders = int(sizeOfHeaders)
+
+	sectionTable := opt + optSize
+	if sectionTable+numSections*40 > len(data) {
+		return nil, fmt.Errorf("%w: truncated section table", ErrMalformedImage)
+	}
+	for i := 0; i < numSections; i++ {
+		s := data[sectionTable+i*40:]
+		size, offset := binary.LittleEndian.Uint32(s[16:]), binary.LittleEndian.Uint32(s[20:])
+		if size == 0 {
+			continue
+		}
+		if !inBounds(offset, size, len(data)) {
+			return nil, fmt.Errorf("%w: section %d out of bounds", ErrMalformedImage, i)
+		}
+		name := string(bytes.TrimRight(s[:8], "\x00"))
+		img.sections = append(img.sections, section{name: name, offset: int(offset), size: int(size)})
+	}
+	sort.Slice(img.sections, func(i, j int) bool { return img.sections[i].offset < img.sections[j].offset })
+	return img, nil
+}
+
+// inBounds reports whether size bytes at off fit into n bytes. Offsets
+// and sizes are added as 64 bit numbers, as the sum of two 32 bit fields
+// can overflow int on 32 bit systems and pass the check as negative.
+func inBounds(off, size uint32, n int) bool {
+	return uint64(off)+uint64(size) <= uint64(n)
+}
+
+// Hash computes the Authenticode digest of the image with h as described
+// in the "Windows Authenticode Portable Executable Signature Format". It
+// covers everything but the checksum and the signatures, so it stays the
+// same when an image is signed. h has to be available.
+func (img *Image) Hash(hash crypto.Hash) []byte {
+	h := hash.New()
+	d := img.data
+	h.Write(d[:img.checksumOff])
+	h.Write(d[img.checksumOff+4 : img.certDirOff])
+	h.Write(d[img.certDirOff+8 : img.sizeOfHeaders])
+
+	hashed := img.sizeOfHeaders
+	for _, s := range img.sections {
+		h.Write(d[s.offset : s.offset+s.size])
+		if end := s.offset + s.size; end > hashed {
+			hashed = end
+		}
+	}
+
+	// Data behind the last section, except the certificate table, is
+	// hashed as well.
+	end := len(d)
+	if img.certSize > 0 {
+		end -= img.certSize
+	}
+	if hashed < end {
+		h.Write(d[hashed:end])
+	}
+	return h.Sum(nil)
+}
+
+// Section returns the raw data of the first section called name, e.g.
+// ".sbat", or nil if there is none.
+func (img *Image) Section(name string) []byte {
+	for _, s := range img.sections {
+		if s.name == name {
+			return img.data[s.offset : s.offset+s.size]
+		}
+	}
+	return nil
+}
+
+// RawSignatures returns the DER encoded PKCS #7 blobs of all
+// Authenticode signatures in the certificate table.
+func (img *Image) RawSignatures() ([][]byte, error) {
+	var sigs [][]byte
+	table := img.data[img.certOff : img.certOff+img.certSize]
+	for len(table) >= 8 {
+		length32 := binary.LittleEndian.Uint32(table[0:])
+		certType := binary.LittleEndian.Uint16(table[6:])
+		if length32 < 8 || !inBounds(length32, 0, len(table)) {
+			return nil, fmt.Errorf("%w: invalid certificate table entry", ErrMalformedImage)
+		}
+		length := int(length32)
+		if certType == winCertTypePKCSSignedData {
+			sigs = append(sigs, table[8:length])
+		}
+		// Entries are aligned to 8 bytes
+		length = (length + 7) &^ 7
+		if length > len(table) {
+			break
+		}
+		table = table[length:]
+	}
+	return sigs, nil
+}
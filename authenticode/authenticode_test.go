@@ -0,0 +1,101 @@
+package authenticode
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// testImage returns a minimal PE32+ image with one section, which fn
+// may modify, given the offsets of the optional header and the section.
+func testImage(fn func(b []byte, opt, section int)) []byte {
+	b := make([]byte, 0x200)
+	b[0], b[1] = 'M', 'Z'
+	binary.LittleEndian.PutUint32(b[0x3c:], 0x40)
+	copy(b[0x40:], "PE\x00\x00")
+	coff := 0x44
+	binary.LittleEndian.PutUint16(b[coff+2:], 1)
+	binary.LittleEndian.PutUint16(b[coff+16:], 240)
+	opt := coff + 20
+	binary.LittleEndian.PutUint16(b[opt:], 0x20b)
+	binary.LittleEndian.PutUint32(b[opt+60:], 0x180)
+	binary.LittleEndian.PutUint32(b[opt+108:], 16)
+	section := opt + 240
+	binary.LittleEndian.PutUint32(b[section+16:], 0x80)
+	binary.LittleEndian.PutUint32(b[section+20:], 0x180)
+	fn(b, opt, section)
+	return b
+}
+
+// TestParsePEBounds checks that offsets close to 2 GiB are rejected
+// instead of overflowing int on 32 bit systems once their size is added,
+// where the sums would be negative and pass the bounds checks.
+func TestParsePEBounds(t *testing.T) {
+	if _, err := Parse(testImage(func([]byte, int, int) {})); err != nil {
+		t.Fatalf("Parse() = %v for a valid image", err)
+	}
+	for _, tt := range []struct {
+		name string
+		fn   func(b []byte, opt, section int)
+	}{
+		{"PE header", func(b []byte, _, _ int) {
+			binary.LittleEndian.PutUint32(b[0x3c:], 0x7ffffff0)
+		}},
+		{"SizeOfHeaders", func(b []byte, opt, _ int) {
+			binary.LittleEndian.PutUint32(b[opt+60:], 0xffffffff)
+		}},
+		{"certificate table", func(b []byte, opt, _ int) {
+			binary.LittleEndian.PutUint32(b[opt+112+4*8:], 0x7ffffff8)
+			binary.LittleEndian.PutUint32(b[opt+112+4*8+4:], 0x10)
+		}},
+		{"section", func(b []byte, _, section int) {
+			binary.LittleEndian.PutUint32(b[section+20:], 0x7fffff00)
+			binary.LittleEndian.PutUint32(b[section+16:], 0x200)
+		}},
+	} {
+		if _, err := Parse(testImage(tt.fn)); !errors.Is(err, ErrMalformedImage) {
+			t.Errorf("%s: Parse() = %v, want ErrMalformedImage", tt.name, err)
+		}
+	}
+}
+
+func TestHash(t *testing.T) {
+	parse := func(fn func(b []byte, opt, section int)) *Image {
+		img, err := Parse(testImage(fn))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return img
+	}
+	want := parse(func([]byte, int, int) {}).Hash(crypto.SHA256)
+
+	// Signing sets the checksum and the certificate table, which the
+	// hash leaves out, and appends the signatures.
+	signed := testImage(func(b []byte, opt, _ int) {
+		binary.LittleEndian.PutUint32(b[opt+64:], 0xdeadbeef)
+		binary.LittleEndian.PutUint32(b[opt+112+4*8:], 0x200)
+		binary.LittleEndian.PutUint32(b[opt+112+4*8+4:], 16)
+	})
+	cert := make([]byte, 16)
+	binary.LittleEndian.PutUint32(cert[0:], 16)
+	binary.LittleEndian.PutUint16(cert[4:], 0x0200)
+	binary.LittleEndian.PutUint16(cert[6:], winCertTypePKCSSignedData)
+	copy(cert[8:], "PKCS#7\x00\x00")
+	img, err := Parse(append(signed, cert...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := img.Hash(crypto.SHA256); !bytes.Equal(got, want) {
+		t.Errorf("Hash() = %x after signing, want %x", got, want)
+	}
+	if sigs, err := img.RawSignatures(); err != nil || len(sigs) != 1 || string(sigs[0]) != "PKCS#7\x00\x00" {
+		t.Errorf("RawSignatures() = %q, %v", sigs, err)
+	}
+
+	modified := parse(func(b []byte, _, _ int) { b[0x1ff] ^= 1 })
+	if got := modified.Hash(crypto.SHA256); bytes.Equal(got, want) {
+		t.Error("Hash() didn't change with the content of the section")
+	}
+}
@@ -0,0 +1,121 @@
+package authenticode
+
+import (
+	"bytes"
+	"crypto"
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+// ErrDigestMismatch is returned if a signature doesn't cover the hash of
+// the image it is embedded in, e.g. as the image was modified after
+// signing.
+var ErrDigestMismatch = errors.New("signature does not cover the image hash")
+
+// spcIndirectDataContent is the Authenticode signed content
+type spcIndirectDataContent struct {
+	Data          asn1.RawValue
+	MessageDigest struct {
+		DigestAlgorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.RawValue `asn1:"optional"`
+		}
+		Digest []byte
+	}
+}
+
+// digestAlgorithms maps the OIDs of digest algorithms to hashes.
+var digestAlgorithms = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// Signature is an Authenticode signature of an image. The certificates
+// embedded in it are deliberately not exposed: anyone can add any
+// certificate to a signature, so only the signers returned by Verify and
+// the paths returned by Chain may be used to decide about trust.
+type Signature struct {
+	// Raw is the DER encoded PKCS #7 SignedData
+	Raw []byte
+	// Hash is the algorithm of Digest
+	Hash crypto.Hash
+	// Digest is the Authenticode hash of the signed image
+	Digest []byte
+
+	sd *pkcs7.SignedData
+}
+
+// ParseSignature decodes a DER encoded Authenticode signature.
+func ParseSignature(der []byte) (*Signature, error) {
+	p, err := pkcs7.Parse(der)
+	if err != nil {
+		return nil, err
+	}
+	var content spcIndirectDataContent
+	// The embedded content octets lack the outer SEQUENCE header
+	full, err := asn1.Marshal(asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true, Bytes: p.Content})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := asn1.Unmarshal(full, &content); err != nil {
+		return nil, fmt.Errorf("%w: %v", pkcs7.ErrMalformed, err)
+	}
+	oid := content.MessageDigest.DigestAlgorithm.Algorithm
+	h, ok := digestAlgorithms[oid.String()]
+	if !ok {
+		return nil, fmt.Errorf("unsupported digest algorithm %v", oid)
+	}
+	return &Signature{
+		Raw:    der,
+		Hash:   h,
+		Digest: content.MessageDigest.Digest,
+		sd:     p,
+	}, nil
+}
+
+// Signatures returns the Authenticode signatures embedded in the image.
+func (img *Image) Signatures() ([]*Signature, error) {
+	raw, err := img.RawSignatures()
+	if err != nil {
+		return nil, err
+	}
+	sigs := make([]*Signature, len(raw))
+	for i, r := range raw {
+		if sigs[i], err = ParseSignature(r); err != nil {
+			return nil, err
+		}
+	}
+	return sigs, nil
+}
+
+// Verify checks that s is a valid signature of img and returns the
+// signer certificates. Whether they are trusted is up to the caller.
+func (s *Signature) Verify(img *Image) ([]*x509.Certificate, error) {
+	if !s.Hash.Available() || !bytes.Equal(s.Digest, img.Hash(s.Hash)) {
+		return nil, ErrDigestMismatch
+	}
+	return s.sd.Verify(nil)
+}
+
+// Chain returns the certification path from signer, as returned by
+// Verify, to one of roots, e.g. the certificates of db, or nil if there
+// is none. Only the certificates embedded in s serve as intermediates;
+// certificates of the signature that aren't on the path play no role.
+// Chain returns nil for certificates that aren't signers of s.
+func (s *Signature) Chain(signer *x509.Certificate, roots []*x509.Certificate) []*x509.Certificate {
+	for _, c := range s.sd.Signers() {
+		if c.Equal(signer) {
+			return s.sd.Chain(signer, roots)
+		}
+	}
+	return nil
+}
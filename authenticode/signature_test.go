@@ -0,0 +1,123 @@
+package authenticode
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *rsa.PrivateKey
+}
+
+// issue returns a certificate for cn issued by parent, or a self-signed
+// one if parent is nil.
+func issue(t *testing.T, cn string, parent *testCert, ca bool) *testCert {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	issuer, issuerKey := tmpl, key
+	if parent != nil {
+		issuer, issuerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCert{cert, key}
+}
+
+// sign returns the DER encoded Authenticode signature of img by s, which
+// embeds the additional certificates bag.
+func sign(t *testing.T, img *Image, s *testCert, bag ...*x509.Certificate) []byte {
+	t.Helper()
+	var content spcIndirectDataContent
+	content.Data = asn1.RawValue{Tag: asn1.TagSequence, IsCompound: true}
+	content.MessageDigest.DigestAlgorithm.Algorithm = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	content.MessageDigest.DigestAlgorithm.Parameters = asn1.NullRawValue
+	content.MessageDigest.Digest = img.Hash(crypto.SHA256)
+	der, err := asn1.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := pkcs7.Sign(seq.Bytes, s.cert, s.key, pkcs7.SignOptions{ContentInfo: true, Attached: true, Certificates: bag})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sig
+}
+
+func TestSignature(t *testing.T) {
+	img, err := Parse(testImage(func([]byte, int, int) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := issue(t, "CA", nil, true)
+	intermediate := issue(t, "Intermediate", ca, true)
+	leaf := issue(t, "Leaf", intermediate, false)
+	other := issue(t, "Other", ca, false)
+	roots := []*x509.Certificate{ca.cert}
+
+	s, err := ParseSignature(sign(t, img, leaf, intermediate.cert, other.cert, ca.cert))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signers, err := s.Verify(img)
+	if err != nil || len(signers) != 1 || !signers[0].Equal(leaf.cert) {
+		t.Fatalf("Verify() = %v, %v, want the leaf only", signers, err)
+	}
+	if path := s.Chain(leaf.cert, roots); len(path) != 3 || !path[1].Equal(intermediate.cert) || !path[2].Equal(ca.cert) {
+		t.Errorf("Chain() = %v, want leaf, intermediate, CA", path)
+	}
+	// Embedded certificates that didn't sign are never trusted, even if
+	// they chain up to or are one of the roots.
+	for _, c := range []*x509.Certificate{other.cert, ca.cert, intermediate.cert} {
+		if path := s.Chain(c, roots); path != nil {
+			t.Errorf("Chain(%q) = %v for a certificate that didn't sign", c.Subject, path)
+		}
+	}
+
+	s, err = ParseSignature(sign(t, img, leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := s.Chain(leaf.cert, roots); path != nil {
+		t.Errorf("Chain() = %v without the intermediate", path)
+	}
+
+	modified, err := Parse(testImage(func(b []byte, _, _ int) { b[0x1ff] ^= 1 }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Verify(modified); !errors.Is(err, ErrDigestMismatch) {
+		t.Errorf("Verify() = %v for a modified image, want ErrDigestMismatch", err)
+	}
+}
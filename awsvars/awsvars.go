@@ -0,0 +1,280 @@
+// Package awsvars encodes and decodes the UEFI variable store blob used
+// by Amazon EC2, e.g. the UefiData of register-image and
+// get-instance-uefi-data.
+//
+// The blob is the base64 encoding of
+//
+//	magic   uint64  "AMZNUEFI"
+//	crc32c  uint32  CRC32C of the compressed payload
+//	version uint32  0
+//	payload         zlib compressed variable list
+//
+// and the decompressed payload is a count followed by the variables:
+//
+//	count       uint64
+//	name        uint64 length + UTF-8 bytes
+//	data        uint64 length + bytes
+//	guid        [16]byte EFI_GUID
+//	attributes  uint32
+//	timestamp   [16]byte EFI_TIME    only for time based authenticated
+//	digest      [32]byte             variables
+//
+// with all integers in little endian.
+package awsvars
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/secureboot"
+)
+
+const (
+	magic   = 0x494645554e5a4d41 // "AMZNUEFI"
+	version = 0
+
+	// maxEntries and maxFieldSize protect against absurd allocations
+	// caused by corrupted blobs
+	maxEntries   = 1 << 16
+	maxFieldSize = 1 << 20
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrInvalidBlob is returned for data that isn't an EC2 UEFI variable store
+	ErrInvalidBlob = errors.New("invalid EC2 UEFI variable store")
+
+	// ErrChecksum is returned if the CRC32C of the blob doesn't match
+	ErrChecksum = errors.New("EC2 UEFI variable store checksum mismatch")
+)
+
+// Variable is an entry of the variable store.
+type Variable struct {
+	efivarfs.VariableDescriptor
+	Attributes efivarfs.VariableAttributes
+	Data       []byte
+	// Timestamp and Digest are only stored for time based
+	// authenticated variables
+	Timestamp secureboot.EFITime
+	Digest    [32]byte
+}
+
+// Store is a decoded EC2 variable store. It implements efivarfs.Backend,
+// so the rest of this module can be used to inspect or build it. Data is
+// stored as is, authenticated variables have to be written without
+// their EFI_VARIABLE_AUTHENTICATION_2 descriptor.
+type Store struct {
+	Variables []Variable
+}
+
+// Decode parses a base64 encoded variable store blob.
+func Decode(blob string) (*Store, error) {
+	raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(blob))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBlob, err)
+	}
+	if len(raw) < 16 || binary.LittleEndian.Uint64(raw[0:8]) != magic {
+		return nil, fmt.Errorf("%w: bad magic", ErrInvalidBlob)
+	}
+	if v := binary.LittleEndian.Uint32(raw[12:16]); v != version {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrInvalidBlob, v)
+	}
+	if crc32.Checksum(raw[16:], castagnoli) != binary.LittleEndian.Uint32(raw[8:12]) {
+		return nil, ErrChecksum
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw[16:]))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBlob, err)
+	}
+	defer zr.Close()
+	r := bufio.NewReader(zr)
+
+	var count uint64
+	if err := binary.Read(r, binary.LittleEndian, &count); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBlob, err)
+	}
+	if count > maxEntries {
+		return nil, fmt.Errorf("%w: %d variables", ErrInvalidBlob, count)
+	}
+	s := &Store{}
+	for i := uint64(0); i < count; i++ {
+		v, err := readVariable(r)
+		if err != nil {
+			return nil, fmt.Errorf("%w: variable %d: %v", ErrInvalidBlob, i, err)
+		}
+		s.Variables = append(s.Variables, v)
+	}
+	return s, nil
+}
+
+func readField(r io.Reader) ([]byte, error) {
+	var n uint64
+	if err := binary.Read(r, binary.LittleEndian, &n); err != nil {
+		return nil, err
+	}
+	if n > maxFieldSize {
+		return nil, fmt.Errorf("field of %d bytes too large", n)
+	}
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	return b, err
+}
+
+func readVariable(r io.Reader) (Variable, error) {
+	var v Variable
+	name, err := readField(r)
+	if err != nil {
+		return v, err
+	}
+	if v.Data, err = readField(r); err != nil {
+		return v, err
+	}
+	var g [16]byte
+	if _, err := io.ReadFull(r, g[:]); err != nil {
+		return v, err
+	}
+	guid := efivarfs.DecodeGUID(g[:])
+	v.VariableDescriptor = efivarfs.VariableDescriptor{Name: string(name), GUID: &guid}
+	if err := binary.Read(r, binary.LittleEndian, &v.Attributes); err != nil {
+		return v, err
+	}
+	if v.Attributes&efivarfs.AttributeTimeBasedAuthenticatedWriteAccess != 0 {
+		var ts [secureboot.EFITimeSize]byte
+		if _, err := io.ReadFull(r, ts[:]); err != nil {
+			return v, err
+		}
+		// Variables that were never written with a timestamp have an
+		// all zero one, which isn't a valid EFI_TIME
+		if ts != [secureboot.EFITimeSize]byte{} {
+			if err := v.Timestamp.UnmarshalBinary(ts[:]); err != nil {
+				return v, err
+			}
+		}
+		if _, err := io.ReadFull(r, v.Digest[:]); err != nil {
+			return v, err
+		}
+	}
+	return v, nil
+}
+
+// Encode returns the base64 encoded blob of s.
+func (s *Store) Encode() (string, error) {
+	var payload bytes.Buffer
+	zw, err := zlib.NewWriterLevel(&payload, zlib.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	w := bufio.NewWriter(zw)
+	binary.Write(w, binary.LittleEndian, uint64(len(s.Variables)))
+	for _, v := range s.Variables {
+		binary.Write(w, binary.LittleEndian, uint64(len(v.Name)))
+		w.WriteString(v.Name)
+		binary.Write(w, binary.LittleEndian, uint64(len(v.Data)))
+		w.Write(v.Data)
+		g := efivarfs.EncodeGUID(*v.GUID)
+		w.Write(g[:])
+		binary.Write(w, binary.LittleEndian, v.Attributes)
+		if v.Attributes&efivarfs.AttributeTimeBasedAuthenticatedWriteAccess != 0 {
+			ts, err := v.Timestamp.MarshalBinary()
+			if err != nil {
+				return "", err
+			}
+			w.Write(ts)
+			w.Write(v.Digest[:])
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return "", err
+	}
+	if err := zw.Close(); err != nil {
+		return "", err
+	}
+
+	hdr := make([]byte, 16)
+	binary.LittleEndian.PutUint64(hdr[0:8], magic)
+	binary.LittleEndian.PutUint32(hdr[8:12], crc32.Checksum(payload.Bytes(), castagnoli))
+	binary.LittleEndian.PutUint32(hdr[12:16], version)
+	return base64.StdEncoding.EncodeToString(append(hdr, payload.Bytes()...)), nil
+}
+
+// find returns the index of desc in s.Variables or -1.
+func (s *Store) find(desc efivarfs.VariableDescriptor) int {
+	for i, v := range s.Variables {
+		if v.VariableDescriptor.Equal(desc) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Get returns the attributes and data of a variable in the store.
+func (s *Store) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	i := s.find(desc)
+	if i < 0 {
+		return 0, nil, efivarfs.ErrVarNotExist
+	}
+	return s.Variables[i].Attributes, s.Variables[i].Data, nil
+}
+
+// Set adds or replaces a variable in the store, following the firmware
+// semantics for appends and empty data. New time based authenticated
+// variables are timestamped with the current time.
+func (s *Store) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	i := s.find(desc)
+	if attrs&efivarfs.AttributeAppendWrite != 0 {
+		if i >= 0 {
+			s.Variables[i].Data = append(s.Variables[i].Data, data...)
+			return nil
+		}
+		attrs &^= efivarfs.AttributeAppendWrite
+	} else if len(data) == 0 {
+		return s.Remove(desc)
+	}
+	g := *desc.GUID
+	v := Variable{
+		VariableDescriptor: efivarfs.VariableDescriptor{Name: desc.Name, GUID: &g},
+		Attributes:         attrs,
+		Data:               append([]byte(nil), data...),
+	}
+	if attrs&efivarfs.AttributeTimeBasedAuthenticatedWriteAccess != 0 {
+		v.Timestamp = secureboot.NewEFITime(time.Now())
+	}
+	if i >= 0 {
+		s.Variables[i] = v
+	} else {
+		s.Variables = append(s.Variables, v)
+	}
+	return nil
+}
+
+// Remove deletes a variable from the store.
+func (s *Store) Remove(desc efivarfs.VariableDescriptor) error {
+	i := s.find(desc)
+	if i < 0 {
+		return efivarfs.ErrVarNotExist
+	}
+	s.Variables = append(s.Variables[:i], s.Variables[i+1:]...)
+	return nil
+}
+
+// List returns the descriptors of all variables in the store.
+func (s *Store) List() ([]efivarfs.VariableDescriptor, error) {
+	var list []efivarfs.VariableDescriptor
+	for _, v := range s.Variables {
+		list = append(list, v.VariableDescriptor)
+	}
+	slices.SortFunc(list, efivarfs.Compare)
+	return list, nil
+}
@@ -0,0 +1,124 @@
+package awsvars
+
+import (
+	"bytes"
+	"compress/zlib"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash/crc32"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/secureboot"
+)
+
+// payload is the decompressed payload of a store holding Timeout and an
+// authenticated db.
+var payload = "0200000000000000" +
+	// Timeout: name, data, GUID, attributes
+	"0700000000000000" + hex.EncodeToString([]byte("Timeout")) +
+	"0200000000000000" + "0500" +
+	"61dfe48bca93d211aa0d00e098032b8c" + "07000000" +
+	// db: name, data, GUID, attributes, timestamp, digest
+	"0200000000000000" + hex.EncodeToString([]byte("db")) +
+	"0100000000000000" + "01" +
+	"cbb219d73a3d9645a3bcdad00e67656f" + "27000000" +
+	"e8070102030405000000000000000000" + strings.Repeat("11", 32)
+
+// blob compresses payload into a base64 encoded store.
+func blob(t *testing.T, payload []byte) string {
+	t.Helper()
+	var z bytes.Buffer
+	zw := zlib.NewWriter(&z)
+	zw.Write(payload)
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	hdr := make([]byte, 16)
+	copy(hdr, "AMZNUEFI")
+	binary.LittleEndian.PutUint32(hdr[8:], crc32.Checksum(z.Bytes(), castagnoli))
+	return base64.StdEncoding.EncodeToString(append(hdr, z.Bytes()...))
+}
+
+// unblob returns the decompressed payload of a base64 encoded store.
+func unblob(t *testing.T, blob string) []byte {
+	t.Helper()
+	raw, err := base64.StdEncoding.DecodeString(blob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(raw[:8]) != "AMZNUEFI" || binary.LittleEndian.Uint32(raw[8:]) != crc32.Checksum(raw[16:], castagnoli) {
+		t.Fatalf("blob header %x", raw[:16])
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(raw[16:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestRoundTrip(t *testing.T) {
+	want, err := hex.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Decode(blob(t, want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Variables) != 2 {
+		t.Fatalf("Decode() = %d variables, want 2", len(s.Variables))
+	}
+	timeout, db := s.Variables[0], s.Variables[1]
+	if timeout.Name != "Timeout" || *timeout.GUID != efivarfs.GlobalVariable || timeout.Attributes != 7 || !bytes.Equal(timeout.Data, []byte{5, 0}) {
+		t.Errorf("Timeout = %+v", timeout)
+	}
+	ts := secureboot.EFITime{Year: 2024, Month: 1, Day: 2, Hour: 3, Minute: 4, Second: 5}
+	if db.Name != "db" || *db.GUID != efivarfs.ImageSecurityDatabase || db.Attributes != 0x27 || db.Timestamp != ts || db.Digest[31] != 0x11 {
+		t.Errorf("db = %+v", db)
+	}
+
+	encoded, err := s.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := unblob(t, encoded); !bytes.Equal(got, want) {
+		t.Errorf("Encode() = payload %x, want %x", got, want)
+	}
+}
+
+func TestDecodeInvalid(t *testing.T) {
+	valid, err := hex.DecodeString(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := base64.StdEncoding.DecodeString(blob(t, valid))
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw[len(raw)-1] ^= 1
+	if _, err := Decode(base64.StdEncoding.EncodeToString(raw)); !errors.Is(err, ErrChecksum) {
+		t.Errorf("Decode() = %v with a corrupted payload, want ErrChecksum", err)
+	}
+	for _, tt := range []struct {
+		name string
+		blob string
+	}{
+		{"not base64", "!"},
+		{"bad magic", base64.StdEncoding.EncodeToString(make([]byte, 16))},
+		{"truncated", blob(t, valid[:len(valid)-1])},
+		{"too many variables", blob(t, []byte{0, 0, 0, 0, 1, 0, 0, 0})},
+	} {
+		if _, err := Decode(tt.blob); !errors.Is(err, ErrInvalidBlob) {
+			t.Errorf("%s: Decode() = %v, want ErrInvalidBlob", tt.name, err)
+		}
+	}
+}
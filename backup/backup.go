@@ -0,0 +1,506 @@
+// Package backup saves and restores complete sets of EFI variables.
+//
+// Archives are tar files, optionally gzip compressed, with one member per
+// variable named Name-GUID.var and holding the same content as the file
+// in efivarfs: the 4 byte little endian attributes followed by the data.
+// The first member, manifest.json, lists all variables with the SHA-256
+// hash of their member, which is verified before anything is restored
+// or compared. It also records when and on which machine the archive
+// was created. As the manifest covers every member by its hash, a
+// signature over it vouches for the whole archive: archives written
+// WithSigner carry one in manifest.json.sig, which Read checks
+// WithVerifier.
+//
+// Due to the .var suffix and the manifest, extracting an archive with
+// tar doesn't yield a directory usable with efivarfs.Dir. Extract
+// verifies an archive and writes its variables to such a directory.
+package backup
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/sysinfo"
+)
+
+// ManifestName is the name of the manifest member of an archive.
+const ManifestName = "manifest.json"
+
+var (
+	// ErrInvalidArchive is caused by importing an archive that lacks a
+	// manifest or whose members don't match it
+	ErrInvalidArchive = errors.New("invalid archive")
+	// ErrChecksumMismatch is caused by importing an archive with a
+	// member whose hash differs from the one in the manifest
+	ErrChecksumMismatch = errors.New("checksum mismatch")
+)
+
+// Manifest describes the content of an archive.
+type Manifest struct {
+	Created time.Time `json:"created"`
+	// Machine identifies the system the archive was created on, nil
+	// if it couldn't be determined
+	Machine   *sysinfo.Info   `json:"machine,omitempty"`
+	Variables []ManifestEntry `json:"variables"`
+}
+
+// ManifestEntry describes a single variable of an archive.
+type ManifestEntry struct {
+	Name       string                      `json:"name"`
+	GUID       string                      `json:"guid"`
+	Attributes efivarfs.VariableAttributes `json:"attributes"`
+	// Size is the size of the data without the attributes
+	Size int `json:"size"`
+	// File is the name of the member holding the variable
+	File string `json:"file"`
+	// SHA256 is the hex encoded hash of the member
+	SHA256 string `json:"sha256"`
+}
+
+// Variable is a variable read from an archive.
+type Variable struct {
+	Desc       efivarfs.VariableDescriptor
+	Attributes efivarfs.VariableAttributes
+	Data       []byte
+}
+
+// variable is a variable read for the archive.
+type variable struct {
+	desc  efivarfs.VariableDescriptor
+	attrs efivarfs.VariableAttributes
+	data  []byte
+	// missing is set for variables removed after listing them
+	missing bool
+	// err is why the variable couldn't be read with ContinueOnError
+	err error
+}
+
+// member returns the name and content of the archive member of v.
+func (v *variable) member() (string, []byte) {
+	b := binary.LittleEndian.AppendUint32(nil, uint32(v.attrs))
+	return fmt.Sprintf("%s.var", v.desc), append(b, v.data...)
+}
+
+// ExportAll writes all variables of b as tar archive to w, see Export.
+func ExportAll(ctx context.Context, b efivarfs.ReadBackend, w io.Writer, concurrency int, opts ...Option) error {
+	descs, err := b.List()
+	if err != nil {
+		return err
+	}
+	return Export(ctx, b, descs, w, concurrency, opts...)
+}
+
+// Export writes the variables descs of b as tar archive to w. The
+// variables are read by up to concurrency goroutines at once, which
+// speeds up dumping stores with hundreds of variables considerably since
+// every read goes to the firmware. The members are written in the order
+// of descs regardless of concurrency. Variables removed while exporting
+// are left out. The manifest records the DMI identification of the
+// running system. With WithSigner the manifest is signed. With
+// ContinueOnError variables that can't be read are left out as well and
+// returned as *efivarfs.BulkError once the archive is complete.
+func Export(ctx context.Context, b efivarfs.ReadBackend, descs []efivarfs.VariableDescriptor, w io.Writer, concurrency int, opts ...Option) error {
+	o := newOptions(opts)
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	vars, err := readAll(ctx, b, descs, concurrency, o)
+	if err != nil {
+		return err
+	}
+	var bulk efivarfs.BulkError
+	for _, v := range vars {
+		if v.err != nil {
+			bulk.Add("read", v.desc, v.err)
+		}
+	}
+
+	now := time.Now()
+	m := Manifest{Created: now.UTC(), Variables: []ManifestEntry{}}
+	if info, err := sysinfo.Read(); err == nil {
+		m.Machine = &info
+	}
+	for _, v := range vars {
+		if v.missing {
+			continue
+		}
+		name, content := v.member()
+		sum := sha256.Sum256(content)
+		m.Variables = append(m.Variables, ManifestEntry{
+			Name:       v.desc.Name,
+			GUID:       v.desc.GUID.String(),
+			Attributes: v.attrs,
+			Size:       len(v.data),
+			File:       name,
+			SHA256:     hex.EncodeToString(sum[:]),
+		})
+	}
+	manifest, err := json.MarshalIndent(&m, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+	if err := writeMember(tw, ManifestName, manifest, now); err != nil {
+		return err
+	}
+	if o.signer != nil {
+		sig, err := o.signer.sign(manifest)
+		if err != nil {
+			return err
+		}
+		if err := writeMember(tw, SignatureName, sig, now); err != nil {
+			return err
+		}
+	}
+	for _, v := range vars {
+		if v.missing {
+			continue
+		}
+		name, content := v.member()
+		if err := writeMember(tw, name, content, now); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return bulk.Err()
+}
+
+func writeMember(tw *tar.Writer, name string, content []byte, modTime time.Time) error {
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     name,
+		Mode:     0644,
+		Size:     int64(len(content)),
+		ModTime:  modTime,
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// Option configures Export, Read and Restore.
+type Option func(*options)
+
+type options struct {
+	signer    *Signer
+	verifier  *Verifier
+	keepGoing bool
+	progress  func(efivarfs.Progress)
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithSigner makes Export sign the manifest with s.
+func WithSigner(s *Signer) Option {
+	return func(o *options) {
+		o.signer = s
+	}
+}
+
+// ContinueOnError makes Export and Restore carry on past variables that
+// fail, e.g. because reading them requires privileges, and report all of
+// them as *efivarfs.BulkError at the end instead of stopping at the
+// first.
+func ContinueOnError() Option {
+	return func(o *options) {
+		o.keepGoing = true
+	}
+}
+
+// WithProgress makes Export call report after reading and Restore after
+// writing every variable, so long operations can show a progress bar.
+// report is called from one goroutine at a time.
+func WithProgress(report func(efivarfs.Progress)) Option {
+	return func(o *options) {
+		o.progress = report
+	}
+}
+
+// WithVerifier makes Read refuse archives that aren't signed by a key
+// trusted by v.
+func WithVerifier(v *Verifier) Option {
+	return func(o *options) {
+		o.verifier = v
+	}
+}
+
+// Archive is the verified content of an archive.
+type Archive struct {
+	Manifest Manifest
+	// Variables are in the order of the manifest
+	Variables []Variable
+}
+
+// ReadArchive reads an archive written by Export, which may be gzip
+// compressed, and verifies it against its manifest. The variables are
+// returned in the order of the manifest. With WithVerifier the archive
+// has to be signed by a trusted key.
+func ReadArchive(r io.Reader, opts ...Option) ([]Variable, error) {
+	a, err := Read(r, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return a.Variables, nil
+}
+
+// Read is ReadArchive also returning the manifest.
+func Read(r io.Reader, opts ...Option) (*Archive, error) {
+	o := newOptions(opts)
+	br := bufio.NewReader(r)
+	if magic, err := br.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		zr, err := gzip.NewReader(br)
+		if err != nil {
+			return nil, err
+		}
+		defer zr.Close()
+		r = zr
+	} else {
+		r = br
+	}
+
+	members := make(map[string][]byte)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		members[hdr.Name] = content
+	}
+
+	manifest, ok := members[ManifestName]
+	if !ok {
+		return nil, fmt.Errorf("no %s: %w", ManifestName, ErrInvalidArchive)
+	}
+	if o.verifier != nil {
+		if err := o.verifier.verify(manifest, members[SignatureName]); err != nil {
+			return nil, err
+		}
+	}
+	var m Manifest
+	if err := json.Unmarshal(manifest, &m); err != nil {
+		return nil, fmt.Errorf("%s: %v: %w", ManifestName, err, ErrInvalidArchive)
+	}
+	vars := make([]Variable, 0, len(m.Variables))
+	for _, e := range m.Variables {
+		v, err := e.verify(members[e.File])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.File, err)
+		}
+		vars = append(vars, v)
+	}
+	return &Archive{Manifest: m, Variables: vars}, nil
+}
+
+// verify checks content, the member of e, against e and returns the
+// variable it holds.
+func (e *ManifestEntry) verify(content []byte) (Variable, error) {
+	if content == nil {
+		return Variable{}, fmt.Errorf("missing member: %w", ErrInvalidArchive)
+	}
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != e.SHA256 {
+		return Variable{}, ErrChecksumMismatch
+	}
+	g, err := efivarfs.ParseGUID(e.GUID)
+	if err != nil {
+		return Variable{}, fmt.Errorf("%v: %w", err, ErrInvalidArchive)
+	}
+	if len(content) < 4 || len(content)-4 != e.Size {
+		return Variable{}, fmt.Errorf("size differs from manifest: %w", ErrInvalidArchive)
+	}
+	attrs := efivarfs.VariableAttributes(binary.LittleEndian.Uint32(content))
+	if attrs != e.Attributes {
+		return Variable{}, fmt.Errorf("attributes differ from manifest: %w", ErrInvalidArchive)
+	}
+	return Variable{
+		Desc:       efivarfs.VariableDescriptor{Name: e.Name, GUID: &g},
+		Attributes: attrs,
+		Data:       content[4:],
+	}, nil
+}
+
+// Extract verifies the archive read from r like Import and writes its
+// variables to dir as files named Name-GUID, the layout of efivarfs, so
+// that dir can be opened with efivarfs.Dir. dir is created if needed.
+func Extract(r io.Reader, dir string, opts ...Option) error {
+	vars, err := ReadArchive(r, opts...)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return Restore(efivarfs.Dir(dir), vars, opts...)
+}
+
+// Import restores all variables of the archive read from r to b. The
+// whole archive is verified before the first variable is written.
+func Import(b efivarfs.Backend, r io.Reader, opts ...Option) error {
+	vars, err := ReadArchive(r, opts...)
+	if err != nil {
+		return err
+	}
+	return Restore(b, vars, opts...)
+}
+
+// Restore writes vars to b. Variables already holding the same content
+// are skipped, which avoids wearing the flash and failing on variables
+// that can't be written directly, like authenticated or volatile ones.
+// With ContinueOnError the remaining variables are still written if one
+// fails, and all that failed are returned as *efivarfs.BulkError.
+func Restore(b efivarfs.Backend, vars []Variable, opts ...Option) error {
+	o := newOptions(opts)
+	var bulk efivarfs.BulkError
+	for i, v := range vars {
+		if err := restore(b, v); err != nil {
+			if !o.keepGoing {
+				return fmt.Errorf("restoring %s: %w", v.Desc, err)
+			}
+			bulk.Add("restore", v.Desc, err)
+		}
+		if o.progress != nil {
+			o.progress(efivarfs.Progress{Done: i + 1, Total: len(vars), Desc: v.Desc, Bytes: len(v.Data)})
+		}
+	}
+	return bulk.Err()
+}
+
+// restore writes v to b unless it already holds the same content.
+func restore(b efivarfs.Backend, v Variable) error {
+	attrs, data, err := b.Get(v.Desc)
+	if err == nil && attrs == v.Attributes && bytes.Equal(data, v.Data) {
+		return nil
+	}
+	return b.Set(v.Desc, v.Attributes, v.Data)
+}
+
+// Difference is a variable of an archive that differs from the one in
+// a backend.
+type Difference struct {
+	Variable
+	// Missing is set if the backend lacks the variable
+	Missing bool
+	// Attributes and Data are the content in the backend otherwise
+	Attributes efivarfs.VariableAttributes
+	Data       []byte
+}
+
+// Diff compares vars, as read from an archive, with the variables of b
+// and returns the ones that are missing from b or differ in attributes
+// or data. Restoring vars would write exactly these.
+func Diff(b efivarfs.ReadBackend, vars []Variable) ([]Difference, error) {
+	var diffs []Difference
+	for _, v := range vars {
+		attrs, data, err := b.Get(v.Desc)
+		switch {
+		case errors.Is(err, efivarfs.ErrVarNotExist):
+			diffs = append(diffs, Difference{Variable: v, Missing: true})
+		case err != nil:
+			return nil, fmt.Errorf("reading %s: %w", v.Desc, err)
+		case attrs != v.Attributes || !bytes.Equal(data, v.Data):
+			diffs = append(diffs, Difference{Variable: v, Attributes: attrs, Data: data})
+		}
+	}
+	return diffs, nil
+}
+
+// readAll reads descs using a pool of concurrency workers and returns
+// them in the same order. With ContinueOnError, errors are recorded in
+// the variables instead of ending the read.
+func readAll(ctx context.Context, b efivarfs.ReadBackend, descs []efivarfs.VariableDescriptor, concurrency int, o *options) ([]variable, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	vars := make([]variable, len(descs))
+	var mu sync.Mutex
+	done := 0
+	jobs := make(chan int)
+	errs := make(chan error, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				v := &vars[i]
+				v.desc = descs[i]
+				attrs, data, err := b.Get(v.desc)
+				switch {
+				case errors.Is(err, efivarfs.ErrVarNotExist):
+					v.missing = true
+				case err != nil && o.keepGoing:
+					v.missing, v.err = true, err
+				case err != nil:
+					errs <- fmt.Errorf("reading %s: %w", v.desc, err)
+					cancel()
+					return
+				default:
+					v.attrs, v.data = attrs, data
+				}
+				if o.progress != nil {
+					mu.Lock()
+					done++
+					o.progress(efivarfs.Progress{Done: done, Total: len(descs), Desc: v.desc, Bytes: len(v.data)})
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+feed:
+	for i := range descs {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
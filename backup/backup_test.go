@@ -0,0 +1,111 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// denying fails to access the variables named in denied like efivarfs
+// does for unprivileged users.
+type denying struct {
+	efivarfs.Backend
+	denied map[string]bool
+}
+
+func (d denying) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	if d.denied[desc.Name] {
+		return 0, nil, efivarfs.ErrVarPermission
+	}
+	return d.Backend.Get(desc)
+}
+
+func (d denying) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	if d.denied[desc.Name] {
+		return efivarfs.ErrVarPermission
+	}
+	return d.Backend.Set(desc, attrs, data)
+}
+
+func TestContinueOnError(t *testing.T) {
+	src := denying{efivarfs.Dir("../testdata/corpus/ovmf"), map[string]bool{"BootOrder": true, "Timeout": true}}
+	descs, err := src.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Export(context.Background(), src, descs, &buf, 2); !errors.Is(err, efivarfs.ErrVarPermission) {
+		t.Fatalf("Export() = %v, want ErrVarPermission", err)
+	}
+	err = Export(context.Background(), src, descs, &buf, 2, ContinueOnError())
+	var bulk *efivarfs.BulkError
+	if !errors.As(err, &bulk) || len(bulk.Errors) != 2 || !errors.Is(err, efivarfs.ErrVarPermission) {
+		t.Fatalf("Export(ContinueOnError()) = %v, want BootOrder and Timeout denied", err)
+	}
+	vars, err := ReadArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != len(descs)-2 {
+		t.Errorf("archive holds %d variables, want %d", len(vars), len(descs)-2)
+	}
+
+	dst := denying{efivarfs.Dir(t.TempDir()), map[string]bool{"Lang": true}}
+	if err := Restore(dst, vars); !errors.Is(err, efivarfs.ErrVarPermission) {
+		t.Fatalf("Restore() = %v, want ErrVarPermission", err)
+	}
+	var reported []efivarfs.Progress
+	err = Restore(dst, vars, ContinueOnError(), WithProgress(func(p efivarfs.Progress) {
+		reported = append(reported, p)
+	}))
+	if len(reported) != len(vars) || reported[len(reported)-1].Done != len(vars) || reported[0].Total != len(vars) {
+		t.Errorf("Restore() reported progress %v for %d variables", reported, len(vars))
+	}
+	if !errors.As(err, &bulk) || len(bulk.Errors) != 1 || bulk.Errors[0].Desc.Name != "Lang" {
+		t.Fatalf("Restore(ContinueOnError()) = %v, want Lang denied", err)
+	}
+	restored, err := dst.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != len(vars)-1 {
+		t.Errorf("restored %d variables, want %d", len(restored), len(vars)-1)
+	}
+}
+
+func TestExtract(t *testing.T) {
+	src := efivarfs.Dir("../testdata/corpus/ovmf")
+	var buf bytes.Buffer
+	if err := ExportAll(context.Background(), src, &buf, 2); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(t.TempDir(), "vars")
+	if err := Extract(&buf, dir); err != nil {
+		t.Fatal(err)
+	}
+	want, err := src.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := efivarfs.Dir(dir).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("extracted %v, want %v", got, want)
+	}
+	for _, desc := range want {
+		wantAttrs, wantData, err := src.Get(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		attrs, data, err := efivarfs.Dir(dir).Get(desc)
+		if err != nil || attrs != wantAttrs || !bytes.Equal(data, wantData) {
+			t.Errorf("extracted %s = %v, %x, %v, want %v, %x", desc, attrs, data, err, wantAttrs, wantData)
+		}
+	}
+}
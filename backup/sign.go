@@ -0,0 +1,124 @@
+package backup
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// SignatureName is the name of the member holding the signature of the
+// manifest in signed archives.
+const SignatureName = ManifestName + ".sig"
+
+var (
+	// ErrUnsigned is caused by reading an archive without signature
+	// with a Verifier
+	ErrUnsigned = errors.New("archive is not signed")
+	// ErrBadSignature is caused by reading an archive whose signature
+	// doesn't verify or was made by an untrusted key
+	ErrBadSignature = errors.New("bad archive signature")
+)
+
+// Signer signs the manifest of archives written by Export. As the
+// manifest holds the hash of every member, this vouches for the whole
+// archive.
+type Signer struct {
+	// Key is an Ed25519, ECDSA or RSA private key. ECDSA and RSA sign
+	// the SHA-256 hash of the manifest, the latter with PKCS #1 v1.5.
+	Key crypto.Signer
+	// Certificate, if set, is stored with the signature, so that
+	// Verifiers can check it against their trusted roots instead of
+	// knowing every key
+	Certificate *x509.Certificate
+}
+
+// Verifier checks the signatures of archives read by Read.
+type Verifier struct {
+	// Keys are the trusted public keys
+	Keys []crypto.PublicKey
+	// Roots are the trusted certificate authorities for archives
+	// carrying a certificate
+	Roots *x509.CertPool
+}
+
+// signature is the content of the SignatureName member.
+type signature struct {
+	// Certificate is the DER encoded certificate of the key
+	Certificate []byte `json:"certificate,omitempty"`
+	Signature   []byte `json:"signature"`
+}
+
+// sign returns the content of the SignatureName member for manifest.
+func (s *Signer) sign(manifest []byte) ([]byte, error) {
+	var (
+		sig []byte
+		err error
+	)
+	if _, ok := s.Key.Public().(ed25519.PublicKey); ok {
+		sig, err = s.Key.Sign(rand.Reader, manifest, crypto.Hash(0))
+	} else {
+		sum := sha256.Sum256(manifest)
+		sig, err = s.Key.Sign(rand.Reader, sum[:], crypto.SHA256)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("signing manifest: %w", err)
+	}
+	m := signature{Signature: sig}
+	if s.Certificate != nil {
+		m.Certificate = s.Certificate.Raw
+	}
+	return json.MarshalIndent(&m, "", "  ")
+}
+
+// verify checks that member, the content of the SignatureName member,
+// is a valid signature of manifest by a trusted key.
+func (v *Verifier) verify(manifest, member []byte) error {
+	if member == nil {
+		return ErrUnsigned
+	}
+	var s signature
+	if err := json.Unmarshal(member, &s); err != nil {
+		return fmt.Errorf("%s: %v: %w", SignatureName, err, ErrBadSignature)
+	}
+	if s.Certificate != nil && v.Roots != nil {
+		cert, err := x509.ParseCertificate(s.Certificate)
+		if err != nil {
+			return fmt.Errorf("%v: %w", err, ErrBadSignature)
+		}
+		opts := x509.VerifyOptions{Roots: v.Roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}}
+		if _, err := cert.Verify(opts); err != nil {
+			return fmt.Errorf("%v: %w", err, ErrBadSignature)
+		}
+		if checkSignature(cert.PublicKey, manifest, s.Signature) {
+			return nil
+		}
+	}
+	for _, key := range v.Keys {
+		if checkSignature(key, manifest, s.Signature) {
+			return nil
+		}
+	}
+	return ErrBadSignature
+}
+
+// checkSignature reports whether sig is a signature of data by key as
+// made by Signer.
+func checkSignature(key crypto.PublicKey, data, sig []byte) bool {
+	sum := sha256.Sum256(data)
+	switch key := key.(type) {
+	case ed25519.PublicKey:
+		return ed25519.Verify(key, data, sig)
+	case *ecdsa.PublicKey:
+		return ecdsa.VerifyASN1(key, sum[:], sig)
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(key, crypto.SHA256, sum[:], sig) == nil
+	}
+	return false
+}
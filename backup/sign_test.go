@@ -0,0 +1,97 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// selfSigned returns an ECDSA key and a CA certificate for it.
+func selfSigned(t *testing.T) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "golden configs"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, cert
+}
+
+func TestSignedArchive(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, cert := selfSigned(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+
+	b := efivarfs.Dir("../testdata/corpus/ovmf")
+	export := func(opts ...Option) []byte {
+		var buf bytes.Buffer
+		if err := ExportAll(context.Background(), b, &buf, 1, opts...); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	unsigned := export()
+	signed := export(WithSigner(&Signer{Key: priv}))
+	certified := export(WithSigner(&Signer{Key: ecKey, Certificate: cert}))
+
+	for _, tt := range []struct {
+		name    string
+		archive []byte
+		v       *Verifier
+		want    error
+	}{
+		{"unsigned without verifier", unsigned, nil, nil},
+		{"signed without verifier", signed, nil, nil},
+		{"key", signed, &Verifier{Keys: []crypto.PublicKey{pub}}, nil},
+		{"other key", signed, &Verifier{Keys: []crypto.PublicKey{other}}, ErrBadSignature},
+		{"unsigned", unsigned, &Verifier{Keys: []crypto.PublicKey{pub}}, ErrUnsigned},
+		{"certificate", certified, &Verifier{Roots: roots}, nil},
+		{"untrusted certificate", certified, &Verifier{Roots: x509.NewCertPool()}, ErrBadSignature},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []Option
+			if tt.v != nil {
+				opts = append(opts, WithVerifier(tt.v))
+			}
+			if _, err := ReadArchive(bytes.NewReader(tt.archive), opts...); !errors.Is(err, tt.want) {
+				t.Errorf("ReadArchive() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
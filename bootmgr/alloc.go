@@ -0,0 +1,94 @@
+package bootmgr
+
+import (
+	"strconv"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithAllocator makes the Manager pick the numbers of new boot entries
+// with a.
+func WithAllocator(a Allocator) Option {
+	return func(m *Manager) {
+		m.alloc = a
+	}
+}
+
+// Range is an inclusive range of boot entry numbers.
+type Range struct {
+	First, Last uint16
+}
+
+func (r Range) contains(n uint16) bool {
+	return r.First <= n && n <= r.Last
+}
+
+// Allocator picks the numbers of new boot entries. The zero Allocator
+// hands out the lowest free number, like efibootmgr does.
+type Allocator struct {
+	// Reserved numbers are never handed out, e.g. the ones firmware
+	// recreates its own entries under on every boot
+	Reserved []Range
+	// Min is the lowest number handed out, e.g. 0x1000 to keep the
+	// entries of an installer apart from the firmware's
+	Min uint16
+}
+
+// Next returns the lowest number from a.Min on that isn't reserved and
+// for which used returns false, or ErrNoFreeNumber.
+func (a Allocator) Next(used func(n uint16) bool) (uint16, error) {
+next:
+	for n := int(a.Min); n <= 0xffff; n++ {
+		for _, r := range a.Reserved {
+			if r.contains(uint16(n)) {
+				n = int(r.Last)
+				continue next
+			}
+		}
+		if !used(uint16(n)) {
+			return uint16(n), nil
+		}
+	}
+	return 0, ErrNoFreeNumber
+}
+
+// NextFreeBootNumber returns the number the next boot entry created by
+// Create gets, as picked by the Allocator passed with WithAllocator.
+//
+// A number counts as used if BootOrder or BootNext refer to it, even if
+// its entry doesn't exist, or if a variable named Boot followed by four
+// hex digits exists for it, even if it can't be parsed or has its digits
+// in lower case, which some tools write and some firmware looks up case
+// insensitively. This keeps new entries from overwriting or being
+// confused with anything already there.
+func (m *Manager) NextFreeBootNumber() (uint16, error) {
+	descs, err := m.b.List()
+	if err != nil {
+		return 0, err
+	}
+	used := make(map[uint16]bool)
+	for _, d := range descs {
+		if *d.GUID != efivarfs.GlobalVariable || len(d.Name) != 8 || d.Name[:4] != "Boot" {
+			continue
+		}
+		if n, err := strconv.ParseUint(d.Name[4:], 16, 16); err == nil {
+			used[uint16(n)] = true
+		}
+	}
+	order, err := m.Order()
+	if err != nil {
+		return 0, err
+	}
+	for _, n := range order {
+		used[n] = true
+	}
+	if next, ok, err := m.Next(); err != nil {
+		return 0, err
+	} else if ok {
+		used[next] = true
+	}
+	return m.alloc.Next(func(n uint16) bool { return used[n] })
+}
@@ -0,0 +1,52 @@
+package bootmgr
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func TestNextFreeBootNumber(t *testing.T) {
+	dir := corpustest.Copy(t, "insyde-laptop")
+	// A malformed entry and one with lower case digits must not be
+	// overwritten either.
+	for _, name := range []string{"Boot0005", "Boot000a"} {
+		path := filepath.Join(dir, name+"-"+efivarfs.GlobalVariable.String())
+		if err := os.WriteFile(path, []byte{7, 0, 0, 0, 1}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	b := efivarfs.Dir(dir)
+	for _, tt := range []struct {
+		alloc Allocator
+		want  uint16
+	}{
+		{Allocator{}, 0},
+		{Allocator{Min: 1}, 6},
+		{Allocator{Min: 1, Reserved: []Range{{6, 9}}}, 0xb},
+		{Allocator{Min: 0x2000, Reserved: []Range{{0x2000, 0x2000}}}, 0x2002},
+	} {
+		n, err := New(b, WithAllocator(tt.alloc)).NextFreeBootNumber()
+		if err != nil || n != tt.want {
+			t.Errorf("NextFreeBootNumber() with %+v = %04X, %v, want %04X", tt.alloc, n, err, tt.want)
+		}
+	}
+
+	// Removing Boot0004 doesn't free its number while BootOrder still
+	// refers to it.
+	if err := b.Remove(desc("Boot0004")); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := New(b, WithAllocator(Allocator{Min: 4})).NextFreeBootNumber(); err != nil || n != 6 {
+		t.Errorf("NextFreeBootNumber() = %04X, %v with 0004 in BootOrder, want 0006", n, err)
+	}
+
+	a := Allocator{Min: 0xfffe, Reserved: []Range{{0xffff, 0xffff}}}
+	if _, err := a.Next(func(n uint16) bool { return n == 0xfffe }); !errors.Is(err, ErrNoFreeNumber) {
+		t.Errorf("Next() = %v, want ErrNoFreeNumber", err)
+	}
+}
@@ -0,0 +1,279 @@
+// Package bootmgr manages the boot entries of the UEFI boot manager: the
+// Boot#### variables and BootOrder, BootNext, BootCurrent and Timeout.
+package bootmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Attributes are the variable attributes used for all boot manager
+// variables.
+const Attributes = efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+
+// ErrNoFreeNumber is caused by creating an entry with all boot numbers
+// the Allocator may hand out in use
+var ErrNoFreeNumber = errors.New("no free boot entry number")
+
+// ErrInvalidTimeout is caused by timeouts that don't fit into Timeout
+var ErrInvalidTimeout = errors.New("invalid timeout")
+
+// NoTimeout is the Timeout making the boot manager wait for input
+// instead of booting the first entry of BootOrder after a while.
+const NoTimeout = 0xffff
+
+// Manager reads and modifies the boot entries stored in a backend.
+type Manager struct {
+	b     efivarfs.Backend
+	alloc Allocator
+}
+
+// New returns a Manager for the variables of b.
+func New(b efivarfs.Backend, opts ...Option) *Manager {
+	m := &Manager{b: b}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// Entry is a boot entry and its number.
+type Entry struct {
+	Number uint16
+	*LoadOption
+}
+
+// BootName returns the name of the variable of boot entry n, e.g.
+// Boot0001.
+func BootName(n uint16) string {
+	return fmt.Sprintf("Boot%04X", n)
+}
+
+// ParseNumber parses a boot entry number given as four hex digits as in
+// efibootmgr, optionally prefixed by Boot.
+func ParseNumber(s string) (uint16, error) {
+	if len(s) == 8 && s[:4] == "Boot" {
+		s = s[4:]
+	}
+	n, err := strconv.ParseUint(s, 16, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid boot entry number %q", s)
+	}
+	return uint16(n), nil
+}
+
+func desc(name string) efivarfs.VariableDescriptor {
+	return efivarfs.NewDescriptor(name, efivarfs.GlobalVariable)
+}
+
+// Entries returns all boot entries ordered by number. Entries that
+// can't be parsed are skipped.
+func (m *Manager) Entries() ([]Entry, error) {
+	descs, err := m.b.List()
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, d := range descs {
+		if *d.GUID != efivarfs.GlobalVariable || len(d.Name) != 8 || d.Name[:4] != "Boot" {
+			continue
+		}
+		n, err := strconv.ParseUint(d.Name[4:], 16, 16)
+		if err != nil || BootName(uint16(n)) != d.Name {
+			continue
+		}
+		o, err := m.Entry(uint16(n))
+		if err != nil {
+			continue
+		}
+		entries = append(entries, Entry{Number: uint16(n), LoadOption: o})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Number < entries[j].Number })
+	return entries, nil
+}
+
+// Entry returns boot entry n.
+func (m *Manager) Entry(n uint16) (*LoadOption, error) {
+	_, data, err := m.b.Get(desc(BootName(n)))
+	if err != nil {
+		return nil, err
+	}
+	return ParseLoadOption(data)
+}
+
+// SetEntry creates or overwrites boot entry n.
+func (m *Manager) SetEntry(n uint16, o *LoadOption) error {
+	data, err := o.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return m.b.Set(desc(BootName(n)), Attributes, data)
+}
+
+// Create adds o as new boot entry with the number returned by
+// NextFreeBootNumber and puts it first in BootOrder, like efibootmgr
+// does.
+func (m *Manager) Create(o *LoadOption) (uint16, error) {
+	n, err := m.NextFreeBootNumber()
+	if err != nil {
+		return 0, err
+	}
+	if err := m.SetEntry(n, o); err != nil {
+		return 0, err
+	}
+	order, err := m.Order()
+	if err != nil {
+		return 0, err
+	}
+	return n, m.SetOrder(append([]uint16{n}, order...))
+}
+
+// Delete removes boot entry n and drops it from BootOrder and BootNext.
+func (m *Manager) Delete(n uint16) error {
+	if err := m.b.Remove(desc(BootName(n))); err != nil {
+		return err
+	}
+	order, err := m.Order()
+	if err != nil {
+		return err
+	}
+	kept := order[:0]
+	for _, o := range order {
+		if o != n {
+			kept = append(kept, o)
+		}
+	}
+	if len(kept) != len(order) {
+		if err := m.SetOrder(kept); err != nil {
+			return err
+		}
+	}
+	if next, ok, err := m.Next(); err != nil {
+		return err
+	} else if ok && next == n {
+		return m.ClearNext()
+	}
+	return nil
+}
+
+// SetActive sets or clears LoadOptionActive of boot entry n. Only the
+// attributes are changed, the rest of the entry is written back as read.
+func (m *Manager) SetActive(n uint16, active bool) error {
+	d := desc(BootName(n))
+	attrs, data, err := m.b.Get(d)
+	if err != nil {
+		return err
+	}
+	if len(data) < 4 {
+		return fmt.Errorf("%s: %w", d.Name, ErrMalformed)
+	}
+	a := binary.LittleEndian.Uint32(data)
+	if active {
+		a |= uint32(LoadOptionActive)
+	} else {
+		a &^= uint32(LoadOptionActive)
+	}
+	data = append([]byte(nil), data...)
+	binary.LittleEndian.PutUint32(data, a)
+	return m.b.Set(d, attrs, data)
+}
+
+// Order returns BootOrder, which is empty if it doesn't exist.
+func (m *Manager) Order() ([]uint16, error) {
+	_, data, err := m.b.Get(desc("BootOrder"))
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		return nil, nil
+	case err != nil:
+		return nil, err
+	}
+	if len(data)%2 != 0 {
+		return nil, fmt.Errorf("BootOrder of odd length: %w", ErrMalformed)
+	}
+	order := make([]uint16, len(data)/2)
+	for i := range order {
+		order[i] = binary.LittleEndian.Uint16(data[2*i:])
+	}
+	return order, nil
+}
+
+// SetOrder replaces BootOrder.
+func (m *Manager) SetOrder(order []uint16) error {
+	data := make([]byte, 0, 2*len(order))
+	for _, n := range order {
+		data = binary.LittleEndian.AppendUint16(data, n)
+	}
+	return m.b.Set(desc("BootOrder"), Attributes, data)
+}
+
+// Next returns BootNext, the entry to boot once on the next boot. ok is
+// false if it isn't set.
+func (m *Manager) Next() (n uint16, ok bool, err error) {
+	return m.number("BootNext")
+}
+
+// SetNext sets BootNext to entry n.
+func (m *Manager) SetNext(n uint16) error {
+	return m.b.Set(desc("BootNext"), Attributes, binary.LittleEndian.AppendUint16(nil, n))
+}
+
+// ClearNext removes BootNext.
+func (m *Manager) ClearNext() error {
+	err := m.b.Remove(desc("BootNext"))
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Current returns BootCurrent, the entry the system was booted from. ok
+// is false if the firmware didn't set it.
+func (m *Manager) Current() (n uint16, ok bool, err error) {
+	return m.number("BootCurrent")
+}
+
+// Timeout returns the boot manager timeout in seconds, which is
+// NoTimeout if it waits for input. ok is false if it isn't set.
+func (m *Manager) Timeout() (seconds uint16, ok bool, err error) {
+	return m.number("Timeout")
+}
+
+// SetTimeout sets the boot manager timeout to seconds, between 0, which
+// boots right away, and NoTimeout. Some firmware doesn't show its menu
+// at all with 0 and has to be entered with a hotkey or OsIndications
+// then, and some ignores NoTimeout and uses its own default.
+func (m *Manager) SetTimeout(seconds int) error {
+	if seconds < 0 || seconds > NoTimeout {
+		return fmt.Errorf("%d seconds: %w", seconds, ErrInvalidTimeout)
+	}
+	return m.b.Set(desc("Timeout"), Attributes, binary.LittleEndian.AppendUint16(nil, uint16(seconds)))
+}
+
+// ClearTimeout removes Timeout, so the firmware uses its default.
+func (m *Manager) ClearTimeout() error {
+	err := m.b.Remove(desc("Timeout"))
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil
+	}
+	return err
+}
+
+// number reads a variable holding a single 16 bit number.
+func (m *Manager) number(name string) (uint16, bool, error) {
+	_, data, err := m.b.Get(desc(name))
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		return 0, false, nil
+	case err != nil:
+		return 0, false, err
+	}
+	if len(data) != 2 {
+		return 0, false, fmt.Errorf("%s of length %d: %w", name, len(data), ErrMalformed)
+	}
+	return binary.LittleEndian.Uint16(data), true, nil
+}
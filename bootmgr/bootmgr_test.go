@@ -0,0 +1,149 @@
+package bootmgr
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func TestTimeout(t *testing.T) {
+	b := efivarfs.Dir(corpustest.Copy(t, "insyde-laptop"))
+	m := New(b)
+	if s, ok, err := m.Timeout(); err != nil || !ok || s != 0 {
+		t.Errorf("Timeout() = %d, %v, %v, want 0 from the corpus", s, ok, err)
+	}
+
+	for _, tt := range []struct {
+		seconds int
+		want    string
+		err     error
+	}{
+		{seconds: 5, want: "\x05\x00"},
+		{seconds: 0, want: "\x00\x00"},
+		{seconds: NoTimeout, want: "\xff\xff"},
+		{seconds: -1, err: ErrInvalidTimeout},
+		{seconds: 0x10000, err: ErrInvalidTimeout},
+	} {
+		// Start from a known value to tell rejected writes apart
+		if err := m.SetTimeout(3); err != nil {
+			t.Fatal(err)
+		}
+		err := m.SetTimeout(tt.seconds)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("SetTimeout(%d) = %v, want %v", tt.seconds, err, tt.err)
+		}
+		want := tt.want
+		if tt.err != nil {
+			want = "\x03\x00"
+		}
+		if attrs, data, err := b.Get(desc("Timeout")); err != nil || attrs != Attributes || string(data) != want {
+			t.Errorf("Timeout = %s, %x, %v after SetTimeout(%d), want %x", attrs, data, err, tt.seconds, want)
+		}
+	}
+	if s, ok, err := m.Timeout(); err != nil || !ok || s != 3 {
+		t.Errorf("Timeout() = %d, %v, %v, want 3", s, ok, err)
+	}
+
+	if err := b.Set(desc("Timeout"), Attributes, []byte{1, 2, 3}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := m.Timeout(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("Timeout() = %v of a 3 byte variable, want ErrMalformed", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := m.ClearTimeout(); err != nil {
+			t.Errorf("ClearTimeout() = %v", err)
+		}
+		if s, ok, err := m.Timeout(); err != nil || ok {
+			t.Errorf("Timeout() = %d, %v, %v after ClearTimeout(), want it unset", s, ok, err)
+		}
+	}
+}
+
+func TestCreateDelete(t *testing.T) {
+	b := efivarfs.Dir(corpustest.Copy(t, "insyde-laptop"))
+	m := New(b)
+	o, err := m.Entry(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o.Description = "Copy"
+	n, err := m.Create(o)
+	if err != nil || n != 0 {
+		t.Fatalf("Create() = %04X, %v, want 0000", n, err)
+	}
+	if got, err := m.Entry(0); err != nil || !reflect.DeepEqual(got, o) {
+		t.Errorf("Entry(0) = %+v, %v, want %+v", got, err, o)
+	}
+
+	for _, tt := range []struct {
+		n     uint16
+		err   error
+		order []uint16
+		next  bool
+	}{
+		{n: 0x3000, order: []uint16{0, 4, 1, 2, 3, 0x2001}, next: true},
+		// BootNext is cleared together with the entry it points to
+		{n: 2, order: []uint16{0, 4, 1, 3, 0x2001}},
+		{n: 0x10, err: efivarfs.ErrVarNotExist, order: []uint16{0, 4, 1, 3, 0x2001}},
+		{n: 0, order: []uint16{4, 1, 3, 0x2001}},
+	} {
+		if err := m.Delete(tt.n); !errors.Is(err, tt.err) {
+			t.Errorf("Delete(%04X) = %v, want %v", tt.n, err, tt.err)
+		}
+		if _, err := m.Entry(tt.n); !errors.Is(err, efivarfs.ErrVarNotExist) {
+			t.Errorf("Entry(%04X) = %v after Delete(), want ErrVarNotExist", tt.n, err)
+		}
+		if order, err := m.Order(); err != nil || !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("BootOrder = %04X, %v after Delete(%04X), want %04X", order, err, tt.n, tt.order)
+		}
+		if _, ok, err := m.Next(); err != nil || ok != tt.next {
+			t.Errorf("BootNext set = %v, %v after Delete(%04X), want %v", ok, err, tt.n, tt.next)
+		}
+	}
+}
+
+func TestSetActive(t *testing.T) {
+	b := efivarfs.Dir(corpustest.Copy(t, "insyde-laptop"))
+	m := New(b)
+	attrs, orig, err := b.Get(desc(BootName(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, active := range []bool{false, false, true} {
+		if err := m.SetActive(1, active); err != nil {
+			t.Fatalf("SetActive(%v) = %v", active, err)
+		}
+		a, data, err := b.Get(desc(BootName(1)))
+		if err != nil || a != attrs {
+			t.Fatalf("Boot0001 = %s, %v after SetActive(%v)", a, err, active)
+		}
+		if o, err := ParseLoadOption(data); err != nil || o.Active() != active {
+			t.Errorf("Boot0001 active = %v, %v after SetActive(%v)", o.Active(), err, active)
+		}
+		// Nothing but the active bit changes
+		want := append([]byte(nil), orig...)
+		want[0] &^= 1
+		if active {
+			want[0] |= 1
+		}
+		if !bytes.Equal(data, want) {
+			t.Errorf("Boot0001 = %x after SetActive(%v), want %x", data, active, want)
+		}
+	}
+
+	if err := b.Set(desc(BootName(5)), Attributes, []byte{1, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetActive(5, true); !errors.Is(err, ErrMalformed) {
+		t.Errorf("SetActive() = %v of a 2 byte entry, want ErrMalformed", err)
+	}
+	if err := m.SetActive(6, true); !errors.Is(err, efivarfs.ErrVarNotExist) {
+		t.Errorf("SetActive() = %v of a missing entry, want ErrVarNotExist", err)
+	}
+}
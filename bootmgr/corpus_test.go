@@ -0,0 +1,218 @@
+package bootmgr
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+const corpus = "../testdata/corpus"
+
+func TestCorpus(t *testing.T) {
+	for _, tt := range []struct {
+		vendor  string
+		order   []uint16
+		current uint16
+		entries map[uint16]string
+	}{
+		{
+			vendor:  "ovmf",
+			order:   []uint16{1, 2, 3, 0},
+			current: 3,
+			entries: map[uint16]string{
+				0: "UiApp",
+				1: "UEFI QEMU DVD-ROM QM00005 ",
+				2: "UEFI PXEv4 (MAC:525400123456)",
+				3: "EFI Internal Shell",
+			},
+		},
+		{
+			vendor:  "ami-desktop",
+			order:   []uint16{0, 1, 2},
+			current: 0,
+			entries: map[uint16]string{
+				0: "Windows Boot Manager",
+				1: "UEFI: SanDisk, Partition 1",
+				2: "P0: Example SSD 500GB ",
+			},
+		},
+		{
+			vendor:  "insyde-laptop",
+			order:   []uint16{4, 1, 2, 3, 0x2001, 0x3000},
+			current: 1,
+			entries: map[uint16]string{
+				1:      "ubuntu",
+				2:      "Linux-Firmware-Updater",
+				3:      "Example NVMe 1TB",
+				4:      "Linux Boot Manager",
+				0x2001: "EFI USB Device",
+				0x3000: "Internal Hard Disk or Solid State Disk",
+			},
+		},
+		{
+			vendor:  "dell-server",
+			order:   []uint16{2, 3, 0, 1},
+			current: 2,
+			entries: map[uint16]string{
+				0: "HTTP Boot 1: NIC.Embedded.1-1-1",
+				1: "PXE Device 1: Embedded NIC 1 Port 1 Partition 1",
+				2: "Red Hat Enterprise Linux",
+				3: "Integrated RAID Controller 1: Red Hat Enterprise Linux",
+			},
+		},
+	} {
+		t.Run(tt.vendor, func(t *testing.T) {
+			m := New(efivarfs.Dir(filepath.Join(corpus, tt.vendor)))
+			order, err := m.Order()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("Order() = %v, want %v", order, tt.order)
+			}
+			current, ok, err := m.Current()
+			if err != nil || !ok || current != tt.current {
+				t.Errorf("Current() = %d, %v, %v, want %d", current, ok, err, tt.current)
+			}
+			entries, err := m.Entries()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tt.entries) {
+				t.Errorf("got %d entries, want %d", len(entries), len(tt.entries))
+			}
+			for _, e := range entries {
+				if want := tt.entries[e.Number]; e.Description != want {
+					t.Errorf("%s: description %q, want %q", BootName(e.Number), e.Description, want)
+				}
+			}
+		})
+	}
+}
+
+// TestCorpusRoundTrip checks that every load option of the corpus is
+// encoded exactly as the firmware stored it.
+func TestCorpusRoundTrip(t *testing.T) {
+	files, err := filepath.Glob(filepath.Join(corpus, "*", "Boot[0-9A-F][0-9A-F][0-9A-F][0-9A-F]-*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatal("no load options in corpus")
+	}
+	for _, file := range files {
+		name := strings.TrimPrefix(file, corpus+string(filepath.Separator))
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := b[4:]
+			o, err := ParseLoadOption(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := o.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("MarshalBinary() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestCorpusFind(t *testing.T) {
+	m := New(efivarfs.Dir(filepath.Join(corpus, "insyde-laptop")))
+	for _, tt := range []struct {
+		path string
+		want []uint16
+	}{
+		{`\EFI\ubuntu\shimx64.efi`, []uint16{1, 2}},
+		{"/efi/UBUNTU/shimx64.efi", []uint16{1, 2}},
+		{`EFI\systemd\systemd-bootx64.efi`, []uint16{4}},
+		{`\EFI\BOOT\BOOTX64.EFI`, nil},
+	} {
+		entries, err := m.FindByLoaderPath(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []uint16
+		for _, e := range entries {
+			got = append(got, e.Number)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("FindByLoaderPath(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	entries, err := m.FindByDescription("Linux")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Number != 2 || entries[1].Number != 4 {
+		t.Errorf("FindByDescription(%q) returned %d entries, want Boot0002 and Boot0004", "Linux", len(entries))
+	}
+	if entries, err := m.FindWindowsBootManager(); err != nil || len(entries) != 0 {
+		t.Errorf("FindWindowsBootManager() = %d entries, %v on a Linux laptop", len(entries), err)
+	}
+
+	// Windows Boot Manager is recognized by its optional data and,
+	// should the firmware drop that, by its description and loader.
+	m = New(efivarfs.Dir(corpustest.Copy(t, "ami-desktop")))
+	windows, err := m.Entry(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied := *windows
+	copied.OptionalData = nil
+	if err := m.SetEntry(5, &copied); err != nil {
+		t.Fatal(err)
+	}
+	entries, err = m.FindWindowsBootManager()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || entries[0].Number != 0 || entries[1].Number != 5 {
+		t.Errorf("FindWindowsBootManager() returned %d entries, want Boot0000 and Boot0005", len(entries))
+	}
+}
+
+func TestUpdateOsIndications(t *testing.T) {
+	m := New(efivarfs.Dir(corpustest.Copy(t, "insyde-laptop")))
+	supported, err := m.OsIndicationsSupported()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := BootToFWUI | FileCapsuleDelivery | CapsuleResultVar; supported != want {
+		t.Errorf("OsIndicationsSupported() = %s, want %s", supported, want)
+	}
+	if err := m.UpdateOsIndications(StartOSRecovery, 0); !errors.Is(err, ErrUnsupportedIndication) {
+		t.Errorf("setting unsupported indication: got %v, want %v", err, ErrUnsupportedIndication)
+	}
+	for _, tt := range []struct {
+		set, clear, want OsIndications
+	}{
+		{BootToFWUI, 0, BootToFWUI},
+		{FileCapsuleDelivery, 0, BootToFWUI | FileCapsuleDelivery},
+		{0, BootToFWUI, FileCapsuleDelivery},
+	} {
+		if err := m.UpdateOsIndications(tt.set, tt.clear); err != nil {
+			t.Fatal(err)
+		}
+		got, err := m.OsIndications()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("UpdateOsIndications(%s, %s) left %s, want %s", tt.set, tt.clear, got, tt.want)
+		}
+	}
+}
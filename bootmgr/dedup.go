@@ -0,0 +1,118 @@
+package bootmgr
+
+import (
+	"errors"
+	"slices"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Duplicates is a group of boot entries with the same description and
+// device path, which firmware re-adding its entries on every boot leaves
+// behind.
+type Duplicates struct {
+	// Keep is the entry Coalesce keeps, the one booted first according
+	// to BootOrder or else the one with the lowest number
+	Keep uint16
+	// Remove are the other entries of the group
+	Remove []uint16
+}
+
+// FindDuplicates returns the groups of entries that are the same but
+// for their attributes and optional data, ordered by Keep.
+func (m *Manager) FindDuplicates() ([]Duplicates, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return nil, err
+	}
+	order, err := m.Order()
+	if err != nil {
+		return nil, err
+	}
+	// rank sorts entries in BootOrder before the others.
+	rank := func(n uint16) int {
+		if i := slices.Index(order, n); i >= 0 {
+			return i
+		}
+		return len(order) + int(n)
+	}
+	type key struct {
+		description, path string
+	}
+	groups := make(map[key][]uint16)
+	var keys []key
+	for _, e := range entries {
+		path, err := e.FilePath.MarshalBinary()
+		if err != nil {
+			continue
+		}
+		k := key{e.Description, string(path)}
+		if groups[k] == nil {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], e.Number)
+	}
+	var dups []Duplicates
+	for _, k := range keys {
+		g := groups[k]
+		if len(g) < 2 {
+			continue
+		}
+		slices.SortFunc(g, func(a, b uint16) int { return rank(a) - rank(b) })
+		dups = append(dups, Duplicates{Keep: g[0], Remove: g[1:]})
+	}
+	slices.SortFunc(dups, func(a, b Duplicates) int { return int(a.Keep) - int(b.Keep) })
+	return dups, nil
+}
+
+// Coalesce removes the duplicate entries returned by FindDuplicates and
+// makes BootOrder and BootNext refer to the entry kept instead, so the
+// boot order stays the same. The groups are returned for reporting.
+func (m *Manager) Coalesce() ([]Duplicates, error) {
+	dups, err := m.FindDuplicates()
+	if err != nil || len(dups) == 0 {
+		return nil, err
+	}
+	replace := make(map[uint16]uint16)
+	for _, d := range dups {
+		for _, n := range d.Remove {
+			replace[n] = d.Keep
+		}
+	}
+
+	order, err := m.Order()
+	if err != nil {
+		return nil, err
+	}
+	var newOrder []uint16
+	for _, n := range order {
+		if k, ok := replace[n]; ok {
+			n = k
+		}
+		if !slices.Contains(newOrder, n) {
+			newOrder = append(newOrder, n)
+		}
+	}
+	if !slices.Equal(newOrder, order) {
+		if err := m.SetOrder(newOrder); err != nil {
+			return nil, err
+		}
+	}
+	if next, ok, err := m.Next(); err != nil {
+		return nil, err
+	} else if k, dup := replace[next]; ok && dup {
+		if err := m.SetNext(k); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, d := range dups {
+		for _, n := range d.Remove {
+			err := m.b.Remove(desc(BootName(n)))
+			if err != nil && !errors.Is(err, efivarfs.ErrVarNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return dups, nil
+}
@@ -0,0 +1,67 @@
+package bootmgr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func TestCoalesce(t *testing.T) {
+	m := New(efivarfs.Dir(corpustest.Copy(t, "ovmf")))
+	dvd, err := m.Entry(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Copies of the DVD entry re-added by the firmware, the one in
+	// front of BootOrder is kept despite being inactive.
+	copies := map[uint16]LoadOption{5: *dvd, 6: *dvd}
+	inactive := copies[5]
+	inactive.Attributes = 0
+	copies[5] = inactive
+	for n, o := range copies {
+		if err := m.SetEntry(n, &o); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := m.SetOrder([]uint16{5, 1, 2, 3, 0, 6}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.SetNext(6); err != nil {
+		t.Fatal(err)
+	}
+
+	dups, err := m.Coalesce()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Duplicates{{Keep: 5, Remove: []uint16{1, 6}}}; !reflect.DeepEqual(dups, want) {
+		t.Errorf("Coalesce() = %+v, want %+v", dups, want)
+	}
+	order, err := m.Order()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint16{5, 2, 3, 0}; !reflect.DeepEqual(order, want) {
+		t.Errorf("BootOrder = %04X, want %04X", order, want)
+	}
+	if next, ok, err := m.Next(); err != nil || !ok || next != 5 {
+		t.Errorf("BootNext = %04X, %v, %v, want 0005", next, ok, err)
+	}
+	entries, err := m.Entries()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var numbers []uint16
+	for _, e := range entries {
+		numbers = append(numbers, e.Number)
+	}
+	if want := []uint16{0, 2, 3, 5}; !reflect.DeepEqual(numbers, want) {
+		t.Errorf("entries %04X remain, want %04X", numbers, want)
+	}
+
+	if dups, err := m.Coalesce(); err != nil || len(dups) != 0 {
+		t.Errorf("second Coalesce() = %+v, %v, want nothing to do", dups, err)
+	}
+}
@@ -0,0 +1,106 @@
+package bootmgr
+
+import (
+	"bytes"
+	"slices"
+
+	"github.com/system-transparency/efivar/devicepath"
+)
+
+// EntrySpec is the desired state of a boot entry for EnsureBootEntry.
+type EntrySpec struct {
+	// LoadOption is the desired content of the entry. Attributes
+	// usually include LoadOptionActive.
+	LoadOption
+	// Position is the index of the entry in BootOrder, 0 being first.
+	// Positions beyond the end put it last. If negative, an existing
+	// entry keeps its position and a new one is appended.
+	Position int
+}
+
+// EnsureBootEntry makes the boot entries converge to spec: the entry
+// with the same device path and optional data as spec, or otherwise
+// with the same description, is updated in place if its content differs, or created
+// if there is none, and moved to spec.Position in BootOrder. Only what
+// differs is written, so calling it again with the same spec changes
+// nothing, which changed reports. If several entries match, the one
+// with the lowest number is used and the others are left alone.
+func (m *Manager) EnsureBootEntry(spec EntrySpec) (n uint16, changed bool, err error) {
+	want, err := spec.MarshalBinary()
+	if err != nil {
+		return 0, false, err
+	}
+	e, ok, err := m.match(&spec.LoadOption)
+	if err != nil {
+		return 0, false, err
+	}
+	if ok {
+		n = e.Number
+		have, err := e.MarshalBinary()
+		if err != nil {
+			return 0, false, err
+		}
+		if !bytes.Equal(have, want) {
+			if err := m.SetEntry(n, &spec.LoadOption); err != nil {
+				return 0, false, err
+			}
+			changed = true
+		}
+	} else {
+		if n, err = m.NextFreeBootNumber(); err != nil {
+			return 0, false, err
+		}
+		if err := m.SetEntry(n, &spec.LoadOption); err != nil {
+			return 0, false, err
+		}
+		changed = true
+	}
+
+	order, err := m.Order()
+	if err != nil {
+		return 0, false, err
+	}
+	if newOrder := placeInOrder(order, n, spec.Position); !slices.Equal(newOrder, order) {
+		if err := m.SetOrder(newOrder); err != nil {
+			return 0, false, err
+		}
+		changed = true
+	}
+	return n, changed, nil
+}
+
+// match returns the entry EnsureBootEntry updates for o.
+func (m *Manager) match(o *LoadOption) (Entry, bool, error) {
+	// The loader alone doesn't identify an entry: shim is shared by the
+	// entry of the distribution and e.g. the one of fwupd, which only
+	// differ in the optional data telling shim what to chain load.
+	found, err := m.find(func(e *LoadOption) bool {
+		return devicepath.Equal(e.FilePath, o.FilePath) && bytes.Equal(e.OptionalData, o.OptionalData)
+	})
+	if err != nil {
+		return Entry{}, false, err
+	}
+	if len(found) == 0 {
+		if found, err = m.find(func(e *LoadOption) bool { return e.Description == o.Description }); err != nil {
+			return Entry{}, false, err
+		}
+	}
+	if len(found) == 0 {
+		return Entry{}, false, nil
+	}
+	return found[0], true, nil
+}
+
+// placeInOrder returns order with n at pos as described for
+// EntrySpec.Position.
+func placeInOrder(order []uint16, n uint16, pos int) []uint16 {
+	i := slices.Index(order, n)
+	if pos < 0 {
+		if i >= 0 {
+			return order
+		}
+		return append(slices.Clone(order), n)
+	}
+	rest := slices.DeleteFunc(slices.Clone(order), func(o uint16) bool { return o == n })
+	return slices.Insert(rest, min(pos, len(rest)), n)
+}
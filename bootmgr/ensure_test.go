@@ -0,0 +1,99 @@
+package bootmgr
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/system-transparency/efivar/devicepath"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func TestEnsureBootEntry(t *testing.T) {
+	m := New(efivarfs.Dir(corpustest.Copy(t, "insyde-laptop")))
+	ubuntu, err := m.Entry(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fwupd loads the same shim as Boot0001 and only differs in the
+	// optional data.
+	fwupd, err := m.Entry(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name    string
+		spec    EntrySpec
+		n       uint16
+		changed bool
+		order   []uint16
+	}{
+		{
+			name:    "unchanged",
+			spec:    EntrySpec{LoadOption: *ubuntu, Position: 1},
+			n:       1,
+			changed: false,
+			order:   []uint16{4, 1, 2, 3, 0x2001, 0x3000},
+		},
+		{
+			name: "shared loader",
+			spec: EntrySpec{LoadOption: LoadOption{
+				Attributes:   fwupd.Attributes,
+				Description:  "Firmware Updater",
+				FilePath:     fwupd.FilePath,
+				OptionalData: fwupd.OptionalData,
+			}, Position: -1},
+			n:       2,
+			changed: true,
+			order:   []uint16{4, 1, 2, 3, 0x2001, 0x3000},
+		},
+		{
+			name: "update",
+			spec: EntrySpec{LoadOption: LoadOption{
+				Attributes:  LoadOptionActive,
+				Description: "Ubuntu",
+				FilePath:    ubuntu.FilePath,
+			}, Position: 0},
+			n:       1,
+			changed: true,
+			order:   []uint16{1, 4, 2, 3, 0x2001, 0x3000},
+		},
+		{
+			name: "create",
+			spec: EntrySpec{LoadOption: LoadOption{
+				Attributes:  LoadOptionActive,
+				Description: "Fedora",
+				FilePath:    devicepath.Path{ubuntu.FilePath[0], devicepath.File(`\EFI\fedora\shimx64.efi`)},
+			}, Position: -1},
+			n:       0,
+			changed: true,
+			order:   []uint16{1, 4, 2, 3, 0x2001, 0x3000, 0},
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			for i, changed := range []bool{tt.changed, false} {
+				n, got, err := m.EnsureBootEntry(tt.spec)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if n != tt.n || got != changed {
+					t.Errorf("call %d: EnsureBootEntry() = %04X, %v, want %04X, %v", i+1, n, got, tt.n, changed)
+				}
+			}
+			o, err := m.Entry(tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if o.Description != tt.spec.Description {
+				t.Errorf("description %q, want %q", o.Description, tt.spec.Description)
+			}
+			order, err := m.Order()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(order, tt.order) {
+				t.Errorf("BootOrder = %v, want %v", order, tt.order)
+			}
+		})
+	}
+}
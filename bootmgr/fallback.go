@@ -0,0 +1,59 @@
+package bootmgr
+
+import (
+	"runtime"
+
+	"github.com/system-transparency/efivar/devicepath"
+	"github.com/system-transparency/efivar/esp"
+)
+
+// Arch is a CPU architecture as named in the removable media path of
+// its loaders, \EFI\BOOT\BOOT<Arch>.EFI.
+type Arch string
+
+// The architectures the UEFI specification defines removable media
+// paths for.
+const (
+	ArchIA32        Arch = "IA32"
+	ArchX64         Arch = "X64"
+	ArchIA64        Arch = "IA64"
+	ArchARM         Arch = "ARM"
+	ArchAA64        Arch = "AA64"
+	ArchRISCV64     Arch = "RISCV64"
+	ArchLoongArch64 Arch = "LOONGARCH64"
+)
+
+// goArchs maps GOARCH to the architectures of UEFI.
+var goArchs = map[string]Arch{
+	"386":     ArchIA32,
+	"amd64":   ArchX64,
+	"arm":     ArchARM,
+	"arm64":   ArchAA64,
+	"riscv64": ArchRISCV64,
+	"loong64": ArchLoongArch64,
+}
+
+// NativeArch returns the architecture of the running system. ok is
+// false if UEFI doesn't support it.
+func NativeArch() (a Arch, ok bool) {
+	a, ok = goArchs[runtime.GOARCH]
+	return a, ok
+}
+
+// FallbackLoader returns the removable media path of a, e.g.
+// \EFI\BOOT\BOOTX64.EFI, which firmware boots if no entry works and
+// where shim installs its fallback loader.
+func (a Arch) FallbackLoader() string {
+	return `\EFI\BOOT\BOOT` + string(a) + ".EFI"
+}
+
+// FallbackEntry returns an active boot entry for the removable media
+// path of arch on the ESP e. Its device path is in short form, starting
+// at the partition, so it stays valid if the disk moves to another port.
+func FallbackEntry(e esp.ESP, arch Arch, description string) *LoadOption {
+	return &LoadOption{
+		Attributes:  LoadOptionActive,
+		Description: description,
+		FilePath:    devicepath.Path{devicepath.HardDrive(e.Partition), devicepath.File(arch.FallbackLoader())},
+	}
+}
@@ -0,0 +1,49 @@
+package bootmgr
+
+import (
+	"encoding/hex"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/esp"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+func TestFallbackEntry(t *testing.T) {
+	e := esp.ESP{Partition: gpt.Partition{
+		Number:   1,
+		GUID:     guid.MustParse("c0ffee00-1234-4bcd-9ef0-123456789abc"),
+		FirstLBA: 0x800,
+		LastLBA:  0x327ff,
+	}}
+	for _, tt := range []struct {
+		arch Arch
+		want string
+	}{
+		{ArchX64, `HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\BOOT\BOOTX64.EFI)`},
+		{ArchAA64, `HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\BOOT\BOOTAA64.EFI)`},
+		{ArchIA32, `HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\BOOT\BOOTIA32.EFI)`},
+	} {
+		o := FallbackEntry(e, tt.arch, "UEFI OS")
+		if got := o.FilePath.String(); got != tt.want {
+			t.Errorf("FallbackEntry(%s) has device path %s, want %s", tt.arch, got, tt.want)
+		}
+		if !o.Active() || o.Description != "UEFI OS" {
+			t.Errorf("FallbackEntry(%s) = %+v, want an active entry described as UEFI OS", tt.arch, o)
+		}
+		if _, err := o.MarshalBinary(); err != nil {
+			t.Errorf("FallbackEntry(%s).MarshalBinary() = %v", tt.arch, err)
+		}
+	}
+
+	// The encoding is fixed, so that running the tests on 32 bit and big
+	// endian architectures catches encoders depending on the host.
+	const want = "010000005e00550045004600490020004f005300000004012a0001000000" +
+		"0008000000000000002003000000000000eeffc03412cd4b9ef0123456789abc0202" +
+		"040430005c004500460049005c0042004f004f0054005c0042004f004f0054005800" +
+		"360034002e0045004600490000007fff0400"
+	b, err := FallbackEntry(e, ArchX64, "UEFI OS").MarshalBinary()
+	if err != nil || hex.EncodeToString(b) != want {
+		t.Errorf("FallbackEntry(X64).MarshalBinary() = %x, %v, want %s", b, err, want)
+	}
+}
@@ -0,0 +1,104 @@
+package bootmgr
+
+import (
+	"strings"
+
+	"github.com/system-transparency/efivar/devicepath"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+// FindByDescription returns the boot entries whose description contains
+// substr, ordered by number.
+func (m *Manager) FindByDescription(substr string) ([]Entry, error) {
+	return m.find(func(o *LoadOption) bool {
+		return strings.Contains(o.Description, substr)
+	})
+}
+
+// FindByLoaderPath returns the boot entries loading the file at path,
+// ordered by number. As on the FAT file system of the ESP, the path is
+// compared case-insensitively, and slashes may be used instead of
+// backslashes, so \EFI\myos\grubx64.efi and /efi/MyOS/grubx64.efi match
+// the same entries. The partition isn't compared.
+func (m *Manager) FindByLoaderPath(path string) ([]Entry, error) {
+	path = normalizePath(path)
+	return m.find(func(o *LoadOption) bool {
+		p, ok := o.FilePath.FilePath()
+		return ok && strings.EqualFold(normalizePath(p), path)
+	})
+}
+
+// FindByDevicePath returns the boot entries whose device path equals p
+// as reported by devicepath.Equal, ordered by number. Unlike
+// FindByLoaderPath, the partition has to match as well, but either path
+// may be in short form.
+func (m *Manager) FindByDevicePath(p devicepath.Path) ([]Entry, error) {
+	return m.find(func(o *LoadOption) bool {
+		return devicepath.Equal(o.FilePath, p)
+	})
+}
+
+// windowsLoader is the path of Windows Boot Manager on the ESP.
+const windowsLoader = `\EFI\Microsoft\Boot\bootmgfw.efi`
+
+// IsWindowsBootManager reports whether o starts Windows Boot Manager.
+// That is the case if its optional data carries the signature Windows
+// writes, or, as some firmware drops the optional data when recreating
+// entries, if it loads \EFI\Microsoft\Boot\bootmgfw.efi and is
+// described as "Windows Boot Manager".
+func (o *LoadOption) IsWindowsBootManager() bool {
+	if _, err := ParseWindowsOptionalData(o.OptionalData); err == nil {
+		return true
+	}
+	p, ok := o.FilePath.FilePath()
+	return ok && strings.EqualFold(normalizePath(p), windowsLoader) && o.Description == "Windows Boot Manager"
+}
+
+// FindWindowsBootManager returns the entries starting Windows Boot
+// Manager as reported by IsWindowsBootManager, ordered by number, e.g.
+// for dual-boot setups placing their own entry relative to it. There
+// is usually one per Windows installation.
+func (m *Manager) FindWindowsBootManager() ([]Entry, error) {
+	return m.find(func(o *LoadOption) bool { return o.IsWindowsBootManager() })
+}
+
+// Expand returns the long form of the device path p, see
+// devicepath.Expand, with the paths of all boot entries as the known
+// ones. Firmware usually creates entries in long form for the disks it
+// finds, which makes short-form entries created by the OS comparable.
+func (m *Manager) Expand(p devicepath.Path, disks []*gpt.Table) (devicepath.Path, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return nil, err
+	}
+	known := make([]devicepath.Path, len(entries))
+	for i, e := range entries {
+		known[i] = e.FilePath
+	}
+	return devicepath.Expand(p, known, disks)
+}
+
+// find returns the entries for which match returns true.
+func (m *Manager) find(match func(o *LoadOption) bool) ([]Entry, error) {
+	entries, err := m.Entries()
+	if err != nil {
+		return nil, err
+	}
+	var found []Entry
+	for _, e := range entries {
+		if match(e.LoadOption) {
+			found = append(found, e)
+		}
+	}
+	return found, nil
+}
+
+// normalizePath converts path to the absolute backslash separated form
+// of device paths.
+func normalizePath(path string) string {
+	path = strings.ReplaceAll(path, "/", `\`)
+	if !strings.HasPrefix(path, `\`) {
+		path = `\` + path
+	}
+	return path
+}
@@ -0,0 +1,101 @@
+package bootmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+
+	"github.com/system-transparency/efivar/devicepath"
+)
+
+// ErrMalformed is caused by load options not following the UEFI
+// specification
+var ErrMalformed = errors.New("malformed load option")
+
+// LoadOptionAttributes are the attributes of a LoadOption.
+type LoadOptionAttributes uint32
+
+const (
+	// LoadOptionActive makes the boot manager consider the entry
+	LoadOptionActive LoadOptionAttributes = 0x00000001
+	// LoadOptionForceReconnect reconnects all drivers after loading a
+	// driver entry
+	LoadOptionForceReconnect LoadOptionAttributes = 0x00000002
+	// LoadOptionHidden hides the entry from boot menus
+	LoadOptionHidden LoadOptionAttributes = 0x00000008
+	// LoadOptionCategoryApp marks entries only started on request
+	LoadOptionCategoryApp LoadOptionAttributes = 0x00000100
+)
+
+// LoadOption is an EFI_LOAD_OPTION, the content of Boot#### and the
+// other numbered option variables.
+type LoadOption struct {
+	Attributes  LoadOptionAttributes
+	Description string
+	// FilePath is the device path of the image to load
+	FilePath devicepath.Path
+	// OptionalData is passed to the image, e.g. the kernel command line
+	OptionalData []byte
+}
+
+// ParseLoadOption parses the content of a load option variable.
+func ParseLoadOption(b []byte) (*LoadOption, error) {
+	if len(b) < 6 {
+		return nil, fmt.Errorf("%d bytes: %w", len(b), ErrMalformed)
+	}
+	o := &LoadOption{Attributes: LoadOptionAttributes(binary.LittleEndian.Uint32(b))}
+	pathLen := int(binary.LittleEndian.Uint16(b[4:]))
+	b = b[6:]
+	var desc []uint16
+	for {
+		if len(b) < 2 {
+			return nil, fmt.Errorf("unterminated description: %w", ErrMalformed)
+		}
+		c := binary.LittleEndian.Uint16(b)
+		b = b[2:]
+		if c == 0 {
+			break
+		}
+		desc = append(desc, c)
+	}
+	o.Description = string(utf16.Decode(desc))
+	if len(b) < pathLen {
+		return nil, fmt.Errorf("file path list exceeds load option: %w", ErrMalformed)
+	}
+	if pathLen > 0 {
+		p, err := devicepath.Parse(b[:pathLen])
+		if err != nil {
+			return nil, err
+		}
+		o.FilePath = p
+	}
+	if len(b) > pathLen {
+		o.OptionalData = append([]byte(nil), b[pathLen:]...)
+	}
+	return o, nil
+}
+
+// MarshalBinary returns the content of the load option variable.
+func (o *LoadOption) MarshalBinary() ([]byte, error) {
+	path, err := o.FilePath.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(path) > 0xffff {
+		return nil, fmt.Errorf("file path list of %d bytes: %w", len(path), ErrMalformed)
+	}
+	b := binary.LittleEndian.AppendUint32(nil, uint32(o.Attributes))
+	b = binary.LittleEndian.AppendUint16(b, uint16(len(path)))
+	for _, c := range utf16.Encode([]rune(o.Description)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	b = append(b, 0, 0)
+	b = append(b, path...)
+	return append(b, o.OptionalData...), nil
+}
+
+// Active reports whether LoadOptionActive is set.
+func (o *LoadOption) Active() bool {
+	return o.Attributes&LoadOptionActive != 0
+}
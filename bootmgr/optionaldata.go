@@ -0,0 +1,91 @@
+package bootmgr
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf16"
+
+	guid "github.com/google/uuid"
+)
+
+// windowsSignature starts the optional data of Windows Boot Manager
+// entries.
+var windowsSignature = []byte("WINDOWS\x00")
+
+// WindowsOptionalData is the optional data Windows writes to the entry
+// of its boot manager, which refers to the object in the BCD store the
+// boot manager reads its settings from.
+type WindowsOptionalData struct {
+	Revision uint32
+	// BCDObject is {9dea862c-5cdd-4e70-acc1-f32b344d4795} for the
+	// default Windows Boot Manager
+	BCDObject guid.UUID
+}
+
+// windowsObjectOffset is where the BCD object string starts in the
+// optional data of Windows Boot Manager entries.
+const windowsObjectOffset = 20
+
+// ParseWindowsOptionalData parses the optional data of a Windows Boot
+// Manager entry: the signature "WINDOWS", a revision, the length of the
+// whole structure, usually 0x88, and the offset of the data following
+// the UTF-16 string BCDOBJECT={GUID}, which starts right after these
+// fields at byte 20.
+func ParseWindowsOptionalData(b []byte) (*WindowsOptionalData, error) {
+	if len(b) < windowsObjectOffset || !bytes.HasPrefix(b, windowsSignature) {
+		return nil, fmt.Errorf("no Windows optional data: %w", ErrMalformed)
+	}
+	w := &WindowsOptionalData{Revision: binary.LittleEndian.Uint32(b[8:])}
+	// Compared as 64 bit numbers, as the length could overflow int on
+	// 32 bit systems.
+	length := binary.LittleEndian.Uint32(b[12:])
+	if uint64(length) > uint64(len(b)) || length < windowsObjectOffset {
+		return nil, fmt.Errorf("Windows optional data of %d bytes exceeds load option: %w", length, ErrMalformed)
+	}
+	s, _, _ := decodeUTF16(b[windowsObjectOffset:length])
+	id, ok := strings.CutPrefix(s, "BCDOBJECT=")
+	if !ok {
+		return nil, fmt.Errorf("Windows optional data without BCD object: %w", ErrMalformed)
+	}
+	var err error
+	if w.BCDObject, err = guid.Parse(strings.Trim(id, "{}")); err != nil {
+		return nil, fmt.Errorf("BCD object %q: %w", id, ErrMalformed)
+	}
+	return w, nil
+}
+
+// Arguments returns the optional data of o as text, if it is a NUL
+// terminated UTF-16 string as passed to loaders like the Linux EFI
+// stub, systemd-stub or shim. ok is false for binary data, which is
+// assumed if anything but padding follows the NUL.
+func (o *LoadOption) Arguments() (args string, ok bool) {
+	s, rest, terminated := decodeUTF16(o.OptionalData)
+	if !terminated || s == "" || len(bytes.Trim(rest, "\x00")) > 0 {
+		return "", false
+	}
+	for _, r := range s {
+		if !unicode.IsPrint(r) {
+			return "", false
+		}
+	}
+	return s, true
+}
+
+// decodeUTF16 decodes b up to the first NUL character and returns what
+// follows it, reporting whether there was one.
+func decodeUTF16(b []byte) (s string, rest []byte, terminated bool) {
+	var u []uint16
+	for len(b) >= 2 {
+		c := binary.LittleEndian.Uint16(b)
+		b = b[2:]
+		if c == 0 {
+			terminated = true
+			break
+		}
+		u = append(u, c)
+	}
+	return string(utf16.Decode(u)), b, terminated
+}
@@ -0,0 +1,63 @@
+package bootmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestOptionalData(t *testing.T) {
+	ami := New(efivarfs.Dir(filepath.Join(corpus, "ami-desktop")))
+	windows, err := ami.Entry(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(windows.OptionalData) != 0x88 {
+		t.Errorf("Windows optional data of %d bytes, want 0x88 as Windows writes it", len(windows.OptionalData))
+	}
+	w, err := ParseWindowsOptionalData(windows.OptionalData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Revision != 1 || w.BCDObject.String() != "9dea862c-5cdd-4e70-acc1-f32b344d4795" {
+		t.Errorf("ParseWindowsOptionalData() = %+v, want revision 1 of the default boot manager", w)
+	}
+	if _, ok := windows.Arguments(); ok {
+		t.Error("Windows optional data taken as arguments")
+	}
+
+	insyde := New(efivarfs.Dir(filepath.Join(corpus, "insyde-laptop")))
+	fwupd, err := insyde.Entry(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if args, ok := fwupd.Arguments(); !ok || args != `\fwupdx64.efi` {
+		t.Errorf("Arguments() = %q, %v, want the loader shim chains to", args, ok)
+	}
+	if _, err := ParseWindowsOptionalData(fwupd.OptionalData); err == nil {
+		t.Error("ParseWindowsOptionalData() accepted shim arguments")
+	}
+	// A length that is negative as 32 bit int mustn't pass the bounds
+	// check on 32 bit systems.
+	for _, length := range []uint32{0xffffffff, 0x89, 19} {
+		bad := slices.Clone(windows.OptionalData)
+		binary.LittleEndian.PutUint32(bad[12:], length)
+		if _, err := ParseWindowsOptionalData(bad); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseWindowsOptionalData() = %v with length 0x%x, want ErrMalformed", err, length)
+		}
+	}
+
+	for _, n := range []uint16{1, 2} {
+		o, err := ami.Entry(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if args, ok := o.Arguments(); ok {
+			t.Errorf("binary optional data of Boot%04X taken as arguments %q", n, args)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package bootmgr
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// ErrUnsupportedIndication is caused by requesting OS indications the
+// firmware doesn't list in OsIndicationsSupported
+var ErrUnsupportedIndication = errors.New("OS indication not supported by firmware")
+
+// OsIndications is the bitmask of OsIndications, which requests
+// features from the firmware on the next boot, and of
+// OsIndicationsSupported, which lists the features it supports.
+type OsIndications uint64
+
+// OS indications as defined by the UEFI specification, section 8.5.4
+const (
+	// BootToFWUI stops at the firmware setup on the next boot
+	BootToFWUI OsIndications = 1 << iota
+	// TimestampRevocation enables the dbt timestamp database
+	TimestampRevocation
+	// FileCapsuleDelivery processes capsules placed on the ESP
+	FileCapsuleDelivery
+	// FMPCapsule is set in OsIndicationsSupported if firmware
+	// management protocol capsules are supported
+	FMPCapsule
+	// CapsuleResultVar is set in OsIndicationsSupported if the result
+	// of capsule processing is reported in Capsule#### variables
+	CapsuleResultVar
+	// StartOSRecovery starts the OS recovery options of OsRecoveryOrder
+	StartOSRecovery
+	// StartPlatformRecovery starts the PlatformRecovery#### options
+	StartPlatformRecovery
+	// JSONConfigDataRefresh makes the firmware refresh its JSON
+	// configuration data
+	JSONConfigDataRefresh
+)
+
+// osIndicationNames are the names of the bits of OsIndications.
+var osIndicationNames = []string{
+	"BootToFWUI",
+	"TimestampRevocation",
+	"FileCapsuleDelivery",
+	"FMPCapsule",
+	"CapsuleResultVar",
+	"StartOSRecovery",
+	"StartPlatformRecovery",
+	"JSONConfigDataRefresh",
+}
+
+// Has reports whether all bits of flags are set in o.
+func (o OsIndications) Has(flags OsIndications) bool {
+	return o&flags == flags
+}
+
+// String returns the names of the set bits separated by "|", unknown
+// bits in hex.
+func (o OsIndications) String() string {
+	if o == 0 {
+		return "none"
+	}
+	var names []string
+	for i, name := range osIndicationNames {
+		if o&(1<<i) != 0 {
+			names = append(names, name)
+		}
+	}
+	if rest := o &^ (1<<len(osIndicationNames) - 1); rest != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint64(rest)))
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseOsIndications parses the content of OsIndications or
+// OsIndicationsSupported.
+func ParseOsIndications(data []byte) (OsIndications, error) {
+	if len(data) != 8 {
+		return 0, fmt.Errorf("OS indications of length %d: %w", len(data), ErrMalformed)
+	}
+	return OsIndications(binary.LittleEndian.Uint64(data)), nil
+}
+
+// OsIndicationsSupported returns the OS indications the firmware
+// supports, none if the variable doesn't exist.
+func (m *Manager) OsIndicationsSupported() (OsIndications, error) {
+	return m.osIndications("OsIndicationsSupported")
+}
+
+// OsIndications returns the OS indications requested for the next boot,
+// none if the variable doesn't exist.
+func (m *Manager) OsIndications() (OsIndications, error) {
+	return m.osIndications("OsIndications")
+}
+
+// UpdateOsIndications sets the bits of set and clears those of clear in
+// OsIndications, keeping all others as they are. Setting bits the
+// firmware doesn't support fails with ErrUnsupportedIndication.
+func (m *Manager) UpdateOsIndications(set, clear OsIndications) error {
+	supported, err := m.OsIndicationsSupported()
+	if err != nil {
+		return err
+	}
+	if missing := set &^ supported; missing != 0 {
+		return fmt.Errorf("%s: %w", missing, ErrUnsupportedIndication)
+	}
+	current, err := m.OsIndications()
+	if err != nil {
+		return err
+	}
+	updated := current&^clear | set
+	if updated == current {
+		return nil
+	}
+	return m.b.Set(desc("OsIndications"), Attributes, binary.LittleEndian.AppendUint64(nil, uint64(updated)))
+}
+
+func (m *Manager) osIndications(name string) (OsIndications, error) {
+	_, data, err := m.b.Get(desc(name))
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		return 0, nil
+	case err != nil:
+		return 0, err
+	}
+	o, err := ParseOsIndications(data)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", name, err)
+	}
+	return o, nil
+}
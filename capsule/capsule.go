@@ -0,0 +1,151 @@
+// Package capsule stages firmware update capsules for delivery on disk.
+// The firmware processes the capsules it finds in \EFI\UpdateCapsule of
+// the EFI System Partition on the next boot if FileCapsuleDelivery is
+// set in OsIndications, as described in section 8.5.5 of the UEFI
+// specification.
+package capsule
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Dir is the directory on the EFI System Partition holding capsules for
+// delivery on disk.
+const Dir = "EFI/UpdateCapsule"
+
+var (
+	// ErrNotSupported is caused by staging a capsule on firmware not
+	// listing FileCapsuleDelivery in OsIndicationsSupported
+	ErrNotSupported = errors.New("firmware does not support capsule delivery on disk")
+
+	// ErrMalformed is caused by capsules without a valid
+	// EFI_CAPSULE_HEADER
+	ErrMalformed = errors.New("malformed capsule")
+)
+
+// headerSize is the size of EFI_CAPSULE_HEADER: the capsule GUID and
+// the 32 bit header size, flags and image size.
+const headerSize = 16 + 4 + 4 + 4
+
+// Header is the EFI_CAPSULE_HEADER every capsule starts with.
+type Header struct {
+	// GUID identifies the type of the capsule
+	GUID guid.UUID
+	// HeaderSize is the size of the header including vendor data
+	HeaderSize uint32
+	// Flags define how the firmware processes the capsule
+	Flags uint32
+	// ImageSize is the size of the whole capsule including the header
+	ImageSize uint32
+}
+
+// ParseHeader parses the header of capsule and checks that its sizes are
+// consistent with the length of capsule.
+func ParseHeader(capsule []byte) (Header, error) {
+	if len(capsule) < headerSize {
+		return Header{}, fmt.Errorf("%d bytes: %w", len(capsule), ErrMalformed)
+	}
+	h := Header{
+		GUID:       efivarfs.DecodeGUID(capsule[:16]),
+		HeaderSize: binary.LittleEndian.Uint32(capsule[16:]),
+		Flags:      binary.LittleEndian.Uint32(capsule[20:]),
+		ImageSize:  binary.LittleEndian.Uint32(capsule[24:]),
+	}
+	switch {
+	case h.HeaderSize < headerSize || h.HeaderSize > h.ImageSize:
+		return Header{}, fmt.Errorf("header size %d: %w", h.HeaderSize, ErrMalformed)
+	case int64(h.ImageSize) != int64(len(capsule)):
+		return Header{}, fmt.Errorf("image size %d of %d byte capsule: %w", h.ImageSize, len(capsule), ErrMalformed)
+	}
+	return h, nil
+}
+
+// CheckSupport returns ErrNotSupported unless the firmware lists
+// FileCapsuleDelivery in OsIndicationsSupported.
+func CheckSupport(m *bootmgr.Manager) error {
+	supported, err := m.OsIndicationsSupported()
+	if err != nil {
+		return err
+	}
+	if !supported.Has(bootmgr.FileCapsuleDelivery) {
+		return ErrNotSupported
+	}
+	return nil
+}
+
+// Path returns the path capsules named name are staged at on the EFI
+// System Partition mounted at espRoot.
+func Path(espRoot, name string) string {
+	return filepath.Join(espRoot, filepath.FromSlash(Dir), name)
+}
+
+// Stage places capsule as name in Dir on the EFI System Partition
+// mounted at espRoot and sets FileCapsuleDelivery in OsIndications, so
+// that the firmware applies it on the next boot. It fails with
+// ErrNotSupported before writing anything if the firmware doesn't
+// support delivery on disk. The capsule is synced to disk before
+// OsIndications is set.
+func Stage(m *bootmgr.Manager, espRoot, name string, capsule []byte) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("invalid capsule file name %q", name)
+	}
+	if _, err := ParseHeader(capsule); err != nil {
+		return err
+	}
+	if err := CheckSupport(m); err != nil {
+		return err
+	}
+	path := Path(espRoot, name)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := writeFile(path, capsule); err != nil {
+		return err
+	}
+	return m.UpdateOsIndications(bootmgr.FileCapsuleDelivery, 0)
+}
+
+// writeFile writes data to path through a temporary file, which is
+// synced and renamed, so the firmware never finds a partial capsule.
+// The directory is synced as well, as FAT may lose the new entry on the
+// reboot applying the capsule otherwise.
+func writeFile(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), ".capsule-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,80 @@
+package capsule
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func testCapsule() []byte {
+	b := make([]byte, headerSize+4)
+	binary.LittleEndian.PutUint32(b[16:], headerSize)
+	binary.LittleEndian.PutUint32(b[24:], uint32(len(b)))
+	return b
+}
+
+func TestStage(t *testing.T) {
+	m := bootmgr.New(efivarfs.Dir(corpustest.Copy(t, "insyde-laptop")))
+	root := t.TempDir()
+	c := testCapsule()
+	if err := Stage(m, root, "fw.cap", c); err != nil {
+		t.Fatal(err)
+	}
+	b, err := os.ReadFile(filepath.Join(root, "EFI", "UpdateCapsule", "fw.cap"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(b, c) {
+		t.Errorf("staged %x, want %x", b, c)
+	}
+	ind, err := m.OsIndications()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ind.Has(bootmgr.FileCapsuleDelivery) {
+		t.Errorf("OsIndications = %v, want FileCapsuleDelivery set", ind)
+	}
+}
+
+func TestStageInvalidName(t *testing.T) {
+	m := bootmgr.New(efivarfs.Dir(corpustest.Copy(t, "insyde-laptop")))
+	root := t.TempDir()
+	for _, name := range []string{"", ".", "..", "../fw.cap", `..\fw.cap`} {
+		if err := Stage(m, root, name, testCapsule()); err == nil {
+			t.Errorf("Stage(%q) succeeded", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(root, "EFI")); !os.IsNotExist(err) {
+		t.Errorf("capsule directory created for invalid names")
+	}
+}
+
+func TestStageUnsupported(t *testing.T) {
+	m := bootmgr.New(efivarfs.Dir(corpustest.Copy(t, "ovmf")))
+	root := t.TempDir()
+	if err := Stage(m, root, "fw.cap", testCapsule()); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("Stage() = %v, want ErrNotSupported", err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "EFI")); !os.IsNotExist(err) {
+		t.Errorf("capsule directory created without support")
+	}
+}
+
+func TestParseHeader(t *testing.T) {
+	c := testCapsule()
+	if _, err := ParseHeader(c); err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range [][]byte{c[:10], c[:len(c)-1], append(c, 0)} {
+		if _, err := ParseHeader(b); !errors.Is(err, ErrMalformed) {
+			t.Errorf("ParseHeader(%x) = %v, want ErrMalformed", b, err)
+		}
+	}
+}
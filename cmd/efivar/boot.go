@@ -0,0 +1,295 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/devicepath"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/esp"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+// bootCommands are the subcommands of "efivar boot".
+var bootCommands = map[string]func(m *bootmgr.Manager, args []string) error{
+	"list":    bootList,
+	"create":  bootCreate,
+	"delete":  bootDelete,
+	"order":   bootOrder,
+	"next":    bootNext,
+	"active":  bootActive,
+	"timeout": bootTimeout,
+	"dedup":   bootDedup,
+}
+
+// boot implements "efivar boot", which manages boot entries with output
+// formatted like efibootmgr's.
+func boot(args []string) error {
+	fs := flag.NewFlagSet("boot", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show what would be written without modifying any variable")
+	fs.Parse(args)
+
+	args = fs.Args()
+	if len(args) == 0 || bootCommands[args[0]] == nil {
+		return errors.New("usage: efivar boot [-dry-run] list|create|delete|order|next|active|timeout|dedup")
+	}
+	b, err := efivarfs.Open(dryRunOptions(*dryRun)...)
+	if err != nil {
+		return err
+	}
+	return bootCommands[args[0]](bootmgr.New(b), args[1:])
+}
+
+// bootList prints the boot manager variables and all entries.
+func bootList(m *bootmgr.Manager, args []string) error {
+	fs := flag.NewFlagSet("boot list", flag.ExitOnError)
+	verbose := fs.Bool("v", false, "Show the optional data of each entry")
+	fs.Parse(args)
+
+	if n, ok, err := m.Current(); err != nil {
+		return err
+	} else if ok {
+		fmt.Printf("BootCurrent: %04X\n", n)
+	}
+	if n, ok, err := m.Next(); err != nil {
+		return err
+	} else if ok {
+		fmt.Printf("BootNext: %04X\n", n)
+	}
+	if t, ok, err := m.Timeout(); err != nil {
+		return err
+	} else if ok {
+		fmt.Printf("Timeout: %s\n", formatTimeout(t))
+	}
+	order, err := m.Order()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("BootOrder: %s\n", formatOrder(order))
+
+	entries, err := m.Entries()
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		active := " "
+		if e.Active() {
+			active = "*"
+		}
+		fmt.Printf("%s%s %s\t%s", bootmgr.BootName(e.Number), active, e.Description, e.FilePath)
+		if *verbose && len(e.OptionalData) > 0 {
+			fmt.Printf("%x", e.OptionalData)
+		}
+		fmt.Println()
+	}
+	return nil
+}
+
+// bootCreate adds an entry for a loader on an EFI System Partition.
+func bootCreate(m *bootmgr.Manager, args []string) error {
+	fs := flag.NewFlagSet("boot create", flag.ExitOnError)
+	label := fs.String("label", "Linux", "Description of the entry")
+	arch, ok := bootmgr.NativeArch()
+	if !ok {
+		arch = bootmgr.ArchX64
+	}
+	loader := fs.String("loader", arch.FallbackLoader(), "Path of the loader on the partition")
+	disk := fs.String("disk", "", "Disk holding the partition, defaults to the disk of the mounted ESP")
+	part := fs.Int("part", 1, "Number of the partition on -disk")
+	data := fs.String("data", "", "Optional data passed to the loader, e.g. a kernel command line")
+	fs.Parse(args)
+
+	var p gpt.Partition
+	if *disk == "" {
+		e, err := esp.Default()
+		if err != nil {
+			return err
+		}
+		p = e.Partition
+	} else {
+		t, err := gpt.ReadDevice(*disk)
+		if err != nil {
+			return err
+		}
+		var ok bool
+		if p, ok = t.Partition(*part); !ok {
+			return fmt.Errorf("%s has no partition %d", *disk, *part)
+		}
+	}
+	o := &bootmgr.LoadOption{
+		Attributes:  bootmgr.LoadOptionActive,
+		Description: *label,
+		FilePath:    devicepath.Path{devicepath.HardDrive(p), devicepath.File(*loader)},
+	}
+	if *data != "" {
+		o.OptionalData = utf16Data(*data)
+	}
+	n, err := m.Create(o)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Created %s\n", bootmgr.BootName(n))
+	return bootList(m, nil)
+}
+
+// bootDelete removes the given entries.
+func bootDelete(m *bootmgr.Manager, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: efivar boot delete NUMBER...")
+	}
+	for _, a := range args {
+		n, err := bootmgr.ParseNumber(a)
+		if err != nil {
+			return err
+		}
+		if err := m.Delete(n); err != nil {
+			return fmt.Errorf("%s: %w", bootmgr.BootName(n), err)
+		}
+	}
+	return bootList(m, nil)
+}
+
+// bootOrder replaces BootOrder with a comma separated list of numbers.
+func bootOrder(m *bootmgr.Manager, args []string) error {
+	if len(args) != 1 {
+		return errors.New("usage: efivar boot order NUMBER[,NUMBER...]")
+	}
+	var order []uint16
+	for _, a := range strings.Split(args[0], ",") {
+		n, err := bootmgr.ParseNumber(a)
+		if err != nil {
+			return err
+		}
+		order = append(order, n)
+	}
+	if err := m.SetOrder(order); err != nil {
+		return err
+	}
+	return bootList(m, nil)
+}
+
+// bootNext sets or, with -delete, removes BootNext.
+func bootNext(m *bootmgr.Manager, args []string) error {
+	fs := flag.NewFlagSet("boot next", flag.ExitOnError)
+	del := fs.Bool("delete", false, "Remove BootNext")
+	fs.Parse(args)
+
+	if *del {
+		if err := m.ClearNext(); err != nil {
+			return err
+		}
+		return bootList(m, nil)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: efivar boot next [-delete] NUMBER")
+	}
+	n, err := bootmgr.ParseNumber(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if err := m.SetNext(n); err != nil {
+		return err
+	}
+	return bootList(m, nil)
+}
+
+// bootActive marks entries active or, with -off, inactive.
+func bootActive(m *bootmgr.Manager, args []string) error {
+	fs := flag.NewFlagSet("boot active", flag.ExitOnError)
+	off := fs.Bool("off", false, "Mark the entries inactive")
+	fs.Parse(args)
+
+	if fs.NArg() == 0 {
+		return errors.New("usage: efivar boot active [-off] NUMBER...")
+	}
+	for _, a := range fs.Args() {
+		n, err := bootmgr.ParseNumber(a)
+		if err != nil {
+			return err
+		}
+		if err := m.SetActive(n, !*off); err != nil {
+			return fmt.Errorf("%s: %w", bootmgr.BootName(n), err)
+		}
+	}
+	return bootList(m, nil)
+}
+
+// bootTimeout sets or, with -delete, removes Timeout. The timeout is
+// given in seconds or as "none" to wait for input.
+func bootTimeout(m *bootmgr.Manager, args []string) error {
+	fs := flag.NewFlagSet("boot timeout", flag.ExitOnError)
+	del := fs.Bool("delete", false, "Remove Timeout to use the default of the firmware")
+	fs.Parse(args)
+
+	if *del {
+		if err := m.ClearTimeout(); err != nil {
+			return err
+		}
+		return bootList(m, nil)
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: efivar boot timeout [-delete] SECONDS|none")
+	}
+	seconds := bootmgr.NoTimeout
+	if fs.Arg(0) != "none" {
+		var err error
+		if seconds, err = strconv.Atoi(fs.Arg(0)); err != nil {
+			return fmt.Errorf("invalid timeout %q", fs.Arg(0))
+		}
+	}
+	if err := m.SetTimeout(seconds); err != nil {
+		return err
+	}
+	return bootList(m, nil)
+}
+
+// bootDedup removes entries duplicating others and updates BootOrder
+// and BootNext to refer to the remaining ones.
+func bootDedup(m *bootmgr.Manager, args []string) error {
+	if len(args) != 0 {
+		return errors.New("usage: efivar boot dedup")
+	}
+	dups, err := m.Coalesce()
+	if err != nil {
+		return err
+	}
+	for _, d := range dups {
+		for _, n := range d.Remove {
+			fmt.Printf("Removed %s, duplicate of %s\n", bootmgr.BootName(n), bootmgr.BootName(d.Keep))
+		}
+	}
+	return bootList(m, nil)
+}
+
+// formatTimeout formats a Timeout in seconds.
+func formatTimeout(seconds uint16) string {
+	if seconds == bootmgr.NoTimeout {
+		return "none, waiting for input"
+	}
+	return fmt.Sprintf("%d seconds", seconds)
+}
+
+// formatOrder formats boot numbers like efibootmgr, e.g. 0001,0000.
+func formatOrder(order []uint16) string {
+	s := make([]string, len(order))
+	for i, n := range order {
+		s[i] = fmt.Sprintf("%04X", n)
+	}
+	return strings.Join(s, ",")
+}
+
+// utf16Data encodes s as NUL terminated UTF-16 like efibootmgr does
+// with -u, which is what the EFI stub of Linux expects as command line.
+func utf16Data(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return append(b, 0, 0)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/capsule"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/esp"
+)
+
+// capsuleCmd implements "efivar capsule", which stages a firmware update
+// capsule for delivery on disk.
+func capsuleCmd(args []string) error {
+	fs := flag.NewFlagSet("capsule", flag.ExitOnError)
+	root := fs.String("esp", "", "Mount point of the EFI System Partition, defaults to the mounted ESP")
+	dryRun := fs.Bool("dry-run", false, "Show what would be written without modifying any file or variable")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: efivar capsule [-esp DIR] [-dry-run] FILE")
+	}
+	data, err := os.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	if *root == "" {
+		e, err := esp.Default()
+		if err != nil {
+			return err
+		}
+		if e.MountPoint == "" {
+			return fmt.Errorf("EFI System Partition %s is not mounted", e.Device)
+		}
+		*root = e.MountPoint
+	}
+	b, err := efivarfs.Open(dryRunOptions(*dryRun)...)
+	if err != nil {
+		return err
+	}
+	m := bootmgr.New(b)
+	name := filepath.Base(fs.Arg(0))
+	if !*dryRun {
+		if err := capsule.Stage(m, *root, name, data); err != nil {
+			return err
+		}
+		fmt.Printf("Staged %s, the firmware applies it on the next boot\n", capsule.Path(*root, name))
+		return nil
+	}
+	if _, err := capsule.ParseHeader(data); err != nil {
+		return err
+	}
+	if err := capsule.CheckSupport(m); err != nil {
+		return err
+	}
+	fmt.Printf("Would write %d bytes to %s\n", len(data), capsule.Path(*root, name))
+	return m.UpdateOsIndications(bootmgr.FileCapsuleDelivery, 0)
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/system-transparency/efivar/doctor"
+	"github.com/system-transparency/efivar/sysinfo"
+)
+
+// doctorCmd implements "efivar doctor", which checks the environment for
+// the usual reasons of failing to access variables.
+func doctorCmd(args []string) error {
+	if info, err := sysinfo.Read(); err == nil {
+		fmt.Printf("System: %s\n", info)
+	}
+	checks := doctor.Run()
+	for _, c := range checks {
+		fmt.Printf("[%4s] %s: %s\n", c.Status, c.Name, c.Detail)
+		if c.Hint != "" {
+			fmt.Printf("       %s\n", c.Hint)
+		}
+	}
+	if doctor.Failed(checks) {
+		return errors.New("efivars are not accessible")
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/enforce"
+	"github.com/system-transparency/efivar/watch"
+)
+
+// enforceCmd implements "efivar enforce", which compares the variables
+// against a golden configuration once or, with -watch, continuously.
+func enforceCmd(args []string) error {
+	fs := flag.NewFlagSet("enforce", flag.ExitOnError)
+	config := fs.String("config", "", "YAML or JSON file with the desired state of the variables")
+	watchVars := fs.Bool("watch", false, "Keep watching the variables for drift until interrupted")
+	remediate := fs.Bool("remediate", false, "Revert drift instead of only reporting it")
+	fs.Parse(args)
+
+	if *config == "" {
+		return errors.New("usage: efivar enforce -config FILE [-watch] [-remediate]")
+	}
+	c, err := enforce.LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+	b, err := efivarfs.Open()
+	if err != nil {
+		return err
+	}
+	// fix reports d and remediates it with -remediate, returning
+	// whether it was reverted.
+	fix := func(d enforce.Drift) bool {
+		if !*remediate || !d.Remediable() {
+			log.Printf("Drift: %s", &d)
+			return false
+		}
+		if err := enforce.Remediate(b, d); err != nil {
+			log.Printf("Drift: %s, remediation failed: %v", &d, err)
+			return false
+		}
+		log.Printf("Remediated: %s", &d)
+		return true
+	}
+
+	if !*watchVars {
+		drifts, err := enforce.Check(b, c)
+		if err != nil {
+			return err
+		}
+		left := 0
+		for _, d := range drifts {
+			if !fix(d) {
+				left++
+			}
+		}
+		if left > 0 {
+			return fmt.Errorf("%d variables drifted from %s", left, *config)
+		}
+		return nil
+	}
+	root, err := efivarfs.MountPoint()
+	if err != nil {
+		return err
+	}
+	w, err := watch.New(root)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	log.Printf("Enforcing %s", *config)
+	err = enforce.Watch(ctx, w, b, c, func(d enforce.Drift) error {
+		fix(d)
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
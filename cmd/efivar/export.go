@@ -0,0 +1,300 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/system-transparency/efivar/backup"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// export implements "efivar export", which saves variables to an
+// archive restorable with "efivar import".
+func export(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	all := fs.Bool("all", false, "Export all variables instead of the ones matching the given patterns")
+	out := fs.String("o", "", "Archive to write, compressed if ending in .gz or .tgz, - for stdout")
+	vendor := fs.String("guid", "", "GUID of the variables matched by the given patterns")
+	concurrency := fs.Int("concurrency", 4, "Number of variables read at once")
+	signKey := fs.String("sign-key", "", "PEM encoded Ed25519, ECDSA or RSA key to sign the archive with")
+	signCert := fs.String("sign-cert", "", "Certificate of -sign-key to include in the archive")
+	keepGoing := fs.Bool("keep-going", false, "Leave out variables that can't be read instead of failing")
+	progress := fs.Bool("progress", false, "Show the number of variables read so far on stderr")
+	fs.Parse(args)
+
+	if *out == "" || *all == (fs.NArg() > 0) {
+		return errors.New("usage: efivar export -o FILE -all | PATTERN...")
+	}
+	var opts []backup.Option
+	if *keepGoing {
+		opts = append(opts, backup.ContinueOnError())
+	}
+	if *progress {
+		opts = append(opts, backup.WithProgress(showProgress("Read")))
+		defer fmt.Fprintln(os.Stderr)
+	}
+	if *signKey != "" {
+		s, err := loadArchiveSigner(*signKey, *signCert)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, backup.WithSigner(s))
+	}
+	c, err := efivarfs.Open()
+	if err != nil {
+		return err
+	}
+	var descs []efivarfs.VariableDescriptor
+	if *all {
+		if descs, err = c.List(); err != nil {
+			return err
+		}
+	}
+	for _, p := range fs.Args() {
+		d, err := expand(c, p, *vendor)
+		if err != nil {
+			return err
+		}
+		descs = append(descs, d...)
+	}
+
+	write := func(w io.Writer) error {
+		if !strings.HasSuffix(*out, ".gz") && !strings.HasSuffix(*out, ".tgz") {
+			return backup.Export(context.Background(), c, descs, w, *concurrency, opts...)
+		}
+		zw := gzip.NewWriter(w)
+		if err := backup.Export(context.Background(), c, descs, zw, *concurrency, opts...); err != nil {
+			return err
+		}
+		return zw.Close()
+	}
+	if *out == "-" {
+		return write(os.Stdout)
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// importArchive implements "efivar import", which verifies an archive
+// written by "efivar export" and restores its variables.
+func importArchive(args []string) error {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "Show what would be written without modifying any variable")
+	keepGoing := fs.Bool("keep-going", false, "Restore the remaining variables if one can't be written")
+	progress := fs.Bool("progress", false, "Show the number of variables restored so far on stderr")
+	dir := fs.String("dir", "", "Write the variables to this directory, usable as snapshot with efivarfs.Dir, instead of the firmware")
+	verify := verifyFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: efivar import [-dry-run] [-keep-going] [-dir DIR] [-verify-key FILE|-verify-ca FILE] FILE")
+	}
+	opts, err := verify()
+	if err != nil {
+		return err
+	}
+	if *keepGoing {
+		opts = append(opts, backup.ContinueOnError())
+	}
+	if *progress {
+		opts = append(opts, backup.WithProgress(showProgress("Restored")))
+		defer fmt.Fprintln(os.Stderr)
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if *dir != "" {
+		return backup.Extract(f, *dir, opts...)
+	}
+	vars, err := backup.ReadArchive(f, opts...)
+	if err != nil {
+		return err
+	}
+	c, err := efivarfs.Open(dryRunOptions(*dryRun)...)
+	if err != nil {
+		return err
+	}
+	return backup.Restore(c, vars, opts...)
+}
+
+// diffArchive implements "efivar diff", which verifies an archive and
+// lists the variables importing it would write.
+func diffArchive(args []string) error {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	verify := verifyFlags(fs)
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("usage: efivar diff [-verify-key FILE|-verify-ca FILE] FILE")
+	}
+	opts, err := verify()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	a, err := backup.Read(f, opts...)
+	if err != nil {
+		return err
+	}
+	machine := "unknown system"
+	if a.Manifest.Machine != nil {
+		machine = a.Manifest.Machine.String()
+	}
+	fmt.Printf("Archive of %d variables created %s on %s\n", len(a.Variables), a.Manifest.Created.Format(time.RFC3339), machine)
+	c, err := efivarfs.Open()
+	if err != nil {
+		return err
+	}
+	diffs, err := backup.Diff(c, a.Variables)
+	if err != nil {
+		return err
+	}
+	for _, d := range diffs {
+		name := d.Desc.String()
+		switch {
+		case d.Missing:
+			fmt.Printf("missing %s\n", name)
+		case d.Attributes != d.Variable.Attributes:
+			fmt.Printf("changed %s: attributes %s, archive has %s\n", name, d.Attributes, d.Variable.Attributes)
+		default:
+			fmt.Printf("changed %s: %d bytes, archive has %d\n", name, len(d.Data), len(d.Variable.Data))
+		}
+	}
+	if len(diffs) == 0 {
+		fmt.Println("All variables match the archive")
+	}
+	return nil
+}
+
+// showProgress returns a progress callback overwriting a line on stderr
+// with the number of variables processed so far.
+func showProgress(verb string) func(efivarfs.Progress) {
+	return func(p efivarfs.Progress) {
+		fmt.Fprintf(os.Stderr, "\r%s %d/%d variables", verb, p.Done, p.Total)
+	}
+}
+
+// verifyFlags adds the flags selecting the keys trusted to sign archives
+// to fs. The returned function builds the options for backup.Read from
+// them once fs is parsed.
+func verifyFlags(fs *flag.FlagSet) func() ([]backup.Option, error) {
+	key := fs.String("verify-key", "", "Only accept archives signed by this PEM encoded public key or certificate")
+	ca := fs.String("verify-ca", "", "Only accept archives signed with a certificate issued by these PEM encoded CAs")
+	return func() ([]backup.Option, error) {
+		if *key == "" && *ca == "" {
+			return nil, nil
+		}
+		v := &backup.Verifier{}
+		if *key != "" {
+			pub, err := loadPublicKey(*key)
+			if err != nil {
+				return nil, err
+			}
+			v.Keys = append(v.Keys, pub)
+		}
+		if *ca != "" {
+			data, err := os.ReadFile(*ca)
+			if err != nil {
+				return nil, err
+			}
+			v.Roots = x509.NewCertPool()
+			if !v.Roots.AppendCertsFromPEM(data) {
+				return nil, fmt.Errorf("%s: no PEM encoded certificates found", *ca)
+			}
+		}
+		return []backup.Option{backup.WithVerifier(v)}, nil
+	}
+}
+
+// loadArchiveSigner reads a PEM encoded private key and, if certPath is
+// given, its certificate.
+func loadArchiveSigner(keyPath, certPath string) (*backup.Signer, error) {
+	block, err := readPEM(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	var key any
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("%s: unsupported key type %T", keyPath, key)
+	}
+	s := &backup.Signer{Key: signer}
+	if certPath != "" {
+		block, err := readPEM(certPath)
+		if err != nil {
+			return nil, err
+		}
+		if s.Certificate, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, fmt.Errorf("%s: %w", certPath, err)
+		}
+	}
+	return s, nil
+}
+
+// loadPublicKey reads a PEM encoded public key or the key of a PEM
+// encoded certificate.
+func loadPublicKey(path string) (crypto.PublicKey, error) {
+	block, err := readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cert.PublicKey, nil
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return pub, nil
+}
+
+// readPEM returns the first PEM block of the file at path.
+func readPEM(path string) (*pem.Block, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+	return block, nil
+}
@@ -0,0 +1,301 @@
+// Efivar lists, reads, writes and deletes EFI variables and manages
+// boot entries and Secure Boot keys using the packages of this repo.
+// It is pure Go and only uses its own flag sets, so it can be built
+// standalone with go build or embedded into a u-root busybox.
+//
+// The file referenced by -content was tested with different
+// amounts of text data in it only.
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/pretty"
+)
+
+// commands are the subcommands, e.g. efivar serve. Without one of them
+// the flags defined in main are used.
+var commands = map[string]func(args []string) error{
+	"serve":   serve,
+	"sb":      sb,
+	"boot":    boot,
+	"export":  export,
+	"import":  importArchive,
+	"diff":    diffArchive,
+	"doctor":  doctorCmd,
+	"capsule": capsuleCmd,
+	"enforce": enforceCmd,
+}
+
+func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := commands[os.Args[1]]; ok {
+			if err := cmd(os.Args[2:]); err != nil {
+				log.Fatalf("Operation failed: %v%s", err, hint(err))
+			}
+			return
+		}
+	}
+	fs := flag.NewFlagSet("efivar", flag.ExitOnError)
+	flist := fs.Bool("list", false, "List all efivars")
+	fread := fs.String("read", "", "Read specified efivar. Variable must be of form -read Name-UUID or a pattern like -read 'Boot*'")
+	fdelete := fs.String("delete", "", "Delete specified efivar. Variable must be of form -delete Name-UUID or a pattern like -delete 'Boot00*-UUID'")
+	fwrite := fs.String("write", "", "Write to specified efivar. Variable must be of form -write Name-UUID OR Name\n"+
+		"In the later case the UUID of well known variables is used, for others a UUID is being generated\n"+
+		"This command is used with -content to specify the data being written to the efivar.")
+	fcontent := fs.String("content", "", "Path to file to write to efivar. Used with -write e.g. -write Foo -content bar.json")
+	fverbose := fs.Bool("verbose", false, "Show size, attributes and a summary of the content for each efivar listed with -list")
+	fpretty := fs.Bool("pretty", false, "Show the content of known variables read with -read in human readable form")
+	fformat := fs.String("format", "", "Print efivars read with -read as source code for test fixtures: c for a C header or go for a Go []byte literal")
+	fguid := fs.String("guid", "", "GUID of the efivars matched by a pattern given to -read or -delete, e.g. -delete 'Boot00*' -guid 8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	fyes := fs.Bool("yes", false, "Delete all efivars matched by a pattern without asking for confirmation")
+	fmount := fs.Bool("mount", false, "Mount efivarfs or remount it read-write if needed")
+	fdryrun := fs.Bool("dry-run", false, "Show what -write and -delete would change without modifying any efivar")
+	fsort := fs.String("sort", "name", "Order of the efivars listed with -list: name, guid, size or none")
+	fguidFirst := fs.Bool("guid-first", false, "Print efivars as GUID-Name like the tools of the C efivar library instead of Name-GUID")
+	fs.Parse(os.Args[1:])
+
+	if *fguidFirst {
+		nameOrder = efivarfs.GUIDFirst
+	}
+
+	order, ok := sortOrders[*fsort]
+	if !ok {
+		log.Fatalf("Unknown sort order %q", *fsort)
+	}
+	format, ok := sourceFormats[*fformat]
+	if !ok && *fformat != "" {
+		log.Fatalf("Unknown format %q", *fformat)
+	}
+	if err := run(*flist, *fread, *fdelete, *fwrite, *fcontent, *fguid, *fpretty, *fverbose, *fyes, *fdryrun, *fmount, order, format); err != nil {
+		log.Fatalf("Operation failed: %v%s", err, hint(err))
+	}
+}
+
+// hint returns advice for errors caused by the environment rather than
+// by the arguments, to be appended to the error message.
+func hint(err error) string {
+	switch {
+	case errors.Is(err, efivarfs.ErrReadOnlyFilesystem):
+		return "\nefivarfs has to be remounted read-write first, which -mount does, see efivar doctor."
+	case errors.Is(err, efivarfs.ErrVarPermission), errors.Is(err, efivarfs.ErrFsNotMounted):
+		return "\nSee efivar doctor for what is missing."
+	}
+	return ""
+}
+
+// nameOrder is the order of name and GUID efivars are printed in,
+// selected with -guid-first. Both are accepted as arguments.
+var nameOrder = efivarfs.NameFirst
+
+// varName returns how desc is printed.
+func varName(desc efivarfs.VariableDescriptor) string {
+	return efivarfs.FormatDescriptor(desc, nameOrder)
+}
+
+// sortOrders maps the values of -sort to the order of efivarfs.Client.List.
+var sortOrders = map[string]efivarfs.SortOrder{
+	"name": efivarfs.SortByName,
+	"guid": efivarfs.SortByGUID,
+	"size": efivarfs.SortBySize,
+	"none": efivarfs.Unsorted,
+}
+
+// sourceFormats maps the values of -format to the functions rendering
+// efivars as source code.
+var sourceFormats = map[string]func(efivarfs.VariableDescriptor, efivarfs.VariableAttributes, []byte) string{
+	"c":  pretty.C,
+	"go": pretty.Go,
+}
+
+func run(list bool, read, delete, write, content, vendor string, prettify, verbose, yes, dryRun, mount bool, order efivarfs.SortOrder, format func(efivarfs.VariableDescriptor, efivarfs.VariableAttributes, []byte) string) error {
+	if !list && read == "" && delete == "" && write == "" {
+		return nil
+	}
+	opts := append(dryRunOptions(dryRun), efivarfs.WithSortOrder(order), efivarfs.WithReadOnlyCheck())
+	if mount {
+		opts = append(opts, efivarfs.WithMount())
+	}
+	c, err := efivarfs.Open(opts...)
+	if err != nil {
+		return err
+	}
+
+	if list && verbose {
+		if err := listVerbose(c); err != nil {
+			return fmt.Errorf("list failed: %w", err)
+		}
+	} else if list {
+		if err := listNames(c); err != nil {
+			return fmt.Errorf("list failed: %w", err)
+		}
+	}
+
+	if read != "" {
+		descs, err := expand(c, read, vendor)
+		if err != nil {
+			return fmt.Errorf("read failed: %w", err)
+		}
+		for _, desc := range descs {
+			attr, b, err := c.Get(desc)
+			if err != nil {
+				return fmt.Errorf("read failed: %w", err)
+			}
+			name := varName(desc)
+			if format != nil {
+				fmt.Println(format(desc, attr, b))
+			} else if prettify {
+				s, err := pretty.Render(desc, attr, b)
+				if err != nil {
+					return err
+				}
+				log.Printf("Name: %s, Attributes: %d, Data:\n%s", name, attr, s)
+			} else {
+				log.Printf("Name: %s, Attributes: %d, Data: %s", name, attr, b)
+			}
+		}
+	}
+
+	if delete != "" {
+		descs, err := expand(c, delete, vendor)
+		if err != nil {
+			return fmt.Errorf("delete failed: %w", err)
+		}
+		if efivarfs.IsGlob(delete) && !yes && !dryRun && !confirm(descs) {
+			return errors.New("delete aborted")
+		}
+		for _, desc := range descs {
+			if err := c.Remove(desc); err != nil {
+				return fmt.Errorf("delete failed: %w", err)
+			}
+		}
+	}
+
+	if write != "" {
+		if strings.ContainsAny(write, "-") {
+			if _, err := efivarfs.ParseDescriptor(write); err != nil {
+				return fmt.Errorf("var name malformed: Must be either Name-GUID, GUID-Name or just Name")
+			}
+		}
+		path, err := filepath.Abs(content)
+		if err != nil {
+			return fmt.Errorf("could not resolve path: %v", err)
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %v", err)
+		}
+		if !strings.ContainsAny(write, "-") {
+			if _, err := efivarfs.LookupGUID(write); err != nil {
+				write = write + "-" + guid.New().String()
+			}
+		}
+		desc, err := efivarfs.ParseDescriptor(write)
+		if err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		if err = c.Set(desc, 7, b); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+	}
+	return nil
+}
+
+// expand returns the efivars named by arg, which is either Name-GUID, the
+// name of a well known efivar or a pattern matched with efivarfs.Glob.
+// vendor is the GUID given with -guid, if any.
+func expand(c *efivarfs.Client, arg, vendor string) ([]efivarfs.VariableDescriptor, error) {
+	if vendor != "" {
+		arg += "-" + vendor
+	}
+	if !efivarfs.IsGlob(arg) {
+		desc, err := efivarfs.ParseDescriptor(arg)
+		if err != nil {
+			return nil, err
+		}
+		return []efivarfs.VariableDescriptor{desc}, nil
+	}
+	descs, err := efivarfs.Glob(c, arg)
+	if err != nil {
+		return nil, err
+	}
+	if len(descs) == 0 {
+		return nil, fmt.Errorf("no efivar matches %s", arg)
+	}
+	return descs, nil
+}
+
+// confirm lists descs and asks whether to delete them.
+func confirm(descs []efivarfs.VariableDescriptor) bool {
+	for _, desc := range descs {
+		fmt.Println(varName(desc))
+	}
+	fmt.Printf("Delete these %d efivars? [y/N] ", len(descs))
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.TrimSpace(answer)
+	return answer == "y" || answer == "Y" || answer == "yes"
+}
+
+// dryRunOptions returns the client options for -dry-run, which print
+// the changes instead of making them.
+func dryRunOptions(dryRun bool) []efivarfs.Option {
+	if !dryRun {
+		return nil
+	}
+	return []efivarfs.Option{efivarfs.WithDryRun(func(c efivarfs.Change) {
+		fmt.Println("Would", c)
+	})}
+}
+
+// listPageSize is the number of efivars listed at once with -sort none.
+const listPageSize = 256
+
+// listNames logs the name of each efivar. Unsorted they are read in
+// pages, which keeps the memory bounded on huge stores.
+func listNames(c *efivarfs.Client) error {
+	r, err := c.OpenList()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	for {
+		descs, err := r.Read(listPageSize)
+		for _, desc := range descs {
+			log.Print(varName(desc))
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// listVerbose logs each efivar with its size, attributes and a summary of
+// its content.
+func listVerbose(c *efivarfs.Client) error {
+	descs, err := c.List()
+	if err != nil {
+		return err
+	}
+	for _, desc := range descs {
+		attrs, data, err := c.Get(desc)
+		if err != nil {
+			log.Printf("%s: %v", varName(desc), err)
+			continue
+		}
+		log.Printf("%s %6d %-14s %s", varName(desc), len(data), attrs, pretty.Summary(desc, attrs, data))
+	}
+	return nil
+}
@@ -0,0 +1,514 @@
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/secureboot"
+)
+
+// sbCommands are the subcommands of "efivar sb".
+var sbCommands = map[string]func(args []string) error{
+	"status":           sbStatus,
+	"enroll":           sbEnroll,
+	"append-db":        func(args []string) error { return sbAppend(secureboot.DB, args) },
+	"append-dbx":       func(args []string) error { return sbAppend(secureboot.DBX, args) },
+	"export":           sbExport,
+	"reset":            sbReset,
+	"restore-defaults": sbRestoreDefaults,
+	"keygen":           sbKeygen,
+}
+
+// sb implements "efivar sb", which inspects and modifies the Secure Boot
+// configuration.
+func sb(args []string) error {
+	if len(args) == 0 || sbCommands[args[0]] == nil {
+		return errors.New("usage: efivar sb status|enroll|append-db|append-dbx|export|reset|restore-defaults|keygen")
+	}
+	return sbCommands[args[0]](args[1:])
+}
+
+// sbFlags are the flags shared by all sb subcommands.
+type sbFlags struct {
+	*flag.FlagSet
+	dryRun   bool
+	yes      bool
+	signCert string
+	signKey  string
+	kekCert  string
+	kekKey   string
+	format   string
+}
+
+// pkcs7Formats are the values of -pkcs7-format.
+var pkcs7Formats = map[string]secureboot.PKCS7Format{
+	"spec":    secureboot.FormatSpec,
+	"openssl": secureboot.FormatOpenSSL,
+}
+
+// newSBFlags returns the flags of an sb subcommand. destructive adds -yes
+// and signed the flags of the signing key.
+func newSBFlags(name string, destructive, signed bool) *sbFlags {
+	f := &sbFlags{FlagSet: flag.NewFlagSet("sb "+name, flag.ExitOnError)}
+	f.BoolVar(&f.dryRun, "dry-run", false, "Show what would be written without modifying any variable")
+	if destructive {
+		f.BoolVar(&f.yes, "yes", false, "Confirm the operation, which may make the system unbootable")
+	}
+	if signed {
+		f.StringVar(&f.signCert, "sign-cert", "", "Certificate of the key signing the update, not needed in Setup Mode")
+		f.StringVar(&f.signKey, "sign-key", "", "RSA key signing the update")
+		f.formatFlag()
+	}
+	return f
+}
+
+// kekFlags adds the flags of the KEK signing updates of db and dbx.
+func (f *sbFlags) kekFlags() {
+	f.StringVar(&f.kekCert, "kek-cert", "", "Certificate of a KEK signing updates of db and dbx, not needed in Setup Mode")
+	f.StringVar(&f.kekKey, "kek-key", "", "RSA key of -kek-cert")
+}
+
+// formatFlag adds -pkcs7-format selecting the encoding of signatures.
+func (f *sbFlags) formatFlag() {
+	f.StringVar(&f.format, "pkcs7-format", "spec", "Encoding of signatures, spec or openssl for firmware only accepting what OpenSSL creates")
+}
+
+// withFormat sets the format given by -pkcs7-format on s.
+func (f *sbFlags) withFormat(s *secureboot.Signer) (*secureboot.Signer, error) {
+	format, ok := pkcs7Formats[f.format]
+	if !ok {
+		return nil, fmt.Errorf("unknown -pkcs7-format %q, use spec or openssl", f.format)
+	}
+	s.Format = format
+	return s, nil
+}
+
+// backend returns the backend to modify variables through, which only
+// reports the changes for -dry-run. Destructive commands fail without
+// -yes unless it is a dry run.
+func (f *sbFlags) backend(destructive bool) (efivarfs.Backend, error) {
+	if destructive && !f.yes && !f.dryRun {
+		return nil, fmt.Errorf("%s is destructive, confirm with -yes or check with -dry-run first", f.Name())
+	}
+	c, err := efivarfs.Open(dryRunOptions(f.dryRun)...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// signer loads the signing key given by -sign-cert and -sign-key.
+func (f *sbFlags) signer() (*secureboot.Signer, error) {
+	if f.signCert == "" && f.signKey == "" {
+		return nil, nil
+	}
+	s, err := loadSigner(f.signCert, f.signKey)
+	if err != nil {
+		return nil, err
+	}
+	return f.withFormat(s)
+}
+
+// kekSigner loads the signing key given by -kek-cert and -kek-key.
+func (f *sbFlags) kekSigner() (*secureboot.Signer, error) {
+	if f.kekCert == "" && f.kekKey == "" {
+		return nil, nil
+	}
+	s, err := loadSigner(f.kekCert, f.kekKey)
+	if err != nil {
+		return nil, err
+	}
+	return f.withFormat(s)
+}
+
+// sbStatus prints the Secure Boot state and the enrolled keys.
+func sbStatus(args []string) error {
+	f := newSBFlags("status", false, false)
+	f.Parse(args)
+
+	b, err := efivarfs.Open()
+	if err != nil {
+		return err
+	}
+	s, err := secureboot.ReadStatus(b)
+	if err != nil {
+		return err
+	}
+	onOff := map[bool]string{false: "disabled", true: "enabled"}
+	fmt.Printf("SecureBoot: %s\n", onOff[s.SecureBoot])
+	fmt.Printf("SetupMode: %s\n", onOff[s.SetupMode])
+	fmt.Printf("AuditMode: %s\n", onOff[s.AuditMode])
+	fmt.Printf("DeployedMode: %s\n", onOff[s.DeployedMode])
+	caps, err := b.Capabilities()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Kernel lockdown: %s\n", caps.Lockdown)
+	if caps.PlatformSize != 0 {
+		fmt.Printf("Firmware: %d bit, UEFI %s\n", caps.PlatformSize, caps.Revision)
+	}
+	if !caps.RuntimeServices.SetVariable {
+		fmt.Println("SetVariable: not supported at runtime, variables are read-only")
+	}
+	if types, err := secureboot.ReadSignatureTypes(b); err != nil {
+		return err
+	} else if types != nil {
+		fmt.Printf("Supported signature types: %s\n", types)
+	}
+	if l, err := secureboot.ReadSBATLevel(b); err != nil {
+		return err
+	} else if l != nil {
+		fmt.Printf("SBAT level: %s\n", l.Datestamp)
+	}
+	for _, d := range []struct {
+		name string
+		db   secureboot.SignatureDatabase
+	}{{"PK", s.PK}, {"KEK", s.KEK}, {"db", s.DB}, {"dbx", s.DBX}} {
+		certs, err := d.db.Certificates()
+		if err != nil {
+			return fmt.Errorf("%s: %w", d.name, err)
+		}
+		hashes := d.db.Hashes(secureboot.CertSHA256GUID)
+		fmt.Printf("%s: %d certificates, %d SHA-256 hashes\n", d.name, len(certs), len(hashes))
+		for _, c := range certs {
+			fmt.Printf("  %s\n", c.Subject)
+		}
+	}
+	return nil
+}
+
+// sbEnroll enrolls PK, KEK and db on a platform in Setup Mode. PK is
+// written last and signed with its own key, which ends Setup Mode.
+func sbEnroll(args []string) error {
+	f := newSBFlags("enroll", true, false)
+	f.formatFlag()
+	pk := f.String("pk", "", "Certificate to enroll as PK")
+	pkKey := f.String("pk-key", "", "RSA key of -pk, signing its enrollment")
+	kek := f.String("kek", "", "Certificates to enroll as KEK")
+	db := f.String("db", "", "Certificates to enroll as db")
+	owner := f.String("owner", guid.Nil.String(), "GUID identifying the owner of the keys")
+	f.Parse(args)
+
+	if *pk == "" || *pkKey == "" {
+		return errors.New("enroll: -pk and -pk-key are required")
+	}
+	ownerGUID, err := efivarfs.ParseGUID(*owner)
+	if err != nil {
+		return err
+	}
+	b, err := f.backend(true)
+	if err != nil {
+		return err
+	}
+	s, err := secureboot.ReadStatus(b)
+	if err != nil {
+		return err
+	}
+	if !s.SetupMode {
+		return errors.New("enroll: platform is not in Setup Mode, use sb reset first")
+	}
+	pkSigner, err := loadSigner(*pk, *pkKey)
+	if err != nil {
+		return err
+	}
+	if pkSigner, err = f.withFormat(pkSigner); err != nil {
+		return err
+	}
+
+	for _, e := range []struct {
+		desc  efivarfs.VariableDescriptor
+		certs string
+	}{{secureboot.DB, *db}, {secureboot.KEK, *kek}} {
+		if e.certs == "" {
+			continue
+		}
+		data, err := readCertificates(ownerGUID, e.certs)
+		if err != nil {
+			return err
+		}
+		if err := secureboot.Write(b, e.desc, data, nil); err != nil {
+			return fmt.Errorf("writing %s: %w", e.desc.Name, err)
+		}
+	}
+	pkDB := secureboot.SignatureDatabase{secureboot.NewX509SignatureList(ownerGUID, pkSigner.Certificate)}
+	if err := secureboot.Write(b, secureboot.PK, pkDB, pkSigner); err != nil {
+		return fmt.Errorf("writing PK: %w", err)
+	}
+	return nil
+}
+
+// sbAppend adds certificates, hashes or a signed .auth file to db or dbx.
+func sbAppend(desc efivarfs.VariableDescriptor, args []string) error {
+	destructive := desc.Equal(secureboot.DBX)
+	f := newSBFlags("append-"+desc.Name, destructive, true)
+	certs := f.String("cert", "", "Certificates to add")
+	hash := f.String("hash", "", "Hex encoded SHA-256 hash to add")
+	image := f.String("image", "", "EFI image whose Authenticode hash to add")
+	auth := f.String("auth", "", "Signed EFI_VARIABLE_AUTHENTICATION_2 payload to append as is, e.g. a dbx update")
+	owner := f.String("owner", guid.Nil.String(), "GUID identifying the owner of the signatures")
+	f.Parse(args)
+
+	b, err := f.backend(destructive)
+	if err != nil {
+		return err
+	}
+	if *auth != "" {
+		payload, err := os.ReadFile(*auth)
+		if err != nil {
+			return err
+		}
+		return secureboot.WriteAuthPayload(b, desc, payload, true)
+	}
+
+	ownerGUID, err := efivarfs.ParseGUID(*owner)
+	if err != nil {
+		return err
+	}
+	var db secureboot.SignatureDatabase
+	switch {
+	case *certs != "":
+		db, err = readCertificates(ownerGUID, *certs)
+		if err != nil {
+			return err
+		}
+	case *hash != "":
+		h, err := hex.DecodeString(*hash)
+		if err != nil || len(h) != sha256.Size {
+			return fmt.Errorf("-hash must be %d hex encoded bytes", sha256.Size)
+		}
+		db = secureboot.SignatureDatabase{secureboot.NewSHA256SignatureList(ownerGUID, [sha256.Size]byte(h))}
+	case *image != "":
+		data, err := os.ReadFile(*image)
+		if err != nil {
+			return err
+		}
+		v, err := secureboot.CheckImage(data, nil, nil)
+		if err != nil {
+			return err
+		}
+		db = secureboot.SignatureDatabase{secureboot.NewSHA256SignatureList(ownerGUID, [sha256.Size]byte(v.Hash))}
+	default:
+		return errors.New("one of -cert, -hash, -image or -auth is required")
+	}
+	signer, err := f.signer()
+	if err != nil {
+		return err
+	}
+	return secureboot.Append(b, desc, db, signer)
+}
+
+// sbExport saves PK, KEK, db and dbx as signature list files.
+func sbExport(args []string) error {
+	f := newSBFlags("export", false, false)
+	dir := f.String("o", ".", "Directory to write PK.esl, KEK.esl, db.esl and dbx.esl to")
+	f.Parse(args)
+
+	b, err := efivarfs.Open()
+	if err != nil {
+		return err
+	}
+	for _, desc := range []efivarfs.VariableDescriptor{secureboot.PK, secureboot.KEK, secureboot.DB, secureboot.DBX} {
+		_, data, err := b.Get(desc)
+		if errors.Is(err, efivarfs.ErrVarNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", desc.Name, err)
+		}
+		path := filepath.Join(*dir, desc.Name+".esl")
+		if f.dryRun {
+			fmt.Printf("Would write %d bytes to %s\n", len(data), path)
+			continue
+		}
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sbReset removes PK and optionally the other databases.
+func sbReset(args []string) error {
+	f := newSBFlags("reset", true, true)
+	var opts secureboot.ResetOptions
+	f.BoolVar(&opts.ClearKEK, "clear-kek", false, "Remove KEK as well")
+	f.BoolVar(&opts.ClearDB, "clear-db", false, "Remove db as well")
+	f.BoolVar(&opts.ClearDBX, "clear-dbx", false, "Remove dbx as well")
+	f.kekFlags()
+	f.Parse(args)
+
+	b, err := f.backend(true)
+	if err != nil {
+		return err
+	}
+	opts.Backend = b
+	if opts.Signer, err = f.signer(); err != nil {
+		return err
+	}
+	if opts.KEKSigner, err = f.kekSigner(); err != nil {
+		return err
+	}
+	return secureboot.Reset(opts)
+}
+
+// sbDatabases maps the names accepted by -only to the databases.
+var sbDatabases = map[string]efivarfs.VariableDescriptor{
+	"pk":  secureboot.PK,
+	"kek": secureboot.KEK,
+	"db":  secureboot.DB,
+	"dbx": secureboot.DBX,
+}
+
+// sbRestoreDefaults re-enrolls the keys the platform shipped with.
+func sbRestoreDefaults(args []string) error {
+	f := newSBFlags("restore-defaults", true, true)
+	only := f.String("only", "", "Comma separated databases to restore, e.g. db,dbx, instead of all of pk, kek, db and dbx")
+	f.kekFlags()
+	f.Parse(args)
+
+	var opts secureboot.RestoreOptions
+	if *only != "" {
+		for _, name := range strings.Split(*only, ",") {
+			desc, ok := sbDatabases[strings.ToLower(strings.TrimSpace(name))]
+			if !ok {
+				return fmt.Errorf("restore-defaults: unknown database %q", name)
+			}
+			opts.Databases = append(opts.Databases, desc)
+		}
+	}
+	b, err := f.backend(true)
+	if err != nil {
+		return err
+	}
+	opts.Backend = b
+	if opts.Signer, err = f.signer(); err != nil {
+		return err
+	}
+	if opts.KEKSigner, err = f.kekSigner(); err != nil {
+		return err
+	}
+	restored, err := secureboot.RestoreDefaults(opts)
+	for _, desc := range restored {
+		fmt.Printf("Restored %s\n", desc.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if len(restored) == 0 {
+		fmt.Println("The firmware provides no default keys to restore")
+	}
+	return nil
+}
+
+// sbKeygen generates a key and self-signed certificate for PK, KEK or db.
+func sbKeygen(args []string) error {
+	f := newSBFlags("keygen", false, false)
+	cn := f.String("cn", "", "Common name of the certificate, e.g. \"Example PK\"")
+	org := f.String("org", "", "Organization of the certificate")
+	bits := f.Int("bits", 2048, "Size of the RSA key, 2048 or 3072")
+	years := f.Int("years", 10, "Years the certificate is valid")
+	out := f.String("o", "", "Path prefix of the written files, e.g. PK for PK.key, PK.crt and PK.cer")
+	f.Parse(args)
+	if *cn == "" || *out == "" {
+		return errors.New("keygen: -cn and -o are required")
+	}
+
+	s, err := secureboot.GenerateSigner(secureboot.KeyOptions{
+		CommonName:   *cn,
+		Organization: *org,
+		Bits:         *bits,
+		Validity:     time.Duration(*years) * 365 * 24 * time.Hour,
+	})
+	if err != nil {
+		return err
+	}
+	certPEM, keyPEM, err := s.MarshalPEM()
+	if err != nil {
+		return err
+	}
+	for _, o := range []struct {
+		ext  string
+		data []byte
+		perm os.FileMode
+	}{
+		{".key", keyPEM, 0600},
+		{".crt", certPEM, 0644},
+		{".cer", s.Certificate.Raw, 0644},
+	} {
+		path := *out + o.ext
+		if f.dryRun {
+			fmt.Printf("Would write %d bytes to %s\n", len(o.data), path)
+			continue
+		}
+		// Never overwrite keys that may already be enrolled.
+		file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, o.perm)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(o.data); err != nil {
+			file.Close()
+			return err
+		}
+		if err := file.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readCertificates builds a signature database from the PEM or DER
+// certificates in path.
+func readCertificates(owner guid.UUID, path string) (secureboot.SignatureDatabase, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return secureboot.NewCertificateDatabase(owner, data)
+}
+
+// loadSigner reads a certificate and the matching PEM encoded RSA key.
+func loadSigner(certPath, keyPath string) (*secureboot.Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	certs, err := secureboot.ParseCertificates(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) != 1 {
+		return nil, fmt.Errorf("%s: expected one certificate, found %d", certPath, len(certs))
+	}
+	data, err = os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM encoded key found", keyPath)
+	}
+	var key *rsa.PrivateKey
+	if k, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		key = k
+	} else if k, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = k.(*rsa.PrivateKey); !ok {
+			return nil, fmt.Errorf("%s: only RSA keys are supported", keyPath)
+		}
+	} else {
+		return nil, fmt.Errorf("%s: %v", keyPath, err)
+	}
+	return &secureboot.Signer{Certificate: certs[0], Key: key}, nil
+}
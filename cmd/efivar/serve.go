@@ -0,0 +1,120 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/system-transparency/efivar/dbusservice"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/rest"
+	"github.com/system-transparency/efivar/watch"
+)
+
+// serve implements "efivar serve", which exposes the variables of this
+// machine over an authenticated REST API or as D-Bus service.
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("http", "", "Address to serve the REST API on, e.g. :8080")
+	tokenFile := fs.String("token-file", "", "File containing the bearer token clients must present.\n"+
+		"Alternatively the token can be set using the EFIVAR_TOKEN environment variable")
+	cert := fs.String("tls-cert", "", "TLS certificate, serving plain HTTP if not set, which is only\n"+
+		"allowed on loopback addresses, e.g. localhost:8080")
+	key := fs.String("tls-key", "", "TLS private key belonging to -tls-cert")
+	readOnly := fs.Bool("read-only", false, "Reject all writes and deletes")
+	useDBus := fs.Bool("dbus", false, "Provide the "+dbusservice.BusName+" service on the system bus")
+	debug := fs.Bool("debug", false, "Log every variable access to stderr")
+	mount := fs.Bool("mount", false, "Mount efivarfs or remount it read-write if needed")
+	fs.Parse(args)
+
+	if *addr == "" && !*useDBus {
+		return errors.New("serve: either -http or -dbus is required")
+	}
+	if *addr != "" && *cert == "" && !loopback(*addr) {
+		return fmt.Errorf("serve: refusing to send the token over plain HTTP on %s, use -tls-cert or a loopback address", *addr)
+	}
+	var opts []efivarfs.Option
+	if *debug {
+		h := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug})
+		opts = append(opts, efivarfs.WithLogger(slog.New(h)))
+	}
+	if *mount {
+		opts = append(opts, efivarfs.WithMount())
+	}
+	var b efivarfs.Backend
+	b, err := efivarfs.Open(opts...)
+	if err != nil {
+		return err
+	}
+	if *readOnly {
+		b = efivarfs.ReadOnly(b)
+	}
+	if *useDBus {
+		return serveDBus(b)
+	}
+	token := os.Getenv("EFIVAR_TOKEN")
+	if *tokenFile != "" {
+		b, err := os.ReadFile(*tokenFile)
+		if err != nil {
+			return fmt.Errorf("reading token: %v", err)
+		}
+		token = strings.TrimSpace(string(b))
+	}
+	h, err := rest.NewHandler(b, token)
+	if err != nil {
+		return err
+	}
+
+	log.Printf("Serving REST API on %s", *addr)
+	if *cert != "" {
+		return http.ListenAndServeTLS(*addr, *cert, *key, h)
+	}
+	return http.ListenAndServe(*addr, h)
+}
+
+// loopback reports whether addr only listens on a loopback interface.
+// An empty host listens on all of them.
+func loopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// serveDBus provides the D-Bus service until the process is killed.
+func serveDBus(b efivarfs.Backend) error {
+	conn, err := dbus.ConnectSystemBus()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	svc, err := dbusservice.Export(conn, b, nil)
+	if err != nil {
+		return err
+	}
+	root, err := efivarfs.MountPoint()
+	if err != nil {
+		return err
+	}
+	w, err := watch.New(root)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	log.Printf("Providing %s on the system bus", dbusservice.BusName)
+	svc.Forward(w)
+	return <-w.Errors
+}
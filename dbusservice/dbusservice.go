@@ -0,0 +1,160 @@
+// Package dbusservice provides the org.systemtransparency.EFIVars D-Bus
+// service. It runs as root on the system bus and lets unprivileged
+// applications access EFI variables after polkit authorized them.
+package dbusservice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/watch"
+)
+
+const (
+	// BusName is the well-known name the service owns
+	BusName = "org.systemtransparency.EFIVars"
+	// ObjectPath is the path of the single exported object
+	ObjectPath = dbus.ObjectPath("/org/systemtransparency/EFIVars")
+	// Interface is the interface implemented by the object
+	Interface = "org.systemtransparency.EFIVars1"
+
+	// ActionRead is the polkit action required for Get and List
+	ActionRead = "org.systemtransparency.efivars.read"
+	// ActionWrite is the polkit action required for Set and Remove
+	ActionWrite = "org.systemtransparency.efivars.write"
+)
+
+// D-Bus error names returned by the service
+const (
+	errNotExist     = Interface + ".Error.NotExist"
+	errPermission   = Interface + ".Error.PermissionDenied"
+	errNoSpace      = Interface + ".Error.NoSpace"
+	errInvalid      = Interface + ".Error.InvalidArgument"
+	errUnauthorized = Interface + ".Error.NotAuthorized"
+	errFailed       = Interface + ".Error.Failed"
+)
+
+// Authorizer decides whether the bus client sender may perform action.
+type Authorizer interface {
+	Authorize(sender dbus.Sender, action string) error
+}
+
+// Service implements the methods of Interface.
+type Service struct {
+	conn *dbus.Conn
+	b    efivarfs.Backend
+	auth Authorizer
+}
+
+// Descriptor is the D-Bus representation of a variable, signature (ss).
+type Descriptor struct {
+	Name string
+	GUID string
+}
+
+// Export registers a Service backed by b on conn and claims BusName.
+// If auth is nil, polkit is used to authorize callers.
+func Export(conn *dbus.Conn, b efivarfs.Backend, auth Authorizer) (*Service, error) {
+	if auth == nil {
+		auth = &Polkit{conn: conn}
+	}
+	s := &Service{conn: conn, b: b, auth: auth}
+	if err := conn.Export(s, ObjectPath, Interface); err != nil {
+		return nil, err
+	}
+	reply, err := conn.RequestName(BusName, dbus.NameFlagDoNotQueue)
+	if err != nil {
+		return nil, err
+	}
+	if reply != dbus.RequestNameReplyPrimaryOwner {
+		return nil, fmt.Errorf("name %s is already taken", BusName)
+	}
+	return s, nil
+}
+
+// Forward emits a Changed signal for every event of w until the
+// watcher is closed.
+func (s *Service) Forward(w *watch.Watcher) {
+	for ev := range w.Events {
+		s.conn.Emit(ObjectPath, Interface+".Changed", ev.Op.String(), ev.Desc.Name, ev.Desc.GUID.String())
+	}
+}
+
+// Get returns the attributes and data of a variable.
+func (s *Service) Get(name, g string, sender dbus.Sender) (uint32, []byte, *dbus.Error) {
+	desc, derr := s.prepare(name, g, sender, ActionRead)
+	if derr != nil {
+		return 0, nil, derr
+	}
+	attrs, data, err := s.b.Get(desc)
+	if err != nil {
+		return 0, nil, toDBusError(err)
+	}
+	return uint32(attrs), data, nil
+}
+
+// Set writes a variable.
+func (s *Service) Set(name, g string, attrs uint32, data []byte, sender dbus.Sender) *dbus.Error {
+	desc, derr := s.prepare(name, g, sender, ActionWrite)
+	if derr != nil {
+		return derr
+	}
+	return toDBusError(s.b.Set(desc, efivarfs.VariableAttributes(attrs), data))
+}
+
+// Remove deletes a variable.
+func (s *Service) Remove(name, g string, sender dbus.Sender) *dbus.Error {
+	desc, derr := s.prepare(name, g, sender, ActionWrite)
+	if derr != nil {
+		return derr
+	}
+	return toDBusError(s.b.Remove(desc))
+}
+
+// List returns all variables.
+func (s *Service) List(sender dbus.Sender) ([]Descriptor, *dbus.Error) {
+	if err := s.auth.Authorize(sender, ActionRead); err != nil {
+		return nil, dbus.NewError(errUnauthorized, []interface{}{err.Error()})
+	}
+	list, err := s.b.List()
+	if err != nil {
+		return nil, toDBusError(err)
+	}
+	out := []Descriptor{}
+	for _, d := range list {
+		out = append(out, Descriptor{Name: d.Name, GUID: d.GUID.String()})
+	}
+	return out, nil
+}
+
+// prepare authorizes the caller and parses the variable arguments.
+func (s *Service) prepare(name, g string, sender dbus.Sender, action string) (efivarfs.VariableDescriptor, *dbus.Error) {
+	if err := s.auth.Authorize(sender, action); err != nil {
+		return efivarfs.VariableDescriptor{}, dbus.NewError(errUnauthorized, []interface{}{err.Error()})
+	}
+	u, err := efivarfs.ParseGUID(g)
+	if err != nil {
+		return efivarfs.VariableDescriptor{}, dbus.NewError(errInvalid, []interface{}{err.Error()})
+	}
+	return efivarfs.VariableDescriptor{Name: name, GUID: &u}, nil
+}
+
+// toDBusError maps the errors of the efivarfs package to D-Bus errors.
+func toDBusError(err error) *dbus.Error {
+	if err == nil {
+		return nil
+	}
+	name := errFailed
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		name = errNotExist
+	case errors.Is(err, efivarfs.ErrVarPermission), errors.Is(err, efivarfs.ErrReadOnlyBackend),
+		errors.Is(err, efivarfs.ErrReadOnlyFilesystem), errors.Is(err, efivarfs.ErrPolicyDenied):
+		name = errPermission
+	case errors.Is(err, efivarfs.ErrNoSpace):
+		name = errNoSpace
+	}
+	return dbus.NewError(name, []interface{}{err.Error()})
+}
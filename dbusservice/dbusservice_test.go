@@ -0,0 +1,45 @@
+package dbusservice
+
+import (
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// readOnly authorizes reads only, like the polkit policy does for
+// active sessions without admin authentication.
+type readOnly struct{}
+
+func (readOnly) Authorize(sender dbus.Sender, action string) error {
+	if action != ActionRead {
+		return ErrNotAuthorized
+	}
+	return nil
+}
+
+func TestAuthorization(t *testing.T) {
+	b := efivarfs.Dir(t.TempDir())
+	attrs := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+	desc := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	if err := b.Set(desc, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+	s := &Service{b: b, auth: readOnly{}}
+	g := efivarfs.GlobalVariable.String()
+	if a, data, err := s.Get("Timeout", g, ":1.1"); err != nil || a != uint32(attrs) || string(data) != "\x05\x00" {
+		t.Errorf("Get() = %d, %x, %v", a, data, err)
+	}
+	if list, err := s.List(":1.1"); err != nil || len(list) != 1 {
+		t.Errorf("List() = %v, %v", list, err)
+	}
+	if err := s.Set("Timeout", g, uint32(attrs), []byte{1, 0}, ":1.1"); err == nil || err.Name != errUnauthorized {
+		t.Errorf("Set() = %v without authorization, want %s", err, errUnauthorized)
+	}
+	if err := s.Remove("Timeout", g, ":1.1"); err == nil || err.Name != errUnauthorized {
+		t.Errorf("Remove() = %v without authorization, want %s", err, errUnauthorized)
+	}
+	if _, data, err := b.Get(desc); err != nil || string(data) != "\x05\x00" {
+		t.Errorf("Timeout = %x, %v, want it unchanged", data, err)
+	}
+}
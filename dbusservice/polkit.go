@@ -0,0 +1,52 @@
+package dbusservice
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/godbus/dbus/v5"
+)
+
+// ErrNotAuthorized is returned if polkit denied an action
+var ErrNotAuthorized = errors.New("not authorized")
+
+// polkitSubject is the (sa{sv}) subject of CheckAuthorization.
+type polkitSubject struct {
+	Kind    string
+	Details map[string]dbus.Variant
+}
+
+// polkitResult is the (bba{ss}) result of CheckAuthorization.
+type polkitResult struct {
+	IsAuthorized bool
+	IsChallenge  bool
+	Details      map[string]string
+}
+
+// Polkit authorizes bus clients using the polkit authority.
+type Polkit struct {
+	conn *dbus.Conn
+}
+
+// allowUserInteraction lets polkit ask the user for authentication
+const allowUserInteraction = 1
+
+// Authorize asks polkit whether sender may perform action, possibly
+// prompting the user to authenticate.
+func (p *Polkit) Authorize(sender dbus.Sender, action string) error {
+	authority := p.conn.Object("org.freedesktop.PolicyKit1", "/org/freedesktop/PolicyKit1/Authority")
+	subject := polkitSubject{
+		Kind:    "system-bus-name",
+		Details: map[string]dbus.Variant{"name": dbus.MakeVariant(string(sender))},
+	}
+	var res polkitResult
+	err := authority.Call("org.freedesktop.PolicyKit1.Authority.CheckAuthorization", 0,
+		subject, action, map[string]string{}, uint32(allowUserInteraction), "").Store(&res)
+	if err != nil {
+		return fmt.Errorf("polkit: %w", err)
+	}
+	if !res.IsAuthorized {
+		return fmt.Errorf("%s for %s: %w", sender, action, ErrNotAuthorized)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package devicepath
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const corpus = "../testdata/corpus"
+
+// TestCorpusRoundTrip checks that the device path variables of the
+// corpus are encoded exactly as the firmware stored them.
+func TestCorpusRoundTrip(t *testing.T) {
+	var files []string
+	for _, name := range []string{"ConOut", "ConOutDev", "ConIn", "ErrOut"} {
+		matches, err := filepath.Glob(filepath.Join(corpus, "*", name+"-*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, matches...)
+	}
+	if len(files) == 0 {
+		t.Fatal("no device paths in corpus")
+	}
+	for _, file := range files {
+		name := strings.TrimPrefix(file, corpus+string(filepath.Separator))
+		t.Run(name, func(t *testing.T) {
+			b, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := b[4:]
+			p, err := Parse(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := p.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("MarshalBinary() = %x, want %x", got, want)
+			}
+		})
+	}
+}
+
+func TestCorpusString(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(corpus, "ovmf", "ConOut-8be4df61-93ca-11d2-aa0d-00e098032b8c"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := Parse(b[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "PciRoot(0x0)/Pci(0x1f,0x0)/Acpi(0x050141d0,0x0)/Path(3,14,0000000000c2010000000000080101)/VenMsg(dfa66065-b419-11d3-9a2d-0090273fc14d)," +
+		"PciRoot(0x0)/Pci(0x1,0x0)/Path(2,3,00010180)"
+	if got := p.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
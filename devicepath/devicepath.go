@@ -0,0 +1,258 @@
+// Package devicepath parses, builds and prints UEFI device paths, the
+// binary descriptions of devices and files used e.g. by boot entries.
+package devicepath
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+// ErrMalformed is caused by device paths not following the UEFI
+// specification
+var ErrMalformed = errors.New("malformed device path")
+
+// Node types
+const (
+	TypeHardware  = 0x01
+	TypeACPI      = 0x02
+	TypeMessaging = 0x03
+	TypeMedia     = 0x04
+	TypeBBS       = 0x05
+	TypeEnd       = 0x7f
+)
+
+// Node sub types, prefixed with their type
+const (
+	HardwarePCI    = 0x01
+	HardwareVendor = 0x04
+
+	ACPIDevice = 0x01
+
+	MessagingUSB    = 0x05
+	MessagingVendor = 0x0a
+	MessagingMAC    = 0x0b
+	MessagingIPv4   = 0x0c
+	MessagingSATA   = 0x12
+	MessagingNVMe   = 0x17
+	MessagingURI    = 0x18
+
+	MediaHardDrive = 0x01
+	MediaCDROM     = 0x02
+	MediaVendor    = 0x03
+	MediaFilePath  = 0x04
+	MediaFvFile    = 0x06
+	MediaFv        = 0x07
+
+	BBSDevice = 0x01
+
+	EndInstance = 0x01
+	EndEntire   = 0xff
+)
+
+// headerSize is the size of the type, sub type and length of a node.
+const headerSize = 4
+
+// Node is a single element of a device path.
+type Node struct {
+	Type    uint8
+	SubType uint8
+	// Data is the content following the 4 byte node header
+	Data []byte
+}
+
+// Path is a device path without the terminating end node. Paths with
+// several instances hold end of instance nodes between them.
+type Path []Node
+
+// Parse parses the device path in b, which has to be terminated by an
+// end of entire path node. Additional paths following it, as allowed in
+// the file path list of a boot entry, are kept as part of the Path
+// separated by an end of entire path node, so that MarshalBinary
+// reproduces b.
+func Parse(b []byte) (Path, error) {
+	var p Path
+	for {
+		if len(b) < headerSize {
+			return nil, fmt.Errorf("truncated node: %w", ErrMalformed)
+		}
+		length := int(binary.LittleEndian.Uint16(b[2:]))
+		if length < headerSize || length > len(b) {
+			return nil, fmt.Errorf("node length %d out of range: %w", length, ErrMalformed)
+		}
+		n := Node{Type: b[0], SubType: b[1], Data: append([]byte(nil), b[headerSize:length]...)}
+		b = b[length:]
+		if n.Type == TypeEnd && n.SubType == EndEntire && len(b) == 0 {
+			return p, nil
+		}
+		p = append(p, n)
+	}
+}
+
+// MarshalBinary returns the binary form of p including the end node.
+func (p Path) MarshalBinary() ([]byte, error) {
+	var b []byte
+	for _, n := range append(p, Node{Type: TypeEnd, SubType: EndEntire}) {
+		length := headerSize + len(n.Data)
+		if length > 0xffff {
+			return nil, fmt.Errorf("node of %d bytes: %w", length, ErrMalformed)
+		}
+		b = append(b, n.Type, n.SubType, 0, 0)
+		binary.LittleEndian.PutUint16(b[len(b)-2:], uint16(length))
+		b = append(b, n.Data...)
+	}
+	return b, nil
+}
+
+// FilePath returns the file the first path of p points to, e.g.
+// \EFI\BOOT\BOOTX64.EFI. Consecutive file path nodes are joined as
+// the UEFI specification demands. ok is false if p has no file path.
+func (p Path) FilePath() (path string, ok bool) {
+	for _, n := range p {
+		if n.Type == TypeEnd {
+			break
+		}
+		if n.Type != TypeMedia || n.SubType != MediaFilePath {
+			continue
+		}
+		s := decodeString(n.Data)
+		if ok && !strings.HasSuffix(path, `\`) && !strings.HasPrefix(s, `\`) {
+			path += `\`
+		}
+		path += s
+		ok = true
+	}
+	return path, ok
+}
+
+// String returns the text representation of p as defined by the UEFI
+// specification, e.g. HD(1,GPT,...)/File(\EFI\BOOT\BOOTX64.EFI).
+func (p Path) String() string {
+	var b strings.Builder
+	for i, n := range p {
+		if n.Type == TypeEnd {
+			b.WriteString(",")
+			continue
+		}
+		if i > 0 && p[i-1].Type != TypeEnd {
+			b.WriteString("/")
+		}
+		b.WriteString(n.String())
+	}
+	return b.String()
+}
+
+// HardDrive returns the node of the GPT partition p.
+func HardDrive(p gpt.Partition) Node {
+	data := make([]byte, 38)
+	binary.LittleEndian.PutUint32(data[0:], uint32(p.Number))
+	binary.LittleEndian.PutUint64(data[4:], p.FirstLBA)
+	binary.LittleEndian.PutUint64(data[12:], p.Size())
+	sig := efivarfs.EncodeGUID(p.GUID)
+	copy(data[20:], sig[:])
+	data[36] = 0x02 // GPT partition table
+	data[37] = 0x02 // GUID signature
+	return Node{Type: TypeMedia, SubType: MediaHardDrive, Data: data}
+}
+
+// File returns the node of a file path. Slashes are converted to the
+// backslashes UEFI uses.
+func File(path string) Node {
+	path = strings.ReplaceAll(path, "/", `\`)
+	var data []byte
+	for _, c := range utf16.Encode([]rune(path)) {
+		data = binary.LittleEndian.AppendUint16(data, c)
+	}
+	data = append(data, 0, 0)
+	return Node{Type: TypeMedia, SubType: MediaFilePath, Data: data}
+}
+
+// String returns the text representation of n as defined by the UEFI
+// specification. Nodes without one are printed in the generic
+// Path(type,subtype,data) form.
+func (n Node) String() string {
+	d := n.Data
+	switch {
+	case n.Type == TypeHardware && n.SubType == HardwarePCI && len(d) == 2:
+		return fmt.Sprintf("Pci(0x%x,0x%x)", d[1], d[0])
+	case n.Type == TypeACPI && n.SubType == ACPIDevice && len(d) == 8:
+		hid, uid := binary.LittleEndian.Uint32(d), binary.LittleEndian.Uint32(d[4:])
+		switch hid {
+		case 0x0a0341d0:
+			return fmt.Sprintf("PciRoot(0x%x)", uid)
+		case 0x0a0841d0:
+			return fmt.Sprintf("PcieRoot(0x%x)", uid)
+		}
+		return fmt.Sprintf("Acpi(0x%08x,0x%x)", hid, uid)
+	case n.Type == TypeMessaging && n.SubType == MessagingUSB && len(d) == 2:
+		return fmt.Sprintf("USB(0x%x,0x%x)", d[0], d[1])
+	case n.Type == TypeMessaging && n.SubType == MessagingMAC && len(d) == 33:
+		return fmt.Sprintf("MAC(%s,0x%x)", hex.EncodeToString(d[:6]), d[32])
+	case n.Type == TypeMessaging && n.SubType == MessagingIPv4 && len(d) >= 8:
+		return fmt.Sprintf("IPv4(%s)", net.IP(d[4:8]))
+	case n.Type == TypeMessaging && n.SubType == MessagingSATA && len(d) == 6:
+		return fmt.Sprintf("Sata(0x%x,0x%x,0x%x)", binary.LittleEndian.Uint16(d), binary.LittleEndian.Uint16(d[2:]), binary.LittleEndian.Uint16(d[4:]))
+	case n.Type == TypeMessaging && n.SubType == MessagingNVMe && len(d) == 12:
+		eui := make([]string, 8)
+		for i := range eui {
+			eui[i] = fmt.Sprintf("%02X", d[11-i])
+		}
+		return fmt.Sprintf("NVMe(0x%x,%s)", binary.LittleEndian.Uint32(d), strings.Join(eui, "-"))
+	case n.Type == TypeMessaging && n.SubType == MessagingURI:
+		return fmt.Sprintf("Uri(%s)", d)
+	case n.Type == TypeMedia && n.SubType == MediaHardDrive && len(d) == 38:
+		part := binary.LittleEndian.Uint32(d)
+		start, size := binary.LittleEndian.Uint64(d[4:]), binary.LittleEndian.Uint64(d[12:])
+		switch d[37] {
+		case 0x01:
+			return fmt.Sprintf("HD(%d,MBR,0x%08x,0x%x,0x%x)", part, binary.LittleEndian.Uint32(d[20:]), start, size)
+		case 0x02:
+			return fmt.Sprintf("HD(%d,GPT,%s,0x%x,0x%x)", part, efivarfs.DecodeGUID(d[20:36]), start, size)
+		}
+		return fmt.Sprintf("HD(%d,0x%x,0,0x%x,0x%x)", part, d[36], start, size)
+	case n.Type == TypeMedia && n.SubType == MediaCDROM && len(d) == 20:
+		return fmt.Sprintf("CDROM(0x%x,0x%x,0x%x)", binary.LittleEndian.Uint32(d), binary.LittleEndian.Uint64(d[4:]), binary.LittleEndian.Uint64(d[12:]))
+	case n.Type == TypeMedia && n.SubType == MediaFilePath:
+		return fmt.Sprintf("File(%s)", decodeString(d))
+	case n.Type == TypeMedia && n.SubType == MediaFvFile && len(d) == 16:
+		return fmt.Sprintf("FvFile(%s)", efivarfs.DecodeGUID(d))
+	case n.Type == TypeMedia && n.SubType == MediaFv && len(d) == 16:
+		return fmt.Sprintf("Fv(%s)", efivarfs.DecodeGUID(d))
+	case n.SubType == vendorSubType[n.Type] && vendorSubType[n.Type] != 0 && len(d) >= 16:
+		s := fmt.Sprintf("%s(%s", vendorName[n.Type], efivarfs.DecodeGUID(d))
+		if len(d) > 16 {
+			s += "," + hex.EncodeToString(d[16:])
+		}
+		return s + ")"
+	case n.Type == TypeBBS && n.SubType == BBSDevice && len(d) >= 4:
+		return fmt.Sprintf("BBS(0x%x,%s,0x%x)", binary.LittleEndian.Uint16(d), strings.TrimRight(string(d[4:]), "\x00"), binary.LittleEndian.Uint16(d[2:]))
+	}
+	return fmt.Sprintf("Path(%d,%d,%s)", n.Type, n.SubType, hex.EncodeToString(d))
+}
+
+// vendorSubType and vendorName describe the vendor defined nodes of
+// each type.
+var (
+	vendorSubType = map[uint8]uint8{TypeHardware: HardwareVendor, TypeMessaging: MessagingVendor, TypeMedia: MediaVendor}
+	vendorName    = map[uint8]string{TypeHardware: "VenHw", TypeMessaging: "VenMsg", TypeMedia: "VenMedia"}
+)
+
+// decodeString decodes a NUL terminated UTF-16 string.
+func decodeString(b []byte) string {
+	var s []uint16
+	for i := 0; i+1 < len(b); i += 2 {
+		c := binary.LittleEndian.Uint16(b[i:])
+		if c == 0 {
+			break
+		}
+		s = append(s, c)
+	}
+	return string(utf16.Decode(s))
+}
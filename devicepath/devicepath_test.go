@@ -0,0 +1,56 @@
+package devicepath
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+// TestEncoding compares against a fixed encoding, so that running the
+// tests on 32 bit and big endian architectures catches encoders that
+// depend on the host. The partition lies beyond 2 TiB, so its LBAs
+// don't fit 32 bits.
+func TestEncoding(t *testing.T) {
+	esp := gpt.Partition{Number: 1, GUID: guid.MustParse("c0ffee00-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x1_0000_0800, LastLBA: 0x1_0003_27ff}
+	const want = "04012a000100000000080000010000000020030000000000" +
+		"00eeffc03412cd4b9ef0123456789abc0202" +
+		"040412005c004500460049005c0041000000" +
+		"7fff0400"
+	p := Path{HardDrive(esp), File(`\EFI\A`)}
+	b, err := p.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(b) != want {
+		t.Errorf("MarshalBinary() = %x, want %s", b, want)
+	}
+	parsed, err := Parse(b)
+	if err != nil || !Equal(parsed, p) || parsed.String() != `HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x100000800,0x32000)/File(\EFI\A)` {
+		t.Errorf("Parse() = %s, %v", parsed, err)
+	}
+}
+
+func TestParseBounds(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		hex  string
+	}{
+		{"empty", ""},
+		{"truncated header", "7fff04"},
+		{"length below header", "7fff0200"},
+		{"length beyond data", "7fff0500"},
+		{"length of 64 KiB", "7fffffff"},
+		{"missing end node", "040406000000"},
+	} {
+		b, _ := hex.DecodeString(tt.hex)
+		if _, err := Parse(b); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: Parse() = %v, want ErrMalformed", tt.name, err)
+		}
+	}
+	if _, err := (Path{{Type: TypeMedia, SubType: MediaFilePath, Data: make([]byte, 0xffff)}}).MarshalBinary(); !errors.Is(err, ErrMalformed) {
+		t.Errorf("MarshalBinary() = %v for a node longer than 64 KiB, want ErrMalformed", err)
+	}
+}
@@ -0,0 +1,108 @@
+package devicepath
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+// ErrNotExpandable is caused by short-form device paths Expand can't
+// find a unique device for
+var ErrNotExpandable = errors.New("short-form device path not expandable")
+
+// IsShortForm reports whether p is a short-form device path, which
+// starts with a hard drive or file path node instead of the path of the
+// device from the root of the system. Firmware expands it by searching
+// its devices, e.g. for the partition with the signature of the HD node
+// of HD(1,GPT,...)/File(\EFI\BOOT\BOOTX64.EFI).
+func (p Path) IsShortForm() bool {
+	return len(p) > 0 && p[0].Type == TypeMedia && (p[0].SubType == MediaHardDrive || p[0].SubType == MediaFilePath)
+}
+
+// Expand returns the long form of p like the firmware boot manager
+// finds it, so paths of entries can be compared regardless of their
+// form. Paths already in long form are returned as is.
+//
+// known are long-form paths the firmware uses, e.g. the ones of the
+// boot entries it created itself, and disks the partition tables of the
+// system. A HD node is expanded with the part of a known path leading to
+// the same partition or, as the firmware doesn't necessarily know a path
+// to every partition, to another partition on the same disk. A path
+// starting with a file path node is expanded if the known paths lead to
+// a single partition only, otherwise which one holds the file can't be
+// told.
+func Expand(p Path, known []Path, disks []*gpt.Table) (Path, error) {
+	if !p.IsShortForm() {
+		return p, nil
+	}
+	if p[0].SubType == MediaFilePath {
+		var prefixes []Path
+		for _, k := range known {
+			if i := hardDrive(k); i > 0 && !slices.ContainsFunc(prefixes, func(q Path) bool { return q.equal(k[:i+1]) }) {
+				prefixes = append(prefixes, k[:i+1])
+			}
+		}
+		if len(prefixes) != 1 {
+			return nil, fmt.Errorf("%s on %d known partitions: %w", p, len(prefixes), ErrNotExpandable)
+		}
+		return append(slices.Clone(prefixes[0]), p...), nil
+	}
+
+	for _, k := range known {
+		if i := hardDrive(k); i > 0 && sameSignature(k[i], p[0]) {
+			return append(slices.Clone(k[:i]), p...), nil
+		}
+	}
+	if disk := diskOf(p[0], disks); disk != nil {
+		for _, k := range known {
+			if i := hardDrive(k); i > 0 && diskOf(k[i], disks) == disk {
+				return append(slices.Clone(k[:i]), p...), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%s: %w", p, ErrNotExpandable)
+}
+
+// hardDrive returns the index of the first HD node of p or -1.
+func hardDrive(p Path) int {
+	return slices.IndexFunc(p, func(n Node) bool {
+		return n.Type == TypeMedia && n.SubType == MediaHardDrive && len(n.Data) == 38
+	})
+}
+
+// sameSignature reports whether the HD nodes a and b refer to the same
+// partition. GPT partitions are identified by their GUID alone, MBR
+// ones by the disk signature and their number.
+func sameSignature(a, b Node) bool {
+	if len(a.Data) != 38 || len(b.Data) != 38 || !bytes.Equal(a.Data[36:], b.Data[36:]) || !bytes.Equal(a.Data[20:36], b.Data[20:36]) {
+		return false
+	}
+	return a.Data[37] == 0x02 || bytes.Equal(a.Data[:4], b.Data[:4])
+}
+
+// diskOf returns the disk holding the GPT partition of the HD node n.
+func diskOf(n Node, disks []*gpt.Table) *gpt.Table {
+	if len(n.Data) != 38 || n.Data[37] != 0x02 {
+		return nil
+	}
+	g := efivarfs.DecodeGUID(n.Data[20:36])
+	for _, t := range disks {
+		for _, p := range t.Partitions {
+			if p.GUID == g {
+				return t
+			}
+		}
+	}
+	return nil
+}
+
+// equal reports whether p and q consist of the same nodes.
+func (p Path) equal(q Path) bool {
+	return slices.EqualFunc(p, q, func(a, b Node) bool {
+		return a.Type == b.Type && a.SubType == b.SubType && bytes.Equal(a.Data, b.Data)
+	})
+}
@@ -0,0 +1,56 @@
+package devicepath
+
+import (
+	"errors"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+func TestExpand(t *testing.T) {
+	esp := gpt.Partition{Number: 1, GUID: guid.MustParse("c0ffee00-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x800, LastLBA: 0x327ff}
+	root := gpt.Partition{Number: 2, GUID: guid.MustParse("5a1ad000-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x32800, LastLBA: 0xfffff}
+	other := gpt.Partition{Number: 1, GUID: guid.MustParse("0dd00000-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x800, LastLBA: 0xfffff}
+	disks := []*gpt.Table{
+		{Partitions: []gpt.Partition{esp, root}},
+		{Partitions: []gpt.Partition{other}},
+	}
+	nvme := Path{
+		{Type: TypeACPI, SubType: ACPIDevice, Data: []byte{0xd0, 0x41, 0x03, 0x0a, 0, 0, 0, 0}},
+		{Type: TypeHardware, SubType: HardwarePCI, Data: []byte{0, 0x1d}},
+	}
+	windows := append(append(Path{}, nvme...), HardDrive(esp), File(`\EFI\Microsoft\Boot\bootmgfw.efi`))
+	known := []Path{windows, {HardDrive(esp), File(`\EFI\BOOT\BOOTX64.EFI`)}}
+
+	for _, tt := range []struct {
+		name string
+		p    Path
+		want string
+	}{
+		{"same partition", Path{HardDrive(esp), File(`\EFI\Linux\linux.efi`)}, `PciRoot(0x0)/Pci(0x1d,0x0)/HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\Linux\linux.efi)`},
+		{"same disk", Path{HardDrive(root), File(`\boot\vmlinuz.efi`)}, `PciRoot(0x0)/Pci(0x1d,0x0)/HD(2,GPT,5a1ad000-1234-4bcd-9ef0-123456789abc,0x32800,0xcd800)/File(\boot\vmlinuz.efi)`},
+		{"file", Path{File(`\EFI\Linux\linux.efi`)}, `PciRoot(0x0)/Pci(0x1d,0x0)/HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\Linux\linux.efi)`},
+		{"long form", windows, windows.String()},
+	} {
+		got, err := Expand(tt.p, known, disks)
+		if err != nil {
+			t.Errorf("%s: Expand(%s) = %v", tt.name, tt.p, err)
+			continue
+		}
+		if got.String() != tt.want {
+			t.Errorf("%s: Expand(%s) = %s, want %s", tt.name, tt.p, got, tt.want)
+		}
+	}
+
+	if _, err := Expand(Path{HardDrive(other), File(`\EFI\BOOT\BOOTX64.EFI`)}, known, disks); !errors.Is(err, ErrNotExpandable) {
+		t.Errorf("Expand() of a partition on an unknown disk = %v, want ErrNotExpandable", err)
+	}
+	usb := Path{{Type: TypeMessaging, SubType: MessagingUSB, Data: []byte{1, 0}}, HardDrive(other)}
+	if _, err := Expand(Path{File(`\EFI\BOOT\BOOTX64.EFI`)}, append(known, usb), disks); !errors.Is(err, ErrNotExpandable) {
+		t.Errorf("Expand() of a file on two known partitions = %v, want ErrNotExpandable", err)
+	}
+	if !windows[len(windows)-1:].IsShortForm() || windows.IsShortForm() {
+		t.Error("IsShortForm() doesn't tell short from long form")
+	}
+}
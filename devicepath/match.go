@@ -0,0 +1,71 @@
+package devicepath
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// Canonical returns p in a form that compares equal for paths the
+// firmware treats the same: cut at the first end node, so trailing end
+// nodes and further instances are dropped, and with consecutive file
+// path nodes joined into one holding an absolute, upper case path with
+// single backslashes, as FAT file systems ignore case. It is meant for
+// comparisons, not for writing back.
+func (p Path) Canonical() Path {
+	var c Path
+	var file []string
+	flush := func() {
+		if file != nil {
+			c = append(c, File(canonicalFile(strings.Join(file, `\`))))
+			file = nil
+		}
+	}
+	for _, n := range p {
+		if n.Type == TypeEnd {
+			break
+		}
+		if n.Type == TypeMedia && n.SubType == MediaFilePath {
+			file = append(file, decodeString(n.Data))
+			continue
+		}
+		flush()
+		c = append(c, n)
+	}
+	flush()
+	return c
+}
+
+// canonicalFile returns path as absolute upper case path with single
+// backslashes.
+func canonicalFile(path string) string {
+	parts := strings.FieldsFunc(path, func(r rune) bool { return r == '\\' || r == '/' })
+	return `\` + strings.ToUpper(strings.Join(parts, `\`))
+}
+
+// Equal reports whether a and b refer to the same file or device once
+// in canonical form. A short-form path equals the long-form paths ending
+// with it, e.g. HD(1,GPT,...)/File(\EFI\BOOT\BOOTX64.EFI) equals
+// PciRoot(0x0)/Pci(0x1d,0x0)/NVMe(...)/HD(1,GPT,...)/File(\efi\boot\bootx64.efi),
+// and HD nodes are compared by the partition signature only, as it
+// identifies the partition even if the firmware recorded it with other
+// bounds.
+func Equal(a, b Path) bool {
+	a, b = a.Canonical(), b.Canonical()
+	if a.IsShortForm() && !b.IsShortForm() {
+		a, b = b, a
+	}
+	if b.IsShortForm() && !a.IsShortForm() {
+		i := slices.IndexFunc(a, func(n Node) bool { return n.Type == b[0].Type && n.SubType == b[0].SubType })
+		if i < 0 {
+			return false
+		}
+		a = a[i:]
+	}
+	return slices.EqualFunc(a, b, func(m, n Node) bool {
+		if hardDrive(Path{m}) == 0 && hardDrive(Path{n}) == 0 {
+			return sameSignature(m, n)
+		}
+		return m.Type == n.Type && m.SubType == n.SubType && bytes.Equal(m.Data, n.Data)
+	})
+}
@@ -0,0 +1,46 @@
+package devicepath
+
+import (
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+func TestEqual(t *testing.T) {
+	esp := gpt.Partition{Number: 1, GUID: guid.MustParse("c0ffee00-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x800, LastLBA: 0x327ff}
+	resized := esp
+	resized.LastLBA = 0x427ff
+	other := gpt.Partition{Number: 2, GUID: guid.MustParse("5a1ad000-1234-4bcd-9ef0-123456789abc"), FirstLBA: 0x32800, LastLBA: 0xfffff}
+	pci := Node{Type: TypeHardware, SubType: HardwarePCI, Data: []byte{0, 0x1d}}
+	end := Node{Type: TypeEnd, SubType: EndEntire}
+	shim := Path{HardDrive(esp), File(`\EFI\ubuntu\shimx64.efi`)}
+
+	for _, tt := range []struct {
+		name  string
+		a, b  Path
+		equal bool
+	}{
+		{"case", shim, Path{HardDrive(esp), File(`\efi\UBUNTU\shimx64.EFI`)}, true},
+		{"slashes", shim, Path{HardDrive(esp), File(`EFI/ubuntu//shimx64.efi`)}, true},
+		{"split file", shim, Path{HardDrive(esp), File(`\EFI\ubuntu`), File(`shimx64.efi`)}, true},
+		{"end node", shim, Path{shim[0], shim[1], end}, true},
+		{"long form", shim, Path{pci, HardDrive(esp), File(`\EFI\ubuntu\shimx64.efi`)}, true},
+		{"resized", Path{pci, HardDrive(resized), File(`\EFI\ubuntu\shimx64.efi`)}, shim, true},
+		{"other partition", shim, Path{HardDrive(other), File(`\EFI\ubuntu\shimx64.efi`)}, false},
+		{"other file", shim, Path{pci, HardDrive(esp), File(`\EFI\ubuntu\grubx64.efi`)}, false},
+		{"no file", shim, Path{pci, HardDrive(esp)}, false},
+	} {
+		if got := Equal(tt.a, tt.b); got != tt.equal {
+			t.Errorf("%s: Equal(%s, %s) = %v, want %v", tt.name, tt.a, tt.b, got, tt.equal)
+		}
+		if got := Equal(tt.b, tt.a); got != tt.equal {
+			t.Errorf("%s: Equal(%s, %s) = %v, want %v", tt.name, tt.b, tt.a, got, tt.equal)
+		}
+	}
+
+	if got, want := (Path{HardDrive(esp), File(`efi/ubuntu`), File(`shimx64.efi`), end, File(`\x`)}).Canonical().String(),
+		`HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\UBUNTU\SHIMX64.EFI)`; got != want {
+		t.Errorf("Canonical() = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,60 @@
+// Package doctor diagnoses the environmental problems that most often
+// keep EFI variables from being read or written: missing firmware or
+// kernel support, efivarfs not being mounted or mounted read-only,
+// missing privileges and a full variable store.
+package doctor
+
+var (
+	// SysFirmwareEFI exists if the system was booted through UEFI and
+	// the kernel supports it
+	SysFirmwareEFI = "/sys/firmware/efi"
+
+	// ProcFilesystems lists the filesystems known to the kernel
+	ProcFilesystems = "/proc/filesystems"
+
+	// ProcStatus is the status of the current process
+	ProcStatus = "/proc/self/status"
+)
+
+// Status is the outcome of a Check.
+type Status int
+
+const (
+	// OK means nothing is wrong
+	OK Status = iota
+	// Warn means variables are accessible but some operations may fail
+	Warn
+	// Fail means variables can't be accessed at all
+	Fail
+)
+
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "OK"
+	case Warn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// Check is the result of a single diagnosis.
+type Check struct {
+	Name   string
+	Status Status
+	// Detail describes what was found
+	Detail string
+	// Hint tells how to fix the problem, empty for OK
+	Hint string
+}
+
+// Failed reports whether any of checks failed.
+func Failed(checks []Check) bool {
+	for _, c := range checks {
+		if c.Status == Fail {
+			return true
+		}
+	}
+	return false
+}
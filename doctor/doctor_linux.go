@@ -0,0 +1,261 @@
+package doctor
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/quirks"
+	"golang.org/x/sys/unix"
+)
+
+// nvramWarnSize is the total size of all variables above which the
+// store is considered almost full. Many firmwares have 64 KiB of
+// variable storage, some of which is used by variables hidden from the
+// OS.
+const nvramWarnSize = 48 << 10
+
+// pstoreGUID is the vendor GUID of the crash dumps efi-pstore writes to
+// the variable store.
+const pstoreGUID = "cfc8fc79-be2e-4ddc-97f0-9f98bfe298a0"
+
+// keyVariables are checked for the immutable flag.
+var keyVariables = []string{"BootOrder", "BootNext", "Timeout", "OsIndications"}
+
+// Run performs all checks. Checks depending on a failed one are
+// skipped, so the first failure is the one to fix.
+func Run() []Check {
+	checks := []Check{kernelSupport()}
+	if checks[0].Status == Fail {
+		return checks
+	}
+	root, mount := mounted()
+	checks = append(checks, mount)
+	if mount.Status == Fail {
+		return checks
+	}
+	return append(checks, writable(root), privileges(), immutable(root), storage(root), lockdown(), knownQuirks())
+}
+
+// kernelSupport checks whether the system booted through UEFI.
+func kernelSupport() Check {
+	c := Check{Name: "UEFI support"}
+	if _, err := os.Stat(SysFirmwareEFI); err != nil {
+		c.Status = Fail
+		c.Detail = SysFirmwareEFI + " does not exist"
+		c.Hint = "Boot the system in UEFI mode instead of legacy BIOS/CSM mode and use a kernel built with CONFIG_EFI."
+		return c
+	}
+	c.Detail = "booted through UEFI"
+	return c
+}
+
+// mounted checks whether efivarfs is mounted and returns where.
+func mounted() (string, Check) {
+	c := Check{Name: "efivarfs mount"}
+	if root, err := efivarfs.MountPoint(); err == nil {
+		c.Detail = "mounted at " + root
+		return root, c
+	}
+	mp := efivarfs.CurrentPaths().EfiVarFs
+	c.Status = Fail
+	c.Detail = "not mounted at " + mp
+	if !knownFilesystem("efivarfs") {
+		c.Hint = "Load the module with 'modprobe efivarfs' or use a kernel built with CONFIG_EFIVAR_FS, then mount it with " +
+			"'mount -t efivarfs efivarfs " + mp + "'."
+		return "", c
+	}
+	c.Hint = "Mount it with 'mount -t efivarfs efivarfs " + mp + "' or run efivar with -mount. In containers bind mount it from the host."
+	return "", c
+}
+
+// knownFilesystem reports whether the kernel supports the filesystem
+// fs. It returns true if that can't be determined.
+func knownFilesystem(fs string) bool {
+	f, err := os.Open(ProcFilesystems)
+	if err != nil {
+		return true
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) > 0 && fields[len(fields)-1] == fs {
+			return true
+		}
+	}
+	return false
+}
+
+// writable checks whether efivarfs is mounted read-write at root.
+func writable(root string) Check {
+	c := Check{Name: "efivarfs writable"}
+	var stat unix.Statfs_t
+	if err := unix.Statfs(root, &stat); err != nil {
+		c.Status = Warn
+		c.Detail = err.Error()
+		return c
+	}
+	if stat.Flags&unix.ST_RDONLY != 0 {
+		c.Status = Warn
+		c.Detail = "mounted read-only, variables can be read but not written"
+		c.Hint = "Remount it with 'mount -o remount,rw " + root + "' or run efivar with -mount. Some distributions mount it read-only on purpose."
+		return c
+	}
+	c.Detail = "mounted read-write"
+	return c
+}
+
+// knownQuirks reports the firmware bugs efivar works around on this
+// system.
+func knownQuirks() Check {
+	c := Check{Name: "firmware quirks"}
+	qs, err := quirks.Detect()
+	if err != nil {
+		c.Detail = "unknown, " + err.Error()
+		return c
+	}
+	if len(qs) == 0 {
+		c.Detail = "none known for this system"
+		return c
+	}
+	names := make([]string, len(qs))
+	for i, q := range qs {
+		names[i] = q.Name
+	}
+	c.Status = Warn
+	c.Detail = "known buggy firmware: " + strings.Join(names, ", ")
+	c.Hint = "Go programs writing variables should wrap their backend with quirks.Apply."
+	return c
+}
+
+// lockdown reports the kernel lockdown mode, which doesn't keep efivarfs
+// from being written but disables other interfaces firmware tools use.
+func lockdown() Check {
+	c := Check{Name: "kernel lockdown"}
+	switch mode := efivarfs.ReadLockdown(); mode {
+	case efivarfs.LockdownUnknown:
+		c.Detail = "unknown, " + efivarfs.SysKernelLockdown + " can't be read"
+	case efivarfs.LockdownNone:
+		c.Detail = "not locked down"
+	default:
+		c.Detail = mode.String() + " mode, efivarfs stays writable but efi_test and /dev/mem are disabled"
+	}
+	return c
+}
+
+// Capabilities needed for writing variables, see capabilities(7).
+const (
+	capDACOverride    = 1
+	capLinuxImmutable = 9
+)
+
+// privileges checks whether the process may write variables and clear
+// the immutable flag.
+func privileges() Check {
+	c := Check{Name: "privileges"}
+	caps, err := effectiveCapabilities()
+	if err != nil {
+		c.Status = Warn
+		c.Detail = err.Error()
+		return c
+	}
+	var missing []string
+	if os.Geteuid() != 0 && caps&(1<<capDACOverride) == 0 {
+		missing = append(missing, "root or CAP_DAC_OVERRIDE")
+	}
+	if caps&(1<<capLinuxImmutable) == 0 {
+		missing = append(missing, "CAP_LINUX_IMMUTABLE")
+	}
+	if len(missing) > 0 {
+		c.Status = Warn
+		c.Detail = "missing " + strings.Join(missing, " and ") + ", variables can be read but most can't be written"
+		c.Hint = "Run as root, e.g. with sudo. In containers grant CAP_LINUX_IMMUTABLE."
+		return c
+	}
+	c.Detail = "variables can be written"
+	return c
+}
+
+// effectiveCapabilities returns the CapEff mask of the current process.
+func effectiveCapabilities() (uint64, error) {
+	f, err := os.Open(ProcStatus)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		if v, ok := strings.CutPrefix(s.Text(), "CapEff:"); ok {
+			return strconv.ParseUint(strings.TrimSpace(v), 16, 64)
+		}
+	}
+	return 0, fmt.Errorf("no CapEff in %s", ProcStatus)
+}
+
+// fsImmutableFL is FS_IMMUTABLE_FL of the inode flags FS_IOC_GETFLAGS
+// returns. The golang.org/x/sys version this module requires lacks it,
+// and STATX_ATTR_IMMUTABLE only happens to have the same value.
+const fsImmutableFL = 0x00000010
+
+// immutable reports the key variables carrying the immutable flag,
+// which this package clears itself but trips up other tools.
+func immutable(root string) Check {
+	c := Check{Name: "immutable flags"}
+	var set []string
+	for _, name := range keyVariables {
+		f, err := os.Open(filepath.Join(root, name+"-"+efivarfs.GlobalVariable.String()))
+		if err != nil {
+			continue
+		}
+		flags, err := unix.IoctlGetInt(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+		f.Close()
+		if err == nil && flags&fsImmutableFL != 0 {
+			set = append(set, name)
+		}
+	}
+	if len(set) > 0 {
+		c.Detail = "set on " + strings.Join(set, ", ") + " as usual, efivar clears it but other tools may need 'chattr -i'"
+		return c
+	}
+	c.Detail = "not set on any key variable"
+	return c
+}
+
+// storage estimates how full the variable store is from the size of
+// the visible variables and looks for efi-pstore crash dumps, which are
+// the most common cause of a full store.
+func storage(root string) Check {
+	c := Check{Name: "variable storage"}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		c.Status = Warn
+		c.Detail = err.Error()
+		return c
+	}
+	var total int64
+	var dumps int
+	for _, e := range entries {
+		if info, err := e.Info(); err == nil && info.Size() > 4 {
+			total += info.Size() - 4
+		}
+		if strings.HasPrefix(e.Name(), "dump-") && strings.HasSuffix(e.Name(), pstoreGUID) {
+			dumps++
+		}
+	}
+	c.Detail = fmt.Sprintf("%d variables using %d KiB", len(entries), total>>10)
+	switch {
+	case dumps > 0:
+		c.Status = Warn
+		c.Detail += fmt.Sprintf(", %d of them efi-pstore crash dumps", dumps)
+		c.Hint = "Remove the crash dumps with 'rm /sys/fs/pstore/*' after saving them and reboot to let the firmware reclaim the space."
+	case total > nvramWarnSize:
+		c.Status = Warn
+		c.Hint = "The store may be almost full, writes failing with \"no space\" often succeed after a reboot lets the firmware garbage collect it."
+	}
+	return c
+}
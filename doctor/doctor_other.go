@@ -0,0 +1,16 @@
+//go:build !linux
+
+package doctor
+
+import "runtime"
+
+// Run performs all checks, of which there is only one outside of Linux:
+// reporting that EFI variables are not accessible.
+func Run() []Check {
+	return []Check{{
+		Name:   "UEFI support",
+		Status: Fail,
+		Detail: "EFI variables are only accessible on Linux, not on " + runtime.GOOS,
+		Hint:   "Run efivar on Linux or work with a copy of the variables using a snapshot directory.",
+	}}
+}
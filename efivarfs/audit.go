@@ -0,0 +1,123 @@
+package efivarfs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// AuditRecord describes a single modification of a variable.
+type AuditRecord struct {
+	// Time is when the modification was attempted
+	Time time.Time
+	// Op is either "set" or "remove"
+	Op   string
+	Desc VariableDescriptor
+	// Attributes are the attributes passed to Set
+	Attributes VariableAttributes
+	// OldHash and NewHash are the hex encoded SHA-256 hashes of the
+	// variable data before and after the modification, empty if the
+	// variable didn't exist
+	OldHash string
+	NewHash string
+	// Err is the result of the modification
+	Err error
+}
+
+// Auditor is invoked after every Set and Remove of a backend returned
+// by Audited.
+type Auditor interface {
+	Audit(r AuditRecord)
+}
+
+// audited is a Backend decorator reporting modifications to an Auditor.
+type audited struct {
+	Backend
+	a Auditor
+}
+
+// Audited returns a Backend reporting every Set and Remove made through
+// it to a, including the data hashes before and after. This is meant for
+// environments that have to keep track of firmware level changes.
+func Audited(b Backend, a Auditor) Backend {
+	return &audited{Backend: b, a: a}
+}
+
+// hash returns the hex encoded SHA-256 of the data of desc or an empty
+// string if it can't be read.
+func (v *audited) hash(desc VariableDescriptor) string {
+	_, data, err := v.Backend.Get(desc)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func (v *audited) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	r := AuditRecord{Time: time.Now(), Op: "set", Desc: desc, Attributes: attrs, OldHash: v.hash(desc)}
+	r.Err = v.Backend.Set(desc, attrs, data)
+	r.NewHash = v.hash(desc)
+	v.a.Audit(r)
+	return r.Err
+}
+
+func (v *audited) Remove(desc VariableDescriptor) error {
+	r := AuditRecord{Time: time.Now(), Op: "remove", Desc: desc, OldHash: v.hash(desc)}
+	r.Err = v.Backend.Remove(desc)
+	r.NewHash = v.hash(desc)
+	v.a.Audit(r)
+	return r.Err
+}
+
+// JSONAuditor writes audit records as JSON lines, e.g. to an append-only
+// log file.
+type JSONAuditor struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewJSONAuditor returns an Auditor writing to w.
+func NewJSONAuditor(w io.Writer) *JSONAuditor {
+	return &JSONAuditor{enc: json.NewEncoder(w)}
+}
+
+// jsonAuditRecord is the format of a line written by JSONAuditor.
+type jsonAuditRecord struct {
+	Time       time.Time          `json:"time"`
+	Op         string             `json:"op"`
+	Name       string             `json:"name"`
+	GUID       string             `json:"guid,omitempty"`
+	Attributes VariableAttributes `json:"attributes,omitempty"`
+	OldSHA256  string             `json:"old_sha256,omitempty"`
+	NewSHA256  string             `json:"new_sha256,omitempty"`
+	Result     string             `json:"result"`
+}
+
+// Audit writes r as a single line.
+func (a *JSONAuditor) Audit(r AuditRecord) {
+	rec := jsonAuditRecord{
+		Time:       r.Time.UTC(),
+		Op:         r.Op,
+		Name:       r.Desc.Name,
+		Attributes: r.Attributes,
+		OldSHA256:  r.OldHash,
+		NewSHA256:  r.NewHash,
+		Result:     "ok",
+	}
+	// Descriptors without GUID are rejected by the backend, but still
+	// recorded
+	if r.Desc.GUID != nil {
+		rec.GUID = r.Desc.GUID.String()
+	}
+	if r.Err != nil {
+		rec.Result = r.Err.Error()
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	// There is no way to report a failure, the modification already happened
+	_ = a.enc.Encode(rec)
+}
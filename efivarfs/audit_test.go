@@ -0,0 +1,62 @@
+package efivarfs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestAudited(t *testing.T) {
+	var buf bytes.Buffer
+	b := Audited(Dir(t.TempDir()), NewJSONAuditor(&buf))
+	desc := NewDescriptor("Counter", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess
+	if err := b.Set(desc, attrs, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Set(desc, attrs, []byte{2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(desc); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(desc); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Remove() = %v for a missing variable", err)
+	}
+	if err := b.Set(VariableDescriptor{Name: "NoGUID"}, attrs, []byte{1}); !errors.Is(err, ErrInvalidGUID) {
+		t.Errorf("Set() = %v without GUID, want ErrInvalidGUID", err)
+	}
+
+	const (
+		one = "4bf5122f344554c53bde2ebb8cd2b7e3d1600ad631c385a5d7cce23c7785459a"
+		two = "dbc1b4c900ffe48d575b5da5c638040125f65db0fe3e24494b76ea986457d986"
+	)
+	global := GlobalVariable.String()
+	want := []jsonAuditRecord{
+		{Op: "set", Name: "Counter", GUID: global, Attributes: attrs, NewSHA256: one, Result: "ok"},
+		{Op: "set", Name: "Counter", GUID: global, Attributes: attrs, OldSHA256: one, NewSHA256: two, Result: "ok"},
+		{Op: "remove", Name: "Counter", GUID: global, OldSHA256: two, Result: "ok"},
+		{Op: "remove", Name: "Counter", GUID: global, Result: ErrVarNotExist.Error()},
+		{Op: "set", Name: "NoGUID", Attributes: attrs, Result: "NoGUID: " + ErrInvalidGUID.Error()},
+	}
+	s := bufio.NewScanner(&buf)
+	var i int
+	for ; s.Scan(); i++ {
+		var rec jsonAuditRecord
+		if err := json.Unmarshal(s.Bytes(), &rec); err != nil {
+			t.Fatal(err)
+		}
+		if rec.Time.IsZero() {
+			t.Errorf("record %d has no time", i)
+		}
+		rec.Time = want[0].Time
+		if i < len(want) && rec != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, rec, want[i])
+		}
+	}
+	if i != len(want) {
+		t.Errorf("got %d records, want %d", i, len(want))
+	}
+}
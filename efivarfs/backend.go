@@ -0,0 +1,39 @@
+package efivarfs
+
+// ReadBackend is the read-only part of Backend. Code that only needs
+// to inspect variables should accept a ReadBackend, which guarantees at
+// compile time that it can't modify them.
+type ReadBackend interface {
+	// Get returns the attributes and data of a variable
+	Get(desc VariableDescriptor) (VariableAttributes, []byte, error)
+	// List returns the descriptors of all variables
+	List() ([]VariableDescriptor, error)
+}
+
+// Backend is implemented by everything that provides access to EFI
+// variables. The efivarfs of the Linux kernel is the default one,
+// others wrap a Backend to add behavior or serve variables from
+// somewhere else entirely.
+type Backend interface {
+	ReadBackend
+	// Set creates or overwrites a variable
+	Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error
+	// Remove deletes a variable
+	Remove(desc VariableDescriptor) error
+}
+
+// Probe returns the efivarfs backend if efivarfs is mounted and
+// ErrFsNotMounted otherwise. On platforms other than Linux it always
+// fails with ErrVarsUnavailable, while Dir works everywhere.
+func Probe() (Backend, error) {
+	return probeAndReturn()
+}
+
+// Dir returns a Backend serving variables from a directory with the same
+// layout as efivarfs: one Name-GUID file per variable, holding the 4 byte
+// little endian attributes followed by the data. This allows working
+// with snapshots captured on other machines, e.g. by copying the content
+// of /sys/firmware/efi/efivars, without any firmware involved.
+func Dir(path string) Backend {
+	return &efivarfs{root: path, snapshot: true}
+}
@@ -0,0 +1,89 @@
+package efivarfs
+
+import (
+	"context"
+	"sync"
+)
+
+// Variable is the content of a variable read by ReadVariables.
+type Variable struct {
+	Attributes VariableAttributes
+	Data       []byte
+	// Err is set instead if the variable couldn't be read, e.g. to
+	// ErrVarNotExist
+	Err error
+}
+
+// ReadVariables reads descs from the current efivarfs backend, which
+// unlike with ReadVariable is only probed once, see Client.ReadVariables.
+func ReadVariables(descs []VariableDescriptor) (map[VariableKey]Variable, error) {
+	e, err := probeAndReturn()
+	if err != nil {
+		return nil, err
+	}
+	return readVariables(context.Background(), e, descs, 1, nil)
+}
+
+// ReadVariables reads descs with up to concurrency goroutines at once,
+// which speeds up inventories of large stores as every read goes to the
+// firmware. A variable that can't be read doesn't keep the others from
+// being read, its Variable holds the error instead and a *BulkError
+// listing all of them is returned along with the variables. If ctx is
+// done, the variables read so far are returned with its error. The
+// progress is reported to the function passed with WithProgress.
+func (c *Client) ReadVariables(ctx context.Context, descs []VariableDescriptor, concurrency int) (map[VariableKey]Variable, error) {
+	return readVariables(ctx, c, descs, concurrency, c.progress)
+}
+
+// readVariables implements ReadVariables for the backend b, reporting
+// to progress if not nil.
+func readVariables(ctx context.Context, b ReadBackend, descs []VariableDescriptor, concurrency int, progress func(Progress)) (map[VariableKey]Variable, error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	vars := make(map[VariableKey]Variable, len(descs))
+	var mu sync.Mutex
+	done := 0
+	jobs := make(chan VariableDescriptor)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for desc := range jobs {
+				attrs, data, err := b.Get(desc)
+				mu.Lock()
+				vars[desc.Key()] = Variable{Attributes: attrs, Data: data, Err: err}
+				done++
+				if progress != nil {
+					progress(Progress{Done: done, Total: len(descs), Desc: desc, Bytes: len(data)})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	var err error
+	for _, desc := range descs {
+		if err = ctx.Err(); err != nil {
+			break
+		}
+		select {
+		case jobs <- desc:
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	if err != nil {
+		return vars, err
+	}
+	var bulk BulkError
+	for _, desc := range descs {
+		if v := vars[desc.Key()]; v.Err != nil {
+			bulk.Add("read", desc, v.Err)
+		}
+	}
+	return vars, bulk.Err()
+}
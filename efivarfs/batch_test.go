@@ -0,0 +1,56 @@
+package efivarfs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestReadVariables(t *testing.T) {
+	done := 0
+	c := NewClient(Dir("../testdata/corpus/ami-desktop"), WithProgress(func(p Progress) {
+		done++
+		if p.Done != done || p.Total == 0 {
+			t.Errorf("got progress %d of %d after %d variables", p.Done, p.Total, done)
+		}
+	}))
+	descs, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing := NewDescriptor("Missing", GlobalVariable)
+	vars, err := c.ReadVariables(context.Background(), append(descs, missing), 4)
+	var bulk *BulkError
+	if !errors.As(err, &bulk) || len(bulk.Errors) != 1 || !bulk.Errors[0].Desc.Equal(missing) {
+		t.Fatalf("got error %v, want a BulkError for the missing variable", err)
+	}
+	if !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("errors.Is(%v, ErrVarNotExist) = false", err)
+	}
+	if len(vars) != len(descs)+1 {
+		t.Errorf("got %d variables, want %d", len(vars), len(descs)+1)
+	}
+	for _, desc := range descs {
+		attrs, data, err := c.Get(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v := vars[desc.Key()]
+		if v.Err != nil || v.Attributes != attrs || !bytes.Equal(v.Data, data) {
+			t.Errorf("%s: got %s %x %v, want %s %x", desc, v.Attributes, v.Data, v.Err, attrs, data)
+		}
+	}
+	if done != len(descs)+1 {
+		t.Errorf("progress reported %d times, want %d", done, len(descs)+1)
+	}
+	if err := vars[missing.Key()].Err; !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("got %v for missing variable, want ErrVarNotExist", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.ReadVariables(ctx, descs, 1); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v with cancelled context, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,81 @@
+package efivarfs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// VariableError is the failure of a single variable in an operation on
+// many of them.
+type VariableError struct {
+	// Op is the failed operation, e.g. "read" or "restore"
+	Op   string
+	Desc VariableDescriptor
+	Err  error
+}
+
+func (e *VariableError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Desc, e.Err)
+}
+
+func (e *VariableError) Unwrap() error {
+	return e.Err
+}
+
+// BulkError is returned by operations on many variables that carry on
+// past failing ones, like ReadVariables. It lists every variable that
+// failed. errors.Is and errors.As look at all of them, so
+// errors.Is(err, ErrVarPermission) tells whether any failed for lack of
+// privileges.
+type BulkError struct {
+	Errors []*VariableError
+}
+
+func (e *BulkError) Error() string {
+	msgs := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("%d variables failed: %s", len(e.Errors), strings.Join(msgs, "; "))
+}
+
+// Unwrap returns the VariableErrors.
+func (e *BulkError) Unwrap() []error {
+	errs := make([]error, len(e.Errors))
+	for i, err := range e.Errors {
+		errs[i] = err
+	}
+	return errs
+}
+
+// Add records that op failed on desc with err.
+func (e *BulkError) Add(op string, desc VariableDescriptor, err error) {
+	e.Errors = append(e.Errors, &VariableError{Op: op, Desc: desc, Err: err})
+}
+
+// Err returns e if any variable failed and nil otherwise, for returning
+// it at the end of the operation.
+func (e *BulkError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Progress tells how far an operation on many variables got.
+type Progress struct {
+	// Done of Total variables were processed
+	Done, Total int
+	// Desc is the variable processed last and Bytes the size of its data
+	Desc  VariableDescriptor
+	Bytes int
+}
+
+// WithProgress makes ReadVariables call report after every variable,
+// e.g. to update a progress bar. report is called from one goroutine at
+// a time.
+func WithProgress(report func(Progress)) Option {
+	return func(c *Client) {
+		c.progress = report
+	}
+}
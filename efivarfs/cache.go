@@ -0,0 +1,134 @@
+package efivarfs
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Cache is a Backend decorator memoizing the results of Get and List,
+// returned by NewCache. Every read of a variable goes to the firmware,
+// which makes daemons polling e.g. SecureBoot or BootOrder expensive.
+//
+// Modifications made through the Cache invalidate the affected entries,
+// changes made by others have to be reported with Invalidate, which
+// watch.Invalidate does for all changes of efivarfs. Without that, a TTL
+// bounds how long stale results are returned.
+type Cache struct {
+	b   Backend
+	ttl time.Duration
+	now func() time.Time
+
+	mu     sync.Mutex
+	vars   map[VariableKey]cachedVariable
+	list   []VariableDescriptor
+	listed time.Time
+	// gen is increased by every invalidation, results read from the
+	// backend meanwhile aren't cached as they might be stale already
+	gen uint64
+}
+
+// cachedVariable is the result of Get for a variable.
+type cachedVariable struct {
+	attrs VariableAttributes
+	data  []byte
+	// err is nil or ErrVarNotExist, other errors aren't cached
+	err  error
+	read time.Time
+}
+
+// NewCache returns a Cache for b whose entries expire after ttl, or only
+// once invalidated if ttl is 0.
+func NewCache(b Backend, ttl time.Duration) *Cache {
+	return &Cache{b: b, ttl: ttl, now: time.Now, vars: make(map[VariableKey]cachedVariable)}
+}
+
+// fresh reports whether an entry cached at t can still be used.
+func (c *Cache) fresh(t time.Time) bool {
+	return c.ttl == 0 || c.now().Sub(t) < c.ttl
+}
+
+// Get returns the cached attributes and data of desc, reading them from
+// the backend first if needed. That a variable doesn't exist is cached
+// as well.
+func (c *Cache) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	key := desc.Key()
+	c.mu.Lock()
+	v, ok := c.vars[key]
+	gen := c.gen
+	c.mu.Unlock()
+	if !ok || !c.fresh(v.read) {
+		v = cachedVariable{read: c.now()}
+		v.attrs, v.data, v.err = c.b.Get(desc)
+		if v.err != nil && !errors.Is(v.err, ErrVarNotExist) {
+			return 0, nil, v.err
+		}
+		c.mu.Lock()
+		if c.gen == gen {
+			c.vars[key] = v
+		}
+		c.mu.Unlock()
+	}
+	if v.err != nil {
+		return 0, nil, v.err
+	}
+	return v.attrs, slices.Clone(v.data), nil
+}
+
+// List returns the cached descriptors of all variables, listing them
+// with the backend first if needed.
+func (c *Cache) List() ([]VariableDescriptor, error) {
+	c.mu.Lock()
+	list, listed, gen := c.list, c.listed, c.gen
+	c.mu.Unlock()
+	if list == nil || !c.fresh(listed) {
+		listed = c.now()
+		descs, err := c.b.List()
+		if err != nil {
+			return nil, err
+		}
+		list = slices.Clip(descs)
+		if list == nil {
+			list = []VariableDescriptor{}
+		}
+		c.mu.Lock()
+		if c.gen == gen {
+			c.list, c.listed = list, listed
+		}
+		c.mu.Unlock()
+	}
+	return slices.Clone(list), nil
+}
+
+// Set writes desc through the backend and invalidates it.
+func (c *Cache) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	defer c.Invalidate(desc)
+	return c.b.Set(desc, attrs, data)
+}
+
+// Remove deletes desc through the backend and invalidates it.
+func (c *Cache) Remove(desc VariableDescriptor) error {
+	defer c.Invalidate(desc)
+	return c.b.Remove(desc)
+}
+
+// Invalidate drops desc and the list of variables from the cache, so
+// they are read again from the backend next time.
+func (c *Cache) Invalidate(desc VariableDescriptor) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.vars, desc.Key())
+	c.list = nil
+	c.gen++
+}
+
+// InvalidateAll empties the cache, e.g. after changes might have been
+// missed.
+func (c *Cache) InvalidateAll() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	clear(c.vars)
+	c.list = nil
+	c.gen++
+}
@@ -0,0 +1,71 @@
+package efivarfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// countingBackend counts the reads reaching a backend.
+type countingBackend struct {
+	Backend
+	gets, lists int
+}
+
+func (b *countingBackend) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	b.gets++
+	return b.Backend.Get(desc)
+}
+
+func (b *countingBackend) List() ([]VariableDescriptor, error) {
+	b.lists++
+	return b.Backend.List()
+}
+
+func TestCache(t *testing.T) {
+	b := &countingBackend{Backend: Dir("../testdata/corpus/ovmf")}
+	c := NewCache(b, time.Minute)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	order := VariableDescriptor{Name: "BootOrder", GUID: &GlobalVariable}
+	for i := 0; i < 3; i++ {
+		_, data, err := c.Get(order)
+		if err != nil {
+			t.Fatalf("Get(BootOrder) = %v", err)
+		}
+		data[0] ^= 0xff
+	}
+	_, data, _ := b.Backend.Get(order)
+	if _, got, _ := c.Get(order); string(got) != string(data) {
+		t.Errorf("Get(BootOrder) = %x after modifying a result, want %x", got, data)
+	}
+	missing := VariableDescriptor{Name: "Missing", GUID: &GlobalVariable}
+	for i := 0; i < 2; i++ {
+		if _, _, err := c.Get(missing); !errors.Is(err, ErrVarNotExist) {
+			t.Fatalf("Get(Missing) = %v, want ErrVarNotExist", err)
+		}
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := c.List(); err != nil {
+			t.Fatalf("List() = %v", err)
+		}
+	}
+	if b.gets != 2 || b.lists != 1 {
+		t.Errorf("backend read %d variables and listed %d times, want 2 and 1", b.gets, b.lists)
+	}
+
+	c.Invalidate(order)
+	c.Get(order)
+	c.List()
+	if b.gets != 3 || b.lists != 2 {
+		t.Errorf("backend read %d variables and listed %d times after Invalidate, want 3 and 2", b.gets, b.lists)
+	}
+
+	now = now.Add(time.Minute)
+	c.Get(order)
+	c.Get(missing)
+	if b.gets != 5 {
+		t.Errorf("backend read %d variables after the TTL, want 5", b.gets)
+	}
+}
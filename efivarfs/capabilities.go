@@ -0,0 +1,185 @@
+package efivarfs
+
+import (
+	"errors"
+	"os"
+	"strings"
+)
+
+// SysKernelLockdown reports the lockdown mode of the kernel, see
+// kernel_lockdown(7)
+var SysKernelLockdown = "/sys/kernel/security/lockdown"
+
+// Lockdown is the lockdown mode of the Linux kernel.
+type Lockdown int
+
+const (
+	// LockdownUnknown means the mode couldn't be read, e.g. because
+	// securityfs isn't mounted or the platform isn't Linux
+	LockdownUnknown Lockdown = iota
+	// LockdownNone means the kernel isn't locked down
+	LockdownNone
+	// LockdownIntegrity blocks modifying the running kernel, which
+	// distribution kernels usually enable when booted with Secure Boot
+	LockdownIntegrity
+	// LockdownConfidentiality additionally blocks reading kernel memory
+	LockdownConfidentiality
+)
+
+func (l Lockdown) String() string {
+	switch l {
+	case LockdownNone:
+		return "none"
+	case LockdownIntegrity:
+		return "integrity"
+	case LockdownConfidentiality:
+		return "confidentiality"
+	}
+	return "unknown"
+}
+
+// Capabilities describes what the backend supports and the
+// restrictions the environment puts on accessing variables, so tools
+// can adapt to it or warn up front instead of failing half way through
+// e.g. enrolling keys.
+type Capabilities struct {
+	// Write reports whether variables can be created and overwritten,
+	// which needs privileges and a read-write mount for efivarfs
+	Write bool
+	// Delete reports whether variables can be removed
+	Delete bool
+	// Append reports whether AttributeAppendWrite is supported
+	Append bool
+	// ImmutableFlags reports whether the backend protects variables
+	// with the immutable inode flag, which Set and Remove clear and
+	// restore transparently given CAP_LINUX_IMMUTABLE
+	ImmutableFlags bool
+	// AuthVariables reports whether authenticated writes are verified
+	// by the firmware instead of being stored as they are
+	AuthVariables bool
+	// MaxVariableSize is a hint for the largest variable that can be
+	// written, e.g. the free space of the variable store, 0 if unknown
+	MaxVariableSize int64
+
+	// Lockdown is the lockdown mode of the kernel. Any mode disables
+	// the efi_test interface, which tools like fwts use to write
+	// variables bypassing efivarfs, while efivarfs stays writable.
+	Lockdown Lockdown
+	// SecureBoot reports whether the firmware enforces Secure Boot,
+	// which only allows updating PK, KEK, db and dbx with signed
+	// authenticated writes
+	SecureBoot bool
+	// SetupMode reports whether no PK is enrolled, so that Secure Boot
+	// keys can be written without signatures
+	SetupMode bool
+	// PlatformSize is the bitness of the firmware, 32 or 64, which
+	// differs from the kernel's on e.g. tablets with 32 bit firmware
+	// booting 64 bit kernels, 0 if unknown
+	PlatformSize int
+	// Revision is the UEFI revision the firmware implements, 0 if
+	// unknown
+	Revision Revision
+	// RuntimeServices are the runtime services the firmware announces
+	// in RuntimeServicesSupported. Without SetVariable, Write, Delete
+	// and Append are false.
+	RuntimeServices RuntimeServices
+}
+
+// CapabilityReporter is implemented by backends that know which
+// operations they support. Client.Capabilities assumes that backends not
+// implementing it support all operations of Backend but nothing beyond.
+type CapabilityReporter interface {
+	// Capabilities returns what the backend supports, Lockdown,
+	// SecureBoot, SetupMode, PlatformSize, Revision and RuntimeServices
+	// are filled in by the Client
+	Capabilities() Capabilities
+}
+
+// Capabilities returns what the backend of c supports and the
+// restrictions for the variables accessed through it. SecureBoot and
+// SetupMode are read from the backend and false if it doesn't hold the
+// variables, PlatformSize and Revision describe the running firmware.
+func (c *Client) Capabilities() (Capabilities, error) {
+	if c.backend == nil {
+		return Capabilities{}, ErrVarsUnavailable
+	}
+	caps := backendCapabilities(c.backend)
+	caps.Lockdown = ReadLockdown()
+	caps.PlatformSize = ReadPlatformSize()
+	caps.Revision = ReadRevision()
+	var err error
+	if caps.SecureBoot, err = c.flag("SecureBoot"); err != nil {
+		return Capabilities{}, err
+	}
+	if caps.SetupMode, err = c.flag("SetupMode"); err != nil {
+		return Capabilities{}, err
+	}
+	if caps.RuntimeServices, err = ReadRuntimeServices(c.backend); err != nil {
+		return Capabilities{}, err
+	}
+	if !caps.RuntimeServices.SetVariable {
+		caps.Write, caps.Delete, caps.Append = false, false, false
+	}
+	return caps, nil
+}
+
+// backendCapabilities returns the capabilities of b as described for
+// CapabilityReporter.
+func backendCapabilities(b ReadBackend) Capabilities {
+	switch b := b.(type) {
+	case CapabilityReporter:
+		return b.Capabilities()
+	case *Client:
+		return backendCapabilities(b.backend)
+	case Backend:
+		return Capabilities{Write: true, Delete: true, Append: true}
+	}
+	return Capabilities{}
+}
+
+// Capabilities of a dry run are those of the wrapped backend, so tools
+// show what the real run could do.
+func (d *dryRun) Capabilities() Capabilities {
+	return backendCapabilities(d.b)
+}
+
+// Capabilities of a read-only backend are those of the wrapped one
+// without any modification.
+func (r readOnly) Capabilities() Capabilities {
+	caps := backendCapabilities(r.ReadBackend)
+	caps.Write, caps.Delete, caps.Append = false, false, false
+	return caps
+}
+
+// flag reads the global variable name holding a single byte boolean.
+func (c *Client) flag(name string) (bool, error) {
+	_, data, err := c.backend.Get(VariableDescriptor{Name: name, GUID: &GlobalVariable})
+	switch {
+	case errors.Is(err, ErrVarNotExist):
+		return false, nil
+	case err != nil:
+		return false, err
+	}
+	return len(data) == 1 && data[0] == 1, nil
+}
+
+// ReadLockdown returns the lockdown mode of the kernel from
+// SysKernelLockdown, which lists all modes with the active one in
+// brackets, e.g. "none [integrity] confidentiality".
+func ReadLockdown() Lockdown {
+	b, err := os.ReadFile(CurrentPaths().SysKernelLockdown)
+	if err != nil {
+		return LockdownUnknown
+	}
+	for _, mode := range strings.Fields(string(b)) {
+		switch mode {
+		case "[none]":
+			return LockdownNone
+		case "[integrity]":
+			return LockdownIntegrity
+		case "[confidentiality]":
+			return LockdownConfidentiality
+		}
+	}
+	return LockdownUnknown
+}
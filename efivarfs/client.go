@@ -0,0 +1,229 @@
+package efivarfs
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"golang.org/x/time/rate"
+)
+
+// Client provides access to EFI variables through a Backend and adds
+// the behavior selected with Options on top of it. A Client is a Backend
+// itself, so it can be used wherever one is expected.
+type Client struct {
+	backend  Backend
+	logger   *slog.Logger
+	tracer   trace.Tracer
+	ctx      context.Context
+	limiter  *rate.Limiter
+	sync     bool
+	lockDir  string
+	force    bool
+	dryRun   func(Change)
+	mount    bool
+	root     string
+	order    SortOrder
+	maxSize  int
+	dups     bool
+	policy   *Policy
+	progress func(Progress)
+	hooks    hooks
+	runtime  *runtimeCheck
+
+	readOnlyCheck bool
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithLogger makes the Client log probing, immutable flag changes and
+// the size of every operation at debug level to logger. Failures of
+// the underlying backend are logged as well, which helps where the
+// returned error alone doesn't tell what went wrong.
+func WithLogger(logger *slog.Logger) Option {
+	return func(c *Client) {
+		c.logger = logger
+	}
+}
+
+// WithSync makes Set return only once the write is durable. The kernel
+// passes every write to efivarfs synchronously to the SetVariable
+// runtime service, which only returns after the firmware has committed
+// the update, so there this just adds O_DSYNC as a safeguard. For
+// snapshot directories returned by Dir the file and directory are
+// synced, which would otherwise sit in the page cache. Callers
+// orchestrating forced power cycles right after an update should use it
+// to not depend on these details.
+func WithSync() Option {
+	return func(c *Client) {
+		c.sync = true
+	}
+}
+
+// NewClient returns a Client using b for all operations.
+func NewClient(b Backend, opts ...Option) *Client {
+	c := &Client{
+		tracer:  noop.NewTracerProvider().Tracer(""),
+		ctx:     context.Background(),
+		maxSize: DefaultMaxVariableSize,
+		runtime: &runtimeCheck{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.backend = c.configure(b)
+	return c
+}
+
+// Open probes for efivarfs like Probe and returns a Client using it.
+// With WithMount it is mounted first if needed.
+func Open(opts ...Option) (*Client, error) {
+	c := NewClient(nil, opts...)
+	if c.mount {
+		if err := mount(c.logger, c.root); err != nil {
+			return nil, err
+		}
+	}
+	b, err := probe(c.logger, c.root)
+	if err != nil {
+		return nil, err
+	}
+	c.backend = c.configure(b)
+	return c, nil
+}
+
+// configure applies the options that are implemented by the efivarfs
+// backend itself to a copy of b and wraps the result for WithDryRun.
+func (c *Client) configure(b Backend) Backend {
+	if v, ok := b.(*efivarfs); ok {
+		v2 := *v
+		v2.logger = c.logger
+		v2.sync = c.sync
+		v2.unsorted = c.order != SortByName
+		b = &v2
+	}
+	if c.dryRun != nil && b != nil {
+		b = newDryRun(b, c.dryRun)
+	}
+	return b
+}
+
+// Get returns the attributes and data of a variable.
+func (c *Client) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	span := c.startSpan("Get", desc)
+	attrs, data, err := c.backend.Get(desc)
+	endSpan(span, len(data), err)
+	if err != nil {
+		c.debug("get failed", desc, "err", err)
+		return 0, nil, err
+	}
+	c.debug("get", desc, "attributes", attrs, "size", len(data))
+	return attrs, data, nil
+}
+
+// Set creates or overwrites a variable. Unless WithForce is used, attrs
+// are checked with ValidateAttributes first. data larger than the size
+// set with WithMaxVariableSize is rejected with ErrVariableTooLarge and
+// writes denied by the Policy set with WithPolicy with a *PolicyError.
+// On firmware announcing in RuntimeServicesSupported that it lacks the
+// SetVariable runtime service, writes fail with ErrSetVariableUnsupported
+// unless WithForce is used, and with WithReadOnlyCheck writes to
+// variables the firmware protects with ErrReadOnlyVariable. The hooks
+// registered with OnBeforeWrite and OnAfterWrite are called around the
+// write.
+func (c *Client) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	if !c.force {
+		if err := ValidateAttributes(desc, attrs); err != nil {
+			return err
+		}
+		if err := c.checkSetVariable("set", desc); err != nil {
+			return err
+		}
+	}
+	if err := c.validateSize(desc, data); err != nil {
+		return err
+	}
+	if err := c.checkPolicy("set", desc, attrs, len(data)); err != nil {
+		return err
+	}
+	if err := c.checkReadOnly("set", desc); err != nil {
+		return err
+	}
+	// The hooks run first, so canceled writes don't use up the rate
+	// limit
+	if err := c.beforeWrite(desc, attrs, data); err != nil {
+		return err
+	}
+	if err := c.allowWrite("set", desc); err != nil {
+		return err
+	}
+	span := c.startSpan("Set", desc, attrs)
+	err := c.backend.Set(desc, attrs, data)
+	endSpan(span, len(data), err)
+	c.afterWrite(desc, attrs, data, err)
+	if err != nil {
+		c.debug("set failed", desc, "attributes", attrs, "size", len(data), "err", err)
+		return err
+	}
+	c.debug("set", desc, "attributes", attrs, "size", len(data))
+	return nil
+}
+
+// Remove deletes a variable, unless a hook registered with OnDelete
+// cancels it. Like Set it fails with ErrSetVariableUnsupported on
+// firmware lacking SetVariable.
+func (c *Client) Remove(desc VariableDescriptor) error {
+	if !c.force {
+		if err := c.checkSetVariable("remove", desc); err != nil {
+			return err
+		}
+	}
+	if err := c.checkPolicy("remove", desc, 0, 0); err != nil {
+		return err
+	}
+	if err := c.checkReadOnly("remove", desc); err != nil {
+		return err
+	}
+	if err := c.beforeDelete(desc); err != nil {
+		return err
+	}
+	if err := c.allowWrite("remove", desc); err != nil {
+		return err
+	}
+	span := c.startSpan("Remove", desc)
+	err := c.backend.Remove(desc)
+	endSpan(span, 0, err)
+	if err != nil {
+		c.debug("remove failed", desc, "err", err)
+		return err
+	}
+	c.debug("remove", desc)
+	return nil
+}
+
+// List returns the descriptors of all variables in the order selected
+// with WithSortOrder.
+func (c *Client) List() ([]VariableDescriptor, error) {
+	_, span := c.tracer.Start(c.ctx, spanPrefix+"List", trace.WithSpanKind(trace.SpanKindClient))
+	descs, err := c.backend.List()
+	endList(span, len(descs), err)
+	if err != nil {
+		debug(c.logger, "list failed", "err", err)
+		return nil, err
+	}
+	c.sort(descs)
+	debug(c.logger, "list", "count", len(descs))
+	if c.dups {
+		if dups := Duplicates(descs); len(dups) > 0 {
+			return descs, &DuplicateError{Groups: dups}
+		}
+	}
+	return descs, nil
+}
+
+// debug logs msg together with the name and GUID of desc.
+func (c *Client) debug(msg string, desc VariableDescriptor, args ...any) {
+	debug(c.logger, msg, append([]any{"name", desc.Name, "guid", desc.GUID}, args...)...)
+}
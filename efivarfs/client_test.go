@@ -0,0 +1,56 @@
+package efivarfs
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"reflect"
+	"testing"
+)
+
+// logRecorder is a slog.Handler keeping the messages and attributes of
+// all records.
+type logRecorder struct {
+	records []map[string]string
+}
+
+func (h *logRecorder) Enabled(context.Context, slog.Level) bool { return true }
+func (h *logRecorder) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *logRecorder) WithGroup(string) slog.Handler            { return h }
+
+func (h *logRecorder) Handle(_ context.Context, r slog.Record) error {
+	rec := map[string]string{"msg": r.Message, "level": r.Level.String()}
+	r.Attrs(func(a slog.Attr) bool {
+		rec[a.Key] = a.Value.String()
+		return true
+	})
+	h.records = append(h.records, rec)
+	return nil
+}
+
+func TestLogger(t *testing.T) {
+	h := &logRecorder{}
+	c := NewClient(Dir(t.TempDir()), WithLogger(slog.New(h)))
+	timeout := NewDescriptor("Timeout", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	if err := c.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Get(timeout); err != nil {
+		t.Fatal(err)
+	}
+	missing := NewDescriptor("Missing", GlobalVariable)
+	if _, _, err := c.Get(missing); !errors.Is(err, ErrVarNotExist) {
+		t.Fatalf("Get() = %v of a missing variable", err)
+	}
+
+	guid := GlobalVariable.String()
+	want := []map[string]string{
+		{"msg": "set", "level": "DEBUG", "name": "Timeout", "guid": guid, "attributes": "NV|BS|RT", "size": "2"},
+		{"msg": "get", "level": "DEBUG", "name": "Timeout", "guid": guid, "attributes": "NV|BS|RT", "size": "2"},
+		{"msg": "get failed", "level": "DEBUG", "name": "Missing", "guid": guid, "err": ErrVarNotExist.Error()},
+	}
+	if !reflect.DeepEqual(h.records, want) {
+		t.Errorf("logged %v, want %v", h.records, want)
+	}
+}
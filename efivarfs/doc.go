@@ -0,0 +1,80 @@
+// Package efivarfs reads and writes EFI variables through the efivarfs
+// of the Linux kernel or snapshot directories of the same layout, and
+// adds dry runs, logging, tracing, locking and other behavior on top of
+// them with the Options of a Client.
+//
+// # Concurrency
+//
+// Backends and Clients may be used by multiple goroutines at once; the
+// firmware serializes the variable accesses anyway. RegisterWellKnown
+// is synchronized as well.
+//
+// The locations of the kernel interfaces, EfiVarFs, ProcMounts,
+// SysKernelLockdown, SysFirmwareEFI and KernelLog, are plain variables
+// for tests to point them to fake files. Assigning them is only safe
+// before the package is used by other goroutines. SetPaths changes them
+// at any time, and WithMountPoint selects the mount point of a single
+// Client without touching any global state.
+package efivarfs
+
+import "sync/atomic"
+
+// Paths are the locations of the kernel interfaces the package uses.
+type Paths struct {
+	// EfiVarFs is where efivarfs is usually mounted
+	EfiVarFs string
+	// ProcMounts is the mount table searched for efivarfs otherwise
+	ProcMounts string
+	// SysKernelLockdown reports the kernel lockdown mode
+	SysKernelLockdown string
+	// SysFirmwareEFI holds the firmware bitness
+	SysFirmwareEFI string
+	// KernelLog holds the boot messages reporting the UEFI revision
+	KernelLog string
+}
+
+// paths holds the Paths set with SetPaths, nil until it is called.
+var paths atomic.Pointer[Paths]
+
+// SetPaths atomically replaces the locations of the kernel interfaces,
+// overriding EfiVarFs, ProcMounts, SysKernelLockdown, SysFirmwareEFI
+// and KernelLog. Empty fields keep the current location.
+func SetPaths(p Paths) {
+	for {
+		old := paths.Load()
+		cur, next := CurrentPaths(), p
+		if next.EfiVarFs == "" {
+			next.EfiVarFs = cur.EfiVarFs
+		}
+		if next.ProcMounts == "" {
+			next.ProcMounts = cur.ProcMounts
+		}
+		if next.SysKernelLockdown == "" {
+			next.SysKernelLockdown = cur.SysKernelLockdown
+		}
+		if next.SysFirmwareEFI == "" {
+			next.SysFirmwareEFI = cur.SysFirmwareEFI
+		}
+		if next.KernelLog == "" {
+			next.KernelLog = cur.KernelLog
+		}
+		if paths.CompareAndSwap(old, &next) {
+			return
+		}
+	}
+}
+
+// CurrentPaths returns the Paths set with SetPaths or, if it wasn't
+// called, the values of the package variables.
+func CurrentPaths() Paths {
+	if p := paths.Load(); p != nil {
+		return *p
+	}
+	return Paths{
+		EfiVarFs:          EfiVarFs,
+		ProcMounts:        ProcMounts,
+		SysKernelLockdown: SysKernelLockdown,
+		SysFirmwareEFI:    SysFirmwareEFI,
+		KernelLog:         KernelLog,
+	}
+}
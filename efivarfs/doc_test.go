@@ -0,0 +1,31 @@
+package efivarfs
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestSetPaths is meant to be run with -race.
+func TestSetPaths(t *testing.T) {
+	old := CurrentPaths()
+	t.Cleanup(func() { SetPaths(old) })
+
+	dir := t.TempDir()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			SetPaths(Paths{EfiVarFs: dir})
+		}()
+		go func() {
+			defer wg.Done()
+			MountPoint()
+			ReadLockdown()
+		}()
+	}
+	wg.Wait()
+	if p := CurrentPaths(); p.EfiVarFs != dir || p.ProcMounts != old.ProcMounts {
+		t.Errorf("CurrentPaths() = %+v", p)
+	}
+}
@@ -0,0 +1,181 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Change is a modification a Client created with WithDryRun would have
+// made.
+type Change struct {
+	// Op is either "set" or "remove"
+	Op   string
+	Desc VariableDescriptor
+	// Attributes and Data are the arguments of Set
+	Attributes VariableAttributes
+	Data       []byte
+	// Existed is set if the variable existed before, in which case
+	// OldAttributes and OldData are its previous content
+	Existed       bool
+	OldAttributes VariableAttributes
+	OldData       []byte
+}
+
+// String describes the change in a single line, including the range of
+// bytes differing from the previous content.
+func (c Change) String() string {
+	name := c.Desc.String()
+	if c.Op == "remove" {
+		if !c.Existed {
+			return fmt.Sprintf("remove %s (does not exist)", name)
+		}
+		return fmt.Sprintf("remove %s (%d bytes, %s)", name, len(c.OldData), c.OldAttributes)
+	}
+	if c.Attributes&AttributeAppendWrite != 0 {
+		return fmt.Sprintf("append %d bytes to %s (%d bytes)", len(c.Data), name, len(c.OldData))
+	}
+	var s strings.Builder
+	fmt.Fprintf(&s, "set %s %s %d bytes", name, c.Attributes, len(c.Data))
+	switch {
+	case !c.Existed:
+		s.WriteString(" (new)")
+	case c.OldAttributes != c.Attributes:
+		fmt.Fprintf(&s, " (was %d bytes, %s)", len(c.OldData), c.OldAttributes)
+	case string(c.OldData) == string(c.Data):
+		s.WriteString(" (unchanged)")
+	default:
+		first, last := diffRange(c.OldData, c.Data)
+		fmt.Fprintf(&s, " (was %d bytes, bytes %d-%d differ)", len(c.OldData), first, last)
+	}
+	return s.String()
+}
+
+// diffRange returns the first and last offset at which a and b differ,
+// counting bytes present in only one of them as different.
+func diffRange(a, b []byte) (first, last int) {
+	n := min(len(a), len(b))
+	for first < n && a[first] == b[first] {
+		first++
+	}
+	i, j := len(a)-1, len(b)-1
+	for i >= first && j >= first && a[i] == b[j] {
+		i--
+		j--
+	}
+	return first, max(i, j)
+}
+
+// WithDryRun makes the Client pass Set and Remove to report instead of
+// the backend. Reads see the reported changes as if they had been made,
+// so multi-step operations like creating a boot entry and adding it to
+// BootOrder report the same writes as they would perform.
+func WithDryRun(report func(Change)) Option {
+	return func(c *Client) {
+		c.dryRun = report
+	}
+}
+
+// dryRun is a Backend decorator keeping modifications in memory.
+type dryRun struct {
+	b      Backend
+	report func(Change)
+
+	mu      sync.Mutex
+	pending map[VariableKey]*Change
+}
+
+func newDryRun(b Backend, report func(Change)) *dryRun {
+	return &dryRun{b: b, report: report, pending: make(map[VariableKey]*Change)}
+}
+
+// current returns the content of desc including pending changes. The
+// caller holds mu.
+func (d *dryRun) current(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	if c, ok := d.pending[desc.Key()]; ok {
+		if c.Op == "remove" {
+			return 0, nil, ErrVarNotExist
+		}
+		return c.Attributes &^ AttributeAppendWrite, c.Data, nil
+	}
+	return d.b.Get(desc)
+}
+
+func (d *dryRun) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.current(desc)
+}
+
+func (d *dryRun) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, err := d.change("set", desc)
+	if err != nil {
+		return err
+	}
+	c.Attributes, c.Data = attrs, append([]byte(nil), data...)
+	d.report(*c)
+	if attrs&AttributeAppendWrite != 0 {
+		c.Data = append(append([]byte(nil), c.OldData...), data...)
+	}
+	d.pending[desc.Key()] = c
+	return nil
+}
+
+func (d *dryRun) Remove(desc VariableDescriptor) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	c, err := d.change("remove", desc)
+	if err != nil {
+		return err
+	}
+	d.report(*c)
+	if !c.Existed {
+		return ErrVarNotExist
+	}
+	d.pending[desc.Key()] = c
+	return nil
+}
+
+// change returns a Change of desc with the current content filled in.
+func (d *dryRun) change(op string, desc VariableDescriptor) (*Change, error) {
+	c := &Change{Op: op, Desc: desc}
+	attrs, data, err := d.current(desc)
+	switch {
+	case errors.Is(err, ErrVarNotExist):
+	case err != nil:
+		return nil, err
+	default:
+		c.Existed, c.OldAttributes, c.OldData = true, attrs, data
+	}
+	return c, nil
+}
+
+func (d *dryRun) List() ([]VariableDescriptor, error) {
+	descs, err := d.b.List()
+	if err != nil {
+		return nil, err
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	listed := make(map[VariableKey]bool, len(descs))
+	kept := descs[:0]
+	for _, desc := range descs {
+		k := desc.Key()
+		listed[k] = true
+		if c, ok := d.pending[k]; !ok || c.Op != "remove" {
+			kept = append(kept, desc)
+		}
+	}
+	var added []VariableDescriptor
+	for k, c := range d.pending {
+		if c.Op == "set" && !listed[k] {
+			added = append(added, c.Desc)
+		}
+	}
+	slices.SortFunc(added, Compare)
+	return append(kept, added...), nil
+}
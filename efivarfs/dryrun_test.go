@@ -0,0 +1,79 @@
+package efivarfs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDiffRange(t *testing.T) {
+	for _, tt := range []struct {
+		a, b        string
+		first, last int
+	}{
+		{"abc", "abd", 2, 2},
+		{"abc", "xbc", 0, 0},
+		{"abc", "axc", 1, 1},
+		{"abcd", "axyd", 1, 2},
+		{"abc", "abcd", 3, 3},
+		{"abcd", "abc", 3, 3},
+		{"bc", "abc", 0, 0},
+		{"aXa", "aa", 1, 1},
+		{"", "ab", 0, 1},
+		{"ab", "", 0, 1},
+	} {
+		if first, last := diffRange([]byte(tt.a), []byte(tt.b)); first != tt.first || last != tt.last {
+			t.Errorf("diffRange(%q, %q) = %d, %d, want %d, %d", tt.a, tt.b, first, last, tt.first, tt.last)
+		}
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	b := Dir(t.TempDir())
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	if err := b.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	var changes []string
+	c := NewClient(b, WithDryRun(func(c Change) { changes = append(changes, c.String()) }))
+	lang := VariableDescriptor{Name: "PlatformLang", GUID: &GlobalVariable}
+	for _, err := range []error{
+		c.Set(timeout, attrs, []byte{5, 0}),
+		c.Set(timeout, attrs, []byte{3, 0}),
+		c.Set(timeout, AttributeNonVolatile|AttributeBootserviceAccess, []byte{3, 0}),
+		c.Set(lang, attrs, []byte("en")),
+		c.Set(lang, attrs|AttributeAppendWrite, []byte("-US")),
+		c.Remove(timeout),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Remove(timeout); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Remove() = %v of a variable removed in the dry run, want ErrVarNotExist", err)
+	}
+	want := []string{
+		"set Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c NV|BS|RT 2 bytes (unchanged)",
+		"set Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c NV|BS|RT 2 bytes (was 2 bytes, bytes 0-0 differ)",
+		"set Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c NV|BS 2 bytes (was 2 bytes, NV|BS|RT)",
+		"set PlatformLang-8be4df61-93ca-11d2-aa0d-00e098032b8c NV|BS|RT 2 bytes (new)",
+		"append 3 bytes to PlatformLang-8be4df61-93ca-11d2-aa0d-00e098032b8c (2 bytes)",
+		"remove Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c (2 bytes, NV|BS)",
+		"remove Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c (does not exist)",
+	}
+	if len(changes) != len(want) {
+		t.Fatalf("reported %q, want %q", changes, want)
+	}
+	for i := range want {
+		if changes[i] != want[i] {
+			t.Errorf("change %d = %q, want %q", i, changes[i], want[i])
+		}
+	}
+	if _, data, err := c.Get(lang); err != nil || string(data) != "en-US" {
+		t.Errorf("Get() = %q, %v after appending in the dry run, want en-US", data, err)
+	}
+	if _, _, err := b.Get(lang); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Get() = %v from the backend, the dry run wrote to it", err)
+	}
+}
@@ -0,0 +1,75 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ErrDuplicateVariable is caused by List returning several variables
+// that only differ in the case of their GUID or the Unicode
+// normalization form of their name, with WithDuplicateCheck.
+var ErrDuplicateVariable = errors.New("duplicate variables")
+
+// DuplicateError lists the near-duplicates found by List.
+type DuplicateError struct {
+	// Groups holds the variables that are the same after normalizing,
+	// each group with at least two of them
+	Groups [][]VariableDescriptor
+}
+
+func (e *DuplicateError) Error() string {
+	groups := make([]string, len(e.Groups))
+	for i, g := range e.Groups {
+		names := make([]string, len(g))
+		for j, desc := range g {
+			names[j] = fmt.Sprintf("%q", desc)
+		}
+		groups[i] = strings.Join(names, " and ")
+	}
+	return fmt.Sprintf("%v: %s", ErrDuplicateVariable, strings.Join(groups, ", "))
+}
+
+func (e *DuplicateError) Unwrap() error {
+	return ErrDuplicateVariable
+}
+
+// WithDuplicateCheck makes List fail with a *DuplicateError if the
+// backend returns variables that only differ in the case of their GUID
+// or the normalization form of their name, e.g. a name once in NFC and
+// once in NFD. Some buggy firmware creates such variables, which the
+// kernel then lists as unrelated files. The variables are returned
+// together with the error, so callers can still show them.
+func WithDuplicateCheck() Option {
+	return func(c *Client) {
+		c.dups = true
+	}
+}
+
+// Duplicates returns the groups of descs that only differ in the case
+// of their GUID or the normalization form of their name, in the order
+// of their first element in descs.
+func Duplicates(descs []VariableDescriptor) [][]VariableDescriptor {
+	seen := make(map[string]int, len(descs))
+	var groups [][]VariableDescriptor
+	for _, desc := range descs {
+		// GUIDs are parsed, so differently cased ones are equal here
+		k := norm.NFC.String(desc.Name) + "-" + desc.GUID.String()
+		i, ok := seen[k]
+		if !ok {
+			seen[k] = len(groups)
+			groups = append(groups, []VariableDescriptor{desc})
+			continue
+		}
+		groups[i] = append(groups[i], desc)
+	}
+	dups := groups[:0]
+	for _, g := range groups {
+		if len(g) > 1 {
+			dups = append(dups, g)
+		}
+	}
+	return dups
+}
@@ -0,0 +1,37 @@
+package efivarfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDuplicateCheck(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{
+		"Caf\u00e9-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		"Cafe\u0301-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		"Timeout-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+		"Timeout-8BE4DF61-93CA-11D2-AA0D-00E098032B8C",
+		"BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{7, 0, 0, 0, 1}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := NewClient(Dir(dir)).List(); err != nil {
+		t.Fatalf("List() without check = %v", err)
+	}
+	descs, err := NewClient(Dir(dir), WithDuplicateCheck()).List()
+	var dup *DuplicateError
+	if !errors.As(err, &dup) || !errors.Is(err, ErrDuplicateVariable) {
+		t.Fatalf("List() = %v, want DuplicateError", err)
+	}
+	if len(descs) != 5 {
+		t.Errorf("List() returned %d variables, want 5", len(descs))
+	}
+	if len(dup.Groups) != 2 || len(dup.Groups[0]) != 2 || len(dup.Groups[1]) != 2 {
+		t.Errorf("Groups = %v, want two pairs", dup.Groups)
+	}
+}
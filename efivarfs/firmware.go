@@ -0,0 +1,123 @@
+package efivarfs
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SysFirmwareEFI holds the EFI information the kernel exports in sysfs
+var SysFirmwareEFI = "/sys/firmware/efi"
+
+// KernelLog holds the kernel messages of the current boot, which are the
+// only place the kernel reports the revision of the EFI system table.
+var KernelLog = "/dev/kmsg"
+
+// Revision is the UEFI revision of the firmware as encoded in the EFI
+// system table, the major revision in the upper and the minor one in the
+// lower 16 bits, e.g. 0x0002001f for 2.3.1.
+type Revision uint32
+
+// Revision28 introduced enhanced authenticated variables.
+const Revision28 Revision = 2<<16 | 80
+
+// Major returns the major revision, e.g. 2 for 2.3.1.
+func (r Revision) Major() int {
+	return int(r >> 16)
+}
+
+// Minor returns the minor revision, e.g. 31 for 2.3.1.
+func (r Revision) Minor() int {
+	return int(r & 0xffff)
+}
+
+// String returns the revision the way the specification names it, e.g.
+// "2.7" or "2.3.1".
+func (r Revision) String() string {
+	if r == 0 {
+		return "unknown"
+	}
+	s := fmt.Sprintf("%d.%d", r.Major(), r.Minor()/10)
+	if r.Minor()%10 != 0 {
+		s += fmt.Sprintf(".%d", r.Minor()%10)
+	}
+	return s
+}
+
+// EnhancedAuthentication reports whether the firmware implements a UEFI
+// revision supporting AttributeEnhancedAuthenticatedAccess.
+func (c Capabilities) EnhancedAuthentication() bool {
+	return c.Revision >= Revision28
+}
+
+// ReadPlatformSize returns the bitness of the firmware, 32 or 64, from
+// fw_platform_size in SysFirmwareEFI, or 0 if it can't be read.
+func ReadPlatformSize() int {
+	b, err := os.ReadFile(filepath.Join(CurrentPaths().SysFirmwareEFI, "fw_platform_size"))
+	if err != nil {
+		return 0
+	}
+	switch n, _ := strconv.Atoi(strings.TrimSpace(string(b))); n {
+	case 32, 64:
+		return n
+	}
+	return 0
+}
+
+// ReadRevision returns the UEFI revision of the firmware, or 0 if it
+// can't be read. The kernel doesn't export the revision of the system
+// table in sysfs, so it is taken from the line it logs at boot, e.g.
+// "efi: EFI v2.7 by EDK II", in KernelLog. Reading it needs the
+// privileges of dmesg and fails once the line was overwritten.
+func ReadRevision() Revision {
+	b, err := readKernelLog(CurrentPaths().KernelLog)
+	if err != nil {
+		return 0
+	}
+	var r Revision
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		_, banner, ok := strings.Cut(s.Text(), "efi: EFI v")
+		if !ok {
+			continue
+		}
+		version, _, _ := strings.Cut(banner, " ")
+		if rev, ok := parseRevision(version); ok {
+			r = rev
+		}
+	}
+	return r
+}
+
+// parseRevision parses the revision as the kernel logs it. Current
+// kernels print the minor revision divided by ten followed by the
+// remainder unless it is 0, e.g. "2.7" or "2.3.1", while kernels before
+// 5.7 printed it with two digits, e.g. "2.70" or "2.31". A two digit
+// minor revision below 20 is taken as the current format, so "2.10" is
+// UEFI 2.10 rather than 2.1, which no firmware booting such kernels
+// implements.
+func parseRevision(s string) (Revision, bool) {
+	parts := strings.Split(s, ".")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, false
+	}
+	var n [3]uint64
+	for i, p := range parts {
+		var err error
+		if n[i], err = strconv.ParseUint(p, 10, 16); err != nil {
+			return 0, false
+		}
+	}
+	minor := n[1]*10 + n[2]
+	if len(parts) == 2 && len(parts[1]) > 1 && n[1] >= 20 {
+		minor = n[1]
+	}
+	if minor > 0xffff {
+		return 0, false
+	}
+	return Revision(n[0]<<16 | minor), true
+}
@@ -0,0 +1,11 @@
+//go:build !unix
+
+package efivarfs
+
+import "os"
+
+// readKernelLog returns the content of the kernel log at path, which
+// only exists on Linux.
+func readKernelLog(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
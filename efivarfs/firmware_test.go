@@ -0,0 +1,56 @@
+package efivarfs
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseRevision(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want Revision
+	}{
+		{"2.7", 0x00020046},
+		{"2.3.1", 0x0002001f},
+		{"2.10", 0x00020064},
+		{"2.70", 0x00020046},
+		{"2.31", 0x0002001f},
+		{"1.10", 0x00010064},
+		{"2", 0},
+		{"2.x", 0},
+	} {
+		got, ok := parseRevision(tt.in)
+		if got != tt.want || ok != (tt.want != 0) {
+			t.Errorf("parseRevision(%q) = %#x, %v, want %#x", tt.in, uint32(got), ok, uint32(tt.want))
+		}
+	}
+	if s := Revision(0x0002001f).String(); s != "2.3.1" {
+		t.Errorf("String() = %s, want 2.3.1", s)
+	}
+}
+
+func TestReadFirmware(t *testing.T) {
+	old := CurrentPaths()
+	t.Cleanup(func() { SetPaths(old) })
+
+	dir := t.TempDir()
+	log := filepath.Join(dir, "kmsg")
+	SetPaths(Paths{SysFirmwareEFI: dir, KernelLog: log})
+	if size, rev := ReadPlatformSize(), ReadRevision(); size != 0 || rev != 0 {
+		t.Errorf("ReadPlatformSize(), ReadRevision() = %d, %s without sysfs", size, rev)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "fw_platform_size"), []byte("32\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(log, []byte("6,0,0,-;Linux version 6.8.0\n"+
+		"6,1,0,-;efi: EFI v2.8 by American Megatrends\n"+
+		"6,2,0,-;efi: ACPI=0x7a8b1000 ACPI 2.0=0x7a8b1014 SMBIOS=0x7b1f5000\n"), 0o444); err != nil {
+		t.Fatal(err)
+	}
+	caps := Capabilities{PlatformSize: ReadPlatformSize(), Revision: ReadRevision()}
+	if caps.PlatformSize != 32 || caps.Revision.String() != "2.8" || !caps.EnhancedAuthentication() {
+		t.Errorf("read %+v, want 32 bit UEFI 2.8 firmware supporting enhanced authentication", caps)
+	}
+}
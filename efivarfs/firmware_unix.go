@@ -0,0 +1,41 @@
+//go:build unix
+
+package efivarfs
+
+import (
+	"errors"
+
+	"golang.org/x/sys/unix"
+)
+
+// readKernelLog returns the records of the kernel log at path without
+// blocking at its end, as reads of /dev/kmsg wait for new messages
+// otherwise. Regular files are read as they are for tests.
+func readKernelLog(path string) ([]byte, error) {
+	fd, err := unix.Open(path, unix.O_RDONLY|unix.O_NONBLOCK|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	var log []byte
+	buf := make([]byte, 8192)
+	for {
+		n, err := unix.Read(fd, buf)
+		switch {
+		case errors.Is(err, unix.EPIPE):
+			// Records were overwritten while reading, the next read
+			// continues with the oldest one left.
+			continue
+		case errors.Is(err, unix.EINTR):
+			continue
+		case errors.Is(err, unix.EAGAIN):
+			return log, nil
+		case err != nil:
+			return nil, err
+		case n == 0:
+			return log, nil
+		}
+		// Each read of /dev/kmsg returns one newline terminated record.
+		log = append(log, buf[:n]...)
+	}
+}
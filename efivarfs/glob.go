@@ -0,0 +1,38 @@
+package efivarfs
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// IsGlob reports whether s contains any of the special characters of
+// path.Match and is to be expanded with Glob.
+func IsGlob(s string) bool {
+	return strings.ContainsAny(s, `*?[\`)
+}
+
+// Glob returns the variables of b matching pattern in the order of List.
+// pattern is a name pattern using the syntax of path.Match, optionally
+// followed by "-" and a GUID, e.g. Boot00*-8be4df61-93ca-11d2-aa0d-00e098032b8c.
+// Without GUID variables of all vendors match.
+func Glob(b ReadBackend, pattern string) ([]VariableDescriptor, error) {
+	name, g := splitNameGUID(pattern)
+	if _, err := path.Match(name, ""); err != nil {
+		return nil, fmt.Errorf("pattern %q: %w", pattern, err)
+	}
+	descs, err := b.List()
+	if err != nil {
+		return nil, err
+	}
+	var matches []VariableDescriptor
+	for _, desc := range descs {
+		if g != nil && *desc.GUID != *g {
+			continue
+		}
+		if ok, _ := path.Match(name, desc.Name); ok {
+			matches = append(matches, desc)
+		}
+	}
+	return matches, nil
+}
@@ -0,0 +1,54 @@
+package efivarfs
+
+import (
+	"errors"
+	"path"
+	"reflect"
+	"testing"
+)
+
+func TestGlob(t *testing.T) {
+	b := Dir(t.TempDir())
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	shim := MustParseGUID("605dab50-e046-4300-abb6-3dd810dd8b23")
+	for _, desc := range []VariableDescriptor{
+		{Name: "Boot0000", GUID: &GlobalVariable},
+		{Name: "Boot0001", GUID: &GlobalVariable},
+		{Name: "BootOrder", GUID: &GlobalVariable},
+		{Name: "Boot0001", GUID: &shim},
+		{Name: "My-Var", GUID: &shim},
+	} {
+		if err := b.Set(desc, attrs, []byte{1}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		pattern string
+		want    []string
+	}{
+		{"Boot00*-8be4df61-93ca-11d2-aa0d-00e098032b8c", []string{"Boot0000", "Boot0001"}},
+		{"Boot00*-{8BE4DF61-93CA-11D2-AA0D-00E098032B8C}", []string{"Boot0000", "Boot0001"}},
+		{"Boot000?", []string{"Boot0000", "Boot0001", "Boot0001"}},
+		{"Boot*-605dab50-e046-4300-abb6-3dd810dd8b23", []string{"Boot0001"}},
+		{"My-*", []string{"My-Var"}},
+		{"My-Var-605dab50-e046-4300-abb6-3dd810dd8b23", []string{"My-Var"}},
+		{"My-*-8be4df61-93ca-11d2-aa0d-00e098032b8c", nil},
+		{"Nothing*", nil},
+	} {
+		descs, err := Glob(b, tt.pattern)
+		if err != nil {
+			t.Errorf("Glob(%q) failed: %v", tt.pattern, err)
+			continue
+		}
+		var got []string
+		for _, d := range descs {
+			got = append(got, d.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Glob(%q) = %q, want %q", tt.pattern, got, tt.want)
+		}
+	}
+	if _, err := Glob(b, "Boot[-8be4df61-93ca-11d2-aa0d-00e098032b8c"); !errors.Is(err, path.ErrBadPattern) {
+		t.Errorf("Glob() = %v for a malformed pattern, want path.ErrBadPattern", err)
+	}
+}
@@ -0,0 +1,143 @@
+package efivarfs
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	guid "github.com/google/uuid"
+)
+
+// DecodeGUID converts the 16 byte on-disk representation of an EFI_GUID
+// into a UUID. UEFI stores the first three fields little endian while
+// RFC 4122 uses big endian for all of them, so the bytes can not simply
+// be copied.
+func DecodeGUID(b []byte) guid.UUID {
+	var g guid.UUID
+	binary.BigEndian.PutUint32(g[0:4], binary.LittleEndian.Uint32(b[0:4]))
+	binary.BigEndian.PutUint16(g[4:6], binary.LittleEndian.Uint16(b[4:6]))
+	binary.BigEndian.PutUint16(g[6:8], binary.LittleEndian.Uint16(b[6:8]))
+	copy(g[8:], b[8:16])
+	return g
+}
+
+// EncodeGUID is the inverse of DecodeGUID and returns the mixed endian
+// EFI_GUID representation of g.
+func EncodeGUID(g guid.UUID) [16]byte {
+	var b [16]byte
+	binary.LittleEndian.PutUint32(b[0:4], binary.BigEndian.Uint32(g[0:4]))
+	binary.LittleEndian.PutUint16(b[4:6], binary.BigEndian.Uint16(g[4:6]))
+	binary.LittleEndian.PutUint16(b[6:8], binary.BigEndian.Uint16(g[6:8]))
+	copy(b[8:], g[8:])
+	return b
+}
+
+// ErrInvalidGUID is caused by text that isn't a GUID in any of the
+// formats ParseGUID understands
+var ErrInvalidGUID = errors.New("invalid GUID")
+
+// GUIDFormat selects the textual representation of FormatGUID.
+type GUIDFormat int
+
+const (
+	// GUIDCanonical is the lowercase RFC 4122 form used by efivarfs,
+	// e.g. 8be4df61-93ca-11d2-aa0d-00e098032b8c
+	GUIDCanonical GUIDFormat = iota
+	// GUIDUpper is GUIDCanonical in uppercase
+	GUIDUpper
+	// GUIDWindows is the braced uppercase form of the Windows registry
+	// and tools, e.g. {8BE4DF61-93CA-11D2-AA0D-00E098032B8C}
+	GUIDWindows
+	// GUIDHex is the 32 lowercase hex digits without any separators
+	GUIDHex
+	// GUIDEDK2 is the C initializer used in edk2 sources and .dec files,
+	// e.g. {0x8be4df61, 0x93ca, 0x11d2, {0xaa, 0x0d, 0x00, 0xe0, 0x98, 0x03, 0x2b, 0x8c}}
+	GUIDEDK2
+)
+
+// ParseGUID parses a GUID in any of the formats of GUIDFormat, ignoring
+// case. It is more lenient than guid.Parse, which only accepts some of
+// them, so GUIDs can be copied from Windows or edk2 as they are.
+func ParseGUID(s string) (guid.UUID, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(s, "{") && strings.Contains(strings.ToLower(s), "0x") {
+		return parseEDK2GUID(s)
+	}
+	if len(s) >= 2 && s[0] == '{' && s[len(s)-1] == '}' {
+		s = s[1 : len(s)-1]
+	}
+	switch len(s) {
+	case 32, 36:
+		if g, err := guid.Parse(s); err == nil {
+			return g, nil
+		}
+	}
+	return guid.UUID{}, fmt.Errorf("%q: %w", s, ErrInvalidGUID)
+}
+
+// parseEDK2GUID parses the GUIDEDK2 format.
+func parseEDK2GUID(s string) (guid.UUID, error) {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return r == '{' || r == '}' || r == ',' || unicode.IsSpace(r)
+	})
+	// The widths of the fields of an EFI_GUID in bits
+	widths := [11]int{32, 16, 16, 8, 8, 8, 8, 8, 8, 8, 8}
+	if len(fields) != len(widths) {
+		return guid.UUID{}, fmt.Errorf("%q: %w", s, ErrInvalidGUID)
+	}
+	var g guid.UUID
+	off := 0
+	for i, f := range fields {
+		if !strings.HasPrefix(strings.ToLower(f), "0x") {
+			return guid.UUID{}, fmt.Errorf("%q: %w", s, ErrInvalidGUID)
+		}
+		n, err := strconv.ParseUint(f[2:], 16, widths[i])
+		if err != nil {
+			return guid.UUID{}, fmt.Errorf("%q: %w", s, ErrInvalidGUID)
+		}
+		// The fields are the big endian parts of the RFC 4122 form
+		for b := widths[i]/8 - 1; b >= 0; b-- {
+			g[off] = byte(n >> (8 * b))
+			off++
+		}
+	}
+	return g, nil
+}
+
+// MustParseGUID is like ParseGUID but panics if s can't be parsed. It
+// is meant for initializing variables with well known GUIDs.
+func MustParseGUID(s string) guid.UUID {
+	g, err := ParseGUID(s)
+	if err != nil {
+		panic(err)
+	}
+	return g
+}
+
+// FormatGUID returns the textual representation of g in format f.
+func FormatGUID(g guid.UUID, f GUIDFormat) string {
+	switch f {
+	case GUIDUpper:
+		return strings.ToUpper(g.String())
+	case GUIDWindows:
+		return "{" + strings.ToUpper(g.String()) + "}"
+	case GUIDHex:
+		return hex.EncodeToString(g[:])
+	case GUIDEDK2:
+		var b strings.Builder
+		fmt.Fprintf(&b, "{0x%08x, 0x%04x, 0x%04x, {", binary.BigEndian.Uint32(g[0:4]), binary.BigEndian.Uint16(g[4:6]), binary.BigEndian.Uint16(g[6:8]))
+		for i, c := range g[8:] {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(&b, "0x%02x", c)
+		}
+		b.WriteString("}}")
+		return b.String()
+	}
+	return g.String()
+}
@@ -0,0 +1,67 @@
+package efivarfs
+
+import "fmt"
+
+// hooks are the functions registered with OnBeforeWrite, OnAfterWrite
+// and OnDelete, called in the order they were registered.
+type hooks struct {
+	beforeWrite []func(VariableDescriptor, VariableAttributes, []byte) error
+	afterWrite  []func(VariableDescriptor, VariableAttributes, []byte, error)
+	delete      []func(VariableDescriptor) error
+}
+
+// OnBeforeWrite makes Set call hook once a write passed all other checks
+// of the Client, right before it reaches the backend. If hook returns an
+// error, the write is canceled and Set returns it wrapped, so hook can
+// e.g. ask the user for confirmation. hook must not modify data.
+func OnBeforeWrite(hook func(desc VariableDescriptor, attrs VariableAttributes, data []byte) error) Option {
+	return func(c *Client) {
+		c.hooks.beforeWrite = append(c.hooks.beforeWrite, hook)
+	}
+}
+
+// OnAfterWrite makes Set call hook with the result of every write that
+// reached the backend, e.g. to log it or to invalidate a cache.
+func OnAfterWrite(hook func(desc VariableDescriptor, attrs VariableAttributes, data []byte, err error)) Option {
+	return func(c *Client) {
+		c.hooks.afterWrite = append(c.hooks.afterWrite, hook)
+	}
+}
+
+// OnDelete makes Remove call hook right before a variable is removed
+// through the backend. Like with OnBeforeWrite, an error returned by hook
+// cancels the removal.
+func OnDelete(hook func(desc VariableDescriptor) error) Option {
+	return func(c *Client) {
+		c.hooks.delete = append(c.hooks.delete, hook)
+	}
+}
+
+// beforeWrite runs the OnBeforeWrite hooks until one fails.
+func (c *Client) beforeWrite(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	for _, hook := range c.hooks.beforeWrite {
+		if err := hook(desc, attrs, data); err != nil {
+			c.debug("set canceled by hook", desc, "err", err)
+			return fmt.Errorf("set %s: %w", desc, err)
+		}
+	}
+	return nil
+}
+
+// afterWrite runs the OnAfterWrite hooks.
+func (c *Client) afterWrite(desc VariableDescriptor, attrs VariableAttributes, data []byte, err error) {
+	for _, hook := range c.hooks.afterWrite {
+		hook(desc, attrs, data, err)
+	}
+}
+
+// beforeDelete runs the OnDelete hooks until one fails.
+func (c *Client) beforeDelete(desc VariableDescriptor) error {
+	for _, hook := range c.hooks.delete {
+		if err := hook(desc); err != nil {
+			c.debug("remove canceled by hook", desc, "err", err)
+			return fmt.Errorf("remove %s: %w", desc, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package efivarfs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	errDeclined := errors.New("declined")
+	var events []string
+	c := NewClient(Dir(t.TempDir()),
+		OnBeforeWrite(func(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+			events = append(events, "before "+desc.Name)
+			if desc.Name == "Lang" {
+				return errDeclined
+			}
+			return nil
+		}),
+		OnAfterWrite(func(desc VariableDescriptor, attrs VariableAttributes, data []byte, err error) {
+			events = append(events, "after "+desc.Name)
+		}),
+		OnDelete(func(desc VariableDescriptor) error {
+			events = append(events, "delete "+desc.Name)
+			return errDeclined
+		}),
+	)
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	if err := c.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Fatalf("Set(Timeout) = %v", err)
+	}
+	lang := VariableDescriptor{Name: "Lang", GUID: &GlobalVariable}
+	if err := c.Set(lang, attrs, []byte("eng")); !errors.Is(err, errDeclined) {
+		t.Errorf("Set(Lang) = %v, want the hook's error", err)
+	}
+	if _, _, err := c.Get(lang); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Get(Lang) = %v, want ErrVarNotExist", err)
+	}
+	if err := c.Remove(timeout); !errors.Is(err, errDeclined) {
+		t.Errorf("Remove(Timeout) = %v, want the hook's error", err)
+	}
+	if _, _, err := c.Get(timeout); err != nil {
+		t.Errorf("Get(Timeout) = %v after canceled removal", err)
+	}
+
+	want := []string{"before Timeout", "after Timeout", "before Lang", "delete Timeout"}
+	if len(events) != len(want) {
+		t.Fatalf("hooks called as %q, want %q", events, want)
+	}
+	for i := range want {
+		if events[i] != want[i] {
+			t.Fatalf("hooks called as %q, want %q", events, want)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package efivarfs
+
+import (
+	guid "github.com/google/uuid"
+)
+
+// VariableKey identifies a variable like VariableDescriptor but holds
+// the GUID by value, so keys compare with == and can be used as map
+// keys. The next major version of this module replaces the GUID pointer
+// of VariableDescriptor with a value as well, making both the same.
+type VariableKey struct {
+	Name string
+	GUID guid.UUID
+}
+
+// NewDescriptor returns the descriptor of the variable name of vendor
+// g. Unlike taking the address of a GUID variable, it never shares the
+// GUID with another descriptor.
+func NewDescriptor(name string, g guid.UUID) VariableDescriptor {
+	return VariableDescriptor{Name: name, GUID: &g}
+}
+
+// Key returns the VariableKey of d, whose GUID must not be nil.
+func (d VariableDescriptor) Key() VariableKey {
+	return VariableKey{Name: d.Name, GUID: *d.GUID}
+}
+
+// Descriptor returns the VariableDescriptor of k with its own copy of
+// the GUID.
+func (k VariableKey) Descriptor() VariableDescriptor {
+	return NewDescriptor(k.Name, k.GUID)
+}
+
+// String returns the Name-GUID form of efivarfs.
+func (k VariableKey) String() string {
+	return k.Descriptor().String()
+}
+
+// MarshalText returns the String form, like for VariableDescriptor.
+func (k VariableKey) MarshalText() ([]byte, error) {
+	return []byte(k.String()), nil
+}
+
+// UnmarshalText parses text with ParseDescriptor.
+func (k *VariableKey) UnmarshalText(text []byte) error {
+	desc, err := ParseDescriptor(string(text))
+	if err != nil {
+		return err
+	}
+	*k = desc.Key()
+	return nil
+}
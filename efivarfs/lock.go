@@ -0,0 +1,81 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DefaultLockDir is the directory for the lock files of WithLocking
+// that cooperating processes on a system should agree on.
+const DefaultLockDir = "/run/efivar/locks"
+
+// WithLocking makes Update hold an exclusive flock on a per variable
+// lock file in dir while reading, modifying and writing the variable,
+// so cooperating processes don't interleave e.g. their BootOrder
+// updates. The locks are advisory: processes not using them, including
+// the firmware itself, can still change the variable at any time.
+func WithLocking(dir string) Option {
+	return func(c *Client) {
+		c.lockDir = dir
+	}
+}
+
+// Lock takes the lock of desc and returns the function releasing it.
+// Without WithLocking it does nothing. The name of desc is checked with
+// ValidateName and a GUID is required, as both make up the name of the
+// lock file.
+func (c *Client) Lock(desc VariableDescriptor) (unlock func(), err error) {
+	if c.lockDir == "" {
+		return func() {}, nil
+	}
+	if err := ValidateName(desc.Name); err != nil {
+		return nil, err
+	}
+	if desc.GUID == nil {
+		return nil, fmt.Errorf("%s: %w", desc.Name, ErrInvalidGUID)
+	}
+	if err := os.MkdirAll(c.lockDir, 0755); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(c.lockDir, fmt.Sprintf("%s.lock", desc))
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f); err != nil {
+		f.Close()
+		return nil, &os.PathError{Op: "flock", Path: path, Err: err}
+	}
+	c.debug("locked", desc, "path", path)
+	return func() {
+		// Closing the file releases the lock
+		f.Close()
+		c.debug("unlocked", desc)
+	}, nil
+}
+
+// Update atomically changes the variable desc with respect to other
+// Clients using the same lock directory. fn receives the current
+// attributes and data, or zero attributes and nil data if the variable
+// doesn't exist yet, and returns what to write. Writing empty data
+// removes the variable like with Set. An error of fn aborts the update
+// and is returned as is.
+func (c *Client) Update(desc VariableDescriptor, fn func(attrs VariableAttributes, data []byte) (VariableAttributes, []byte, error)) error {
+	unlock, err := c.Lock(desc)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	attrs, data, err := c.Get(desc)
+	if err != nil && !errors.Is(err, ErrVarNotExist) {
+		return err
+	}
+	attrs, data, err = fn(attrs, data)
+	if err != nil {
+		return err
+	}
+	return c.Set(desc, attrs, data)
+}
@@ -0,0 +1,13 @@
+//go:build !unix
+
+package efivarfs
+
+import (
+	"errors"
+	"os"
+)
+
+// flock is not supported on this platform.
+func flock(f *os.File) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,92 @@
+//go:build unix
+
+package efivarfs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	locks := t.TempDir()
+	vars := t.TempDir()
+	desc := NewDescriptor("Counter", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess
+
+	// Each Update runs with its own Client like separate processes would
+	// and increments the counter while holding the lock.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c := NewClient(Dir(vars), WithLocking(locks))
+			for j := 0; j < 50; j++ {
+				err := c.Update(desc, func(_ VariableAttributes, data []byte) (VariableAttributes, []byte, error) {
+					if data == nil {
+						data = []byte{0}
+					}
+					return attrs, []byte{data[0] + 1}, nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	_, data, err := NewClient(Dir(vars)).Get(desc)
+	if err != nil || len(data) != 1 || data[0] != 100 {
+		t.Errorf("Get() = %v, %v after 100 updates, want [100]", data, err)
+	}
+}
+
+func TestUpdateNotExist(t *testing.T) {
+	c := NewClient(Dir(t.TempDir()), WithLocking(t.TempDir()))
+	desc := NewDescriptor("New", GlobalVariable)
+	called := false
+	err := c.Update(desc, func(attrs VariableAttributes, data []byte) (VariableAttributes, []byte, error) {
+		called = true
+		if attrs != 0 || data != nil {
+			t.Errorf("Update() passed %v, %v for a missing variable, want 0, nil", attrs, data)
+		}
+		return AttributeNonVolatile | AttributeBootserviceAccess, []byte{1}, nil
+	})
+	if err != nil || !called {
+		t.Fatalf("Update() = %v, called %v", err, called)
+	}
+	if _, data, err := c.Get(desc); err != nil || len(data) != 1 {
+		t.Errorf("Get() = %v, %v after Update()", data, err)
+	}
+
+	errAbort := errors.New("abort")
+	err = c.Update(NewDescriptor("Other", GlobalVariable), func(VariableAttributes, []byte) (VariableAttributes, []byte, error) {
+		return 0, nil, errAbort
+	})
+	if !errors.Is(err, errAbort) {
+		t.Errorf("Update() = %v, want the error of fn", err)
+	}
+}
+
+func TestLockInvalid(t *testing.T) {
+	dir := t.TempDir()
+	locks := filepath.Join(dir, "locks")
+	c := NewClient(Dir(t.TempDir()), WithLocking(locks))
+	for _, name := range []string{"../../escaped", "a/b", ""} {
+		if _, err := c.Lock(NewDescriptor(name, GlobalVariable)); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("Lock(%q) = %v, want ErrInvalidName", name, err)
+		}
+	}
+	if _, err := c.Lock(VariableDescriptor{Name: "NoGUID"}); !errors.Is(err, ErrInvalidGUID) {
+		t.Errorf("Lock() = %v without GUID, want ErrInvalidGUID", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 0 {
+		t.Errorf("Lock() created %v for invalid descriptors", entries)
+	}
+}
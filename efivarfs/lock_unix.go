@@ -0,0 +1,19 @@
+//go:build unix
+
+package efivarfs
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// flock takes an exclusive flock on f, waiting until it is available.
+func flock(f *os.File) error {
+	for {
+		err := unix.Flock(int(f.Fd()), unix.LOCK_EX)
+		if err != unix.EINTR {
+			return err
+		}
+	}
+}
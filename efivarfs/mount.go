@@ -0,0 +1,31 @@
+package efivarfs
+
+// ProcMounts is the mount table searched for efivarfs if it isn't
+// mounted at EfiVarFs.
+var ProcMounts = "/proc/self/mounts"
+
+// WithMountPoint makes Open use efivarfs mounted at path instead of
+// looking it up with MountPoint.
+func WithMountPoint(path string) Option {
+	return func(c *Client) {
+		c.root = path
+	}
+}
+
+// WithMount makes Open mount efivarfs at EfiVarFs if it isn't mounted
+// yet and remount it read-write if it is mounted read-only, instead of
+// failing or returning a backend that can't write. This needs
+// CAP_SYS_ADMIN and is meant for minimal environments like an initramfs
+// where nothing else takes care of mounting it.
+func WithMount() Option {
+	return func(c *Client) {
+		c.mount = true
+	}
+}
+
+// Mount mounts efivarfs at EfiVarFs, creating the mount point if
+// needed, or remounts it read-write if it is mounted read-only. It
+// does nothing if efivarfs is mounted read-write already.
+func Mount() error {
+	return mount(nil, "")
+}
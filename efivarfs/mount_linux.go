@@ -0,0 +1,94 @@
+package efivarfs
+
+import (
+	"bufio"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/system-transparency/efivar/internal/mountinfo"
+	"golang.org/x/sys/unix"
+)
+
+// mountFlags are the flags efivarfs is mounted with by systemd.
+const mountFlags = unix.MS_NOSUID | unix.MS_NODEV | unix.MS_NOEXEC
+
+// MountPoint returns where efivarfs is mounted: EfiVarFs if it is
+// mounted there, which is the case on almost all systems, and otherwise
+// the first efivarfs mount listed in ProcMounts, e.g. in containers
+// with the host's efivarfs bind mounted elsewhere. It returns an error
+// wrapping ErrFsNotMounted if there is none.
+func MountPoint() (string, error) {
+	p := CurrentPaths()
+	if _, err := statEfivarfs(p.EfiVarFs); err == nil {
+		return p.EfiVarFs, nil
+	}
+	f, err := os.Open(p.ProcMounts)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrFsNotMounted)
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[2] != "efivarfs" {
+			continue
+		}
+		mp := mountinfo.Unescape(fields[1])
+		if _, err := statEfivarfs(mp); err == nil {
+			return mp, nil
+		}
+	}
+	return "", fmt.Errorf("not mounted at %s or listed in %s: %w", p.EfiVarFs, p.ProcMounts, ErrFsNotMounted)
+}
+
+// checkEfivarfs returns an error wrapping ErrFsNotMounted unless
+// efivarfs is mounted at path.
+func checkEfivarfs(path string) error {
+	_, err := statEfivarfs(path)
+	return err
+}
+
+// statEfivarfs returns the statfs result of path, failing with an error
+// wrapping ErrFsNotMounted unless efivarfs is mounted there.
+func statEfivarfs(path string) (*unix.Statfs_t, error) {
+	var stat unix.Statfs_t
+	if err := unix.Statfs(path, &stat); err != nil {
+		return nil, fmt.Errorf("statfs error occured: %w", ErrFsNotMounted)
+	}
+	if uint(stat.Type) != uint(unix.EFIVARFS_MAGIC) {
+		return nil, fmt.Errorf("wrong fs type: %w", ErrFsNotMounted)
+	}
+	return &stat, nil
+}
+
+// mount implements Mount for the mount point root, if empty the one
+// returned by MountPoint or EfiVarFs.
+func mount(logger *slog.Logger, root string) error {
+	mp := root
+	if mp == "" {
+		var err error
+		if mp, err = MountPoint(); err != nil {
+			mp = CurrentPaths().EfiVarFs
+		}
+	}
+	if stat, err := statEfivarfs(mp); err == nil {
+		if stat.Flags&unix.ST_RDONLY == 0 {
+			return nil
+		}
+		debug(logger, "remounting efivarfs read-write", "path", mp)
+		if err := unix.Mount("", mp, "", unix.MS_REMOUNT|mountFlags, ""); err != nil {
+			return fmt.Errorf("remounting %s read-write: %w", mp, err)
+		}
+		return nil
+	}
+	if err := os.MkdirAll(mp, 0755); err != nil {
+		return err
+	}
+	debug(logger, "mounting efivarfs", "path", mp)
+	if err := unix.Mount("efivarfs", mp, "efivarfs", mountFlags, ""); err != nil {
+		return fmt.Errorf("mounting efivarfs at %s: %w", mp, err)
+	}
+	return nil
+}
@@ -0,0 +1,23 @@
+//go:build !linux
+
+package efivarfs
+
+import "log/slog"
+
+// MountPoint returns where efivarfs is mounted, which is only supported
+// on Linux. Elsewhere it always fails with ErrVarsUnavailable.
+func MountPoint() (string, error) {
+	return "", ErrVarsUnavailable
+}
+
+// checkEfivarfs always fails with ErrVarsUnavailable as efivarfs only
+// exists on Linux.
+func checkEfivarfs(path string) error {
+	return ErrVarsUnavailable
+}
+
+// mount always fails with ErrVarsUnavailable as efivarfs only exists on
+// Linux.
+func mount(logger *slog.Logger, root string) error {
+	return ErrVarsUnavailable
+}
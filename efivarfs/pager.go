@@ -0,0 +1,118 @@
+package efivarfs
+
+import (
+	"io"
+	"os"
+)
+
+// ListReader reads the variables of a backend incrementally, so memory
+// stays bounded on stores with tens of thousands of variables.
+type ListReader interface {
+	// Read returns up to n further variables and io.EOF once all
+	// were returned. With n <= 0 it returns all remaining ones and a
+	// nil error, like os.File.ReadDir.
+	Read(n int) ([]VariableDescriptor, error)
+	// Close releases the resources of the reader
+	Close() error
+}
+
+// ListOpener is implemented by backends that can list their variables
+// incrementally.
+type ListOpener interface {
+	OpenList() (ListReader, error)
+}
+
+// OpenList returns a ListReader returning the variables in the order of
+// List. Only with WithSortOrder(Unsorted) and a backend implementing
+// ListOpener, like efivarfs, they are actually read in pages. Otherwise
+// they are listed in full first, as sorting needs all of them at once.
+func (c *Client) OpenList() (ListReader, error) {
+	if o, ok := c.backend.(ListOpener); ok && c.order == Unsorted {
+		return o.OpenList()
+	}
+	descs, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	return &sliceLister{descs: descs}, nil
+}
+
+// OpenList reads the directory in pages of the requested size.
+func (v *efivarfs) OpenList() (ListReader, error) {
+	f, err := os.Open(v.root)
+	switch {
+	case os.IsNotExist(err):
+		return nil, ErrVarNotExist
+	case os.IsPermission(err):
+		return nil, permission(err)
+	case err != nil:
+		return nil, err
+	}
+	return &dirLister{f: f}, nil
+}
+
+// dirLister is the ListReader of efivarfs.
+type dirLister struct {
+	f *os.File
+}
+
+func (l *dirLister) Read(n int) ([]VariableDescriptor, error) {
+	var descs []VariableDescriptor
+	for n <= 0 || len(descs) < n {
+		count := -1
+		if n > 0 {
+			count = n - len(descs)
+		}
+		files, err := l.f.ReadDir(count)
+		for _, f := range files {
+			if !f.Type().IsRegular() {
+				continue
+			}
+			if info, err := f.Info(); err != nil || info.Size() == 0 {
+				// Skip deleted variables like List
+				continue
+			}
+			if e, ok := parseName([]byte(f.Name())); ok {
+				g := e.guid
+				descs = append(descs, VariableDescriptor{Name: e.name[:len(e.name)-guidLength-1], GUID: &g})
+			}
+		}
+		switch {
+		case err == io.EOF && len(descs) > 0:
+			return descs, nil
+		case err != nil:
+			return descs, err
+		case n <= 0:
+			return descs, nil
+		}
+	}
+	return descs, nil
+}
+
+func (l *dirLister) Close() error {
+	return l.f.Close()
+}
+
+// sliceLister pages through variables listed in full.
+type sliceLister struct {
+	descs []VariableDescriptor
+}
+
+func (l *sliceLister) Read(n int) ([]VariableDescriptor, error) {
+	if n <= 0 {
+		descs := l.descs
+		l.descs = nil
+		return descs, nil
+	}
+	if len(l.descs) == 0 {
+		return nil, io.EOF
+	}
+	n = min(n, len(l.descs))
+	descs := l.descs[:n:n]
+	l.descs = l.descs[n:]
+	return descs, nil
+}
+
+func (l *sliceLister) Close() error {
+	return nil
+}
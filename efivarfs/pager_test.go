@@ -0,0 +1,67 @@
+package efivarfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenList(t *testing.T) {
+	b := Dir("../testdata/corpus/ami-desktop")
+	all, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, order := range []SortOrder{SortByName, Unsorted} {
+		r, err := NewClient(b, WithSortOrder(order)).OpenList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := make(map[string]bool)
+		for {
+			descs, err := r.Read(3)
+			if len(descs) > 3 {
+				t.Errorf("Read(3) returned %d variables", len(descs))
+			}
+			for _, desc := range descs {
+				seen[desc.Name+"-"+desc.GUID.String()] = true
+			}
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Close()
+		if len(seen) != len(all) {
+			t.Errorf("order %d: read %d variables, want %d", order, len(seen), len(all))
+		}
+	}
+}
+
+func TestOpenListDeleted(t *testing.T) {
+	dir := t.TempDir()
+	live := NewDescriptor("Live", GlobalVariable)
+	if err := Dir(dir).Set(live, AttributeNonVolatile|AttributeBootserviceAccess, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	// Deleted variables remain as empty files until efivarfs is
+	// remounted
+	gone := NewDescriptor("Gone", GlobalVariable)
+	if err := os.WriteFile(filepath.Join(dir, gone.String()), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewClient(Dir(dir), WithSortOrder(Unsorted)).OpenList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	descs, err := r.Read(10)
+	if err != nil || len(descs) != 1 || !descs[0].Equal(live) {
+		t.Errorf("Read() = %v, %v, want only %s", descs, err, live)
+	}
+}
@@ -0,0 +1,155 @@
+package efivarfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"os"
+)
+
+// errNegativeOffset is returned by ReadAt for offsets before the data.
+var errNegativeOffset = errors.New("negative offset")
+
+// AttributeReader is implemented by backends that can read the
+// attributes of a variable without its data.
+type AttributeReader interface {
+	GetAttributes(desc VariableDescriptor) (VariableAttributes, error)
+}
+
+// PartialReader is implemented by backends that can read part of the
+// data of a variable without the rest.
+type PartialReader interface {
+	ReadAt(desc VariableDescriptor, p []byte, off int64) (int, error)
+}
+
+// SizeReader is implemented by backends that can tell the size of the
+// data of a variable without reading it.
+type SizeReader interface {
+	GetSize(desc VariableDescriptor) (int, error)
+}
+
+// GetAttributes returns the attributes of a variable. With a backend
+// implementing AttributeReader, like efivarfs, only the attributes are
+// read, which is much cheaper than Get when classifying all variables of
+// a large store. Other backends fall back to Get.
+func (c *Client) GetAttributes(desc VariableDescriptor) (VariableAttributes, error) {
+	r, ok := c.backend.(AttributeReader)
+	if !ok {
+		attrs, _, err := c.Get(desc)
+		return attrs, err
+	}
+	span := c.startSpan("GetAttributes", desc)
+	attrs, err := r.GetAttributes(desc)
+	endSpan(span, 0, err)
+	if err != nil {
+		c.debug("get attributes failed", desc, "err", err)
+		return 0, err
+	}
+	c.debug("get attributes", desc, "attributes", attrs)
+	return attrs, nil
+}
+
+// GetAttributes reads just the attributes in front of the data.
+func (v *efivarfs) GetAttributes(desc VariableDescriptor) (VariableAttributes, error) {
+	f, err := v.open(desc)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var b [4]byte
+	switch _, err := io.ReadFull(f, b[:]); {
+	case err == io.EOF:
+		return 0, ErrVarNotExist
+	case err != nil:
+		return 0, err
+	}
+	return VariableAttributes(binary.LittleEndian.Uint32(b[:])), nil
+}
+
+// ReadAt reads len(p) bytes of the data of a variable starting at off,
+// not counting the attributes, with the semantics of io.ReaderAt. Tools
+// only looking at the header of a signature list or load option so avoid
+// reading large variables like dbx in full. Backends not implementing
+// PartialReader fall back to Get.
+func (c *Client) ReadAt(desc VariableDescriptor, p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errNegativeOffset
+	}
+	r, ok := c.backend.(PartialReader)
+	if !ok {
+		_, data, err := c.Get(desc)
+		if err != nil {
+			return 0, err
+		}
+		if off >= int64(len(data)) {
+			return 0, io.EOF
+		}
+		n := copy(p, data[off:])
+		if n < len(p) {
+			return n, io.EOF
+		}
+		return n, nil
+	}
+	span := c.startSpan("ReadAt", desc)
+	n, err := r.ReadAt(desc, p, off)
+	endSpan(span, n, err)
+	if err != nil && err != io.EOF {
+		c.debug("read at failed", desc, "offset", off, "err", err)
+		return n, err
+	}
+	c.debug("read at", desc, "offset", off, "size", n)
+	return n, err
+}
+
+// ReadAt reads from the file behind the attributes.
+func (v *efivarfs) ReadAt(desc VariableDescriptor, p []byte, off int64) (int, error) {
+	f, err := v.open(desc)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return f.ReadAt(p, off+4)
+}
+
+// GetSize returns the size of the data of a variable. With a backend
+// implementing SizeReader, like efivarfs, the variable isn't read at
+// all, so inventories of large stores stay cheap. Other backends fall
+// back to Get.
+func (c *Client) GetSize(desc VariableDescriptor) (int, error) {
+	r, ok := c.backend.(SizeReader)
+	if !ok {
+		_, data, err := c.Get(desc)
+		return len(data), err
+	}
+	span := c.startSpan("GetSize", desc)
+	size, err := r.GetSize(desc)
+	endSpan(span, size, err)
+	if err != nil {
+		c.debug("get size failed", desc, "err", err)
+		return 0, err
+	}
+	c.debug("get size", desc, "size", size)
+	return size, nil
+}
+
+// GetSize returns the size of the file minus the attributes, as
+// efivarfs reports the size of variables without reading them.
+func (v *efivarfs) GetSize(desc VariableDescriptor) (int, error) {
+	path, err := v.path(desc)
+	if err != nil {
+		return 0, err
+	}
+	fi, err := os.Stat(path)
+	switch {
+	case os.IsNotExist(err):
+		return 0, ErrVarNotExist
+	case os.IsPermission(err):
+		return 0, permission(err)
+	case err != nil:
+		return 0, err
+	case fi.Size() < 4:
+		return 0, ErrVarNotExist
+	}
+	return int(fi.Size()) - 4, nil
+}
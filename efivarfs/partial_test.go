@@ -0,0 +1,86 @@
+package efivarfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestGetAttributes(t *testing.T) {
+	c := NewClient(Dir("../testdata/corpus/ami-desktop"))
+	descs, err := c.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range descs {
+		want, _, err := c.Get(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := c.GetAttributes(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("%s: got attributes %s, want %s", desc.Name, got, want)
+		}
+	}
+	if _, err := c.GetAttributes(VariableDescriptor{Name: "Missing", GUID: &GlobalVariable}); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("got %v for missing variable, want ErrVarNotExist", err)
+	}
+}
+
+func TestReadAt(t *testing.T) {
+	b := Dir("../testdata/corpus/ami-desktop")
+	descs, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// ReadOnly hides PartialReader, testing the fallback to Get.
+	for _, c := range []*Client{NewClient(b), NewClient(ReadOnly(b))} {
+		for _, desc := range descs {
+			_, data, err := c.Get(desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, off := range []int{0, 1, len(data) / 2, len(data)} {
+				p := make([]byte, 4)
+				n, err := c.ReadAt(desc, p, int64(off))
+				want := data[off:min(off+4, len(data))]
+				if !bytes.Equal(p[:n], want) {
+					t.Errorf("%s at %d: got %x, want %x", desc.Name, off, p[:n], want)
+				}
+				if n < len(p) && err != io.EOF || n == len(p) && err != nil {
+					t.Errorf("%s at %d: got %d bytes and error %v", desc.Name, off, n, err)
+				}
+			}
+		}
+	}
+}
+
+func TestGetSize(t *testing.T) {
+	b := Dir("../testdata/corpus/ami-desktop")
+	descs, err := b.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []*Client{NewClient(b), NewClient(ReadOnly(b))} {
+		for _, desc := range descs {
+			_, data, err := c.Get(desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			size, err := c.GetSize(desc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if size != len(data) {
+				t.Errorf("%s: got size %d, want %d", desc.Name, size, len(data))
+			}
+		}
+		if _, err := c.GetSize(VariableDescriptor{Name: "Missing", GUID: &GlobalVariable}); !errors.Is(err, ErrVarNotExist) {
+			t.Errorf("got %v for missing variable, want ErrVarNotExist", err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package efivarfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// Plan collects the changes of a dry run into the net effect on each
+// variable, with the content before and after, for infrastructure as
+// code tools to show what applying an operation would change. Pass
+// Record to WithDryRun:
+//
+//	var plan efivarfs.Plan
+//	c := efivarfs.NewClient(b, efivarfs.WithDryRun(plan.Record))
+//
+// Plan is safe for concurrent use and marshals to JSON.
+type Plan struct {
+	mu      sync.Mutex
+	keys    []VariableKey
+	changes map[VariableKey]*PlannedChange
+}
+
+// PlannedChange is the net change of a single variable.
+type PlannedChange struct {
+	// Action is "create", "update" or "delete"
+	Action string `json:"action"`
+	Name   string `json:"name"`
+	GUID   string `json:"guid"`
+	// Before is the content before, nil if the variable doesn't exist
+	Before *PlannedValue `json:"before"`
+	// After is the content after, nil if the variable is deleted
+	After *PlannedValue `json:"after"`
+}
+
+// PlannedValue is the content of a variable.
+type PlannedValue struct {
+	Attributes VariableAttributes `json:"attributes"`
+	Data       []byte             `json:"data"`
+}
+
+// Record adds c to the plan, it is to be passed to WithDryRun.
+func (p *Plan) Record(c Change) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.changes == nil {
+		p.changes = make(map[VariableKey]*PlannedChange)
+	}
+	k := c.Desc.Key()
+	pc, ok := p.changes[k]
+	if !ok {
+		pc = &PlannedChange{Name: c.Desc.Name, GUID: c.Desc.GUID.String()}
+		if c.Existed {
+			pc.Before = &PlannedValue{Attributes: c.OldAttributes, Data: c.OldData}
+		}
+		p.changes[k] = pc
+		p.keys = append(p.keys, k)
+	}
+	switch {
+	case c.Op == "remove":
+		pc.After = nil
+	case c.Attributes&AttributeAppendWrite != 0:
+		after := &PlannedValue{Attributes: c.Attributes &^ AttributeAppendWrite, Data: c.Data}
+		if c.Existed {
+			after = &PlannedValue{Attributes: c.OldAttributes, Data: append(append([]byte(nil), c.OldData...), c.Data...)}
+		}
+		pc.After = after
+	default:
+		pc.After = &PlannedValue{Attributes: c.Attributes, Data: c.Data}
+	}
+}
+
+// Changes returns the variables that would change, in the order they
+// were first modified. Variables written with their previous content or
+// created and deleted again are left out.
+func (p *Plan) Changes() []PlannedChange {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	changes := []PlannedChange{}
+	for _, k := range p.keys {
+		pc := *p.changes[k]
+		switch {
+		case pc.Before == nil && pc.After == nil:
+			continue
+		case pc.Before == nil:
+			pc.Action = "create"
+		case pc.After == nil:
+			pc.Action = "delete"
+		case pc.Before.Attributes == pc.After.Attributes && bytes.Equal(pc.Before.Data, pc.After.Data):
+			continue
+		default:
+			pc.Action = "update"
+		}
+		changes = append(changes, pc)
+	}
+	return changes
+}
+
+// MarshalJSON encodes the plan as an object holding the Changes.
+func (p *Plan) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Changes []PlannedChange `json:"changes"`
+	}{p.Changes()})
+}
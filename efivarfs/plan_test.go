@@ -0,0 +1,55 @@
+package efivarfs
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPlan(t *testing.T) {
+	b := Dir(t.TempDir())
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	order := VariableDescriptor{Name: "BootOrder", GUID: &GlobalVariable}
+	next := VariableDescriptor{Name: "BootNext", GUID: &GlobalVariable}
+	lang := VariableDescriptor{Name: "PlatformLang", GUID: &GlobalVariable}
+	for _, desc := range []VariableDescriptor{timeout, order, next} {
+		if err := b.Set(desc, attrs, []byte{1, 0}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var plan Plan
+	c := NewClient(b, WithDryRun(plan.Record))
+	temp := VariableDescriptor{Name: "Temp", GUID: &GlobalVariable}
+	for _, err := range []error{
+		c.Set(timeout, attrs, []byte{5, 0}),
+		c.Set(timeout, attrs, []byte{6, 0}),
+		c.Set(order, attrs, []byte{2, 0}),
+		c.Set(order, attrs, []byte{1, 0}),
+		c.Set(lang, attrs, []byte("en")),
+		c.Set(lang, attrs|AttributeAppendWrite, []byte("-US")),
+		c.Set(temp, attrs, []byte{1}),
+		c.Remove(temp),
+		c.Remove(next),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	got, err := json.Marshal(&plan)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"changes":[` +
+		`{"action":"update","name":"Timeout","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","before":{"attributes":7,"data":"AQA="},"after":{"attributes":7,"data":"BgA="}},` +
+		`{"action":"create","name":"PlatformLang","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","before":null,"after":{"attributes":7,"data":"ZW4tVVM="}},` +
+		`{"action":"delete","name":"BootNext","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","before":{"attributes":7,"data":"AQA="},"after":null}]}`
+	if string(got) != want {
+		t.Errorf("json.Marshal() = %s, want %s", got, want)
+	}
+
+	var empty Plan
+	if got, err := json.Marshal(&empty); err != nil || string(got) != `{"changes":[]}` {
+		t.Errorf("json.Marshal() = %s, %v of an empty plan", got, err)
+	}
+}
@@ -0,0 +1,131 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"path"
+
+	guid "github.com/google/uuid"
+)
+
+// ErrPolicyDenied is caused by modifications a Policy doesn't allow.
+var ErrPolicyDenied = errors.New("denied by policy")
+
+// PolicyError is returned by a Client for every Set and Remove rejected
+// by its Policy.
+type PolicyError struct {
+	Op   string
+	Desc VariableDescriptor
+	// Reason tells which rule denied the operation
+	Reason string
+}
+
+func (e *PolicyError) Error() string {
+	return fmt.Sprintf("%s %s: %v: %s", e.Op, e.Desc, ErrPolicyDenied, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrPolicyDenied) work.
+func (e *PolicyError) Is(target error) bool {
+	return target == ErrPolicyDenied
+}
+
+// PolicyRule allows or denies the modification of the variables it
+// matches.
+type PolicyRule struct {
+	// Allow permits matching operations, otherwise they are denied
+	Allow bool `json:"allow"`
+	// Name is a path.Match pattern of the variable names the rule
+	// applies to, e.g. "Boot*", all if empty
+	Name string `json:"name,omitempty"`
+	// GUID restricts the rule to the variables of one vendor
+	GUID *guid.UUID `json:"guid,omitempty"`
+	// Remove restricts the rule to deletions and Set to writes, both
+	// kinds of modification match if neither is given
+	Remove bool `json:"remove,omitempty"`
+	Set    bool `json:"set,omitempty"`
+
+	// AttributeMask, if not zero, lists the attributes allowed writes
+	// may use, writes with any other attribute are denied
+	AttributeMask VariableAttributes `json:"attribute_mask,omitempty"`
+	// MaxSize, if not zero, is the largest write allowed
+	MaxSize int `json:"max_size,omitempty"`
+}
+
+// Policy decides which variables a Client may modify. The first rule
+// matching an operation decides it, operations no rule matches are
+// allowed if DefaultAllow is set. E.g. a policy keeping an application
+// from touching the Secure Boot keys and vendor variables denies PK and
+// KEK before allowing the other variables with the GUID of
+// GlobalVariable:
+//
+//	efivarfs.Policy{Rules: []efivarfs.PolicyRule{
+//		{Name: "PK", GUID: &efivarfs.GlobalVariable},
+//		{Name: "KEK", GUID: &efivarfs.GlobalVariable},
+//		{Allow: true, GUID: &efivarfs.GlobalVariable},
+//	}}
+type Policy struct {
+	Rules        []PolicyRule `json:"rules"`
+	DefaultAllow bool         `json:"default_allow,omitempty"`
+}
+
+// WithPolicy makes the Client check every Set and Remove against p and
+// fail with a *PolicyError before reaching the backend if p denies it.
+func WithPolicy(p *Policy) Option {
+	return func(c *Client) {
+		c.policy = p
+	}
+}
+
+// Check returns a *PolicyError if p denies op, which is "set" or
+// "remove", on desc. attrs and size are the arguments of writes.
+func (p *Policy) Check(op string, desc VariableDescriptor, attrs VariableAttributes, size int) error {
+	for i, r := range p.Rules {
+		if !r.matches(op, desc) {
+			continue
+		}
+		deny := func(reason string) error {
+			return &PolicyError{Op: op, Desc: desc, Reason: fmt.Sprintf("rule %d %s", i, reason)}
+		}
+		switch {
+		case !r.Allow:
+			return deny("denies it")
+		case op != "set":
+		case r.AttributeMask != 0 && attrs&^r.AttributeMask != 0:
+			return deny(fmt.Sprintf("doesn't allow attributes %s", attrs&^r.AttributeMask))
+		case r.MaxSize != 0 && size > r.MaxSize:
+			return deny(fmt.Sprintf("allows at most %d bytes", r.MaxSize))
+		}
+		return nil
+	}
+	if !p.DefaultAllow {
+		return &PolicyError{Op: op, Desc: desc, Reason: "no rule allows it"}
+	}
+	return nil
+}
+
+// matches reports whether r applies to op on desc.
+func (r *PolicyRule) matches(op string, desc VariableDescriptor) bool {
+	if r.Set != r.Remove && r.Set != (op == "set") {
+		return false
+	}
+	if r.GUID != nil && *r.GUID != *desc.GUID {
+		return false
+	}
+	if r.Name == "" {
+		return true
+	}
+	ok, _ := path.Match(r.Name, desc.Name)
+	return ok
+}
+
+// checkPolicy applies the policy set with WithPolicy to op on desc.
+func (c *Client) checkPolicy(op string, desc VariableDescriptor, attrs VariableAttributes, size int) error {
+	if c.policy == nil {
+		return nil
+	}
+	if err := c.policy.Check(op, desc, attrs, size); err != nil {
+		c.debug(op+" denied by policy", desc, "err", err)
+		return err
+	}
+	return nil
+}
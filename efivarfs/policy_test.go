@@ -0,0 +1,47 @@
+package efivarfs
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	vendor := MustParseGUID("605dab50-e046-4300-abb6-3dd810dd8b23")
+	p := &Policy{Rules: []PolicyRule{
+		{Name: "PK", GUID: &GlobalVariable},
+		{Name: "KEK", GUID: &GlobalVariable},
+		{Allow: true, Name: "Boot*", GUID: &GlobalVariable, Remove: true},
+		{Allow: true, GUID: &GlobalVariable, AttributeMask: 7, MaxSize: 16},
+	}}
+	global := func(name string) VariableDescriptor {
+		return VariableDescriptor{Name: name, GUID: &GlobalVariable}
+	}
+	for _, tt := range []struct {
+		op    string
+		desc  VariableDescriptor
+		attrs VariableAttributes
+		size  int
+		allow bool
+	}{
+		{"set", global("PK"), 7, 1, false},
+		{"remove", global("KEK"), 0, 0, false},
+		{"remove", global("Boot0001"), 0, 0, true},
+		{"set", global("Timeout"), 7, 2, true},
+		{"set", global("Timeout"), 0x27, 2, false},
+		{"set", global("Timeout"), 7, 17, false},
+		{"set", VariableDescriptor{Name: "MokList", GUID: &vendor}, 7, 1, false},
+	} {
+		err := p.Check(tt.op, tt.desc, tt.attrs, tt.size)
+		if tt.allow && err != nil || !tt.allow && !errors.Is(err, ErrPolicyDenied) {
+			t.Errorf("Check(%s %s, %s, %d) = %v, want allowed %v", tt.op, tt.desc.Name, tt.attrs, tt.size, err, tt.allow)
+		}
+	}
+
+	c := NewClient(Dir(t.TempDir()), WithPolicy(p))
+	if err := c.Set(global("PK"), 0x27, []byte{1}); !errors.Is(err, ErrPolicyDenied) {
+		t.Errorf("Set(PK) = %v, want ErrPolicyDenied", err)
+	}
+	if err := c.Set(global("Timeout"), 7, []byte{1, 0}); err != nil {
+		t.Errorf("Set(Timeout) = %v", err)
+	}
+}
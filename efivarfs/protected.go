@@ -0,0 +1,82 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	guid "github.com/google/uuid"
+)
+
+// ErrReadOnlyVariable is caused by modifying a variable the firmware
+// only allows reading at runtime, like SecureBoot or SetupMode, with
+// WithReadOnlyCheck. Without it, efivarfs clears the immutable flag of
+// the variable and then fails with ErrVarPermission once the firmware
+// rejects the write.
+var ErrReadOnlyVariable = errors.New("variable is read-only at runtime")
+
+// readOnlyVars holds the variables the firmware rejects writes to at
+// runtime, keyed by vendor and name.
+var (
+	readOnlyMu   sync.RWMutex
+	readOnlyVars = map[guid.UUID]map[string]bool{}
+)
+
+func init() {
+	// The variables marked read-only in the table of global variables
+	// of the UEFI specification. AuditMode and DeployedMode are
+	// writable in some modes and left out.
+	for _, name := range []string{
+		"BootCurrent", "BootOptionSupport", "ConInDev", "ConOutDev",
+		"dbDefault", "dbrDefault", "dbtDefault", "dbxDefault", "devAuthBoot",
+		"ErrOutDev", "HwErrRecSupport", "KEKDefault", "LangCodes",
+		"OsIndicationsSupported", "PKDefault", "PlatformLangCodes",
+		"RuntimeServicesSupported", "SecureBoot", "SetupMode",
+		"SignatureSupport", "VendorKeys",
+	} {
+		RegisterReadOnly(name, GlobalVariable)
+	}
+}
+
+// RegisterReadOnly adds name of vendor g to the variables
+// WithReadOnlyCheck refuses to modify, e.g. for vendor variables a
+// platform locks at the end of the boot.
+func RegisterReadOnly(name string, g guid.UUID) {
+	readOnlyMu.Lock()
+	defer readOnlyMu.Unlock()
+	if readOnlyVars[g] == nil {
+		readOnlyVars[g] = map[string]bool{}
+	}
+	readOnlyVars[g][name] = true
+}
+
+// IsReadOnly reports whether desc is registered as read-only at runtime,
+// either by the UEFI specification or with RegisterReadOnly.
+func IsReadOnly(desc VariableDescriptor) bool {
+	if desc.GUID == nil {
+		return false
+	}
+	readOnlyMu.RLock()
+	defer readOnlyMu.RUnlock()
+	return readOnlyVars[*desc.GUID][desc.Name]
+}
+
+// WithReadOnlyCheck makes Set and Remove fail with an error wrapping
+// ErrReadOnlyVariable for variables IsReadOnly reports, before touching
+// the immutable flag or calling the firmware. OpenRaw of the Client
+// only opens files for reading, so it can't bypass the check.
+func WithReadOnlyCheck() Option {
+	return func(c *Client) {
+		c.readOnlyCheck = true
+	}
+}
+
+// checkReadOnly returns an error wrapping ErrReadOnlyVariable if c
+// checks for read-only variables and desc is one.
+func (c *Client) checkReadOnly(op string, desc VariableDescriptor) error {
+	if !c.readOnlyCheck || !IsReadOnly(desc) {
+		return nil
+	}
+	c.debug(op+" of read-only variable", desc)
+	return fmt.Errorf("%s %s: %w", op, desc, ErrReadOnlyVariable)
+}
@@ -0,0 +1,62 @@
+package efivarfs
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	guid "github.com/google/uuid"
+)
+
+func TestReadOnlyCheck(t *testing.T) {
+	dir := Dir(t.TempDir())
+	attrs := AttributeBootserviceAccess | AttributeRuntimeAccess
+	secureBoot := VariableDescriptor{Name: "SecureBoot", GUID: &GlobalVariable}
+	if err := dir.Set(secureBoot, attrs, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewClient(dir, WithReadOnlyCheck())
+	if err := c.Set(secureBoot, attrs, []byte{0}); !errors.Is(err, ErrReadOnlyVariable) {
+		t.Errorf("Set(SecureBoot) = %v, want ErrReadOnlyVariable", err)
+	}
+	if err := c.Remove(secureBoot); !errors.Is(err, ErrReadOnlyVariable) {
+		t.Errorf("Remove(SecureBoot) = %v, want ErrReadOnlyVariable", err)
+	}
+	if _, data, err := c.Get(secureBoot); err != nil || data[0] != 1 {
+		t.Errorf("Get(SecureBoot) = %v, %v, want it unchanged", data, err)
+	}
+
+	// A vendor of its own keeps the registration from leaking into
+	// other tests, and the cleanup from the registry of the package.
+	g := guid.MustParse("6a1e3a5c-2b7d-4f0e-9c8a-1d2e3f4a5b6c")
+	vendor := VariableDescriptor{Name: "Locked", GUID: &g}
+	if err := c.Set(vendor, AttributeNonVolatile|attrs, []byte{1}); err != nil {
+		t.Errorf("Set(%s) = %v before registering it", vendor, err)
+	}
+	RegisterReadOnly(vendor.Name, g)
+	t.Cleanup(func() {
+		readOnlyMu.Lock()
+		defer readOnlyMu.Unlock()
+		delete(readOnlyVars, g)
+	})
+	if err := c.Set(vendor, AttributeNonVolatile|attrs, []byte{2}); !errors.Is(err, ErrReadOnlyVariable) {
+		t.Errorf("Set(%s) = %v, want ErrReadOnlyVariable", vendor, err)
+	}
+
+	if err := NewClient(dir).Set(secureBoot, attrs, []byte{0}); err != nil {
+		t.Errorf("Set(SecureBoot) = %v without WithReadOnlyCheck", err)
+	}
+}
+
+func TestReadOnlyCheckOpenRaw(t *testing.T) {
+	dir := Dir(t.TempDir())
+	secureBoot := VariableDescriptor{Name: "SecureBoot", GUID: &GlobalVariable}
+	if err := dir.Set(secureBoot, AttributeBootserviceAccess|AttributeRuntimeAccess, []byte{1}); err != nil {
+		t.Fatal(err)
+	}
+	c := NewClient(dir, WithReadOnlyCheck())
+	if _, err := c.OpenRaw(secureBoot, os.O_WRONLY); err == nil {
+		t.Error("OpenRaw(SecureBoot) for writing bypassed WithReadOnlyCheck")
+	}
+}
@@ -0,0 +1,42 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// ErrRateLimited is caused by modifying variables through a Client more
+// often than allowed by WithRateLimit
+var ErrRateLimited = errors.New("write rate limit exceeded")
+
+// WithRateLimit limits Set and Remove to writes operations per window.
+// Up to writes operations may happen in a burst, after that the budget
+// refills evenly over window. Operations exceeding it fail with
+// ErrRateLimited instead of reaching the firmware, which protects the
+// flash from wearing out when e.g. a reconciler keeps rewriting the same
+// variable due to a bug. Reads are never limited.
+//
+// Like time.NewTicker, WithRateLimit panics unless writes and window
+// are positive, as a zero window would lift the limit and zero writes
+// would block every write.
+func WithRateLimit(writes int, window time.Duration) Option {
+	if writes <= 0 || window <= 0 {
+		panic(fmt.Sprintf("efivarfs: non-positive rate limit of %d writes per %v", writes, window))
+	}
+	return func(c *Client) {
+		c.limiter = rate.NewLimiter(rate.Limit(float64(writes)/window.Seconds()), writes)
+	}
+}
+
+// allowWrite returns an error wrapping ErrRateLimited if op on desc
+// exceeds the rate limit.
+func (c *Client) allowWrite(op string, desc VariableDescriptor) error {
+	if c.limiter == nil || c.limiter.Allow() {
+		return nil
+	}
+	c.debug(op+" rate limited", desc)
+	return fmt.Errorf("%s %s: %w", op, desc, ErrRateLimited)
+}
@@ -0,0 +1,62 @@
+package efivarfs
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestRateLimit(t *testing.T) {
+	c := NewClient(Dir(t.TempDir()), WithRateLimit(2, time.Hour))
+	desc := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	for i := 0; i < 2; i++ {
+		if err := c.Set(desc, attrs, []byte{byte(i), 0}); err != nil {
+			t.Fatalf("Set() = %v within the burst", err)
+		}
+	}
+	if err := c.Remove(desc); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Remove() = %v beyond the burst, want ErrRateLimited", err)
+	}
+	if _, _, err := c.Get(desc); err != nil {
+		t.Errorf("Get() = %v, reads aren't limited", err)
+	}
+
+	for _, tt := range []struct {
+		writes int
+		window time.Duration
+	}{{0, time.Hour}, {-1, time.Hour}, {1, 0}, {1, -time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithRateLimit(%d, %v) didn't panic", tt.writes, tt.window)
+				}
+			}()
+			WithRateLimit(tt.writes, tt.window)
+		}()
+	}
+}
+
+func TestRateLimitHooks(t *testing.T) {
+	errDeclined := errors.New("declined")
+	declined := true
+	c := NewClient(Dir(t.TempDir()), WithRateLimit(1, time.Hour),
+		OnBeforeWrite(func(VariableDescriptor, VariableAttributes, []byte) error {
+			if declined {
+				return errDeclined
+			}
+			return nil
+		}))
+	desc := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	for i := 0; i < 3; i++ {
+		if err := c.Set(desc, attrs, []byte{1, 0}); !errors.Is(err, errDeclined) {
+			t.Fatalf("Set() = %v, want the hook's error", err)
+		}
+	}
+	// Writes canceled by hooks don't count against the limit
+	declined = false
+	if err := c.Set(desc, attrs, []byte{1, 0}); err != nil {
+		t.Errorf("Set() = %v after canceled writes", err)
+	}
+}
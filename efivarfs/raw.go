@@ -0,0 +1,113 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"syscall"
+)
+
+// fsImmutableFL is FS_IMMUTABLE_FL, the inode flag efivarfs sets on
+// most variables to protect them from accidental deletion.
+const fsImmutableFL = 0x00000010
+
+// File is an open efivarfs file as returned by OpenRaw. Reads return
+// the 4 byte little endian attributes followed by the data, and every
+// write has to hold attributes and data in a single call, as efivarfs
+// passes each write to SetVariable on its own.
+type File interface {
+	io.ReadWriteCloser
+	// Name returns the path of the file
+	Name() string
+	// Fd returns the file descriptor for custom ioctls or mmap
+	Fd() uintptr
+	// InodeFlags returns the inode flags as reported by FS_IOC_GETFLAGS
+	InodeFlags() (int, error)
+	// SetInodeFlags sets the inode flags with FS_IOC_SETFLAGS
+	SetInodeFlags(flags int) error
+	// Immutable reports whether the immutable flag is set
+	Immutable() (bool, error)
+	// SetImmutable sets or clears the immutable flag
+	SetImmutable(immutable bool) error
+}
+
+// rawFile implements File for an *os.File.
+type rawFile struct {
+	*os.File
+}
+
+func (f rawFile) InodeFlags() (int, error) {
+	return getInodeFlags(f.File)
+}
+
+func (f rawFile) SetInodeFlags(flags int) error {
+	return setInodeFlags(f.File, flags)
+}
+
+func (f rawFile) Immutable() (bool, error) {
+	flags, err := f.InodeFlags()
+	if err != nil {
+		return false, err
+	}
+	return flags&fsImmutableFL != 0, nil
+}
+
+func (f rawFile) SetImmutable(immutable bool) error {
+	flags, err := f.InodeFlags()
+	if err != nil {
+		return err
+	}
+	if immutable {
+		return f.SetInodeFlags(flags | fsImmutableFL)
+	}
+	return f.SetInodeFlags(flags &^ fsImmutableFL)
+}
+
+// OpenRaw probes for efivarfs like Probe and opens the file backing desc
+// with flag, e.g. os.O_RDONLY or os.O_WRONLY|os.O_CREATE. It is meant
+// for advanced uses the rest of the API doesn't cover, which then have
+// to deal with the immutable flag and the efivarfs file format
+// themselves.
+func OpenRaw(desc VariableDescriptor, flag int) (File, error) {
+	v, err := probeAndReturn()
+	if err != nil {
+		return nil, err
+	}
+	return v.openRaw(desc, flag)
+}
+
+// OpenRaw is like the package level OpenRaw for the efivarfs used by c,
+// but only opens files for reading. Writes to the file would bypass
+// everything c checks and does around Set and Remove, like the policy,
+// the rate limit, dry runs and hooks, so flag asking for write access
+// fails with errors.ErrUnsupported.
+func (c *Client) OpenRaw(desc VariableDescriptor, flag int) (File, error) {
+	v, ok := c.backend.(*efivarfs)
+	if !ok {
+		return nil, fmt.Errorf("raw access to %T: %w", c.backend, errors.ErrUnsupported)
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, fmt.Errorf("raw write access to %s through a Client: %w", desc, errors.ErrUnsupported)
+	}
+	return v.openRaw(desc, flag)
+}
+
+func (v *efivarfs) openRaw(desc VariableDescriptor, flag int) (File, error) {
+	path, err := v.path(desc)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, flag, 0644)
+	switch {
+	case os.IsNotExist(err):
+		return nil, ErrVarNotExist
+	case errors.Is(err, syscall.EROFS):
+		return nil, readOnlyFilesystem(err)
+	case os.IsPermission(err):
+		return nil, permission(err)
+	case err != nil:
+		return nil, err
+	}
+	return rawFile{f}, nil
+}
@@ -0,0 +1,33 @@
+package efivarfs
+
+import (
+	"errors"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestClientOpenRaw(t *testing.T) {
+	c := NewClient(Dir(t.TempDir()))
+	desc := VariableDescriptor{Name: "Test", GUID: &GlobalVariable}
+	if err := c.Set(desc, AttributeNonVolatile|AttributeBootserviceAccess, []byte("data")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := c.OpenRaw(desc, os.O_RDONLY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(b[4:]) != "data" {
+		t.Errorf("read %q, %v", b, err)
+	}
+
+	// Writes would bypass policy, rate limit, dry run and hooks
+	for _, flag := range []int{os.O_WRONLY, os.O_RDWR, os.O_RDONLY | os.O_CREATE, os.O_WRONLY | os.O_APPEND} {
+		if _, err := c.OpenRaw(desc, flag); !errors.Is(err, errors.ErrUnsupported) {
+			t.Errorf("OpenRaw(0x%x) = %v, want errors.ErrUnsupported", flag, err)
+		}
+	}
+}
@@ -0,0 +1,48 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReadOnlyBackend is caused by modifying variables through a backend
+// returned by ReadOnly
+var ErrReadOnlyBackend = errors.New("backend is read-only")
+
+// ReadOnlyError is returned by the backend of ReadOnly for every
+// attempted modification.
+type ReadOnlyError struct {
+	// Op is the rejected operation, either "set" or "remove"
+	Op string
+	// Desc is the variable that was supposed to be modified
+	Desc VariableDescriptor
+}
+
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s %s: %v", e.Op, e.Desc, ErrReadOnlyBackend)
+}
+
+// Is makes errors.Is(err, ErrReadOnlyBackend) work.
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnlyBackend
+}
+
+// readOnly passes reads to the wrapped backend and rejects everything else.
+type readOnly struct {
+	ReadBackend
+}
+
+// ReadOnly returns a Backend that passes Get and List through to b and
+// rejects Set and Remove with a *ReadOnlyError, so monitoring tools can
+// be sure they never modify the variable store.
+func ReadOnly(b ReadBackend) Backend {
+	return readOnly{b}
+}
+
+func (readOnly) Set(desc VariableDescriptor, _ VariableAttributes, _ []byte) error {
+	return &ReadOnlyError{Op: "set", Desc: desc}
+}
+
+func (readOnly) Remove(desc VariableDescriptor) error {
+	return &ReadOnlyError{Op: "remove", Desc: desc}
+}
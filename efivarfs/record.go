@@ -0,0 +1,264 @@
+package efivarfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	guid "github.com/google/uuid"
+)
+
+// ErrReplayMismatch is returned by the replay backend if a call doesn't
+// match the next recorded one
+var ErrReplayMismatch = errors.New("call does not match recording")
+
+// sentinels are the errors which keep their identity when recorded, so
+// errors.Is works the same on replayed errors. An error matching several
+// of them is recorded as the first, so the more specific ones come
+// first, e.g. ErrReadOnlyFilesystem wraps the error of the file system
+// as well.
+var sentinels = []struct {
+	kind string
+	err  error
+}{
+	{"ErrPolicyDenied", ErrPolicyDenied},
+	{"ErrReadOnlyVariable", ErrReadOnlyVariable},
+	{"ErrSetVariableUnsupported", ErrSetVariableUnsupported},
+	{"ErrReadOnlyBackend", ErrReadOnlyBackend},
+	{"ErrReadOnlyFilesystem", ErrReadOnlyFilesystem},
+	{"ErrRateLimited", ErrRateLimited},
+	{"ErrDuplicateVariable", ErrDuplicateVariable},
+	{"ErrInvalidAttributes", ErrInvalidAttributes},
+	{"ErrInvalidName", ErrInvalidName},
+	{"ErrInvalidGUID", ErrInvalidGUID},
+	{"ErrUnknownVariable", ErrUnknownVariable},
+	{"ErrAmbiguousVariable", ErrAmbiguousVariable},
+	{"ErrVariableTooLarge", ErrVariableTooLarge},
+	{"ErrFsNotMounted", ErrFsNotMounted},
+	{"ErrVarsUnavailable", ErrVarsUnavailable},
+	{"ErrVarNotExist", ErrVarNotExist},
+	{"ErrVarPermission", ErrVarPermission},
+	{"ErrNoSpace", ErrNoSpace},
+}
+
+// Call is a single recorded backend call and its result. A recording is
+// a stream of JSON encoded Calls, one per line.
+type Call struct {
+	Op         string             `json:"op"`
+	Name       string             `json:"name,omitempty"`
+	GUID       string             `json:"guid,omitempty"`
+	Attributes VariableAttributes `json:"attributes,omitempty"`
+	Data       []byte             `json:"data,omitempty"`
+	List       []string           `json:"list,omitempty"`
+	Error      string             `json:"error,omitempty"`
+	Kind       string             `json:"kind,omitempty"`
+}
+
+// setError stores err in c, remembering which sentinel it wraps.
+func (c *Call) setError(err error) {
+	if err == nil {
+		return
+	}
+	c.Error = err.Error()
+	for _, s := range sentinels {
+		if errors.Is(err, s.err) {
+			c.Kind = s.kind
+			break
+		}
+	}
+}
+
+// err returns the recorded error.
+func (c *Call) err() error {
+	if c.Error == "" {
+		return nil
+	}
+	e := &replayedError{msg: c.Error}
+	for _, s := range sentinels {
+		if s.kind == c.Kind {
+			e.target = s.err
+			break
+		}
+	}
+	return e
+}
+
+// replayedError is an error read back from a recording.
+type replayedError struct {
+	msg    string
+	target error
+}
+
+func (e *replayedError) Error() string { return e.msg }
+func (e *replayedError) Unwrap() error { return e.target }
+
+// recorder is a Backend decorator writing all calls to a recording.
+type recorder struct {
+	b   Backend
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// Record returns a Backend passing all calls to b and writing them
+// together with their results to w. The recording can be served by
+// Replay, e.g. to reproduce a bug report in a test without the
+// reporter's firmware.
+func Record(b Backend, w io.Writer) Backend {
+	return &recorder{b: b, enc: json.NewEncoder(w)}
+}
+
+func (r *recorder) write(c *Call) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// A failing recording must not change the behavior of the backend
+	_ = r.enc.Encode(c)
+}
+
+func (r *recorder) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	attrs, data, err := r.b.Get(desc)
+	c := &Call{Op: "get", Name: desc.Name, GUID: desc.GUID.String(), Attributes: attrs, Data: data}
+	c.setError(err)
+	r.write(c)
+	return attrs, data, err
+}
+
+func (r *recorder) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	err := r.b.Set(desc, attrs, data)
+	c := &Call{Op: "set", Name: desc.Name, GUID: desc.GUID.String(), Attributes: attrs, Data: data}
+	c.setError(err)
+	r.write(c)
+	return err
+}
+
+func (r *recorder) Remove(desc VariableDescriptor) error {
+	err := r.b.Remove(desc)
+	c := &Call{Op: "remove", Name: desc.Name, GUID: desc.GUID.String()}
+	c.setError(err)
+	r.write(c)
+	return err
+}
+
+func (r *recorder) List() ([]VariableDescriptor, error) {
+	list, err := r.b.List()
+	c := &Call{Op: "list"}
+	for _, d := range list {
+		c.List = append(c.List, d.String())
+	}
+	c.setError(err)
+	r.write(c)
+	return list, err
+}
+
+// Replayer is a Backend serving the responses of a recording created
+// by Record. Calls have to be made in the same order as recorded.
+type Replayer struct {
+	mu    sync.Mutex
+	calls []Call
+}
+
+// Replay reads a recording created by Record.
+func Replay(r io.Reader) (*Replayer, error) {
+	var calls []Call
+	dec := json.NewDecoder(r)
+	for {
+		var c Call
+		err := dec.Decode(&c)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading recording: %w", err)
+		}
+		calls = append(calls, c)
+	}
+	return &Replayer{calls: calls}, nil
+}
+
+// Remaining returns the number of recorded calls not replayed yet.
+func (r *Replayer) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.calls)
+}
+
+// next pops the next recorded call and checks it matches. match checks
+// the arguments beyond desc, if not nil.
+func (r *Replayer) next(op string, desc *VariableDescriptor, match func(c *Call) error) (*Call, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.calls) == 0 {
+		return nil, fmt.Errorf("%w: unexpected %s, recording exhausted", ErrReplayMismatch, op)
+	}
+	c := r.calls[0]
+	if c.Op != op {
+		return nil, fmt.Errorf("%w: got %s, recorded %s", ErrReplayMismatch, op, c.Op)
+	}
+	if desc != nil && (c.Name != desc.Name || c.GUID != desc.GUID.String()) {
+		return nil, fmt.Errorf("%w: got %s of %s, recorded %s-%s",
+			ErrReplayMismatch, op, desc, c.Name, c.GUID)
+	}
+	if match != nil {
+		if err := match(&c); err != nil {
+			return nil, err
+		}
+	}
+	r.calls = r.calls[1:]
+	return &c, nil
+}
+
+// Get returns the recorded result of the next call, which must be a Get of desc.
+func (r *Replayer) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	c, err := r.next("get", &desc, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	return c.Attributes, c.Data, c.err()
+}
+
+// Set returns the recorded result of the next call, which must be a Set
+// of desc with the same attributes and data.
+func (r *Replayer) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	c, err := r.next("set", &desc, func(c *Call) error {
+		if c.Attributes != attrs || !bytes.Equal(c.Data, data) {
+			return fmt.Errorf("%w: got set of %s to %s %x, recorded %s %x",
+				ErrReplayMismatch, desc, attrs, data, c.Attributes, c.Data)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return c.err()
+}
+
+// Remove returns the recorded result of the next call, which must be a Remove of desc.
+func (r *Replayer) Remove(desc VariableDescriptor) error {
+	c, err := r.next("remove", &desc, nil)
+	if err != nil {
+		return err
+	}
+	return c.err()
+}
+
+// List returns the recorded result of the next call, which must be a List.
+func (r *Replayer) List() ([]VariableDescriptor, error) {
+	c, err := r.next("list", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	var list []VariableDescriptor
+	for _, s := range c.List {
+		if len(s) < guidLength+1 {
+			return nil, fmt.Errorf("invalid recorded variable %q", s)
+		}
+		g, err := guid.Parse(s[len(s)-guidLength:])
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, VariableDescriptor{Name: s[:len(s)-guidLength-1], GUID: &g})
+	}
+	return list, c.err()
+}
@@ -0,0 +1,108 @@
+package efivarfs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestReplay(t *testing.T) {
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	var recording bytes.Buffer
+	b := Record(Dir(t.TempDir()), &recording)
+	if err := b.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := b.Get(timeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(timeout); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Remove(timeout); !errors.Is(err, ErrVarNotExist) {
+		t.Fatalf("Remove() = %v of a removed variable", err)
+	}
+
+	r, err := Replay(&recording)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		name  string
+		attrs VariableAttributes
+		data  []byte
+	}{
+		{"other data", attrs, []byte{6, 0}},
+		{"no data", attrs, nil},
+		{"other attributes", AttributeNonVolatile | AttributeBootserviceAccess, []byte{5, 0}},
+	} {
+		if err := r.Set(timeout, tt.attrs, tt.data); !errors.Is(err, ErrReplayMismatch) {
+			t.Errorf("Set() = %v with %s, want ErrReplayMismatch", err, tt.name)
+		}
+	}
+	if n := r.Remaining(); n != 4 {
+		t.Fatalf("Remaining() = %d after mismatching calls, want 4", n)
+	}
+	if err := r.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Errorf("Set() = %v as recorded", err)
+	}
+	if a, data, err := r.Get(timeout); err != nil || a != attrs || !bytes.Equal(data, []byte{5, 0}) {
+		t.Errorf("Get() = %s, %x, %v, want the recorded content", a, data, err)
+	}
+	lang := VariableDescriptor{Name: "PlatformLang", GUID: &GlobalVariable}
+	if err := r.Remove(lang); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("Remove() = %v of another variable, want ErrReplayMismatch", err)
+	}
+	if err := r.Remove(timeout); err != nil {
+		t.Errorf("Remove() = %v as recorded", err)
+	}
+	if err := r.Remove(timeout); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Remove() = %v, want the recorded ErrVarNotExist", err)
+	}
+	if err := r.Remove(timeout); !errors.Is(err, ErrReplayMismatch) {
+		t.Errorf("Remove() = %v after the recording ended, want ErrReplayMismatch", err)
+	}
+}
+
+// errorBackend fails Remove with err.
+type errorBackend struct {
+	Backend
+	err error
+}
+
+func (b errorBackend) Remove(VariableDescriptor) error {
+	return b.err
+}
+
+func TestReplayErrors(t *testing.T) {
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	for _, s := range sentinels {
+		var recording bytes.Buffer
+		b := Record(errorBackend{err: fmt.Errorf("remove Timeout: %w", s.err)}, &recording)
+		b.Remove(timeout)
+		r, err := Replay(&recording)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = r.Remove(timeout)
+		for _, o := range sentinels {
+			if errors.Is(err, o.err) != (o.err == s.err) {
+				t.Errorf("%s: errors.Is(%v, %s) = %v after replay", s.kind, err, o.kind, o.err != s.err)
+			}
+		}
+	}
+
+	// Errors matching several sentinels are always recorded as the
+	// most specific one
+	err := &BulkError{Errors: []*VariableError{
+		{Op: "remove", Desc: timeout, Err: ErrVarNotExist},
+		{Op: "remove", Desc: timeout, Err: &PolicyError{}},
+	}}
+	var c Call
+	c.setError(err)
+	if c.Kind != "ErrPolicyDenied" {
+		t.Errorf("error recorded as %s, want ErrPolicyDenied", c.Kind)
+	}
+}
@@ -0,0 +1,114 @@
+package efivarfs
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrSetVariableUnsupported is caused by modifying variables on firmware
+// that doesn't provide the SetVariable runtime service, e.g. because the
+// variable store can only be written before ExitBootServices. The kernel
+// mounts efivarfs read-only there, so writes would fail anyway.
+var ErrSetVariableUnsupported = errors.New("firmware doesn't support SetVariable at runtime")
+
+// RuntimeServicesSupported is the variable holding the runtime services
+// the firmware provides after ExitBootServices, as a bit mask of
+// RuntimeServices.
+var RuntimeServicesSupported = VariableDescriptor{Name: "RuntimeServicesSupported", GUID: &GlobalVariable}
+
+// RuntimeServices tells which runtime services the firmware provides
+// after ExitBootServices, decoded from the EFI_RT_SUPPORTED_* bits of
+// RuntimeServicesSupported.
+type RuntimeServices struct {
+	GetTime                   bool
+	SetTime                   bool
+	GetWakeupTime             bool
+	SetWakeupTime             bool
+	GetVariable               bool
+	GetNextVariableName       bool
+	SetVariable               bool
+	SetVirtualAddressMap      bool
+	ConvertPointer            bool
+	GetNextHighMonotonicCount bool
+	ResetSystem               bool
+	UpdateCapsule             bool
+	QueryCapsuleCapabilities  bool
+	QueryVariableInfo         bool
+}
+
+// fields returns pointers to the fields of r in the order of their bits.
+func (r *RuntimeServices) fields() []*bool {
+	return []*bool{
+		&r.GetTime, &r.SetTime, &r.GetWakeupTime, &r.SetWakeupTime,
+		&r.GetVariable, &r.GetNextVariableName, &r.SetVariable,
+		&r.SetVirtualAddressMap, &r.ConvertPointer,
+		&r.GetNextHighMonotonicCount, &r.ResetSystem, &r.UpdateCapsule,
+		&r.QueryCapsuleCapabilities, &r.QueryVariableInfo,
+	}
+}
+
+// AllRuntimeServices has every runtime service, which firmware not
+// providing RuntimeServicesSupported implements.
+var AllRuntimeServices = DecodeRuntimeServices(0x3fff)
+
+// DecodeRuntimeServices decodes the bit mask of RuntimeServicesSupported.
+// Reserved bits are ignored.
+func DecodeRuntimeServices(mask uint16) RuntimeServices {
+	var r RuntimeServices
+	for i, f := range r.fields() {
+		*f = mask&(1<<i) != 0
+	}
+	return r
+}
+
+// Mask returns the bit mask of RuntimeServicesSupported for r.
+func (r RuntimeServices) Mask() uint16 {
+	var mask uint16
+	for i, f := range r.fields() {
+		if *f {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+// ReadRuntimeServices reads RuntimeServicesSupported from b. The
+// variable is optional, firmware without it provides all services.
+func ReadRuntimeServices(b ReadBackend) (RuntimeServices, error) {
+	_, data, err := b.Get(RuntimeServicesSupported)
+	switch {
+	case errors.Is(err, ErrVarNotExist):
+		return AllRuntimeServices, nil
+	case err != nil:
+		return RuntimeServices{}, err
+	case len(data) != 2:
+		return RuntimeServices{}, fmt.Errorf("%s: %d bytes instead of 2", RuntimeServicesSupported, len(data))
+	}
+	return DecodeRuntimeServices(binary.LittleEndian.Uint16(data)), nil
+}
+
+// runtimeCheck caches whether SetVariable is missing, shared by the
+// copies of a Client made by WithContext.
+type runtimeCheck struct {
+	once          sync.Once
+	noSetVariable bool
+}
+
+// checkSetVariable returns an error wrapping ErrSetVariableUnsupported
+// if the firmware behind c announces that it lacks SetVariable, which
+// both op "set" and "remove" need. The variable is read once per Client,
+// failures to read it are left to the write itself.
+func (c *Client) checkSetVariable(op string, desc VariableDescriptor) error {
+	c.runtime.once.Do(func() {
+		if rt, err := ReadRuntimeServices(c.backend); err == nil {
+			c.runtime.noSetVariable = !rt.SetVariable
+		}
+	})
+	if !c.runtime.noSetVariable {
+		return nil
+	}
+	c.debug(op+" without SetVariable", desc)
+	return fmt.Errorf("%s %s: %w", op, desc, ErrSetVariableUnsupported)
+}
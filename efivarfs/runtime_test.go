@@ -0,0 +1,37 @@
+package efivarfs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRuntimeServices(t *testing.T) {
+	if rt := DecodeRuntimeServices(0x2070); !rt.GetVariable || !rt.GetNextVariableName ||
+		!rt.SetVariable || !rt.QueryVariableInfo || rt.GetTime || rt.Mask() != 0x2070 {
+		t.Errorf("DecodeRuntimeServices(0x2070) = %+v", rt)
+	}
+
+	dir := Dir(t.TempDir())
+	attrs := AttributeBootserviceAccess | AttributeRuntimeAccess
+	// GetVariable and GetNextVariableName only, as on firmware keeping
+	// the variable store in flash owned by the boot services
+	if err := dir.Set(RuntimeServicesSupported, attrs, []byte{0x30, 0}); err != nil {
+		t.Fatal(err)
+	}
+	timeout := VariableDescriptor{Name: "Timeout", GUID: &GlobalVariable}
+	c := NewClient(dir)
+	if err := c.Set(timeout, AttributeNonVolatile|attrs, []byte{5, 0}); !errors.Is(err, ErrSetVariableUnsupported) {
+		t.Errorf("Set() = %v, want ErrSetVariableUnsupported", err)
+	}
+	if err := c.WithContext(context.Background()).Remove(RuntimeServicesSupported); !errors.Is(err, ErrSetVariableUnsupported) {
+		t.Errorf("Remove() = %v, want ErrSetVariableUnsupported", err)
+	}
+	caps, err := c.Capabilities()
+	if err != nil || caps.Write || caps.RuntimeServices.SetVariable || !caps.RuntimeServices.GetVariable {
+		t.Errorf("Capabilities() = %+v, %v, want no writes", caps, err)
+	}
+	if err := NewClient(dir, WithForce()).Set(timeout, AttributeNonVolatile|attrs, []byte{5, 0}); err != nil {
+		t.Errorf("Set() = %v with WithForce", err)
+	}
+}
@@ -0,0 +1,95 @@
+package efivarfs
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+)
+
+// SortOrder selects the order of the variables returned by the List
+// method of a Client.
+type SortOrder int
+
+const (
+	// SortByName orders variables by name and then GUID, which is the
+	// default
+	SortByName SortOrder = iota
+	// SortByGUID groups the variables of each vendor, ordered by name
+	SortByGUID
+	// SortBySize puts the largest variables first. Determining the
+	// sizes needs a stat of every efivarfs file and reads every
+	// variable of other backends.
+	SortBySize
+	// Unsorted keeps the order of the backend, for efivarfs the order
+	// of the directory, and saves sorting large stores
+	Unsorted
+)
+
+// WithSortOrder makes List return the variables in the given order
+// instead of SortByName.
+func WithSortOrder(order SortOrder) Option {
+	return func(c *Client) {
+		c.order = order
+	}
+}
+
+// Compare orders descriptors by name and then GUID, returning -1, 0 or
+// +1 like strings.Compare. Names are compared by code points, which
+// doesn't depend on the locale, and GUIDs by their bytes, which is the
+// order of their textual representation.
+func Compare(a, b VariableDescriptor) int {
+	if c := strings.Compare(a.Name, b.Name); c != 0 {
+		return c
+	}
+	return bytes.Compare(a.GUID[:], b.GUID[:])
+}
+
+// compareGUID orders descriptors by GUID and then name.
+func compareGUID(a, b VariableDescriptor) int {
+	if c := bytes.Compare(a.GUID[:], b.GUID[:]); c != 0 {
+		return c
+	}
+	return strings.Compare(a.Name, b.Name)
+}
+
+// sort orders descs as selected with WithSortOrder. Backends usually
+// return them ordered by name already, which is only checked then.
+func (c *Client) sort(descs []VariableDescriptor) {
+	switch c.order {
+	case SortByName:
+		if !slices.IsSortedFunc(descs, Compare) {
+			slices.SortFunc(descs, Compare)
+		}
+	case SortByGUID:
+		slices.SortFunc(descs, compareGUID)
+	case SortBySize:
+		sizes := make(map[VariableKey]int, len(descs))
+		for _, desc := range descs {
+			sizes[desc.Key()] = c.size(desc)
+		}
+		slices.SortFunc(descs, func(a, b VariableDescriptor) int {
+			if sa, sb := sizes[a.Key()], sizes[b.Key()]; sa != sb {
+				return sb - sa
+			}
+			return Compare(a, b)
+		})
+	}
+}
+
+// size returns the size of the data of desc for SortBySize, -1 if it
+// can't be determined, e.g. because the variable was removed since it
+// was listed.
+func (c *Client) size(desc VariableDescriptor) int {
+	if r, ok := c.backend.(SizeReader); ok {
+		size, err := r.GetSize(desc)
+		if err != nil {
+			return -1
+		}
+		return size
+	}
+	_, data, err := c.backend.Get(desc)
+	if err != nil {
+		return -1
+	}
+	return len(data)
+}
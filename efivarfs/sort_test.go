@@ -0,0 +1,60 @@
+package efivarfs
+
+import (
+	"reflect"
+	"testing"
+)
+
+// copyingBackend returns a fresh GUID pointer for every descriptor it
+// lists, so sorting can't rely on descriptors being comparable.
+type copyingBackend struct {
+	Backend
+}
+
+func (b copyingBackend) List() ([]VariableDescriptor, error) {
+	descs, err := b.Backend.List()
+	for i := range descs {
+		g := *descs[i].GUID
+		descs[i].GUID = &g
+	}
+	return descs, err
+}
+
+func TestSortOrder(t *testing.T) {
+	b := Dir(t.TempDir())
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	shim := MustParseGUID("605dab50-e046-4300-abb6-3dd810dd8b23")
+	for _, v := range []struct {
+		desc VariableDescriptor
+		size int
+	}{
+		{VariableDescriptor{Name: "A", GUID: &GlobalVariable}, 1},
+		{VariableDescriptor{Name: "B", GUID: &GlobalVariable}, 3},
+		{VariableDescriptor{Name: "C", GUID: &GlobalVariable}, 3},
+		{VariableDescriptor{Name: "Z", GUID: &shim}, 2},
+	} {
+		if err := b.Set(v.desc, attrs, make([]byte, v.size)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, tt := range []struct {
+		order SortOrder
+		want  []string
+	}{
+		{SortByName, []string{"A", "B", "C", "Z"}},
+		{SortByGUID, []string{"Z", "A", "B", "C"}},
+		{SortBySize, []string{"B", "C", "Z", "A"}},
+	} {
+		descs, err := NewClient(copyingBackend{b}, WithSortOrder(tt.order)).List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, d := range descs {
+			got = append(got, d.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("List() = %q with order %d, want %q", got, tt.order, tt.want)
+		}
+	}
+}
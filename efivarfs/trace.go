@@ -0,0 +1,70 @@
+package efivarfs
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName identifies the spans of a Client.
+const tracerName = "github.com/system-transparency/efivar/efivarfs"
+
+// spanPrefix is prepended to the operation in span names.
+const spanPrefix = "efivarfs."
+
+// WithTracerProvider makes the Client create a span for every operation
+// using a tracer of tp. The spans carry the name and GUID of the
+// variable and the number of bytes read or written, so the time spent
+// on slow NVRAM writes shows up in the traces of the calling program.
+// Use WithContext to make them children of an existing span.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *Client) {
+		c.tracer = tp.Tracer(tracerName)
+	}
+}
+
+// WithContext returns a shallow copy of c that starts its spans from
+// ctx, e.g. as children of the span of the provisioning step.
+func (c *Client) WithContext(ctx context.Context) *Client {
+	c2 := *c
+	c2.ctx = ctx
+	return &c2
+}
+
+// startSpan starts the span of op on desc. attrs is only passed for
+// writes. The GUID is left out for descriptors without one, which the
+// backend rejects.
+func (c *Client) startSpan(op string, desc VariableDescriptor, attrs ...VariableAttributes) trace.Span {
+	kv := []attribute.KeyValue{attribute.String("efivar.name", desc.Name)}
+	if desc.GUID != nil {
+		kv = append(kv, attribute.String("efivar.guid", desc.GUID.String()))
+	}
+	for _, a := range attrs {
+		kv = append(kv, attribute.Int64("efivar.attributes", int64(a)))
+	}
+	_, span := c.tracer.Start(c.ctx, spanPrefix+op, trace.WithSpanKind(trace.SpanKindClient), trace.WithAttributes(kv...))
+	return span
+}
+
+// endSpan records the size of the transferred data and err on span and
+// ends it.
+func endSpan(span trace.Span, size int, err error) {
+	span.SetAttributes(attribute.Int("efivar.size", size))
+	end(span, err)
+}
+
+// endList is endSpan for List, which records the number of variables.
+func endList(span trace.Span, count int, err error) {
+	span.SetAttributes(attribute.Int("efivar.count", count))
+	end(span, err)
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,102 @@
+package efivarfs
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// spanRecorder is a TracerProvider keeping the spans in memory.
+type spanRecorder struct {
+	noop.TracerProvider
+	spans []*recordedSpan
+}
+
+func (r *spanRecorder) Tracer(string, ...trace.TracerOption) trace.Tracer {
+	return recordingTracer{r: r}
+}
+
+type recordingTracer struct {
+	noop.Tracer
+	r *spanRecorder
+}
+
+func (t recordingTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	cfg := trace.NewSpanStartConfig(opts...)
+	s := &recordedSpan{name: name, kind: cfg.SpanKind(), attrs: cfg.Attributes()}
+	t.r.spans = append(t.r.spans, s)
+	return ctx, s
+}
+
+type recordedSpan struct {
+	noop.Span
+	name   string
+	kind   trace.SpanKind
+	attrs  []attribute.KeyValue
+	status codes.Code
+	err    error
+	ended  bool
+}
+
+func (s *recordedSpan) SetAttributes(kv ...attribute.KeyValue) { s.attrs = append(s.attrs, kv...) }
+func (s *recordedSpan) SetStatus(c codes.Code, _ string)       { s.status = c }
+func (s *recordedSpan) RecordError(err error, _ ...trace.EventOption) {
+	s.err = err
+}
+func (s *recordedSpan) End(...trace.SpanEndOption) { s.ended = true }
+
+func TestTracing(t *testing.T) {
+	r := &spanRecorder{}
+	c := NewClient(Dir(t.TempDir()), WithTracerProvider(r))
+	timeout := NewDescriptor("Timeout", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess
+	if err := c.Set(timeout, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := c.Get(timeout); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.List(); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Remove(NewDescriptor("Missing", GlobalVariable)); !errors.Is(err, ErrVarNotExist) {
+		t.Fatalf("Remove() = %v of a missing variable", err)
+	}
+	if err := c.Set(VariableDescriptor{Name: "NoGUID"}, attrs, []byte{1}); !errors.Is(err, ErrInvalidGUID) {
+		t.Fatalf("Set() = %v without GUID, want ErrInvalidGUID", err)
+	}
+
+	guid := attribute.String("efivar.guid", GlobalVariable.String())
+	for i, want := range []struct {
+		name  string
+		attrs []attribute.KeyValue
+		err   error
+	}{
+		{"efivarfs.Set", []attribute.KeyValue{attribute.String("efivar.name", "Timeout"), guid, attribute.Int64("efivar.attributes", 7), attribute.Int("efivar.size", 2)}, nil},
+		{"efivarfs.Get", []attribute.KeyValue{attribute.String("efivar.name", "Timeout"), guid, attribute.Int("efivar.size", 2)}, nil},
+		{"efivarfs.List", []attribute.KeyValue{attribute.Int("efivar.count", 1)}, nil},
+		{"efivarfs.Remove", []attribute.KeyValue{attribute.String("efivar.name", "Missing"), guid, attribute.Int("efivar.size", 0)}, ErrVarNotExist},
+		{"efivarfs.Set", []attribute.KeyValue{attribute.String("efivar.name", "NoGUID"), attribute.Int64("efivar.attributes", 7), attribute.Int("efivar.size", 1)}, ErrInvalidGUID},
+	} {
+		if i >= len(r.spans) {
+			t.Fatalf("got %d spans, want %s next", len(r.spans), want.name)
+		}
+		s := r.spans[i]
+		if s.name != want.name || s.kind != trace.SpanKindClient || !s.ended || !reflect.DeepEqual(s.attrs, want.attrs) {
+			t.Errorf("span %d = %s %s %v ended %v, want %s %v", i, s.kind, s.name, s.attrs, s.ended, want.name, want.attrs)
+		}
+		wantStatus := codes.Unset
+		if want.err != nil {
+			wantStatus = codes.Error
+		}
+		if s.status != wantStatus || !errors.Is(s.err, want.err) {
+			t.Errorf("span %d %s has status %v and error %v, want %v", i, s.name, s.status, s.err, want.err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrInvalidAttributes is caused by attribute combinations the UEFI
+// specification doesn't allow
+var ErrInvalidAttributes = errors.New("invalid variable attributes")
+
+// ErrInvalidName is caused by variable names that can't be stored
+var ErrInvalidName = errors.New("invalid variable name")
+
+// ErrVariableTooLarge is caused by writing more data than allowed with
+// WithMaxVariableSize
+var ErrVariableTooLarge = errors.New("variable exceeds maximum size")
+
+// DefaultMaxVariableSize is the largest variable a Client writes unless
+// configured otherwise with WithMaxVariableSize. Many firmwares have 64
+// KiB of variable storage in total and fail larger writes with errors
+// as unhelpful as EIO, or worse, corrupt the store.
+const DefaultMaxVariableSize = 64 << 10
+
+// maxNameLength is the longest name in bytes that still fits into the
+// 255 byte file names of efivarfs together with the hyphen and GUID.
+const maxNameLength = 255 - 1 - guidLength
+
+// ValidateName returns an error wrapping ErrInvalidName if name is
+// empty, too long for efivarfs, not valid UTF-8 or contains a slash or
+// NUL, which would change the meaning of the efivarfs path built from it.
+func ValidateName(name string) error {
+	var reason string
+	switch {
+	case name == "":
+		reason = "name is empty"
+	case len(name) > maxNameLength:
+		reason = fmt.Sprintf("name is longer than %d bytes", maxNameLength)
+	case !utf8.ValidString(name):
+		reason = "name is not valid UTF-8"
+	case strings.ContainsAny(name, "/\x00"):
+		reason = "name contains a slash or NUL"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%q: %s: %w", name, reason, ErrInvalidName)
+}
+
+// WithForce disables the validation of attributes in Set and the check
+// for the SetVariable runtime service in Set and Remove, e.g. to test
+// how a firmware reacts to invalid ones.
+func WithForce() Option {
+	return func(c *Client) {
+		c.force = true
+	}
+}
+
+// WithMaxVariableSize makes Set reject data larger than size bytes with
+// ErrVariableTooLarge instead of DefaultMaxVariableSize, for platforms
+// known to accept larger variables. A size of 0 disables the check.
+func WithMaxVariableSize(size int) Option {
+	return func(c *Client) {
+		c.maxSize = size
+	}
+}
+
+// validateSize returns an error wrapping ErrVariableTooLarge if data
+// exceeds the maximum size of c. For appends only the appended data is
+// checked, as the resulting size isn't known without reading the
+// variable.
+func (c *Client) validateSize(desc VariableDescriptor, data []byte) error {
+	if c.maxSize > 0 && len(data) > c.maxSize {
+		return fmt.Errorf("%s: %d bytes, the maximum is %d: %w", desc, len(data), c.maxSize, ErrVariableTooLarge)
+	}
+	return nil
+}
+
+// ValidateAttributes returns an error wrapping ErrInvalidAttributes if
+// attrs are not allowed for desc by the UEFI specification. Firmware
+// reacts to them in different ways, from rejecting the write to
+// silently storing a variable that is inaccessible afterwards.
+func ValidateAttributes(desc VariableDescriptor, attrs VariableAttributes) error {
+	var reason string
+	switch {
+	case attrs&AttributeRuntimeAccess != 0 && attrs&AttributeBootserviceAccess == 0:
+		reason = "runtime access requires boot service access"
+	case attrs&AttributeTimeBasedAuthenticatedWriteAccess != 0 && attrs&AttributeEnhancedAuthenticatedAccess != 0:
+		reason = "time based and enhanced authenticated access are mutually exclusive"
+	case attrs&AttributeHardwareErrorRecord != 0 && !strings.HasPrefix(desc.Name, "HwErrRec"):
+		reason = "hardware error record attribute is reserved for HwErrRec variables"
+	default:
+		return nil
+	}
+	return fmt.Errorf("%s with attributes %#x: %s: %w", desc, uint32(attrs), reason, ErrInvalidAttributes)
+}
@@ -0,0 +1,98 @@
+package efivarfs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateAttributes(t *testing.T) {
+	boot := NewDescriptor("Boot0001", GlobalVariable)
+	hwErr := NewDescriptor("HwErrRec0001", GlobalVariable)
+	for _, tt := range []struct {
+		desc  VariableDescriptor
+		attrs VariableAttributes
+		valid bool
+	}{
+		{boot, AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess, true},
+		{boot, AttributeBootserviceAccess, true},
+		{boot, 0, true},
+		{boot, AttributeNonVolatile | AttributeRuntimeAccess, false},
+		{boot, AttributeBootserviceAccess | AttributeTimeBasedAuthenticatedWriteAccess | AttributeEnhancedAuthenticatedAccess, false},
+		{boot, AttributeNonVolatile | AttributeBootserviceAccess | AttributeHardwareErrorRecord, false},
+		{hwErr, AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess | AttributeHardwareErrorRecord, true},
+	} {
+		err := ValidateAttributes(tt.desc, tt.attrs)
+		if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidAttributes)) {
+			t.Errorf("ValidateAttributes(%s, %s) = %v, want valid %v", tt.desc.Name, tt.attrs, err, tt.valid)
+		}
+	}
+}
+
+func TestForce(t *testing.T) {
+	desc := NewDescriptor("Test", GlobalVariable)
+	invalid := AttributeNonVolatile | AttributeRuntimeAccess
+	c := NewClient(Dir(t.TempDir()))
+	if err := c.Set(desc, invalid, []byte{1}); !errors.Is(err, ErrInvalidAttributes) {
+		t.Errorf("Set() = %v with invalid attributes, want ErrInvalidAttributes", err)
+	}
+	if _, _, err := c.Get(desc); !errors.Is(err, ErrVarNotExist) {
+		t.Errorf("Get() = %v after rejected Set(), want ErrVarNotExist", err)
+	}
+
+	c = NewClient(Dir(t.TempDir()), WithForce())
+	if err := c.Set(desc, invalid, []byte{1}); err != nil {
+		t.Errorf("Set() = %v with invalid attributes and WithForce", err)
+	}
+	if attrs, _, err := c.Get(desc); err != nil || attrs != invalid {
+		t.Errorf("Get() = %s, %v, want %s", attrs, err, invalid)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		valid bool
+	}{
+		{"Boot0001", true},
+		{"Größe", true},
+		{"name-with-hyphens", true},
+		{strings.Repeat("a", maxNameLength), true},
+		{"", false},
+		{strings.Repeat("a", maxNameLength+1), false},
+		{"invalid\xff", false},
+		{"a/b", false},
+		{"../escaped", false},
+		{"nul\x00", false},
+	} {
+		err := ValidateName(tt.name)
+		if (err == nil) != tt.valid || (err != nil && !errors.Is(err, ErrInvalidName)) {
+			t.Errorf("ValidateName(%q) = %v, want valid %v", tt.name, err, tt.valid)
+		}
+	}
+}
+
+func TestMaxVariableSize(t *testing.T) {
+	desc := NewDescriptor("Large", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess
+	for _, tt := range []struct {
+		opts []Option
+		size int
+		err  error
+	}{
+		{nil, DefaultMaxVariableSize, nil},
+		{nil, DefaultMaxVariableSize + 1, ErrVariableTooLarge},
+		{[]Option{WithMaxVariableSize(16)}, 16, nil},
+		{[]Option{WithMaxVariableSize(16)}, 17, ErrVariableTooLarge},
+		{[]Option{WithMaxVariableSize(0)}, DefaultMaxVariableSize + 1, nil},
+	} {
+		c := NewClient(Dir(t.TempDir()), tt.opts...)
+		if err := c.Set(desc, attrs, make([]byte, tt.size)); !errors.Is(err, tt.err) {
+			t.Errorf("Set() = %v with %d bytes and %d options, want %v", err, tt.size, len(tt.opts), tt.err)
+		}
+		// Appends are checked as well
+		if err := c.Set(desc, attrs|AttributeAppendWrite, make([]byte, tt.size)); !errors.Is(err, tt.err) {
+			t.Errorf("append = %v with %d bytes and %d options, want %v", err, tt.size, len(tt.opts), tt.err)
+		}
+	}
+}
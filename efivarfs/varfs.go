@@ -1,23 +1,26 @@
 package efivarfs
 
 import (
-	"bytes"
 	"encoding/binary"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"sort"
+	"slices"
+	"syscall"
 
 	guid "github.com/google/uuid"
-	"golang.org/x/sys/unix"
 )
 
-// EfiVarFs is the path to the efivarfs mount point
+// EfiVarFs is the path to the efivarfs mount point, if it isn't mounted
+// there MountPoint looks for it in ProcMounts
 //
 // Note: This has to be a var instead of const because of
-// our unit tests.
+// our unit tests. Use SetPaths to change it while the package is in
+// use.
 var EfiVarFs = "/sys/firmware/efi/efivars/"
 
 var (
@@ -31,15 +34,43 @@ var (
 	ErrVarNotExist = errors.New("variable does not exist")
 
 	// ErrVarPermission is caused by not haven the right permissions either
-	// because of not being root or xattrs not allowing changes
+	// because of not being root or xattrs not allowing changes. The
+	// returned errors wrap it together with the underlying
+	// *os.PathError, so test for it with errors.Is.
 	ErrVarPermission = errors.New("permission denied")
+
+	// ErrReadOnlyFilesystem is caused by modifying variables while
+	// efivarfs is mounted read-only, which Mount and WithMount fix by
+	// remounting it read-write. The returned errors wrap it together
+	// with the underlying *os.PathError.
+	ErrReadOnlyFilesystem = errors.New("efivarfs is mounted read-only")
+
+	// ErrNoSpace is caused by the firmware running out of variable
+	// storage, a garbage collection usually happens on the next reboot
+	ErrNoSpace = errors.New("no space left in variable store")
 )
 
+// guidLength is the length of the textual representation of a GUID
+const guidLength = 36
+
 // efivarfs represents the real efivarfs of the Linux kernel
 // and has the relevant methods like get, set and remove, which
 // will operate on the actual efi variables inside the Linux
 // efivarfs backend.
-type efivarfs struct{}
+type efivarfs struct {
+	// root is the directory holding the variable files
+	root string
+	// snapshot is set for plain directories, which don't need the
+	// immutable flag handling of the real efivarfs
+	snapshot bool
+	// logger receives debug messages about the immutable flag handling,
+	// nil disables logging
+	logger *slog.Logger
+	// sync makes writes durable before Set returns
+	sync bool
+	// unsorted makes List return the variables in directory order
+	unsorted bool
+}
 
 // probeAndReturn will probe for the efivarfs filesystem
 // magic value on the expected mountpoint inside the sysfs.
@@ -48,173 +79,412 @@ type efivarfs struct{}
 // operations can be done. Otherwise it will return an
 // error of type ErrFsNotMounted.
 func probeAndReturn() (*efivarfs, error) {
-	var stat unix.Statfs_t
-	if err := unix.Statfs(EfiVarFs, &stat); err != nil {
-		return nil, fmt.Errorf("statfs error occured: %w", ErrFsNotMounted)
+	return probe(nil, "")
+}
+
+// probe is probeAndReturn with a logger for the returned backend. root
+// is the mount point to use, if empty it is looked up with MountPoint.
+func probe(logger *slog.Logger, root string) (*efivarfs, error) {
+	if root == "" {
+		mp, err := MountPoint()
+		if err != nil {
+			debug(logger, "probing efivarfs failed", "path", CurrentPaths().EfiVarFs, "err", err)
+			return nil, err
+		}
+		root = mp
+	} else if err := checkEfivarfs(root); err != nil {
+		debug(logger, "probing efivarfs failed", "path", root, "err", err)
+		return nil, err
+	}
+	debug(logger, "probed efivarfs", "path", root)
+	return &efivarfs{root: root, logger: logger}, nil
+}
+
+// debug logs to logger at debug level unless it is nil.
+func debug(logger *slog.Logger, msg string, args ...any) {
+	if logger != nil {
+		logger.Debug(msg, args...)
+	}
+}
+
+// path returns the location of the file backing desc. The name is
+// validated first, so it can't point outside of root.
+func (v *efivarfs) path(desc VariableDescriptor) (string, error) {
+	if err := ValidateName(desc.Name); err != nil {
+		return "", err
+	}
+	if desc.GUID == nil {
+		return "", fmt.Errorf("%s: %w", desc.Name, ErrInvalidGUID)
+	}
+	b := make([]byte, 0, len(v.root)+1+len(desc.Name)+1+guidLength)
+	b = append(b, v.root...)
+	if len(b) > 0 && !os.IsPathSeparator(b[len(b)-1]) {
+		b = append(b, filepath.Separator)
 	}
-	if uint(stat.Type) != uint(unix.EFIVARFS_MAGIC) {
-		return nil, fmt.Errorf("wrong fs type: %w", ErrFsNotMounted)
+	b = append(b, desc.Name...)
+	b = append(b, '-')
+	b = appendGUID(b, *desc.GUID)
+	return string(b), nil
+}
+
+// appendGUID appends the textual representation of g to b, without the
+// temporary string of g.String().
+func appendGUID(b []byte, g guid.UUID) []byte {
+	var s [guidLength]byte
+	hex.Encode(s[0:8], g[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], g[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], g[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], g[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], g[10:])
+	return append(b, s[:]...)
+}
+
+// Get reads the contents of an efivar if it exists and has the necessary permission
+func (v *efivarfs) Get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
+	f, err := v.open(desc)
+	if err != nil {
+		return 0, nil, err
 	}
-	return &efivarfs{}, nil
+	defer f.Close()
+
+	b, err := readFile(f)
+	switch {
+	case err != nil:
+		return 0, nil, err
+	case len(b) == 0:
+		return 0, nil, ErrVarNotExist
+	case len(b) < 4:
+		return 0, nil, io.ErrUnexpectedEOF
+	}
+	return VariableAttributes(binary.LittleEndian.Uint32(b)), b[4:], nil
 }
 
-// get reads the contents of an efivar if it exists and has the necessary permission
-func (v *efivarfs) get(desc VariableDescriptor) (VariableAttributes, []byte, error) {
-	path := filepath.Join(EfiVarFs, fmt.Sprintf("%s-%s", desc.Name, desc.GUID.String()))
+// open opens the file of desc for reading.
+func (v *efivarfs) open(desc VariableDescriptor) (*os.File, error) {
+	path, err := v.path(desc)
+	if err != nil {
+		return nil, err
+	}
 	f, err := os.OpenFile(path, os.O_RDONLY, 0)
 	switch {
 	case os.IsNotExist(err):
-		return 0, nil, ErrVarNotExist
+		return nil, ErrVarNotExist
 	case os.IsPermission(err):
-		return 0, nil, ErrVarPermission
+		return nil, permission(err)
 	case err != nil:
-		return 0, nil, err
+		return nil, err
 	}
-	defer f.Close()
+	return f, nil
+}
 
-	var attrs VariableAttributes
-	if err := binary.Read(f, binary.LittleEndian, &attrs); err != nil {
+// readFile reads all of f into a buffer sized by its file size, which
+// efivarfs reports as the size of attributes and data. Every read of
+// efivarfs fetches the variable from the firmware again, so unlike with
+// io.ReadAll large variables are read in a single call.
+func readFile(f *os.File) ([]byte, error) {
+	size := 0
+	if fi, err := f.Stat(); err == nil {
+		size = int(fi.Size())
+	}
+	// One byte more than the size lets the read hitting the end of the
+	// file succeed without growing the buffer.
+	b := make([]byte, 0, size+1)
+	for {
+		n, err := f.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
 		if err == io.EOF {
-			return 0, nil, ErrVarNotExist
+			return b, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(b) == cap(b) {
+			b = append(b, 0)[:len(b)]
 		}
-		return 0, nil, err
 	}
+}
 
-	data, err := io.ReadAll(f)
+// Set modifies a given efivar with the provided contents
+func (v *efivarfs) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	if v.snapshot {
+		return v.setSnapshot(desc, attrs, data)
+	}
+	path, err := v.path(desc)
 	if err != nil {
-		return 0, nil, err
+		return err
 	}
-	return attrs, data, nil
-}
-
-// set modifies a given efivar with the provided contents
-func (v *efivarfs) set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
-	path := filepath.Join(EfiVarFs, fmt.Sprintf("%s-%s", desc.Name, desc.GUID.String()))
 	flags := os.O_WRONLY | os.O_CREATE
 	if attrs&AttributeAppendWrite != 0 {
 		flags |= os.O_APPEND
 	}
+	if v.sync {
+		flags |= oDSYNC
+	}
 
-	read, err := os.OpenFile(path, os.O_RDONLY, 0)
+	// Most writes go to new or mutable variables, so try opening for
+	// writing right away and only deal with the immutable flag if the
+	// kernel refuses that with EPERM.
+	write, err := os.OpenFile(path, flags, 0644)
+	if errors.Is(err, syscall.EPERM) {
+		var restoreImmutable func()
+		restoreImmutable, err = v.clearImmutable(path)
+		if err != nil {
+			return err
+		}
+		defer restoreImmutable()
+		write, err = os.OpenFile(path, flags, 0644)
+	}
 	switch {
 	case os.IsNotExist(err):
+		return ErrVarNotExist
+	case errors.Is(err, syscall.EROFS):
+		return readOnlyFilesystem(err)
 	case os.IsPermission(err):
-		return ErrVarPermission
+		return permission(err)
 	case err != nil:
-		return err
-	default:
-		defer read.Close()
+		return noSpace(desc, err)
+	}
+	defer write.Close()
+
+	// efivarfs needs attributes and data in a single write
+	if _, err := write.Write(encode(attrs, data)); err != nil {
+		return noSpace(desc, err)
+	}
+	return nil
+}
 
-		restoreImmutable, err := makeMutable(read)
+// encode returns the content of the efivarfs file for a variable.
+func encode(attrs VariableAttributes, data []byte) []byte {
+	b := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint32(b, uint32(attrs))
+	copy(b[4:], data)
+	return b
+}
+
+// setSnapshot emulates what the firmware does on SetVariable for a plain
+// directory: appends are added to the existing data, writing no data
+// deletes the variable and the append attribute is never stored.
+func (v *efivarfs) setSnapshot(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	path, err := v.path(desc)
+	if err != nil {
+		return err
+	}
+	if attrs&AttributeAppendWrite != 0 {
+		attrs &^= AttributeAppendWrite
+		oldAttrs, old, err := v.Get(desc)
 		switch {
-		case os.IsPermission(err):
-			return ErrVarPermission
+		case errors.Is(err, ErrVarNotExist):
 		case err != nil:
 			return err
+		default:
+			attrs = oldAttrs
+			data = append(old, data...)
 		}
-		defer restoreImmutable()
+	} else if len(data) == 0 {
+		return v.Remove(desc)
 	}
 
-	write, err := os.OpenFile(path, flags, 0644)
+	err = v.writeFile(path, encode(attrs, data))
 	switch {
-	case os.IsNotExist(err):
-		return ErrVarNotExist
+	case errors.Is(err, syscall.EROFS):
+		return readOnlyFilesystem(err)
 	case os.IsPermission(err):
-		return ErrVarPermission
+		return permission(err)
 	case err != nil:
-		return err
+		return noSpace(desc, err)
 	}
-	defer write.Close()
+	return nil
+}
 
-	var buf bytes.Buffer
-	if err := binary.Write(&buf, binary.LittleEndian, attrs); err != nil {
+// writeFile is os.WriteFile, which additionally flushes the file and the
+// directory holding it to disk if v.sync is set.
+func (v *efivarfs) writeFile(path string, data []byte) error {
+	if !v.sync {
+		return os.WriteFile(path, data, 0644)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
 		return err
 	}
-	if _, err := buf.Write(data); err != nil {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
 		return err
 	}
-	if _, err := buf.WriteTo(write); err != nil {
+	if err := f.Sync(); err != nil {
+		f.Close()
 		return err
 	}
-	return nil
+	if err := f.Close(); err != nil {
+		return err
+	}
+	dir, err := os.Open(v.root)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+// permission wraps err, for which os.IsPermission holds, so that
+// errors.Is(err, ErrVarPermission) is true while the *os.PathError and
+// the errno, e.g. EACCES for missing privileges and EPERM for the
+// immutable flag, stay reachable with errors.As and errors.Is.
+func permission(err error) error {
+	return fmt.Errorf("%w: %w", ErrVarPermission, err)
 }
 
-// remove makes the specified EFI var mutable and then deletes it
-func (v *efivarfs) remove(desc VariableDescriptor) error {
-	path := filepath.Join(EfiVarFs, fmt.Sprintf("%s-%s", desc.Name, desc.GUID.String()))
+// readOnlyFilesystem wraps err, which is EROFS, like permission does.
+func readOnlyFilesystem(err error) error {
+	return fmt.Errorf("%w: %w", ErrReadOnlyFilesystem, err)
+}
+
+// noSpace maps the errors the kernel returns for a full variable store
+// to ErrNoSpace and returns all other errors unchanged. Besides ENOSPC
+// some firmware reports EFI_DEVICE_ERROR, which becomes EIO, when a dbx
+// update doesn't fit anymore.
+func noSpace(desc VariableDescriptor, err error) error {
+	switch {
+	case errors.Is(err, syscall.ENOSPC):
+		return fmt.Errorf("writing %s: %w", desc, ErrNoSpace)
+	case errors.Is(err, syscall.EIO) && desc.Name == "dbx" && *desc.GUID == ImageSecurityDatabase:
+		return fmt.Errorf("writing %s failed with %v, assuming dbx is full: %w", desc, err, ErrNoSpace)
+	}
+	return err
+}
+
+// Remove makes the specified EFI var mutable and then deletes it
+func (v *efivarfs) Remove(desc VariableDescriptor) error {
+	path, err := v.path(desc)
+	if err != nil {
+		return err
+	}
 	f, err := os.OpenFile(path, os.O_WRONLY, 0)
 	switch {
 	case os.IsNotExist(err):
 		return ErrVarNotExist
+	case errors.Is(err, syscall.EROFS):
+		return readOnlyFilesystem(err)
 	case os.IsPermission(err):
-		return ErrVarPermission
+		return permission(err)
 	case err != nil:
 		return err
+	case v.snapshot:
+		f.Close()
 	default:
-		_, err := makeMutable(f)
+		_, err := v.makeMutable(f)
 		switch {
+		case errors.Is(err, syscall.EROFS):
+			return readOnlyFilesystem(err)
 		case os.IsPermission(err):
-			return ErrVarPermission
+			return permission(err)
 		case err != nil:
 			return err
 		default:
 			f.Close()
 		}
 	}
-	return os.Remove(path)
+	if err := os.Remove(path); errors.Is(err, syscall.EROFS) {
+		return readOnlyFilesystem(err)
+	} else if err != nil {
+		return err
+	}
+	return nil
 }
 
-// list returns the VariableDescriptor for each efivar in the system
-func (v *efivarfs) list() ([]VariableDescriptor, error) {
-	const guidLength = 36
-	f, err := os.OpenFile(EfiVarFs, os.O_RDONLY, 0)
+// clearImmutable removes the immutable flag of the file at path and
+// returns the function restoring it.
+func (v *efivarfs) clearImmutable(path string) (restore func(), err error) {
+	read, err := os.OpenFile(path, os.O_RDONLY, 0)
 	switch {
-	case os.IsNotExist(err):
-		return nil, ErrVarNotExist
 	case os.IsPermission(err):
-		return nil, ErrVarPermission
+		return nil, permission(err)
 	case err != nil:
 		return nil, err
 	}
-	defer f.Close()
+	restore, err = v.makeMutable(read)
+	switch {
+	case errors.Is(err, syscall.EROFS):
+		read.Close()
+		return nil, readOnlyFilesystem(err)
+	case os.IsPermission(err):
+		read.Close()
+		return nil, permission(err)
+	case err != nil:
+		read.Close()
+		return nil, err
+	}
+	return func() {
+		restore()
+		read.Close()
+	}, nil
+}
 
-	dirents, err := f.Readdir(-1)
+// makeMutable is the package level makeMutable with debug logging.
+func (v *efivarfs) makeMutable(f *os.File) (restore func(), err error) {
+	restore, changed, err := makeMutable(f)
 	if err != nil {
+		debug(v.logger, "clearing immutable flag failed", "path", f.Name(), "err", err)
 		return nil, err
 	}
-	var entries []VariableDescriptor
-	for _, dirent := range dirents {
-		if !dirent.Mode().IsRegular() {
-			// Skip non-regular files
-			continue
-		}
-		if len(dirent.Name()) < guidLength+1 {
-			// Skip files with a basename that isn't long enough
-			// to contain a GUID and a hyphen
-			continue
-		}
-		if dirent.Name()[len(dirent.Name())-guidLength-1] != '-' {
-			// Skip files where the basename doesn't contain a
-			// hyphen between the name and GUID
-			continue
-		}
-		if dirent.Size() == 0 {
-			// Skip files with zero size. These are variables that
-			// have been deleted by writing an empty payload
-			continue
-		}
-
-		name := dirent.Name()[:len(dirent.Name())-guidLength-1]
-		guid, err := guid.Parse(dirent.Name()[len(name)+1:])
-		if err != nil {
-			continue
-		}
+	if !changed {
+		return restore, nil
+	}
+	debug(v.logger, "cleared immutable flag", "path", f.Name())
+	return func() {
+		restore()
+		debug(v.logger, "restored immutable flag", "path", f.Name())
+	}, nil
+}
 
-		entries = append(entries, VariableDescriptor{Name: name, GUID: &guid})
+// List returns the VariableDescriptor for each efivar in the system,
+// ordered with Compare unless unsorted is set.
+// Files of zero size are left out, they are variables that were deleted
+// by writing an empty payload or files created but never written.
+func (v *efivarfs) List() ([]VariableDescriptor, error) {
+	entries, err := v.readDir()
+	if err != nil {
+		return nil, err
+	}
+	// The GUIDs share a single allocation instead of one per variable
+	descs := make([]VariableDescriptor, len(entries))
+	guids := make([]guid.UUID, len(entries))
+	for i, e := range entries {
+		guids[i] = e.guid
+		descs[i] = VariableDescriptor{Name: e.name[:len(e.name)-guidLength-1], GUID: &guids[i]}
 	}
+	if !v.unsorted {
+		slices.SortFunc(descs, Compare)
+	}
+	return descs, nil
+}
+
+// entry is a variable found by List: its file name and the GUID parsed
+// from it.
+type entry struct {
+	name string
+	guid guid.UUID
+}
 
-	sort.Slice(entries, func(i, j int) bool {
-		return fmt.Sprintf("%s-%v", entries[i].Name, entries[i].GUID) < fmt.Sprintf("%s-%v", entries[j].Name, entries[j].GUID)
-	})
-	return entries, nil
+// parseName returns the entry for the file name, which is false if name
+// isn't of the form Name-GUID.
+func parseName(name []byte) (entry, bool) {
+	if len(name) < guidLength+1 {
+		// Skip files with a basename that isn't long enough
+		// to contain a GUID and a hyphen
+		return entry{}, false
+	}
+	if name[len(name)-guidLength-1] != '-' {
+		// Skip files where the basename doesn't contain a
+		// hyphen between the name and GUID
+		return entry{}, false
+	}
+	g, err := guid.ParseBytes(name[len(name)-guidLength:])
+	if err != nil {
+		return entry{}, false
+	}
+	return entry{name: string(name), guid: g}, true
 }
@@ -0,0 +1,167 @@
+package efivarfs
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// oDSYNC is the open flag making writes synchronous.
+const oDSYNC = unix.O_DSYNC
+
+// readDir returns the variables in v.root. The directory is read with
+// getdents in large batches and each entry is checked with fstatat
+// relative to it, which saves resolving the path of every file.
+func (v *efivarfs) readDir() ([]entry, error) {
+	fd, err := unix.Open(v.root, unix.O_RDONLY|unix.O_DIRECTORY|unix.O_CLOEXEC, 0)
+	switch {
+	case err == unix.ENOENT:
+		return nil, ErrVarNotExist
+	case err == unix.EACCES || err == unix.EPERM:
+		return nil, permission(&os.PathError{Op: "open", Path: v.root, Err: err})
+	case err != nil:
+		return nil, &os.PathError{Op: "open", Path: v.root, Err: err}
+	}
+	defer unix.Close(fd)
+
+	var entries []entry
+	buf := make([]byte, direntBufSize)
+	for {
+		n, err := unix.Getdents(fd, buf)
+		if err == unix.EINTR {
+			continue
+		}
+		if err != nil {
+			return nil, os.NewSyscallError("getdents", err)
+		}
+		if n <= 0 {
+			break
+		}
+		entries = parseDirents(fd, buf[:n], entries)
+	}
+	return entries, nil
+}
+
+// direntBufSize is the size of the buffer readDir passes to getdents,
+// which fits a few hundred entries.
+const direntBufSize = 32 << 10
+
+// parseDirents appends the variables in buf, filled by getdents for the
+// directory dirfd, to entries. Each record is a linux_dirent64: the
+// inode and offset as 8 byte values, the 2 byte record length, the 1
+// byte type and the NUL terminated name.
+func parseDirents(dirfd int, buf []byte, entries []entry) []entry {
+	const (
+		reclenOff = 16
+		typeOff   = 18
+		nameOff   = 19
+	)
+	for len(buf) >= nameOff {
+		reclen := int(binary.NativeEndian.Uint16(buf[reclenOff:]))
+		if reclen < nameOff || reclen > len(buf) {
+			break
+		}
+		rec := buf[:reclen]
+		buf = buf[reclen:]
+
+		ino := binary.NativeEndian.Uint64(rec)
+		if ino == 0 {
+			// Skip deleted entries
+			continue
+		}
+		name := rec[nameOff:]
+		if i := bytes.IndexByte(name, 0); i >= 0 {
+			name = name[:i]
+		}
+		e, ok := parseName(name)
+		if !ok {
+			continue
+		}
+		if t := rec[typeOff]; t != unix.DT_REG && t != unix.DT_UNKNOWN {
+			// Skip non-regular files. Some filesystems don't fill
+			// in the type, which only matters for snapshot
+			// directories and is checked below.
+			continue
+		}
+		var st unix.Stat_t
+		if err := unix.Fstatat(dirfd, e.name, &st, unix.AT_SYMLINK_NOFOLLOW); err != nil ||
+			st.Mode&unix.S_IFMT != unix.S_IFREG {
+			continue
+		}
+		if st.Size == 0 {
+			// Skip files with zero size. These are variables that
+			// have been deleted by writing an empty payload
+			continue
+		}
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+// getInodeFlags returns the extended attributes of a file.
+func getInodeFlags(f *os.File) (int, error) {
+	// If I knew how unix.Getxattr works I'd use that...
+	flags, err := unix.IoctlGetInt(int(f.Fd()), unix.FS_IOC_GETFLAGS)
+	if err != nil {
+		return 0, &os.PathError{Op: "ioctl", Path: f.Name(), Err: err}
+	}
+	return flags, nil
+}
+
+// setInodeFlags sets the extended attributes of a file.
+func setInodeFlags(f *os.File, flags int) error {
+	// If I knew how unix.Setxattr works I'd use that...
+	if err := unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, flags); err != nil {
+		return &os.PathError{Op: "ioctl", Path: f.Name(), Err: err}
+	}
+	return nil
+}
+
+// makeMutable will change a files xattrs so that
+// the immutable flag is removed and return a restore
+// function which can reset the flag for that filee.
+// changed reports whether the flag was set at all.
+func makeMutable(f *os.File) (restore func(), changed bool, err error) {
+	flags, err := getInodeFlags(f)
+	if err != nil {
+		return nil, false, err
+	}
+	if flags&unix.STATX_ATTR_IMMUTABLE == 0 {
+		return func() {}, false, nil
+	}
+
+	if err := setInodeFlags(f, flags&^unix.STATX_ATTR_IMMUTABLE); err != nil {
+		return nil, false, err
+	}
+	return func() {
+		if err := setInodeFlags(f, flags); err != nil {
+			// If setting the immutable did
+			// not work it's alright to do nothing
+			// because after a reboot the flag is
+			// automatically reapplied
+			return
+		}
+	}, true, nil
+}
+
+// Capabilities reports write access as the kernel checks it, which
+// covers missing privileges as well as read-only mounts. efivarfs
+// reports the free space of the variable store in statfs since Linux
+// 6.8, older kernels and snapshot directories report 0.
+func (v *efivarfs) Capabilities() Capabilities {
+	write := unix.Faccessat(unix.AT_FDCWD, v.root, unix.W_OK, unix.AT_EACCESS) == nil
+	caps := Capabilities{
+		Write:          write,
+		Delete:         write,
+		Append:         write,
+		ImmutableFlags: !v.snapshot,
+		AuthVariables:  !v.snapshot,
+	}
+	var stat unix.Statfs_t
+	if !v.snapshot && unix.Statfs(v.root, &stat) == nil {
+		caps.MaxVariableSize = int64(stat.Bavail) * int64(stat.Bsize)
+	}
+	return caps
+}
@@ -0,0 +1,67 @@
+//go:build !linux
+
+package efivarfs
+
+import (
+	"errors"
+	"os"
+)
+
+// oDSYNC is the open flag making writes synchronous.
+const oDSYNC = os.O_SYNC
+
+// readDir returns the variables in v.root, which is only a snapshot
+// directory on platforms other than Linux.
+func (v *efivarfs) readDir() ([]entry, error) {
+	files, err := os.ReadDir(v.root)
+	switch {
+	case os.IsNotExist(err):
+		return nil, ErrVarNotExist
+	case os.IsPermission(err):
+		return nil, permission(err)
+	case err != nil:
+		return nil, err
+	}
+	var entries []entry
+	for _, f := range files {
+		if !f.Type().IsRegular() {
+			continue
+		}
+		if info, err := f.Info(); err != nil || info.Size() == 0 {
+			// Skip files with zero size. These are variables that
+			// have been deleted by writing an empty payload
+			continue
+		}
+		if e, ok := parseName([]byte(f.Name())); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// makeMutable does nothing as there is no efivarfs with its immutable
+// flags on platforms other than Linux.
+func makeMutable(f *os.File) (restore func(), changed bool, err error) {
+	return func() {}, false, nil
+}
+
+// getInodeFlags fails as there are no inode flags to query on platforms
+// other than Linux.
+func getInodeFlags(f *os.File) (int, error) {
+	return 0, &os.PathError{Op: "ioctl", Path: f.Name(), Err: errors.ErrUnsupported}
+}
+
+// setInodeFlags fails as there are no inode flags to set on platforms
+// other than Linux.
+func setInodeFlags(f *os.File, flags int) error {
+	return &os.PathError{Op: "ioctl", Path: f.Name(), Err: errors.ErrUnsupported}
+}
+
+// Capabilities reports write access from the permissions of the
+// directory, which is only a snapshot directory on platforms other than
+// Linux.
+func (v *efivarfs) Capabilities() Capabilities {
+	fi, err := os.Stat(v.root)
+	write := err == nil && fi.Mode().Perm()&0200 != 0
+	return Capabilities{Write: write, Delete: write, Append: write}
+}
@@ -0,0 +1,109 @@
+package efivarfs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// populate creates n variables in a new snapshot directory, named like
+// the HwErrRec entries that make large stores slow to list.
+func populate(b *testing.B, n int) *efivarfs {
+	b.Helper()
+	dir := b.TempDir()
+	for i := 0; i < n; i++ {
+		name := fmt.Sprintf("HwErrRec%04X-%s", i, GlobalVariable)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte{7, 0, 0, 0, 0}, 0644); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return &efivarfs{root: dir, snapshot: true}
+}
+
+func BenchmarkList(b *testing.B) {
+	for _, n := range []int{10, 100, 1000, 5000} {
+		b.Run(fmt.Sprint(n), func(b *testing.B) {
+			v := populate(b, n)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				descs, err := v.List()
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(descs) != n {
+					b.Fatalf("got %d variables, want %d", len(descs), n)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkSet rewrites existing variables like a bulk restore does. As
+// they are mutable, every Set needs a single open only.
+func BenchmarkSet(b *testing.B) {
+	const n = 100
+	v := populate(b, n)
+	v.snapshot = false
+	descs, err := v.List()
+	if err != nil {
+		b.Fatal(err)
+	}
+	data := make([]byte, 64)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := v.Set(descs[i%n], AttributeNonVolatile|AttributeBootserviceAccess|AttributeRuntimeAccess, data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkGet reads variables of the size of a typical db, which
+// io.ReadAll would grow into several times.
+func BenchmarkGet(b *testing.B) {
+	dir := b.TempDir()
+	data := make([]byte, 4+4096)
+	data[0] = byte(AttributeNonVolatile | AttributeBootserviceAccess | AttributeRuntimeAccess)
+	desc := VariableDescriptor{Name: "db", GUID: &ImageSecurityDatabase}
+	if err := os.WriteFile(filepath.Join(dir, "db-"+ImageSecurityDatabase.String()), data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	v := &efivarfs{root: dir, snapshot: true}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, got, err := v.Get(desc)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(got) != len(data)-4 {
+			b.Fatalf("got %d bytes, want %d", len(got), len(data)-4)
+		}
+	}
+}
+
+// TestListSkipsDeleted checks that List leaves out the empty files
+// efivarfs keeps for deleted variables.
+func TestListSkipsDeleted(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string][]byte{
+		"Boot0000-" + GlobalVariable.String(): {7, 0, 0, 0, 1},
+		"Boot0001-" + GlobalVariable.String(): {},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "Boot0002-"+GlobalVariable.String()), 0755); err != nil {
+		t.Fatal(err)
+	}
+	descs, err := (&efivarfs{root: dir, snapshot: true}).List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(descs) != 1 || descs[0].Name != "Boot0000" {
+		t.Errorf("List() = %v, want Boot0000 only", descs)
+	}
+}
@@ -2,11 +2,10 @@ package efivarfs
 
 import (
 	"bytes"
-	"os"
+	"fmt"
 	"strings"
 
 	guid "github.com/google/uuid"
-	"golang.org/x/sys/unix"
 )
 
 // VariableAttributes uint32 identifying the variables attributes
@@ -31,167 +30,277 @@ const (
 	AttributeEnhancedAuthenticatedAccess VariableAttributes = 0x00000080
 )
 
-// VariableDescriptor contains the name and GUID identifying a variable
+// attributeNames are the short names of the attributes used by String.
+var attributeNames = []struct {
+	attr VariableAttributes
+	name string
+}{
+	{AttributeNonVolatile, "NV"},
+	{AttributeBootserviceAccess, "BS"},
+	{AttributeRuntimeAccess, "RT"},
+	{AttributeHardwareErrorRecord, "HR"},
+	{AttributeAuthenticatedWriteAccess, "AW"},
+	{AttributeTimeBasedAuthenticatedWriteAccess, "AT"},
+	{AttributeAppendWrite, "AP"},
+	{AttributeEnhancedAuthenticatedAccess, "EA"},
+}
+
+// String returns the short names of the attributes set in a joined with
+// "|", e.g. NV|BS|RT, and unknown bits in hex.
+func (a VariableAttributes) String() string {
+	var names []string
+	for _, n := range attributeNames {
+		if a&n.attr != 0 {
+			names = append(names, n.name)
+			a &^= n.attr
+		}
+	}
+	if a != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint32(a)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, "|")
+}
+
+// VariableDescriptor contains the name and GUID identifying a variable.
+//
+// The GUID is a pointer for historical reasons, which makes == compare
+// the addresses of the GUIDs and descriptors unfit as map keys. Use
+// Equal to compare them and Key for maps. The next major version of this
+// module holds the GUID by value instead.
 type VariableDescriptor struct {
 	Name string
 	GUID *guid.UUID
 }
 
-// ReadVariable calls get() on the current efivarfs backend.
+// String returns the Name-GUID form of efivarfs, which ParseDescriptor
+// parses, or the name alone if d has no GUID.
+func (d VariableDescriptor) String() string {
+	return FormatDescriptor(d, NameFirst)
+}
+
+// Equal reports whether d and o identify the same variable. Unlike ==,
+// it compares the GUIDs and not the pointers to them.
+func (d VariableDescriptor) Equal(o VariableDescriptor) bool {
+	if d.Name != o.Name || (d.GUID == nil) != (o.GUID == nil) {
+		return false
+	}
+	return d.GUID == nil || *d.GUID == *o.GUID
+}
+
+// MarshalText returns the String form, so descriptors are encoded as
+// JSON strings and can be used as keys of JSON objects.
+func (d VariableDescriptor) MarshalText() ([]byte, error) {
+	if d.GUID == nil {
+		return nil, fmt.Errorf("%s: %w", d.Name, ErrInvalidGUID)
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText parses text with ParseDescriptor.
+func (d *VariableDescriptor) UnmarshalText(text []byte) error {
+	desc, err := ParseDescriptor(string(text))
+	if err != nil {
+		return err
+	}
+	*d = desc
+	return nil
+}
+
+// ReadVariable calls Get() on the current efivarfs backend.
 func ReadVariable(desc VariableDescriptor) (VariableAttributes, []byte, error) {
 	e, err := probeAndReturn()
 	if err != nil {
 		return 0, nil, err
 	}
-	return e.get(desc)
+	return e.Get(desc)
 }
 
 // SimpleReadVariable is like ReadVariables but takes the combined name and guid string
 // of the form name-guid and returns a bytes.Reader instead of a []byte.
+// For well known variables like BootOrder or db the guid can be left out.
 func SimpleReadVariable(v string) (VariableAttributes, *bytes.Reader, error) {
 	e, err := probeAndReturn()
 	if err != nil {
 		return 0, nil, err
 	}
-	vs := strings.SplitN(v, "-", 2)
-	g, err := guid.Parse(vs[1])
+	desc, err := ParseDescriptor(v)
 	if err != nil {
 		return 0, nil, err
 	}
-	attrs, data, err := e.get(
-		VariableDescriptor{
-			Name: vs[0],
-			GUID: &g,
-		},
-	)
+	attrs, data, err := e.Get(desc)
 	return attrs, bytes.NewReader(data), err
 }
 
-// WriteVariable calls set() on the current efivarfs backend.
+// WriteVariable calls Set() on the current efivarfs backend.
 func WriteVariable(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
 	e, err := probeAndReturn()
 	if err != nil {
 		return err
 	}
-	return e.set(desc, attrs, data)
+	return e.Set(desc, attrs, data)
+}
+
+// AppendVariable appends data to an existing variable, or creates it if
+// it doesn't exist yet, by setting AttributeAppendWrite on attrs.
+//
+// Unlike a regular write, an empty data never deletes the variable,
+// it is a no-op instead. For time based authenticated variables like
+// db and dbx, data has to be a signed EFI_VARIABLE_AUTHENTICATION_2
+// payload whose signature covers attrs including the append bit.
+// Firmware only adds signatures of such a dbx or db append that
+// aren't already present, so repeating an append is harmless.
+func AppendVariable(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	e, err := probeAndReturn()
+	if err != nil {
+		return err
+	}
+	return appendVariable(e, desc, attrs, data)
+}
+
+// appendVariable is AppendVariable on b.
+func appendVariable(b Backend, desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	return b.Set(desc, attrs|AttributeAppendWrite, data)
 }
 
 // SimpleWriteVariable is like WriteVariables but takes the combined name and guid string
 // of the form name-guid and returns a bytes.Buffer instead of a []byte.
+// For well known variables like BootOrder or db the guid can be left out.
 func SimpleWriteVariable(v string, attrs VariableAttributes, data bytes.Buffer) error {
 	e, err := probeAndReturn()
 	if err != nil {
 		return err
 	}
-	vs := strings.SplitN(v, "-", 2)
-	g, err := guid.Parse(vs[1])
+	desc, err := ParseDescriptor(v)
 	if err != nil {
 		return err
 	}
-	return e.set(
-		VariableDescriptor{
-			Name: vs[0],
-			GUID: &g,
-		}, attrs, data.Bytes(),
-	)
+	return e.Set(desc, attrs, data.Bytes())
 }
 
-// RemoveVariable calls remove() on the current efivarfs backend.
+// RemoveVariable calls Remove() on the current efivarfs backend.
 func RemoveVariable(desc VariableDescriptor) error {
 	e, err := probeAndReturn()
 	if err != nil {
 		return err
 	}
-	return e.remove(desc)
+	return e.Remove(desc)
 }
 
 // SimpleRemoveVariable is like RemoveVariable but takes the combined name and guid string
-// of the form name-guid.
+// of the form name-guid. For well known variables the guid can be left out.
 func SimpleRemoveVariable(v string) error {
 	e, err := probeAndReturn()
 	if err != nil {
 		return err
 	}
-	vs := strings.SplitN(v, "-", 2)
-	g, err := guid.Parse(vs[1])
+	desc, err := ParseDescriptor(v)
 	if err != nil {
 		return err
 	}
-	return e.remove(
-		VariableDescriptor{
-			Name: vs[0],
-			GUID: &g,
-		},
-	)
+	return e.Remove(desc)
 }
 
-// ListVariables calls list() on the current efivarfs backend.
-func ListVariables() ([]VariableDescriptor, error) {
-	e, err := probeAndReturn()
-	if err != nil {
-		return nil, err
+// NameOrder selects the order of name and GUID of FormatDescriptor.
+type NameOrder int
+
+const (
+	// NameFirst is the Name-GUID form of efivarfs, e.g.
+	// Boot0001-8be4df61-93ca-11d2-aa0d-00e098032b8c
+	NameFirst NameOrder = iota
+	// GUIDFirst is the GUID-Name form of the C efivar library and its
+	// tools, e.g. 8be4df61-93ca-11d2-aa0d-00e098032b8c-Boot0001
+	GUIDFirst
+)
+
+// FormatDescriptor returns name and GUID of desc combined in order o, or
+// the name alone if desc has no GUID.
+func FormatDescriptor(desc VariableDescriptor, o NameOrder) string {
+	if desc.GUID == nil {
+		return desc.Name
+	}
+	if o == GUIDFirst {
+		return desc.GUID.String() + "-" + desc.Name
 	}
-	return e.list()
+	return desc.Name + "-" + desc.GUID.String()
 }
 
-// SimpleListVariables is like ListVariables but returns a []string instead of a []VariableDescriptor.
-func SimpleListVariables() ([]string, error) {
-	e, err := probeAndReturn()
-	if err != nil {
-		return nil, err
+// ParseDescriptor parses the combined name-guid form taken by the Simple
+// functions, or the guid-name form of the C efivar library if v isn't
+// of the former. The GUID may be in any format accepted by ParseGUID
+// and the name may contain hyphens itself. Without GUID, v is resolved
+// with LookupGUID.
+func ParseDescriptor(v string) (VariableDescriptor, error) {
+	if name, g := splitNameGUID(v); g != nil {
+		return VariableDescriptor{Name: name, GUID: g}, nil
 	}
-	list, err := e.list()
-	if err != nil {
-		return nil, err
+	for i := 0; i < len(v)-1; i++ {
+		if v[i] != '-' {
+			continue
+		}
+		if g, err := ParseGUID(v[:i]); err == nil {
+			return VariableDescriptor{Name: v[i+1:], GUID: &g}, nil
+		}
 	}
-	var out []string
-	for _, v := range list {
-		out = append(out, v.Name+"-"+v.GUID.String())
+	g, err := LookupGUID(v)
+	if err != nil {
+		return VariableDescriptor{}, err
 	}
-	return out, nil
+	return VariableDescriptor{Name: v, GUID: &g}, nil
 }
 
-// getInodeFlags returns the extended attributes of a file.
-func getInodeFlags(f *os.File) (int, error) {
-	// If I knew how unix.Getxattr works I'd use that...
-	flags, err := unix.IoctlGetInt(int(f.Fd()), unix.FS_IOC_GETFLAGS)
-	if err != nil {
-		return 0, &os.PathError{Op: "ioctl", Path: f.Name(), Err: err}
+// ParseFileName parses the Name-GUID form of efivarfs file names, which
+// String returns. Unlike ParseDescriptor it accepts no other form and
+// fails with ErrInvalidName if s doesn't end in a GUID.
+func ParseFileName(s string) (VariableDescriptor, error) {
+	name, g := splitNameGUID(s)
+	if g == nil || name == "" {
+		return VariableDescriptor{}, fmt.Errorf("%q is not of the form Name-GUID: %w", s, ErrInvalidName)
 	}
-	return flags, nil
+	return VariableDescriptor{Name: name, GUID: g}, nil
 }
 
-// setInodeFlags sets the extended attributes of a file.
-func setInodeFlags(f *os.File, flags int) error {
-	// If I knew how unix.Setxattr works I'd use that...
-	if err := unix.IoctlSetPointerInt(int(f.Fd()), unix.FS_IOC_SETFLAGS, flags); err != nil {
-		return &os.PathError{Op: "ioctl", Path: f.Name(), Err: err}
+// splitNameGUID splits v at the first hyphen followed by a GUID. The
+// GUID is nil if v doesn't end in one.
+func splitNameGUID(v string) (string, *guid.UUID) {
+	for i := 0; i < len(v); i++ {
+		if v[i] != '-' {
+			continue
+		}
+		if g, err := ParseGUID(v[i+1:]); err == nil {
+			return v[:i], &g
+		}
 	}
-	return nil
+	return v, nil
 }
 
-// makeMutable will change a files xattrs so that
-// the immutable flag is removed and return a restore
-// function which can reset the flag for that filee.
-func makeMutable(f *os.File) (restore func(), err error) {
-	flags, err := getInodeFlags(f)
+// ListVariables calls List() on the current efivarfs backend.
+func ListVariables() ([]VariableDescriptor, error) {
+	e, err := probeAndReturn()
 	if err != nil {
 		return nil, err
 	}
-	if flags&unix.STATX_ATTR_IMMUTABLE == 0 {
-		return func() {}, nil
-	}
+	return e.List()
+}
 
-	if err := setInodeFlags(f, flags&^unix.STATX_ATTR_IMMUTABLE); err != nil {
+// SimpleListVariables is like ListVariables but returns a []string instead of a []VariableDescriptor.
+func SimpleListVariables() ([]string, error) {
+	e, err := probeAndReturn()
+	if err != nil {
 		return nil, err
 	}
-	return func() {
-		if err := setInodeFlags(f, flags); err != nil {
-			// If setting the immutable did
-			// not work it's alright to do nothing
-			// because after a reboot the flag is
-			// automatically reapplied
-			return
-		}
-	}, nil
+	list, err := e.List()
+	if err != nil {
+		return nil, err
+	}
+	var out []string
+	for _, v := range list {
+		out = append(out, v.String())
+	}
+	return out, nil
 }
@@ -0,0 +1,136 @@
+package efivarfs
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestParseDescriptor(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		name string
+	}{
+		{"Boot0001-8be4df61-93ca-11d2-aa0d-00e098032b8c", "Boot0001"},
+		{"8be4df61-93ca-11d2-aa0d-00e098032b8c-Boot0001", "Boot0001"},
+		{"{8BE4DF61-93CA-11D2-AA0D-00E098032B8C}-Boot0001", "Boot0001"},
+		{"My-Var-8be4df61-93ca-11d2-aa0d-00e098032b8c", "My-Var"},
+		{"8be4df61-93ca-11d2-aa0d-00e098032b8c-My-Var", "My-Var"},
+		{"BootOrder", "BootOrder"},
+	} {
+		desc, err := ParseDescriptor(tt.in)
+		if err != nil {
+			t.Errorf("ParseDescriptor(%q) failed: %v", tt.in, err)
+			continue
+		}
+		if desc.Name != tt.name || *desc.GUID != GlobalVariable {
+			t.Errorf("ParseDescriptor(%q) = %s-%s, want %s-%s", tt.in, desc.Name, desc.GUID, tt.name, GlobalVariable)
+		}
+		for _, o := range []NameOrder{NameFirst, GUIDFirst} {
+			s := FormatDescriptor(desc, o)
+			if got, err := ParseDescriptor(s); err != nil || got.Name != desc.Name || *got.GUID != *desc.GUID {
+				t.Errorf("ParseDescriptor(%q) = %v, %v after formatting", s, got, err)
+			}
+		}
+	}
+	for _, in := range []string{"", "Unknown", "8be4df61-93ca-11d2-aa0d-00e098032b8c-"} {
+		if desc, err := ParseDescriptor(in); err == nil {
+			t.Errorf("ParseDescriptor(%q) = %s-%s, want error", in, desc.Name, desc.GUID)
+		}
+	}
+	if s := (VariableDescriptor{Name: "BootOrder"}).String(); s != "BootOrder" {
+		t.Errorf("String() = %q without GUID, want the name alone", s)
+	}
+}
+
+func TestParseFileName(t *testing.T) {
+	desc, err := ParseFileName("My-Var-8be4df61-93ca-11d2-aa0d-00e098032b8c")
+	if err != nil || desc.Name != "My-Var" || *desc.GUID != GlobalVariable {
+		t.Errorf("ParseFileName() = %v, %v", desc, err)
+	}
+	for _, in := range []string{"", "BootOrder", "-8be4df61-93ca-11d2-aa0d-00e098032b8c", "BootOrder-8be4df61"} {
+		if desc, err := ParseFileName(in); !errors.Is(err, ErrInvalidName) {
+			t.Errorf("ParseFileName(%q) = %v, %v, want ErrInvalidName", in, desc, err)
+		}
+	}
+}
+
+func TestDescriptorJSON(t *testing.T) {
+	g := GlobalVariable
+	desc := VariableDescriptor{Name: "BootOrder", GUID: &g}
+	if !desc.Equal(VariableDescriptor{Name: "BootOrder", GUID: &GlobalVariable}) {
+		t.Errorf("%s isn't equal to itself with a different GUID pointer", desc)
+	}
+	if desc.Equal(VariableDescriptor{Name: "BootOrder", GUID: &ImageSecurityDatabase}) {
+		t.Errorf("%s equals a variable of another vendor", desc)
+	}
+	b, err := json.Marshal(map[VariableDescriptor][]VariableDescriptor{desc: {desc}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c":["BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c"]}`
+	if string(b) != want {
+		t.Errorf("json.Marshal() = %s, want %s", b, want)
+	}
+	var got []VariableDescriptor
+	if err := json.Unmarshal([]byte(`["BootOrder-8be4df61-93ca-11d2-aa0d-00e098032b8c", "8be4df61-93ca-11d2-aa0d-00e098032b8c-BootOrder"]`), &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range got {
+		if !d.Equal(desc) {
+			t.Errorf("json.Unmarshal() = %s, want %s", d, desc)
+		}
+	}
+	if err := json.Unmarshal([]byte(`["Unknown"]`), &got); err == nil {
+		t.Error("json.Unmarshal() of an unknown variable without GUID succeeded")
+	}
+}
+
+func TestVariableKey(t *testing.T) {
+	a := NewDescriptor("BootOrder", GlobalVariable)
+	b := NewDescriptor("BootOrder", GlobalVariable)
+	if a == b {
+		t.Error("descriptors returned by NewDescriptor share their GUID")
+	}
+	seen := map[VariableKey]bool{a.Key(): true}
+	if !seen[b.Key()] {
+		t.Errorf("key of %s not found", b)
+	}
+	if d := a.Key().Descriptor(); !d.Equal(a) || d.GUID == a.GUID {
+		t.Errorf("Descriptor() = %s, want a copy of %s", d, a)
+	}
+}
+
+// attrsBackend records the attributes of the last Set.
+type attrsBackend struct {
+	Backend
+	attrs VariableAttributes
+}
+
+func (b *attrsBackend) Set(desc VariableDescriptor, attrs VariableAttributes, data []byte) error {
+	b.attrs = attrs
+	return b.Backend.Set(desc, attrs, data)
+}
+
+func TestAppendVariable(t *testing.T) {
+	b := &attrsBackend{Backend: Dir(t.TempDir())}
+	desc := NewDescriptor("Log", GlobalVariable)
+	attrs := AttributeNonVolatile | AttributeBootserviceAccess
+	for _, data := range []string{"a", "b"} {
+		if err := appendVariable(b, desc, attrs, []byte(data)); err != nil {
+			t.Fatal(err)
+		}
+		if b.attrs != attrs|AttributeAppendWrite {
+			t.Errorf("appendVariable() wrote with %s, want %s", b.attrs, attrs|AttributeAppendWrite)
+		}
+	}
+	if a, data, err := b.Get(desc); err != nil || a != attrs || !bytes.Equal(data, []byte("ab")) {
+		t.Errorf("Get() = %s, %q, %v after two appends, want %s, \"ab\"", a, data, err, attrs)
+	}
+
+	// Empty data neither deletes the variable nor needs efivarfs
+	if err := AppendVariable(desc, attrs, nil); err != nil {
+		t.Errorf("AppendVariable() = %v with empty data, want a no-op", err)
+	}
+}
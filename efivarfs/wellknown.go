@@ -0,0 +1,132 @@
+package efivarfs
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	guid "github.com/google/uuid"
+)
+
+var (
+	// GlobalVariable is the vendor GUID of the variables defined by
+	// the UEFI specification, e.g. BootOrder or PK
+	GlobalVariable = guid.MustParse("8be4df61-93ca-11d2-aa0d-00e098032b8c")
+
+	// ImageSecurityDatabase is the vendor GUID of db, dbx, dbt and dbr
+	ImageSecurityDatabase = guid.MustParse("d719b2cb-3d3a-4596-a3bc-dad00e67656f")
+
+	// ShimLock is the vendor GUID of the variables of shim, e.g. MokList
+	ShimLock = guid.MustParse("605dab50-e046-4300-abb6-3dd810dd8b23")
+
+	// LoaderVendor is the vendor GUID of the variables of the Boot Loader
+	// Interface implemented by systemd-boot, e.g. LoaderEntryDefault
+	LoaderVendor = guid.MustParse("4a67b082-0a4c-41cf-b6c7-440b29bb8c4f")
+)
+
+var (
+	// ErrUnknownVariable is caused by names without GUID that are not
+	// registered as well known
+	ErrUnknownVariable = errors.New("no GUID given and variable is not well known")
+
+	// ErrAmbiguousVariable is caused by names without GUID that are
+	// registered as well known for several vendors
+	ErrAmbiguousVariable = errors.New("no GUID given and variable is well known for several vendors")
+)
+
+// wellKnown maps the names of well known variables to their vendor
+// GUIDs. Names ending in #### stand for the numbered variables with four
+// hex digits in their place.
+var (
+	wellKnownMu sync.RWMutex
+	wellKnown   = map[string][]guid.UUID{}
+)
+
+func init() {
+	for _, name := range []string{
+		"AuditMode", "Boot####", "BootCurrent", "BootNext", "BootOptionSupport",
+		"BootOrder", "ConIn", "ConInDev", "ConOut", "ConOutDev", "dbDefault",
+		"dbrDefault", "dbtDefault", "dbxDefault", "DeployedMode", "devAuthBoot",
+		"Driver####", "DriverOrder", "ErrOut", "ErrOutDev", "HwErrRecSupport",
+		"KEK", "KEKDefault", "Key####", "Lang", "LangCodes", "OsIndications",
+		"OsIndicationsSupported", "OsRecoveryOrder", "PK", "PKDefault",
+		"PlatformLang", "PlatformLangCodes", "PlatformRecovery####",
+		"RuntimeServicesSupported", "SecureBoot", "SetupMode", "SignatureSupport",
+		"SysPrep####", "SysPrepOrder", "Timeout", "VendorKeys",
+	} {
+		RegisterWellKnown(name, GlobalVariable)
+	}
+	for _, name := range []string{"db", "dbx", "dbt", "dbr"} {
+		RegisterWellKnown(name, ImageSecurityDatabase)
+	}
+	for _, name := range []string{
+		"MokList", "MokListRT", "MokListX", "MokListXRT", "MokSBState",
+		"MokSBStateRT", "MokDBState", "MokIgnoreDB", "MokPolicy", "SbatLevel",
+		"SbatLevelRT", "SbatPolicy",
+	} {
+		RegisterWellKnown(name, ShimLock)
+	}
+	for _, name := range []string{
+		"LoaderConfigTimeout", "LoaderConfigTimeoutOneShot", "LoaderDevicePartUUID",
+		"LoaderEntries", "LoaderEntryDefault", "LoaderEntryOneShot",
+		"LoaderEntrySelected", "LoaderFeatures", "LoaderFirmwareInfo",
+		"LoaderFirmwareType", "LoaderImageIdentifier", "LoaderInfo",
+		"LoaderSystemToken", "LoaderTimeExecUSec", "LoaderTimeInitUSec",
+		"LoaderTimeMenuUSec", "StubInfo",
+	} {
+		RegisterWellKnown(name, LoaderVendor)
+	}
+}
+
+// RegisterWellKnown adds name as well known variable of vendor g, so
+// LookupGUID and the Simple functions resolve it. A name ending in ####
+// registers all numbered variables with four hex digits in its place,
+// e.g. Boot#### for Boot0000 to BootFFFF.
+func RegisterWellKnown(name string, g guid.UUID) {
+	wellKnownMu.Lock()
+	defer wellKnownMu.Unlock()
+	for _, known := range wellKnown[name] {
+		if known == g {
+			return
+		}
+	}
+	wellKnown[name] = append(wellKnown[name], g)
+}
+
+// LookupGUID returns the vendor GUID of the well known variable name.
+// It fails with ErrUnknownVariable if name isn't registered and with
+// ErrAmbiguousVariable if it is registered for several vendors.
+func LookupGUID(name string) (guid.UUID, error) {
+	wellKnownMu.RLock()
+	defer wellKnownMu.RUnlock()
+	gs := wellKnown[name]
+	if len(gs) == 0 && isNumbered(name) {
+		gs = wellKnown[name[:len(name)-4]+"####"]
+	}
+	switch len(gs) {
+	case 0:
+		return guid.UUID{}, fmt.Errorf("%s: %w", name, ErrUnknownVariable)
+	case 1:
+		return gs[0], nil
+	}
+	s := make([]string, len(gs))
+	for i, g := range gs {
+		s[i] = g.String()
+	}
+	return guid.UUID{}, fmt.Errorf("%s could be any of %s: %w", name, strings.Join(s, ", "), ErrAmbiguousVariable)
+}
+
+// isNumbered reports whether name ends in four uppercase hex digits like
+// Boot0001.
+func isNumbered(name string) bool {
+	if len(name) < 4 {
+		return false
+	}
+	for _, c := range name[len(name)-4:] {
+		if !('0' <= c && c <= '9' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,168 @@
+package enforce
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/devicepath"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/esp"
+)
+
+// findESPs and defaultESP are esp.FindESPs and esp.Default, replaced by
+// tests.
+var (
+	findESPs   = esp.FindESPs
+	defaultESP = esp.Default
+)
+
+// BootEntry is the structured content of a load option, the exact value
+// of rules for variables like Boot0001.
+//
+// Its fields are text/template templates, so one configuration fits
+// machines with different disk layouts. They can refer to the ESP
+// boot entries are usually created on, as returned by esp.Default, e.g.
+// partition: "{{ .ESP.PartUUID }}" or data: "esp={{ .ESP.Device }}".
+// The templates are expanded and the partition looked up whenever the
+// rule is checked, not when the configuration is parsed.
+type BootEntry struct {
+	Description string `json:"description" yaml:"description"`
+	// Partition is the PARTUUID of the EFI System Partition holding
+	// Loader
+	Partition string `json:"partition" yaml:"partition"`
+	// Loader is the path of the image on the partition, e.g.
+	// \EFI\Linux\linux.efi
+	Loader string `json:"loader" yaml:"loader"`
+	// Data is passed to the loader as UTF-16 string, e.g. a kernel
+	// command line
+	Data string `json:"data,omitempty" yaml:"data,omitempty"`
+	// Active is the LoadOptionActive attribute, true if not given
+	Active *bool `json:"active,omitempty" yaml:"active,omitempty"`
+}
+
+// validate checks the syntax of the templates of e without expanding
+// them, which is left to marshal so the disks aren't scanned before the
+// rules are checked.
+func (e *BootEntry) validate() error {
+	if e.Loader == "" {
+		return errors.New("no loader")
+	}
+	for _, f := range []struct {
+		name string
+		text string
+	}{
+		{"description", e.Description},
+		{"partition", e.Partition},
+		{"loader", e.Loader},
+		{"data", e.Data},
+	} {
+		if _, err := parseTemplate(f.text); err != nil {
+			return fmt.Errorf("%s: %w", f.name, err)
+		}
+	}
+	if !strings.Contains(e.Partition, "{{") {
+		if _, err := efivarfs.ParseGUID(e.Partition); err != nil {
+			return fmt.Errorf("partition: %w", err)
+		}
+	}
+	return nil
+}
+
+// marshal expands the templates of e and returns its load option. Its
+// partition is looked up among the EFI System Partitions of the system.
+func (e *BootEntry) marshal() ([]byte, error) {
+	x := *e
+	var t templateData
+	for _, f := range []struct {
+		name string
+		text *string
+	}{
+		{"description", &x.Description},
+		{"partition", &x.Partition},
+		{"loader", &x.Loader},
+		{"data", &x.Data},
+	} {
+		s, err := t.expand(*f.text)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.name, err)
+		}
+		*f.text = s
+	}
+	partUUID, err := efivarfs.ParseGUID(x.Partition)
+	if err != nil {
+		return nil, fmt.Errorf("partition: %w", err)
+	}
+	esps, err := findESPs()
+	if err != nil {
+		return nil, err
+	}
+	var node *devicepath.Node
+	for _, p := range esps {
+		if p.GUID == partUUID {
+			n := devicepath.HardDrive(p.Partition)
+			node = &n
+			break
+		}
+	}
+	if node == nil {
+		return nil, fmt.Errorf("partition %s: %w", partUUID, esp.ErrNotFound)
+	}
+	o := &bootmgr.LoadOption{
+		Description: x.Description,
+		FilePath:    devicepath.Path{*node, devicepath.File(x.Loader)},
+	}
+	if e.Active == nil || *e.Active {
+		o.Attributes = bootmgr.LoadOptionActive
+	}
+	if x.Data != "" {
+		o.OptionalData = encodeUTF16(x.Data)
+	}
+	return o.MarshalBinary()
+}
+
+// templateData is what the templates of boot entries are executed on.
+// The ESP is only looked up once a template refers to it.
+type templateData struct {
+	esp *esp.ESP
+}
+
+// ESP returns the default EFI System Partition.
+func (t *templateData) ESP() (*esp.ESP, error) {
+	if t.esp == nil {
+		e, err := defaultESP()
+		if err != nil {
+			return nil, err
+		}
+		t.esp = e
+	}
+	return t.esp, nil
+}
+
+// parseTemplate parses text as template, nil unless it contains an
+// action.
+func parseTemplate(text string) (*template.Template, error) {
+	if !strings.Contains(text, "{{") {
+		return nil, nil
+	}
+	return template.New("").Option("missingkey=error").Parse(text)
+}
+
+// expand executes text as template, which is returned as is unless it
+// contains an action.
+func (t *templateData) expand(text string) (string, error) {
+	tmpl, err := parseTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	if tmpl == nil {
+		return text, nil
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, t); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,376 @@
+// Package enforce compares EFI variables against a desired state, the
+// golden configuration of a fleet, and reverts drift from it.
+//
+// A configuration is a YAML document listing rules, or the same as JSON:
+//
+//	variables:
+//	  - name: Timeout
+//	    value: "0500"
+//	  - name: PlatformLang
+//	    base64: ZW4tVVMA
+//	  - name: BootOrder
+//	    match: "^\\x01\\x00"
+//	  - name: Boot0001
+//	    boot:
+//	      description: Linux
+//	      partition: 0f6b8b38-7d2e-4a8c-9cbd-3c5a8a9f4e11
+//	      loader: \EFI\Linux\linux.efi
+//	      data: root=/dev/sda2 quiet
+//	  - name: MokSBState
+//	    guid: 605dab50-e046-4300-abb6-3dd810dd8b23
+//	    state: absent
+//
+// The GUID may be left out for well known variables. Apply makes the
+// variables converge to the configuration.
+package enforce
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"unicode/utf16"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/watch"
+	"gopkg.in/yaml.v3"
+)
+
+// States of variables in rules
+const (
+	// Present requires the variable to exist, which is the default
+	Present = "present"
+	// Absent requires the variable to not exist
+	Absent = "absent"
+)
+
+// DefaultAttributes are the attributes variables are created with when
+// remediating rules without attributes.
+const DefaultAttributes = efivarfs.AttributeNonVolatile |
+	efivarfs.AttributeBootserviceAccess |
+	efivarfs.AttributeRuntimeAccess
+
+// ErrNotRemediable is caused by remediating drift from a rule that
+// doesn't define the content to write, like one with a pattern.
+var ErrNotRemediable = errors.New("drift can't be remediated automatically")
+
+// Rule is the desired state of a single variable.
+type Rule struct {
+	Name string `json:"name" yaml:"name"`
+	GUID string `json:"guid,omitempty" yaml:"guid,omitempty"`
+	// State is Present or Absent, Present if empty
+	State string `json:"state,omitempty" yaml:"state,omitempty"`
+	// Value is the hex encoded data the variable must hold exactly.
+	// Only one of Value, Base64, UTF16 and Boot may be set.
+	Value string `json:"value,omitempty" yaml:"value,omitempty"`
+	// Base64 is the data as standard base64
+	Base64 string `json:"base64,omitempty" yaml:"base64,omitempty"`
+	// UTF16 is a string the variable holds as NUL terminated UTF-16
+	UTF16 string `json:"utf16,omitempty" yaml:"utf16,omitempty"`
+	// Boot is the content of a load option like Boot0001
+	Boot *BootEntry `json:"boot,omitempty" yaml:"boot,omitempty"`
+	// Match is a regular expression the raw data has to match, e.g.
+	// "\\x00" matches a NUL byte
+	Match string `json:"match,omitempty" yaml:"match,omitempty"`
+	// Attributes the variable must have if not zero, also used to
+	// write the value
+	Attributes efivarfs.VariableAttributes `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+
+	desc  efivarfs.VariableDescriptor
+	value []byte
+	match *regexp.Regexp
+}
+
+// Desc returns the variable the rule applies to.
+func (r *Rule) Desc() efivarfs.VariableDescriptor {
+	return r.desc
+}
+
+// Config is a set of rules.
+type Config struct {
+	Variables []*Rule `json:"variables" yaml:"variables"`
+}
+
+// ParseConfig decodes and validates a configuration in YAML or JSON.
+func ParseConfig(b []byte) (*Config, error) {
+	var c Config
+	if err := yaml.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	for i, r := range c.Variables {
+		if err := r.compile(); err != nil {
+			return nil, fmt.Errorf("rule %d (%s): %w", i, r.Name, err)
+		}
+	}
+	return &c, nil
+}
+
+// LoadConfig reads and validates the configuration at path.
+func LoadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := ParseConfig(b)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// compile resolves the GUID of r and parses its value and Match.
+func (r *Rule) compile() error {
+	if r.Name == "" {
+		return errors.New("no name")
+	}
+	g, err := efivarfs.LookupGUID(r.Name)
+	if r.GUID != "" {
+		g, err = efivarfs.ParseGUID(r.GUID)
+	}
+	if err != nil {
+		return err
+	}
+	r.desc = efivarfs.VariableDescriptor{Name: r.Name, GUID: &g}
+	switch r.State {
+	case "":
+		r.State = Present
+	case Present:
+	case Absent:
+		if r.hasValue() || r.Match != "" || r.Attributes != 0 {
+			return errors.New("absent variables can't have content")
+		}
+	default:
+		return fmt.Errorf("unknown state %q", r.State)
+	}
+	n := 0
+	for _, set := range []bool{r.Value != "", r.Base64 != "", r.UTF16 != "", r.Boot != nil} {
+		if set {
+			n++
+		}
+	}
+	if n > 1 {
+		return errors.New("only one of value, base64, utf16 and boot may be given")
+	}
+	switch {
+	case r.Value != "":
+		if r.value, err = hex.DecodeString(r.Value); err != nil {
+			return fmt.Errorf("value: %w", err)
+		}
+	case r.Base64 != "":
+		if r.value, err = base64.StdEncoding.DecodeString(r.Base64); err != nil {
+			return fmt.Errorf("base64: %w", err)
+		}
+	case r.UTF16 != "":
+		r.value = encodeUTF16(r.UTF16)
+	case r.Boot != nil:
+		if err := r.Boot.validate(); err != nil {
+			return fmt.Errorf("boot: %w", err)
+		}
+	}
+	if r.Match != "" {
+		if r.match, err = regexp.Compile(r.Match); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasValue reports whether r defines the exact data of the variable.
+func (r *Rule) hasValue() bool {
+	return r.Value != "" || r.Base64 != "" || r.UTF16 != "" || r.Boot != nil
+}
+
+// data returns the exact data the variable of r has to hold, nil if r
+// doesn't define it. The load option of a boot entry is built anew on
+// every call, as the partitions it refers to may have changed since the
+// configuration was parsed.
+func (r *Rule) data() ([]byte, error) {
+	if r.Boot == nil {
+		return r.value, nil
+	}
+	b, err := r.Boot.marshal()
+	if err != nil {
+		return nil, fmt.Errorf("%s: boot: %w", r.desc, err)
+	}
+	return b, nil
+}
+
+// encodeUTF16 returns s as NUL terminated little endian UTF-16.
+func encodeUTF16(s string) []byte {
+	var b []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		b = binary.LittleEndian.AppendUint16(b, c)
+	}
+	return append(b, 0, 0)
+}
+
+// Drift is a variable deviating from its rule.
+type Drift struct {
+	Rule *Rule
+	// Reason describes the deviation
+	Reason string
+	// Exists is set if the variable exists, in which case Attributes
+	// and Data are its content
+	Exists     bool
+	Attributes efivarfs.VariableAttributes
+	Data       []byte
+}
+
+func (d *Drift) String() string {
+	return fmt.Sprintf("%s: %s", d.Rule.desc, d.Reason)
+}
+
+// Remediable reports whether Remediate can revert d.
+func (d *Drift) Remediable() bool {
+	return d.Rule.State == Absent || d.Rule.hasValue()
+}
+
+// Check compares the variables of b with the rules of c and returns the
+// deviations.
+func Check(b efivarfs.ReadBackend, c *Config) ([]Drift, error) {
+	var drifts []Drift
+	for _, r := range c.Variables {
+		d, err := r.check(b)
+		if err != nil {
+			return nil, err
+		}
+		if d != nil {
+			drifts = append(drifts, *d)
+		}
+	}
+	return drifts, nil
+}
+
+// check compares the variable of r with it and returns the drift, nil
+// if there is none.
+func (r *Rule) check(b efivarfs.ReadBackend) (*Drift, error) {
+	attrs, data, err := b.Get(r.desc)
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		if r.State == Absent {
+			return nil, nil
+		}
+		return &Drift{Rule: r, Reason: "missing"}, nil
+	case err != nil:
+		return nil, fmt.Errorf("reading %s: %w", r.desc, err)
+	}
+	value, err := r.data()
+	if err != nil {
+		return nil, err
+	}
+	d := &Drift{Rule: r, Exists: true, Attributes: attrs, Data: data}
+	switch {
+	case r.State == Absent:
+		d.Reason = "exists"
+	case r.Attributes != 0 && attrs != r.Attributes:
+		d.Reason = fmt.Sprintf("attributes are %s instead of %s", attrs, r.Attributes)
+	case value != nil && !bytes.Equal(data, value):
+		d.Reason = fmt.Sprintf("value is %x instead of %x", data, value)
+	case r.match != nil && !r.match.Match(data):
+		d.Reason = fmt.Sprintf("value %x doesn't match %s", data, r.Match)
+	default:
+		return nil, nil
+	}
+	return d, nil
+}
+
+// Remediate reverts d by writing the value of its rule or removing the
+// variable. A variable with other attributes than the rule's is removed
+// and written anew, as the attributes of an existing variable can't be
+// changed. It fails with ErrNotRemediable unless d.Remediable().
+func Remediate(b efivarfs.Backend, d Drift) error {
+	r := d.Rule
+	switch {
+	case r.State == Absent:
+		err := b.Remove(r.desc)
+		if errors.Is(err, efivarfs.ErrVarNotExist) {
+			return nil
+		}
+		return err
+	case r.hasValue():
+		value, err := r.data()
+		if err != nil {
+			return err
+		}
+		attrs := r.Attributes
+		if attrs == 0 {
+			attrs = DefaultAttributes
+		}
+		if d.Exists && d.Attributes != attrs {
+			if err := b.Remove(r.desc); err != nil && !errors.Is(err, efivarfs.ErrVarNotExist) {
+				return fmt.Errorf("%s: removing to change attributes: %w", r.desc, err)
+			}
+		}
+		return b.Set(r.desc, attrs, value)
+	}
+	return fmt.Errorf("%s: %w", d.String(), ErrNotRemediable)
+}
+
+// Apply makes the variables of b converge to c by remediating all drift
+// from it. It returns the drift that was reverted and, joined into one
+// error, the reasons the rest couldn't be, ErrNotRemediable for rules
+// without value.
+func Apply(b efivarfs.Backend, c *Config) ([]Drift, error) {
+	drifts, err := Check(b, c)
+	if err != nil {
+		return nil, err
+	}
+	var applied []Drift
+	var errs []error
+	for _, d := range drifts {
+		if err := Remediate(b, d); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		applied = append(applied, d)
+	}
+	return applied, errors.Join(errs...)
+}
+
+// Watch reports drift to handle until ctx is done or w fails. It checks
+// all rules first and then the rules of every variable w reports as
+// changed. handle returns the error ending Watch, if any, and is called
+// from a single goroutine.
+func Watch(ctx context.Context, w *watch.Watcher, b efivarfs.ReadBackend, c *Config, handle func(Drift) error) error {
+	drifts, err := Check(b, c)
+	if err != nil {
+		return err
+	}
+	for _, d := range drifts {
+		if err := handle(d); err != nil {
+			return err
+		}
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-w.Errors:
+			return err
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			for _, r := range c.Variables {
+				if !r.desc.Equal(ev.Desc) {
+					continue
+				}
+				d, err := r.check(b)
+				if err != nil {
+					return err
+				}
+				if d == nil {
+					continue
+				}
+				if err := handle(*d); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
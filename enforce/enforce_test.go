@@ -0,0 +1,206 @@
+package enforce
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/esp"
+	"github.com/system-transparency/efivar/gpt"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+func TestEnforce(t *testing.T) {
+	dir := corpustest.Copy(t, "ovmf")
+	c, err := ParseConfig([]byte(`{"variables": [
+		{"name": "Timeout", "value": "0a00"},
+		{"name": "SecureBoot", "state": "absent"},
+		{"name": "BootOrder", "match": "^\\x05"},
+		{"name": "Lang", "state": "present"}
+	]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := efivarfs.Dir(dir)
+	drifts, err := Check(b, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 3 {
+		t.Fatalf("Check() = %v, want Timeout, SecureBoot and BootOrder", drifts)
+	}
+	for _, d := range drifts {
+		err := Remediate(b, d)
+		if d.Rule.Name == "BootOrder" {
+			if !errors.Is(err, ErrNotRemediable) {
+				t.Errorf("Remediate(%s) = %v, want ErrNotRemediable", &d, err)
+			}
+		} else if err != nil {
+			t.Errorf("Remediate(%s) = %v", &d, err)
+		}
+	}
+	if drifts, err = Check(b, c); err != nil || len(drifts) != 1 {
+		t.Errorf("Check() after remediation = %v, %v, want BootOrder only", drifts, err)
+	}
+
+	for _, config := range []string{
+		`{"variables": [{"name": "Timeout", "value": "xyz"}]}`,
+		`{"variables": [{"name": "Timeout", "state": "absent", "value": "00"}]}`,
+		`{"variables": [{"name": "Unknown"}]}`,
+		`{"variables": [{"name": "Timeout", "match": "("}]}`,
+		`{"variables": [{"name": "Timeout", "value": "00", "base64": "AA=="}]}`,
+		`{"variables": [{"name": "Boot0001", "boot": {"partition": "xyz", "loader": "a.efi"}}]}`,
+		`{"variables": [{"name": "Boot0001", "boot": {"partition": "{{ .ESP", "loader": "a.efi"}}]}`,
+	} {
+		if _, err := ParseConfig([]byte(config)); err == nil {
+			t.Errorf("ParseConfig(%s) succeeded", config)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	partUUID := guid.MustParse("0f6b8b38-7d2e-4a8c-9cbd-3c5a8a9f4e11")
+	e := esp.ESP{Device: "/dev/sda1", Partition: gpt.Partition{Number: 1, GUID: partUUID, FirstLBA: 2048, LastLBA: 206847}}
+	findESPs = func() ([]esp.ESP, error) { return []esp.ESP{e}, nil }
+	defaultESP = func() (*esp.ESP, error) { return &e, nil }
+	defer func() { findESPs, defaultESP = esp.FindESPs, esp.Default }()
+
+	dir := corpustest.Copy(t, "ovmf")
+	c, err := ParseConfig([]byte(`
+variables:
+  - name: Timeout
+    value: "0300"
+  - name: PlatformLang
+    base64: ZGUtREUA
+  - name: Greeting
+    guid: 3b1bd3c2-6a3f-4b1c-9a56-0c2e0f7a5d11
+    utf16: Hello
+  - name: Boot0007
+    boot:
+      description: Linux
+      partition: 0f6b8b38-7d2e-4a8c-9cbd-3c5a8a9f4e11
+      loader: \EFI\Linux\linux.efi
+      data: quiet
+  - name: Boot0008
+    boot:
+      description: Fallback
+      partition: "{{ .ESP.PartUUID }}"
+      loader: \EFI\BOOT\BOOTX64.EFI
+      data: esp={{ .ESP.Device }}
+  - name: BootOrder
+    match: "^\\x05"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := efivarfs.Dir(dir)
+	applied, err := Apply(b, c)
+	if !errors.Is(err, ErrNotRemediable) {
+		t.Errorf("Apply() = %v, want ErrNotRemediable for BootOrder", err)
+	}
+	if len(applied) != 5 {
+		t.Errorf("Apply() applied %v, want all but BootOrder", applied)
+	}
+	if drifts, err := Check(b, c); err != nil || len(drifts) != 1 {
+		t.Errorf("Check() after Apply() = %v, %v, want BootOrder only", drifts, err)
+	}
+
+	_, data, err := b.Get(c.Variables[2].Desc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []byte("H\x00e\x00l\x00l\x00o\x00\x00\x00"); !bytes.Equal(data, want) {
+		t.Errorf("Greeting = %x, want %x", data, want)
+	}
+	_, data, err = b.Get(c.Variables[3].Desc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	o, err := bootmgr.ParseLoadOption(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path, _ := o.FilePath.FilePath(); o.Description != "Linux" || !o.Active() || path != `\EFI\Linux\linux.efi` {
+		t.Errorf("Boot0007 = %q, active %v, loader %s", o.Description, o.Active(), path)
+	}
+	_, data, err = b.Get(c.Variables[4].Desc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if o, err = bootmgr.ParseLoadOption(data); err != nil {
+		t.Fatal(err)
+	}
+	if want := encodeUTF16("esp=/dev/sda1"); !bytes.Equal(o.OptionalData, want) {
+		t.Errorf("Boot0008 has optional data %q, want %q", o.OptionalData, want)
+	}
+}
+
+// firmwareBackend refuses to change the attributes of existing
+// variables like the firmware does.
+type firmwareBackend struct {
+	efivarfs.Backend
+}
+
+func (b firmwareBackend) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	if old, _, err := b.Get(desc); err == nil && old != attrs {
+		return efivarfs.ErrInvalidAttributes
+	}
+	return b.Backend.Set(desc, attrs, data)
+}
+
+func TestRemediateAttributes(t *testing.T) {
+	b := firmwareBackend{efivarfs.Dir(corpustest.Copy(t, "ovmf"))}
+	want := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess
+	c, err := ParseConfig([]byte(`{"variables": [{"name": "Timeout", "value": "0500", "attributes": 3}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	drifts, err := Check(b, c)
+	if err != nil || len(drifts) != 1 {
+		t.Fatalf("Check() = %v, %v, want the attributes of Timeout", drifts, err)
+	}
+	if err := Remediate(b, drifts[0]); err != nil {
+		t.Fatalf("Remediate(%s) = %v", &drifts[0], err)
+	}
+	attrs, data, err := b.Get(c.Variables[0].Desc())
+	if err != nil || attrs != want || !bytes.Equal(data, []byte{5, 0}) {
+		t.Errorf("Timeout = %s, %x, %v after Remediate(), want %s, 0500", attrs, data, err, want)
+	}
+}
+
+// TestBootEntryLazy checks that boot entries are resolved when checked,
+// so a long running Watch follows a changed ESP.
+func TestBootEntryLazy(t *testing.T) {
+	findESPs = func() ([]esp.ESP, error) { return nil, errors.New("disks scanned while parsing") }
+	defaultESP = func() (*esp.ESP, error) { return nil, errors.New("disks scanned while parsing") }
+	defer func() { findESPs, defaultESP = esp.FindESPs, esp.Default }()
+
+	c, err := ParseConfig([]byte(`{"variables": [{"name": "Boot0007", "boot": {"description": "Linux", "partition": "{{ .ESP.PartUUID }}", "loader": "\\EFI\\Linux\\linux.efi"}}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := efivarfs.Dir(corpustest.Copy(t, "ovmf"))
+	if _, err := Check(b, c); err != nil {
+		t.Fatalf("Check() = %v for a missing variable", err)
+	}
+
+	use := func(partUUID string) {
+		e := esp.ESP{Device: "/dev/sda1", Partition: gpt.Partition{Number: 1, GUID: guid.MustParse(partUUID), FirstLBA: 2048, LastLBA: 206847}}
+		findESPs = func() ([]esp.ESP, error) { return []esp.ESP{e}, nil }
+		defaultESP = func() (*esp.ESP, error) { return &e, nil }
+	}
+	use("0f6b8b38-7d2e-4a8c-9cbd-3c5a8a9f4e11")
+	if _, err := Apply(b, c); err != nil {
+		t.Fatal(err)
+	}
+	if drifts, err := Check(b, c); err != nil || len(drifts) != 0 {
+		t.Errorf("Check() = %v, %v after Apply()", drifts, err)
+	}
+	use("5d8e4f1a-3c2b-4e6d-9f8a-7b6c5d4e3f2a")
+	if drifts, err := Check(b, c); err != nil || len(drifts) != 1 {
+		t.Errorf("Check() = %v, %v after the ESP changed, want Boot0007", drifts, err)
+	}
+}
@@ -0,0 +1,175 @@
+// Package esp locates EFI System Partitions on the running system.
+package esp
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/gpt"
+	"github.com/system-transparency/efivar/internal/mountinfo"
+)
+
+var (
+	// SysBlock is the sysfs directory listing all block devices
+	SysBlock = "/sys/block"
+
+	// DevDir is where the device nodes of block devices live
+	DevDir = "/dev"
+
+	// MountInfo is the mount table of the current process
+	MountInfo = "/proc/self/mountinfo"
+)
+
+// ErrNotFound is returned if no EFI System Partition could be found
+var ErrNotFound = errors.New("no EFI system partition found")
+
+// ESP describes an EFI System Partition.
+type ESP struct {
+	// Disk is the device node of the whole disk, e.g. /dev/sda
+	Disk string
+	// Device is the device node of the partition, e.g. /dev/sda1
+	Device string
+	// DiskGUID identifies the disk the partition is located on
+	DiskGUID guid.UUID
+	// MountPoint is where the partition is mounted or empty if it isn't
+	MountPoint string
+	// Partition holds the GPT entry, its GUID is the PARTUUID
+	gpt.Partition
+}
+
+// PartUUID returns the GUID of the partition in the form used by
+// PARTUUID= on the kernel command line and in /dev/disk/by-partuuid.
+func (e ESP) PartUUID() string {
+	return e.GUID.String()
+}
+
+// FindESPs scans all block devices for GPT partitions with the EFI
+// System Partition type GUID and resolves their mount points using the
+// mount table. Disks that can't be read, e.g. due to missing permissions,
+// are skipped.
+func FindESPs() ([]ESP, error) {
+	disks, err := os.ReadDir(SysBlock)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := readMounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var esps []ESP
+	for _, d := range disks {
+		disk := d.Name()
+		if strings.HasPrefix(disk, "ram") || strings.HasPrefix(disk, "zram") {
+			continue
+		}
+		table, err := gpt.ReadDevice(filepath.Join(DevDir, disk))
+		if err != nil {
+			continue
+		}
+		parts := partitionNames(disk)
+		for _, p := range table.Partitions {
+			if p.Type != gpt.TypeEFISystem {
+				continue
+			}
+			e := ESP{
+				Disk:      filepath.Join(DevDir, disk),
+				DiskGUID:  table.DiskGUID,
+				Partition: p,
+			}
+			if name, ok := parts[p.Number]; ok {
+				e.Device = filepath.Join(DevDir, name)
+				e.MountPoint = mounts[devNumber(filepath.Join(SysBlock, disk, name))]
+			}
+			esps = append(esps, e)
+		}
+	}
+	sort.SliceStable(esps, func(i, j int) bool {
+		if esps[i].Disk != esps[j].Disk {
+			return esps[i].Disk < esps[j].Disk
+		}
+		return esps[i].Number < esps[j].Number
+	})
+	return esps, nil
+}
+
+// Default returns the ESP boot entries should be created on when the
+// user didn't specify one. Mounted partitions are preferred, as those
+// are the ones the installed operating system is using.
+func Default() (*ESP, error) {
+	esps, err := FindESPs()
+	if err != nil {
+		return nil, err
+	}
+	if len(esps) == 0 {
+		return nil, ErrNotFound
+	}
+	for i := range esps {
+		if esps[i].MountPoint != "" {
+			return &esps[i], nil
+		}
+	}
+	return &esps[0], nil
+}
+
+// partitionNames maps the partition numbers of a disk to the kernel
+// names of the partition devices, e.g. 1 -> nvme0n1p1.
+func partitionNames(disk string) map[int]string {
+	names := make(map[int]string)
+	entries, err := os.ReadDir(filepath.Join(SysBlock, disk))
+	if err != nil {
+		return names
+	}
+	for _, e := range entries {
+		b, err := os.ReadFile(filepath.Join(SysBlock, disk, e.Name(), "partition"))
+		if err != nil {
+			// Not a partition
+			continue
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(string(b)))
+		if err != nil {
+			continue
+		}
+		names[n] = e.Name()
+	}
+	return names
+}
+
+// devNumber returns the major:minor string of a sysfs block device.
+func devNumber(sysPath string) string {
+	b, err := os.ReadFile(filepath.Join(sysPath, "dev"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+// readMounts parses the mount table and returns the first mount point
+// of each device keyed by its major:minor number.
+func readMounts() (map[string]string, error) {
+	f, err := os.Open(MountInfo)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := make(map[string]string)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw
+		fields := strings.Fields(s.Text())
+		if len(fields) < 5 {
+			continue
+		}
+		if _, ok := mounts[fields[2]]; !ok {
+			mounts[fields[2]] = mountinfo.Unescape(fields[4])
+		}
+	}
+	return mounts, s.Err()
+}
@@ -0,0 +1,141 @@
+package esp
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/gpt"
+)
+
+var typeLinux = guid.MustParse("0fc63daf-8483-4772-8e79-3d69d8477de4")
+
+// testDisk returns a disk image of 64 sectors with a GPT holding a
+// partition of each of types, numbered from 1.
+func testDisk(types ...guid.UUID) []byte {
+	const sectorSize = 512
+	disk := make([]byte, 64*sectorSize)
+	entries := disk[2*sectorSize : 2*sectorSize+4*128]
+	for i, typ := range types {
+		e := entries[i*128:]
+		t := efivarfs.EncodeGUID(typ)
+		copy(e[0:], t[:])
+		e[16] = byte(i + 1)
+		binary.LittleEndian.PutUint64(e[32:], uint64(34+i))
+		binary.LittleEndian.PutUint64(e[40:], uint64(34+i))
+	}
+
+	hdr := disk[sectorSize : sectorSize+92]
+	copy(hdr, "EFI PART")
+	binary.LittleEndian.PutUint32(hdr[8:], 0x00010000)
+	binary.LittleEndian.PutUint32(hdr[12:], 92)
+	binary.LittleEndian.PutUint64(hdr[24:], 1)
+	binary.LittleEndian.PutUint64(hdr[32:], 63)
+	binary.LittleEndian.PutUint64(hdr[40:], 34)
+	binary.LittleEndian.PutUint64(hdr[48:], 62)
+	binary.LittleEndian.PutUint64(hdr[72:], 2)
+	binary.LittleEndian.PutUint32(hdr[80:], 4)
+	binary.LittleEndian.PutUint32(hdr[84:], 128)
+	binary.LittleEndian.PutUint32(hdr[88:], crc32.ChecksumIEEE(entries))
+	binary.LittleEndian.PutUint32(hdr[16:], crc32.ChecksumIEEE(hdr))
+	return disk
+}
+
+// fakeSystem points SysBlock, DevDir and MountInfo to a temporary
+// directory with the disks sda, with an unmounted ESP as second
+// partition, and nvme0n1, with one mounted at /boot/my efi, for the
+// duration of the test.
+func fakeSystem(t *testing.T) {
+	t.Helper()
+	sysBlock, devDir, mountInfo := SysBlock, DevDir, MountInfo
+	t.Cleanup(func() { SysBlock, DevDir, MountInfo = sysBlock, devDir, mountInfo })
+	root := t.TempDir()
+	SysBlock = filepath.Join(root, "sys", "block")
+	DevDir = filepath.Join(root, "dev")
+	MountInfo = filepath.Join(root, "mountinfo")
+
+	files := map[string]string{
+		"sys/block/sda/sda1/partition":          "1\n",
+		"sys/block/sda/sda1/dev":                "8:1\n",
+		"sys/block/sda/sda2/partition":          "2\n",
+		"sys/block/sda/sda2/dev":                "8:2\n",
+		"sys/block/sda/queue/rotational":        "0\n",
+		"sys/block/nvme0n1/nvme0n1p1/partition": "1\n",
+		"sys/block/nvme0n1/nvme0n1p1/dev":       "259:1\n",
+		"sys/block/ram0/dev":                    "1:0\n",
+		"dev/sda":                               string(testDisk(typeLinux, gpt.TypeEFISystem)),
+		"dev/nvme0n1":                           string(testDisk(gpt.TypeEFISystem)),
+		"dev/ram0":                              string(testDisk(gpt.TypeEFISystem)),
+		"mountinfo": "22 1 8:1 / / rw,relatime shared:1 - ext4 /dev/sda1 rw\n" +
+			"23 22 259:1 / /boot/my\\040efi rw,relatime shared:2 - vfat /dev/nvme0n1p1 rw\n" +
+			"24 22 259:1 / /mnt rw,relatime shared:3 - vfat /dev/nvme0n1p1 rw\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPartitionNames(t *testing.T) {
+	fakeSystem(t)
+	names := partitionNames("sda")
+	if len(names) != 2 || names[1] != "sda1" || names[2] != "sda2" {
+		t.Errorf("partitionNames(sda) = %v", names)
+	}
+	if names := partitionNames("missing"); len(names) != 0 {
+		t.Errorf("partitionNames(missing) = %v", names)
+	}
+}
+
+func TestReadMounts(t *testing.T) {
+	fakeSystem(t)
+	mounts, err := readMounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 2 || mounts["8:1"] != "/" || mounts["259:1"] != "/boot/my efi" {
+		t.Errorf("readMounts() = %q", mounts)
+	}
+}
+
+func TestFindESPs(t *testing.T) {
+	fakeSystem(t)
+	esps, err := FindESPs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(esps) != 2 {
+		t.Fatalf("FindESPs() = %+v, want the ESPs of nvme0n1 and sda", esps)
+	}
+	for i, want := range []ESP{
+		{Disk: filepath.Join(DevDir, "nvme0n1"), Device: filepath.Join(DevDir, "nvme0n1p1"), MountPoint: "/boot/my efi"},
+		{Disk: filepath.Join(DevDir, "sda"), Device: filepath.Join(DevDir, "sda2")},
+	} {
+		if e := esps[i]; e.Disk != want.Disk || e.Device != want.Device || e.MountPoint != want.MountPoint {
+			t.Errorf("FindESPs()[%d] = %+v, want %+v", i, e, want)
+		}
+	}
+
+	// The mounted ESP is preferred even if it isn't the first one
+	os.Rename(filepath.Join(DevDir, "nvme0n1"), filepath.Join(DevDir, "vda"))
+	os.Rename(filepath.Join(SysBlock, "nvme0n1"), filepath.Join(SysBlock, "vda"))
+	os.Rename(filepath.Join(SysBlock, "vda", "nvme0n1p1"), filepath.Join(SysBlock, "vda", "vda1"))
+	e, err := Default()
+	if err != nil || e.Device != filepath.Join(DevDir, "vda1") || e.MountPoint != "/boot/my efi" {
+		t.Errorf("Default() = %+v, %v, want the mounted ESP", e, err)
+	}
+
+	os.WriteFile(MountInfo, nil, 0644)
+	if e, err := Default(); err != nil || e.Device != filepath.Join(DevDir, "sda2") {
+		t.Errorf("Default() = %+v, %v without mounts, want the first ESP", e, err)
+	}
+}
@@ -1,7 +1,23 @@
 module github.com/system-transparency/efivar
 
-go 1.17
+go 1.21
 
 require golang.org/x/sys v0.0.0-20211020174200-9d6173849985
 
-require github.com/google/uuid v1.3.0
+require (
+	github.com/godbus/dbus/v5 v5.1.0
+	github.com/google/uuid v1.3.0
+	go.opentelemetry.io/otel v1.24.0
+	go.opentelemetry.io/otel/trace v1.24.0
+	golang.org/x/text v0.3.0
+	golang.org/x/time v0.5.0
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/golang/protobuf v1.4.3 // indirect
+	golang.org/x/net v0.0.0-20200822124328-c89045814202 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
+)
@@ -0,0 +1,247 @@
+// Package gpt implements a minimal reader for GUID partition tables.
+//
+// It only exposes what is needed to describe a partition inside an
+// UEFI device path, e.g. for the HD() node of a boot entry, so tools
+// don't have to shell out to lsblk or blkid.
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"unicode/utf16"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+const (
+	// headerLBA is the location of the primary GPT header
+	headerLBA = 1
+	// minHeaderSize is the size of the header fields defined by the spec
+	minHeaderSize = 92
+	// maxEntries limits the amount of partition entries we are willing to
+	// read to keep a corrupted header from exhausting memory
+	maxEntries = 1024
+	// minEntrySize is the size of the entry fields defined by the spec,
+	// larger entries have to be a multiple of it
+	minEntrySize = 128
+	// maxEntrySize limits the size of a single partition entry
+	maxEntrySize = 4096
+	// DefaultSectorSize is used whenever the logical block size of a
+	// device can not be determined
+	DefaultSectorSize = 512
+)
+
+var signature = []byte("EFI PART")
+
+// TypeEFISystem is the partition type GUID of an EFI System Partition
+var TypeEFISystem = guid.MustParse("c12a7328-f81f-11d2-ba4b-00a0c93ec93b")
+
+var (
+	// ErrNoGPT is returned if no valid GPT header was found
+	ErrNoGPT = errors.New("no valid GPT found")
+
+	// ErrCorrupted is returned if a checksum of the GPT does not match
+	ErrCorrupted = errors.New("GPT checksum mismatch")
+)
+
+// header is the on-disk layout of the fields of the GPT header we use.
+type header struct {
+	Signature      [8]byte
+	Revision       uint32
+	HeaderSize     uint32
+	HeaderCRC32    uint32
+	Reserved       uint32
+	MyLBA          uint64
+	AlternateLBA   uint64
+	FirstUsableLBA uint64
+	LastUsableLBA  uint64
+	DiskGUID       [16]byte
+	EntriesLBA     uint64
+	NumEntries     uint32
+	EntrySize      uint32
+	EntriesCRC32   uint32
+}
+
+// entry is the on-disk layout of a GPT partition entry.
+type entry struct {
+	TypeGUID   [16]byte
+	UniqueGUID [16]byte
+	FirstLBA   uint64
+	LastLBA    uint64
+	Attributes uint64
+	Name       [36]uint16
+}
+
+// Partition describes a single used entry of a partition table.
+type Partition struct {
+	// Number is the 1-based index of the entry in the partition array,
+	// which is also what the kernel uses as partition number.
+	Number int
+	// Type identifies the purpose of the partition, e.g. ESP
+	Type guid.UUID
+	// GUID is the unique partition GUID, also known as PARTUUID
+	GUID guid.UUID
+	// FirstLBA is the first sector of the partition
+	FirstLBA uint64
+	// LastLBA is the last sector of the partition (inclusive)
+	LastLBA uint64
+	// Attributes holds the GPT attribute bits of the partition
+	Attributes uint64
+	// Name is the human readable partition label
+	Name string
+}
+
+// Size returns the size of the partition in sectors.
+func (p Partition) Size() uint64 {
+	return p.LastLBA - p.FirstLBA + 1
+}
+
+// Table is the parsed content of a GUID partition table.
+type Table struct {
+	// DiskGUID uniquely identifies the disk
+	DiskGUID guid.UUID
+	// SectorSize is the logical block size the table was read with
+	SectorSize int
+	// Partitions holds all used partition entries ordered by number
+	Partitions []Partition
+}
+
+// Partition returns the partition with the given number.
+func (t *Table) Partition(number int) (Partition, bool) {
+	for _, p := range t.Partitions {
+		if p.Number == number {
+			return p, true
+		}
+	}
+	return Partition{}, false
+}
+
+// Read parses the primary GUID partition table from r using the given
+// logical sector size. Both the header and the partition entry array
+// checksums are verified. If r knows its size, like *os.File,
+// *bytes.Reader or *io.SectionReader, the partition entry array has
+// to be within it.
+func Read(r io.ReaderAt, sectorSize int) (*Table, error) {
+	if sectorSize <= 0 {
+		sectorSize = DefaultSectorSize
+	}
+	buf := make([]byte, sectorSize)
+	if _, err := r.ReadAt(buf, headerLBA*int64(sectorSize)); err != nil {
+		return nil, fmt.Errorf("reading GPT header: %w", err)
+	}
+	var hdr header
+	if err := binary.Read(bytes.NewReader(buf), binary.LittleEndian, &hdr); err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(hdr.Signature[:], signature) {
+		return nil, ErrNoGPT
+	}
+	if hdr.HeaderSize < minHeaderSize || int(hdr.HeaderSize) > sectorSize {
+		return nil, fmt.Errorf("invalid header size %d: %w", hdr.HeaderSize, ErrNoGPT)
+	}
+	raw := append([]byte(nil), buf[:hdr.HeaderSize]...)
+	// The checksum is calculated with the CRC field itself zeroed
+	binary.LittleEndian.PutUint32(raw[16:20], 0)
+	if crc32.ChecksumIEEE(raw) != hdr.HeaderCRC32 {
+		return nil, fmt.Errorf("header: %w", ErrCorrupted)
+	}
+	if hdr.NumEntries > maxEntries || hdr.EntrySize < minEntrySize ||
+		hdr.EntrySize > maxEntrySize || hdr.EntrySize%minEntrySize != 0 {
+		return nil, fmt.Errorf("unsupported partition array (%d entries of %d bytes): %w",
+			hdr.NumEntries, hdr.EntrySize, ErrNoGPT)
+	}
+	// Sizes and offsets are computed with 64 bits, as the fields are
+	// read from disk and the products overflow int on 32 bit systems.
+	tableSize := uint64(hdr.NumEntries) * uint64(hdr.EntrySize)
+	if hdr.EntriesLBA > math.MaxInt64/uint64(sectorSize) {
+		return nil, fmt.Errorf("partition entries at LBA %d out of range: %w", hdr.EntriesLBA, ErrNoGPT)
+	}
+	tableOff := hdr.EntriesLBA * uint64(sectorSize)
+	if size, ok := readerSize(r); ok && (tableOff > size || tableSize > size-tableOff) {
+		return nil, fmt.Errorf("partition entries at LBA %d exceed the device size of %d bytes: %w",
+			hdr.EntriesLBA, size, ErrNoGPT)
+	}
+
+	entries := make([]byte, tableSize)
+	if _, err := r.ReadAt(entries, int64(tableOff)); err != nil {
+		return nil, fmt.Errorf("reading partition entries: %w", err)
+	}
+	if crc32.ChecksumIEEE(entries) != hdr.EntriesCRC32 {
+		return nil, fmt.Errorf("partition entries: %w", ErrCorrupted)
+	}
+
+	t := &Table{
+		DiskGUID:   efivarfs.DecodeGUID(hdr.DiskGUID[:]),
+		SectorSize: sectorSize,
+	}
+	for i := 0; i < int(hdr.NumEntries); i++ {
+		var e entry
+		off := i * int(hdr.EntrySize)
+		if err := binary.Read(bytes.NewReader(entries[off:off+int(hdr.EntrySize)]), binary.LittleEndian, &e); err != nil {
+			return nil, err
+		}
+		if e.TypeGUID == [16]byte{} {
+			// Unused entry
+			continue
+		}
+		t.Partitions = append(t.Partitions, Partition{
+			Number:     i + 1,
+			Type:       efivarfs.DecodeGUID(e.TypeGUID[:]),
+			GUID:       efivarfs.DecodeGUID(e.UniqueGUID[:]),
+			FirstLBA:   e.FirstLBA,
+			LastLBA:    e.LastLBA,
+			Attributes: e.Attributes,
+			Name:       decodeName(e.Name[:]),
+		})
+	}
+	return t, nil
+}
+
+// ReadDevice opens the block device (or disk image) at path and reads
+// its partition table. The logical block size is queried from the
+// kernel and falls back to DefaultSectorSize for regular files.
+func ReadDevice(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sectorSize, err := logicalBlockSize(f)
+	if err != nil {
+		sectorSize = DefaultSectorSize
+	}
+	return Read(f, sectorSize)
+}
+
+// readerSize returns the size of r in bytes, if it is known.
+func readerSize(r io.ReaderAt) (uint64, bool) {
+	switch s := r.(type) {
+	case interface{ Size() int64 }:
+		return uint64(s.Size()), true
+	case io.Seeker:
+		// Block devices report a size of 0 to stat, but can be seeked
+		// to their end. ReadAt doesn't depend on the offset.
+		n, err := s.Seek(0, io.SeekEnd)
+		return uint64(n), err == nil
+	}
+	return 0, false
+}
+
+// decodeName converts the NUL padded UTF-16 partition name to a string.
+func decodeName(name []uint16) string {
+	for i, c := range name {
+		if c == 0 {
+			name = name[:i]
+			break
+		}
+	}
+	return string(utf16.Decode(name))
+}
@@ -0,0 +1,12 @@
+package gpt
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// logicalBlockSize queries the logical block size of the block device f.
+func logicalBlockSize(f *os.File) (int, error) {
+	return unix.IoctlGetInt(int(f.Fd()), unix.BLKSSZGET)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package gpt
+
+import (
+	"errors"
+	"os"
+)
+
+// logicalBlockSize is not supported on this platform, so ReadDevice
+// always uses DefaultSectorSize.
+func logicalBlockSize(f *os.File) (int, error) {
+	return 0, errors.ErrUnsupported
+}
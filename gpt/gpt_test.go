@@ -0,0 +1,103 @@
+package gpt
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// testDisk returns a disk image of 64 sectors with a GPT holding an ESP
+// as second entry. fn may modify the header before the checksums are
+// computed.
+func testDisk(fn func(h *header)) []byte {
+	const sectorSize = 512
+	disk := make([]byte, 64*sectorSize)
+	h := header{
+		Revision:       0x00010000,
+		HeaderSize:     minHeaderSize,
+		MyLBA:          1,
+		AlternateLBA:   63,
+		FirstUsableLBA: 34,
+		LastUsableLBA:  62,
+		EntriesLBA:     2,
+		NumEntries:     128,
+		EntrySize:      128,
+	}
+	copy(h.Signature[:], signature)
+	h.DiskGUID = efivarfs.EncodeGUID(TypeEFISystem)
+	fn(&h)
+
+	if uint64(h.NumEntries)*uint64(h.EntrySize) <= uint64(len(disk)-2*sectorSize) && h.EntrySize >= 128 && h.NumEntries >= 2 {
+		entries := disk[2*sectorSize : 2*sectorSize+int(h.NumEntries)*int(h.EntrySize)]
+		e := entries[h.EntrySize:]
+		typ := efivarfs.EncodeGUID(TypeEFISystem)
+		copy(e[0:], typ[:])
+		e[16] = 1
+		binary.LittleEndian.PutUint64(e[32:], 34)
+		binary.LittleEndian.PutUint64(e[40:], 62)
+		for i, c := range utf16.Encode([]rune("EFI")) {
+			binary.LittleEndian.PutUint16(e[56+2*i:], c)
+		}
+		h.EntriesCRC32 = crc32.ChecksumIEEE(entries)
+	}
+
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, h)
+	hdr := buf.Bytes()[:h.HeaderSize]
+	binary.LittleEndian.PutUint32(hdr[16:], crc32.ChecksumIEEE(hdr))
+	copy(disk[sectorSize:], hdr)
+	return disk
+}
+
+func TestRead(t *testing.T) {
+	table, err := Read(bytes.NewReader(testDisk(func(*header) {})), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, ok := table.Partition(2)
+	if len(table.Partitions) != 1 || !ok || p.Type != TypeEFISystem || p.FirstLBA != 34 || p.Size() != 29 || p.Name != "EFI" {
+		t.Errorf("Read() = %+v", table)
+	}
+
+	// Entries may be larger than 128 bytes if they are a multiple of it
+	table, err = Read(bytes.NewReader(testDisk(func(h *header) { h.EntrySize = 256; h.NumEntries = 4 })), 0)
+	if err != nil || len(table.Partitions) != 1 || table.Partitions[0].Number != 2 {
+		t.Errorf("Read() = %+v, %v with 256 byte entries", table, err)
+	}
+
+	if _, err := Read(bytes.NewReader(make([]byte, 4096)), 0); !errors.Is(err, ErrNoGPT) {
+		t.Errorf("Read() = %v without a GPT, want ErrNoGPT", err)
+	}
+	corrupted := testDisk(func(*header) {})
+	corrupted[2*512+40] ^= 1
+	if _, err := Read(bytes.NewReader(corrupted), 0); !errors.Is(err, ErrCorrupted) {
+		t.Errorf("Read() = %v with corrupted entries, want ErrCorrupted", err)
+	}
+}
+
+// TestReadBounds checks that header fields which would make Read
+// allocate or read beyond the device, or overflow int on 32 bit
+// systems, are rejected before anything is read.
+func TestReadBounds(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		fn   func(h *header)
+	}{
+		{"entry size not a multiple of 128", func(h *header) { h.EntrySize = 129 }},
+		{"entry size too large", func(h *header) { h.EntrySize = 8192; h.NumEntries = 1 }},
+		{"entry size overflowing", func(h *header) { h.EntrySize = 0x80000000 }},
+		{"too many entries", func(h *header) { h.NumEntries = maxEntries + 1 }},
+		{"entries beyond the device", func(h *header) { h.EntriesLBA = 60 }},
+		{"table larger than the device", func(h *header) { h.EntrySize = 4096; h.NumEntries = 1024 }},
+		{"entries LBA overflowing", func(h *header) { h.EntriesLBA = 1 << 62 }},
+	} {
+		if _, err := Read(bytes.NewReader(testDisk(tt.fn)), 0); !errors.Is(err, ErrNoGPT) {
+			t.Errorf("%s: Read() = %v, want ErrNoGPT", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+// Package integration holds the end-to-end tests running efivar against
+// the real efivarfs of a Linux kernel booted with OVMF under QEMU. They
+// are only built with the integration tag and need qemu-system-x86_64,
+// a kernel with the EFI stub and efivarfs built in and an OVMF build:
+//
+//	EFIVAR_KERNEL=/boot/vmlinuz \
+//	EFIVAR_OVMF_CODE=/usr/share/OVMF/OVMF_CODE.fd \
+//	EFIVAR_OVMF_VARS=/usr/share/OVMF/OVMF_VARS.fd \
+//	go test -tags integration ./integration
+//
+// Tests are skipped if any of them is missing. The guest directory is
+// the init process of the initramfs the tests build.
+package integration
@@ -0,0 +1,136 @@
+//go:build linux
+
+// Guest is the init process of the initramfs booted by the integration
+// tests. It mounts efivarfs, runs the step named by efivar.step= on the
+// kernel command line, reports the outcome on the console and powers
+// off.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"golang.org/x/sys/unix"
+)
+
+// Result markers searched for by the tests in the console output.
+const (
+	pass = "efivar-integration: PASS"
+	fail = "efivar-integration: FAIL"
+)
+
+// vendor is the GUID of the test variables.
+var vendor = guid.MustParse("3b1f1a7e-5c6d-4e2f-9a8b-0c1d2e3f4a5b")
+
+var (
+	persisted = efivarfs.VariableDescriptor{Name: "EfivarPersisted", GUID: &vendor}
+	removed   = efivarfs.VariableDescriptor{Name: "EfivarRemoved", GUID: &vendor}
+)
+
+const attrs = efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+
+var steps = map[string]func(c *efivarfs.Client) error{
+	"write":  write,
+	"verify": verify,
+}
+
+func main() {
+	if err := run(); err != nil {
+		fmt.Printf("%s: %v\n", fail, err)
+	} else {
+		fmt.Println(pass)
+	}
+	unix.Sync()
+	unix.Reboot(unix.LINUX_REBOOT_CMD_POWER_OFF)
+}
+
+func run() error {
+	for _, m := range []struct{ source, target, fstype string }{
+		{"proc", "/proc", "proc"},
+		{"sysfs", "/sys", "sysfs"},
+	} {
+		if err := os.MkdirAll(m.target, 0755); err != nil {
+			return err
+		}
+		if err := unix.Mount(m.source, m.target, m.fstype, 0, ""); err != nil {
+			return fmt.Errorf("mounting %s: %w", m.target, err)
+		}
+	}
+	cmdline, err := os.ReadFile("/proc/cmdline")
+	if err != nil {
+		return err
+	}
+	var step func(c *efivarfs.Client) error
+	for _, f := range strings.Fields(string(cmdline)) {
+		if name, ok := strings.CutPrefix(f, "efivar.step="); ok {
+			step = steps[name]
+		}
+	}
+	if step == nil {
+		return errors.New("no valid efivar.step= on the command line")
+	}
+	c, err := efivarfs.Open(efivarfs.WithMount(), efivarfs.WithSync())
+	if err != nil {
+		return err
+	}
+	return step(c)
+}
+
+// write exercises list, write, read and delete and leaves persisted
+// behind for verify.
+func write(c *efivarfs.Client) error {
+	descs, err := c.List()
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	if len(descs) == 0 {
+		return errors.New("list: no variables")
+	}
+	for _, desc := range []efivarfs.VariableDescriptor{persisted, removed} {
+		if err := c.Set(desc, attrs, []byte(desc.Name)); err != nil {
+			return fmt.Errorf("set %s: %w", desc.Name, err)
+		}
+		if err := expect(c, desc, []byte(desc.Name)); err != nil {
+			return err
+		}
+	}
+	if err := c.Set(persisted, attrs|efivarfs.AttributeAppendWrite, []byte("+")); err != nil {
+		return fmt.Errorf("append %s: %w", persisted.Name, err)
+	}
+	if err := c.Remove(removed); err != nil {
+		return fmt.Errorf("remove %s: %w", removed.Name, err)
+	}
+	if _, _, err := c.Get(removed); !errors.Is(err, efivarfs.ErrVarNotExist) {
+		return fmt.Errorf("get %s after remove: %v", removed.Name, err)
+	}
+	return nil
+}
+
+// verify checks the variables written by write survived the reboot and
+// cleans up.
+func verify(c *efivarfs.Client) error {
+	if err := expect(c, persisted, []byte(persisted.Name+"+")); err != nil {
+		return err
+	}
+	if _, _, err := c.Get(removed); !errors.Is(err, efivarfs.ErrVarNotExist) {
+		return fmt.Errorf("get %s after reboot: %v", removed.Name, err)
+	}
+	return c.Remove(persisted)
+}
+
+// expect checks desc holds data.
+func expect(c *efivarfs.Client, desc efivarfs.VariableDescriptor, data []byte) error {
+	a, got, err := c.Get(desc)
+	if err != nil {
+		return fmt.Errorf("get %s: %w", desc.Name, err)
+	}
+	if a != attrs || !bytes.Equal(got, data) {
+		return fmt.Errorf("get %s: got %s %q, want %s %q", desc.Name, a, got, attrs, data)
+	}
+	return nil
+}
@@ -0,0 +1,152 @@
+//go:build integration
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bootTimeout limits a single boot of the guest.
+const bootTimeout = 3 * time.Minute
+
+// env are the paths the tests need, taken from the environment.
+type env struct {
+	qemu, kernel, ovmfCode, ovmfVars string
+	initramfs                        string
+}
+
+// setup builds the initramfs or skips the test if QEMU, the kernel or
+// OVMF are missing.
+func setup(t *testing.T) *env {
+	t.Helper()
+	e := &env{
+		kernel:   os.Getenv("EFIVAR_KERNEL"),
+		ovmfCode: os.Getenv("EFIVAR_OVMF_CODE"),
+		ovmfVars: os.Getenv("EFIVAR_OVMF_VARS"),
+	}
+	if e.kernel == "" || e.ovmfCode == "" || e.ovmfVars == "" {
+		t.Skip("EFIVAR_KERNEL, EFIVAR_OVMF_CODE and EFIVAR_OVMF_VARS have to be set")
+	}
+	var err error
+	if e.qemu, err = exec.LookPath("qemu-system-x86_64"); err != nil {
+		t.Skip(err)
+	}
+
+	dir := t.TempDir()
+	init := filepath.Join(dir, "init")
+	build := exec.Command("go", "build", "-o", init, "./guest")
+	build.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH=amd64")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("building guest: %v\n%s", err, out)
+	}
+	prog, err := os.ReadFile(init)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cpio bytes.Buffer
+	w := &cpioWriter{w: &cpio}
+	w.dir("dev")
+	w.charDevice("dev/console", 5, 1)
+	w.file("init", 0755, prog)
+	w.close()
+	e.initramfs = filepath.Join(dir, "initramfs.cpio")
+	if err := os.WriteFile(e.initramfs, cpio.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// boot runs the guest step with the variable store vars and returns the
+// console output.
+func (e *env) boot(t *testing.T, vars, step string) string {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), bootTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, e.qemu,
+		"-machine", "q35",
+		"-m", "512",
+		"-nographic",
+		"-no-reboot",
+		"-drive", "if=pflash,format=raw,readonly=on,file="+e.ovmfCode,
+		"-drive", "if=pflash,format=raw,file="+vars,
+		"-kernel", e.kernel,
+		"-initrd", e.initramfs,
+		"-append", "console=ttyS0 panic=-1 efivar.step="+step,
+	)
+	out, err := cmd.CombinedOutput()
+	if ctx.Err() != nil {
+		t.Fatalf("step %s timed out:\n%s", step, out)
+	}
+	if err != nil {
+		t.Fatalf("step %s: qemu: %v\n%s", step, err, out)
+	}
+	return string(out)
+}
+
+// TestPersistence writes, reads and deletes variables through efivarfs
+// and checks the result survives a reboot of the firmware.
+func TestPersistence(t *testing.T) {
+	e := setup(t)
+	vars := filepath.Join(t.TempDir(), "OVMF_VARS.fd")
+	data, err := os.ReadFile(e.ovmfVars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(vars, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, step := range []string{"write", "verify"} {
+		out := e.boot(t, vars, step)
+		if i := strings.Index(out, "efivar-integration: FAIL"); i >= 0 {
+			t.Fatalf("step %s: %s", step, strings.SplitN(out[i:], "\n", 2)[0])
+		}
+		if !strings.Contains(out, "efivar-integration: PASS") {
+			t.Fatalf("step %s: guest didn't report a result:\n%s", step, out)
+		}
+	}
+}
+
+// cpioWriter writes an initramfs in the newc format the kernel expects,
+// which allows creating device nodes without being root.
+type cpioWriter struct {
+	w   *bytes.Buffer
+	ino int
+}
+
+func (c *cpioWriter) entry(name string, mode, rdevMajor, rdevMinor int, data []byte) {
+	c.ino++
+	fmt.Fprintf(c.w, "070701%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+		c.ino, mode, 0, 0, 1, 0, len(data), 0, 0, rdevMajor, rdevMinor, len(name)+1, 0)
+	c.w.WriteString(name)
+	c.w.WriteByte(0)
+	c.pad()
+	c.w.Write(data)
+	c.pad()
+}
+
+// pad aligns the archive to 4 bytes.
+func (c *cpioWriter) pad() {
+	for c.w.Len()%4 != 0 {
+		c.w.WriteByte(0)
+	}
+}
+
+func (c *cpioWriter) dir(name string) { c.entry(name, 040755, 0, 0, nil) }
+
+func (c *cpioWriter) charDevice(name string, major, minor int) {
+	c.entry(name, 020600, major, minor, nil)
+}
+
+func (c *cpioWriter) file(name string, perm int, data []byte) {
+	c.entry(name, 0100000|perm, 0, 0, data)
+}
+
+func (c *cpioWriter) close() { c.entry("TRAILER!!!", 0, 0, 0, nil) }
@@ -0,0 +1,41 @@
+// Package corpustest gives tests access to the variables of the corpus
+// in testdata/corpus, which were dumped from real machines.
+package corpustest
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// Dir returns the directory holding the variables of vendor, e.g.
+// "ovmf", as files named like in efivarfs.
+func Dir(vendor string) string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Join(filepath.Dir(file), "..", "..", "testdata", "corpus", vendor)
+}
+
+// Copy copies the variables of vendor to a temporary directory removed
+// at the end of the test, for tests that modify them.
+func Copy(t testing.TB, vendor string) string {
+	t.Helper()
+	dir := t.TempDir()
+	files, err := filepath.Glob(filepath.Join(Dir(vendor), "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) == 0 {
+		t.Fatalf("no variables of %s in the corpus", vendor)
+	}
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, filepath.Base(f)), b, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
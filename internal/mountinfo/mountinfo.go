@@ -0,0 +1,28 @@
+// Package mountinfo helps parsing the mount tables of the kernel in
+// /proc/mounts and /proc/self/mountinfo.
+package mountinfo
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Unescape decodes the octal escapes the kernel uses for whitespace and
+// backslashes in the paths of mount tables, e.g. \040 for a space.
+func Unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
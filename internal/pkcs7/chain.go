@@ -0,0 +1,61 @@
+package pkcs7
+
+import "crypto/x509"
+
+// maxChainLength bounds the certification paths Chain builds.
+const maxChainLength = 8
+
+// Chain returns a certification path from cert to one of roots, using
+// only the certificates embedded in p as intermediates: cert first, the
+// certificate of roots last. cert itself may be one of roots. It returns
+// nil if there is no such path.
+//
+// x509.Certificate.Verify isn't used as it can't ignore the validity
+// periods and extended key usages, which firmware doesn't check either
+// as it has no trusted time source. Issuers have to be CAs and have to
+// have signed the certificate they issued.
+func (p *SignedData) Chain(cert *x509.Certificate, roots []*x509.Certificate) []*x509.Certificate {
+	return chain([]*x509.Certificate{cert}, p.Certificates, roots)
+}
+
+// chain extends path, which ends with the certificate to find an issuer
+// for, until it reaches one of roots.
+func chain(path, intermediates, roots []*x509.Certificate) []*x509.Certificate {
+	c := path[len(path)-1]
+	for _, r := range roots {
+		if r.Equal(c) {
+			return path
+		}
+	}
+	for _, r := range roots {
+		if issued(r, c) {
+			return append(path, r)
+		}
+	}
+	if len(path) >= maxChainLength {
+		return nil
+	}
+	for _, i := range intermediates {
+		if contains(path, i) || !issued(i, c) {
+			continue
+		}
+		if full := chain(append(path[:len(path):len(path)], i), intermediates, roots); full != nil {
+			return full
+		}
+	}
+	return nil
+}
+
+// issued reports whether the CA certificate parent issued c.
+func issued(parent, c *x509.Certificate) bool {
+	return string(c.RawIssuer) == string(parent.RawSubject) && c.CheckSignatureFrom(parent) == nil
+}
+
+func contains(certs []*x509.Certificate, c *x509.Certificate) bool {
+	for _, x := range certs {
+		if x.Equal(c) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,147 @@
+// Package pkcs7 implements the subset of PKCS #7 (RFC 2315) SignedData
+// needed for UEFI authenticated variables and Authenticode.
+package pkcs7
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	oidSHA1          = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512        = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidRSAEncryption = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+)
+
+// ErrMalformed is returned when parsing invalid PKCS #7 structures
+var ErrMalformed = errors.New("malformed PKCS #7 SignedData")
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// rawCertificates is the implicitly tagged SET OF Certificate. A struct
+// is used instead of asn1.RawValue, as the latter matches any tag and
+// would break parsing of the optional field.
+type rawCertificates struct {
+	Raw asn1.RawContent
+}
+
+// rawAttributes is an implicitly tagged SET OF Attribute, see
+// rawCertificates for why this isn't an asn1.RawValue.
+type rawAttributes struct {
+	Raw asn1.RawContent
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	ContentInfo      contentInfo
+	Certificates     rawCertificates `asn1:"optional,tag:0"`
+	CRLs             rawCertificates `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo    `asn1:"set"`
+}
+
+type issuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values []asn1.RawValue `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version                   int
+	IssuerAndSerialNumber     issuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   rawAttributes `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes rawAttributes `asn1:"optional,tag:1"`
+}
+
+// SignedData is a parsed PKCS #7 SignedData structure.
+type SignedData struct {
+	// ContentType is the type of the signed content
+	ContentType asn1.ObjectIdentifier
+	// Content holds the embedded content octets, it is nil for
+	// detached signatures
+	Content []byte
+	// Certificates are all certificates shipped with the signature
+	Certificates []*x509.Certificate
+
+	sd signedData
+}
+
+// Parse decodes a DER encoded SignedData structure, which may or may
+// not be wrapped in a ContentInfo.
+func Parse(der []byte) (*SignedData, error) {
+	var sd signedData
+	var ci contentInfo
+	if rest, err := asn1.Unmarshal(der, &ci); err == nil && len(rest) == 0 && ci.ContentType.Equal(oidSignedData) {
+		// The explicitly tagged RawValue still contains the [0] header
+		der = ci.Content.Bytes
+	}
+	if _, err := asn1.Unmarshal(der, &sd); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+
+	p := &SignedData{
+		ContentType: sd.ContentInfo.ContentType,
+		sd:          sd,
+	}
+	if len(sd.ContentInfo.Content.Bytes) > 0 {
+		var content asn1.RawValue
+		if _, err := asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &content); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		p.Content = content.Bytes
+	}
+	if len(sd.Certificates.Raw) > 0 {
+		var set asn1.RawValue
+		if _, err := asn1.Unmarshal(sd.Certificates.Raw, &set); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		certs, err := x509.ParseCertificates(set.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		p.Certificates = certs
+	}
+	return p, nil
+}
+
+// Signers returns the certificates of all signers that are included in
+// the SignedData.
+func (p *SignedData) Signers() []*x509.Certificate {
+	var signers []*x509.Certificate
+	for _, si := range p.sd.SignerInfos {
+		if c := p.findCertificate(si.IssuerAndSerialNumber); c != nil {
+			signers = append(signers, c)
+		}
+	}
+	return signers
+}
+
+// findCertificate returns the embedded certificate identified by ias.
+func (p *SignedData) findCertificate(ias issuerAndSerial) *x509.Certificate {
+	for _, c := range p.Certificates {
+		if c.SerialNumber.Cmp(ias.Serial) == 0 && string(c.RawIssuer) == string(ias.Issuer.FullBytes) {
+			return c
+		}
+	}
+	return nil
+}
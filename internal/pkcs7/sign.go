@@ -0,0 +1,140 @@
+package pkcs7
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+)
+
+var oidContentType = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+
+// SignOptions select the encoding variants of Sign.
+type SignOptions struct {
+	// ContentInfo wraps the SignedData in a ContentInfo of type
+	// signedData, as OpenSSL does by default
+	ContentInfo bool
+	// Attached embeds the content instead of leaving it detached
+	Attached bool
+	// SignedAttributes signs the content type and message digest
+	// attributes instead of the content itself
+	SignedAttributes bool
+	// Certificates are embedded in addition to the signing certificate,
+	// e.g. the intermediates of its chain
+	Certificates []*x509.Certificate
+}
+
+// Sign returns the DER encoded SignedData structure containing a SHA-256
+// RSA signature over content made by key, encoded as selected by opts.
+// The zero SignOptions leave the content detached. The signing
+// certificate is always embedded. key may be any crypto.Signer holding
+// an RSA key, e.g. one kept in an HSM, it is asked for a PKCS #1 v1.5
+// signature of the digest.
+func Sign(content []byte, cert *x509.Certificate, key crypto.Signer, opts SignOptions) ([]byte, error) {
+	if _, ok := key.Public().(*rsa.PublicKey); !ok {
+		return nil, fmt.Errorf("unsupported key type %T, only RSA is supported", key.Public())
+	}
+	digest := sha256.Sum256(content)
+	si := signerInfo{
+		Version: 1,
+		IssuerAndSerialNumber: issuerAndSerial{
+			Issuer: asn1.RawValue{FullBytes: cert.RawIssuer},
+			Serial: cert.SerialNumber,
+		},
+		DigestAlgorithm:           pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		DigestEncryptionAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidRSAEncryption},
+	}
+	if opts.SignedAttributes {
+		set, err := signedAttributes(digest[:])
+		if err != nil {
+			return nil, err
+		}
+		// The signature covers the attributes with the universal SET
+		// tag, while they are stored with the implicit [0].
+		digest = sha256.Sum256(set)
+		si.AuthenticatedAttributes.Raw = append([]byte{0xa0}, set[1:]...)
+	}
+	sig, err := key.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+	si.EncryptedDigest = sig
+
+	raw := cert.Raw
+	for _, c := range opts.Certificates {
+		raw = append(raw[:len(raw):len(raw)], c.Raw...)
+	}
+	certs, err := asn1.Marshal(asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      raw,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sd := signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		ContentInfo:      contentInfo{ContentType: oidData},
+		Certificates:     rawCertificates{Raw: certs},
+		SignerInfos:      []signerInfo{si},
+	}
+	if opts.Attached {
+		if sd.ContentInfo.Content, err = explicit(content); err != nil {
+			return nil, err
+		}
+	}
+	der, err := asn1.Marshal(sd)
+	if err != nil || !opts.ContentInfo {
+		return der, err
+	}
+	ci := contentInfo{ContentType: oidSignedData, Content: asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      der,
+	}}
+	return asn1.Marshal(ci)
+}
+
+// explicit returns content as OCTET STRING explicitly tagged with [0].
+func explicit(content []byte) (asn1.RawValue, error) {
+	octets, err := asn1.Marshal(content)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return asn1.RawValue{
+		Class:      asn1.ClassContextSpecific,
+		Tag:        0,
+		IsCompound: true,
+		Bytes:      octets,
+	}, nil
+}
+
+// signedAttributes returns the DER encoded SET of the content type and
+// message digest attributes for digest.
+func signedAttributes(digest []byte) ([]byte, error) {
+	var attrs []attribute
+	for _, a := range []struct {
+		oid   asn1.ObjectIdentifier
+		value any
+	}{
+		{oidContentType, oidData},
+		{oidMessageDigest, digest},
+	} {
+		v, err := asn1.Marshal(a.value)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attribute{Type: a.oid, Values: []asn1.RawValue{{FullBytes: v}}})
+	}
+	// Both attributes have OIDs of the same length, so they are in the
+	// order DER requires for a SET OF.
+	return asn1.MarshalWithParams(attrs, "set")
+}
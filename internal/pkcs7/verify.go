@@ -0,0 +1,140 @@
+package pkcs7
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoSigner is returned if no signer certificate is available
+	ErrNoSigner = errors.New("signer certificate not found")
+
+	// ErrDigestMismatch is returned if the signed digest doesn't match the content
+	ErrDigestMismatch = errors.New("message digest mismatch")
+)
+
+// digestHash maps digest algorithm OIDs to hash functions.
+func digestHash(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %v", oid)
+}
+
+// Verify checks the signatures of all signers over content, or over the
+// embedded content if content is nil. Each signer has to be verifiable
+// by either an embedded certificate or one of extra. The certificates
+// of the signers are returned. Verify does not check certificate
+// chains, that is up to the caller.
+func (p *SignedData) Verify(content []byte, extra ...*x509.Certificate) ([]*x509.Certificate, error) {
+	if content == nil {
+		content = p.Content
+	}
+	if len(p.sd.SignerInfos) == 0 {
+		return nil, ErrNoSigner
+	}
+	var signers []*x509.Certificate
+	for _, si := range p.sd.SignerInfos {
+		cert := p.findCertificate(si.IssuerAndSerialNumber)
+		if cert == nil {
+			for _, c := range extra {
+				if c.SerialNumber.Cmp(si.IssuerAndSerialNumber.Serial) == 0 &&
+					bytes.Equal(c.RawIssuer, si.IssuerAndSerialNumber.Issuer.FullBytes) {
+					cert = c
+					break
+				}
+			}
+		}
+		if cert == nil {
+			return nil, ErrNoSigner
+		}
+		if err := verifySignerInfo(si, cert, content); err != nil {
+			return nil, err
+		}
+		signers = append(signers, cert)
+	}
+	return signers, nil
+}
+
+// verifySignerInfo checks the signature of a single signer.
+func verifySignerInfo(si signerInfo, cert *x509.Certificate, content []byte) error {
+	hash, err := digestHash(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return err
+	}
+	h := hash.New()
+	h.Write(content)
+	digest := h.Sum(nil)
+
+	signed := content
+	if len(si.AuthenticatedAttributes.Raw) > 0 {
+		attrs, err := parseAttributes(si.AuthenticatedAttributes.Raw)
+		if err != nil {
+			return err
+		}
+		md, ok := attrs[oidMessageDigest.String()]
+		if !ok {
+			return fmt.Errorf("%w: missing message digest attribute", ErrMalformed)
+		}
+		var signedDigest []byte
+		if _, err := asn1.Unmarshal(md, &signedDigest); err != nil {
+			return fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if !bytes.Equal(signedDigest, digest) {
+			return ErrDigestMismatch
+		}
+		// The signature covers the DER encoding of the attributes with
+		// the universal SET tag instead of the implicit [0].
+		signed = append([]byte{0x31}, si.AuthenticatedAttributes.Raw[1:]...)
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	switch pub := cert.PublicKey.(type) {
+	case *rsa.PublicKey:
+		return rsa.VerifyPKCS1v15(pub, hash, digest, si.EncryptedDigest)
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(pub, digest, si.EncryptedDigest) {
+			return errors.New("ECDSA verification failure")
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported public key type %T", cert.PublicKey)
+}
+
+// parseAttributes returns the first value of each attribute in the
+// implicitly tagged attribute set raw, keyed by its OID.
+func parseAttributes(raw []byte) (map[string][]byte, error) {
+	var set asn1.RawValue
+	if _, err := asn1.Unmarshal(raw, &set); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	attrs := make(map[string][]byte)
+	rest := set.Bytes
+	for len(rest) > 0 {
+		var a attribute
+		var err error
+		rest, err = asn1.Unmarshal(rest, &a)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+		}
+		if len(a.Values) > 0 {
+			attrs[a.Type.String()] = a.Values[0].FullBytes
+		}
+	}
+	return attrs, nil
+}
@@ -0,0 +1,266 @@
+// Package journal applies changes to several EFI variables in a way that
+// survives crashes and reboots in the middle.
+//
+// Before touching any variable, the intended changes are written to a
+// journal file together with the previous values of the variables. The
+// file is removed once all changes are applied. If it still exists on
+// the next start, Recover completes the interrupted changes or, if that
+// fails, restores the previous values.
+//
+// Authenticated variables like db can't be journaled: their previous
+// value can only be written back with a new signature, which the
+// journal has no way to create.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// DefaultPath is the location of the journal file used by the efivar
+// tool.
+const DefaultPath = "/var/lib/efivar/journal.json"
+
+// ErrRolledBack is caused by changes that couldn't be applied and were
+// reverted instead
+var ErrRolledBack = errors.New("changes were rolled back")
+
+// ErrAuthenticated is caused by changes of authenticated variables,
+// which can't be rolled back
+var ErrAuthenticated = errors.New("authenticated variables can't be journaled")
+
+// ErrPending is caused by applying changes while the journal of an
+// interrupted Apply still waits for Recover
+var ErrPending = errors.New("journal of an interrupted run is pending")
+
+// authenticated are the attributes of variables whose writes have to be
+// signed.
+const authenticated = efivarfs.AttributeAuthenticatedWriteAccess |
+	efivarfs.AttributeTimeBasedAuthenticatedWriteAccess |
+	efivarfs.AttributeEnhancedAuthenticatedAccess
+
+// Change is a single modification of a variable.
+type Change struct {
+	Desc efivarfs.VariableDescriptor
+	// Remove deletes the variable, Attributes and Data are ignored then
+	Remove     bool
+	Attributes efivarfs.VariableAttributes
+	Data       []byte
+}
+
+// entry is a Change as stored in the journal file.
+type entry struct {
+	Name       string                      `json:"name"`
+	GUID       guid.UUID                   `json:"guid"`
+	Remove     bool                        `json:"remove,omitempty"`
+	Attributes efivarfs.VariableAttributes `json:"attributes"`
+	Data       []byte                      `json:"data,omitempty"`
+	// Old is the value before the change, nil if the variable didn't
+	// exist
+	Old *value `json:"old,omitempty"`
+}
+
+// value is the content of a variable.
+type value struct {
+	Attributes efivarfs.VariableAttributes `json:"attributes"`
+	Data       []byte                      `json:"data"`
+}
+
+func (e *entry) desc() efivarfs.VariableDescriptor {
+	g := e.GUID
+	return efivarfs.VariableDescriptor{Name: e.Name, GUID: &g}
+}
+
+// Journal applies changes to the variables of a backend, recording them
+// in a file first.
+type Journal struct {
+	b    efivarfs.Backend
+	path string
+}
+
+// New returns a Journal modifying the variables of b and keeping the
+// journal at path.
+func New(b efivarfs.Backend, path string) *Journal {
+	return &Journal{b: b, path: path}
+}
+
+// Apply records changes in the journal and then applies them in order.
+// If one of them fails, the ones already applied are reverted and the
+// returned error wraps ErrRolledBack. If the process dies before Apply
+// returns, the journal is left behind for Recover. Apply fails with
+// ErrAuthenticated without changing anything if one of the variables
+// is or would become authenticated, and with ErrPending if a journal
+// left behind needs Recover first.
+func (j *Journal) Apply(changes []Change) error {
+	if _, err := os.Stat(j.path); err == nil {
+		return fmt.Errorf("%s: %w", j.path, ErrPending)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	entries := make([]entry, len(changes))
+	for i, c := range changes {
+		e := &entries[i]
+		e.Name, e.GUID = c.Desc.Name, *c.Desc.GUID
+		e.Remove, e.Attributes, e.Data = c.Remove, c.Attributes, c.Data
+		if !c.Remove && c.Attributes&authenticated != 0 {
+			return fmt.Errorf("%s: %w", c.Desc, ErrAuthenticated)
+		}
+		attrs, data, err := j.b.Get(c.Desc)
+		switch {
+		case errors.Is(err, efivarfs.ErrVarNotExist):
+		case err != nil:
+			return fmt.Errorf("reading %s: %w", c.Desc, err)
+		case attrs&authenticated != 0:
+			return fmt.Errorf("%s: %w", c.Desc, ErrAuthenticated)
+		default:
+			e.Old = &value{Attributes: attrs &^ efivarfs.AttributeAppendWrite, Data: data}
+		}
+	}
+	if err := j.write(entries); err != nil {
+		return err
+	}
+	return j.complete(entries, false)
+}
+
+// Recover finishes the changes of an interrupted Apply. Changes with the
+// append attribute are redone from the recorded previous value, all
+// others are simply applied again. If that fails, the previous values
+// are restored and the returned error wraps ErrRolledBack. Recover does
+// nothing if there is no journal.
+func (j *Journal) Recover() error {
+	buf, err := os.ReadFile(j.path)
+	switch {
+	case os.IsNotExist(err):
+		return nil
+	case err != nil:
+		return err
+	}
+	var entries []entry
+	if err := json.Unmarshal(buf, &entries); err != nil {
+		return fmt.Errorf("parsing journal %s: %v", j.path, err)
+	}
+	return j.complete(entries, true)
+}
+
+// complete applies entries, rolling back on failure, and removes the
+// journal. For recovery, appends are preceded by restoring the previous
+// value, since they may have happened already.
+func (j *Journal) complete(entries []entry, recovery bool) error {
+	for i := range entries {
+		e := &entries[i]
+		var err error
+		if recovery && !e.Remove && e.Attributes&efivarfs.AttributeAppendWrite != 0 {
+			err = j.restore(e)
+		}
+		if err == nil {
+			err = j.apply(e)
+		}
+		if err != nil {
+			err = fmt.Errorf("%s: %v", e.desc(), err)
+			// During recovery it's unknown how far the interrupted
+			// Apply got, so everything is restored.
+			n := i
+			if recovery {
+				n = len(entries) - 1
+			}
+			if rerr := j.rollback(entries[:n+1]); rerr != nil {
+				return fmt.Errorf("%v, rolling back failed as well, keeping journal: %v", err, rerr)
+			}
+			if rerr := j.remove(); rerr != nil {
+				return rerr
+			}
+			return fmt.Errorf("%v: %w", err, ErrRolledBack)
+		}
+	}
+	return j.remove()
+}
+
+// rollback restores the previous values of entries in reverse order.
+// It tries all of them and returns the first error.
+func (j *Journal) rollback(entries []entry) error {
+	var first error
+	for i := len(entries) - 1; i >= 0; i-- {
+		if err := j.restore(&entries[i]); err != nil && first == nil {
+			first = fmt.Errorf("%s: %v", entries[i].desc(), err)
+		}
+	}
+	return first
+}
+
+// apply makes the change of e.
+func (j *Journal) apply(e *entry) error {
+	if e.Remove {
+		return ignoreNotExist(j.b.Remove(e.desc()))
+	}
+	return j.b.Set(e.desc(), e.Attributes, e.Data)
+}
+
+// restore sets the variable of e to its previous value.
+func (j *Journal) restore(e *entry) error {
+	if e.Old == nil {
+		return ignoreNotExist(j.b.Remove(e.desc()))
+	}
+	return j.b.Set(e.desc(), e.Old.Attributes, e.Old.Data)
+}
+
+func ignoreNotExist(err error) error {
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil
+	}
+	return err
+}
+
+// write atomically replaces the journal with entries and makes sure it
+// is on disk.
+func (j *Journal) write(entries []entry) error {
+	buf, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(j.path), 0700); err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		return err
+	}
+	return syncDir(filepath.Dir(j.path))
+}
+
+// remove deletes the journal once all changes are done.
+func (j *Journal) remove() error {
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return syncDir(filepath.Dir(j.path))
+}
+
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,176 @@
+package journal
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/corpustest"
+)
+
+var attrs = efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+
+// failingBackend fails writes of the variable named fail.
+type failingBackend struct {
+	efivarfs.Backend
+	fail string
+}
+
+func (b failingBackend) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	if desc.Name == b.fail {
+		return efivarfs.ErrNoSpace
+	}
+	return b.Backend.Set(desc, attrs, data)
+}
+
+func TestApply(t *testing.T) {
+	b := efivarfs.Dir(corpustest.Copy(t, "ami-desktop"))
+	path := filepath.Join(t.TempDir(), "journal.json")
+	timeout := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	next := efivarfs.VariableDescriptor{Name: "BootNext", GUID: &efivarfs.GlobalVariable}
+
+	err := New(failingBackend{b, "BootNext"}, path).Apply([]Change{
+		{Desc: timeout, Attributes: attrs, Data: []byte{5, 0}},
+		{Desc: next, Attributes: attrs, Data: []byte{1, 0}},
+	})
+	if !errors.Is(err, ErrRolledBack) {
+		t.Errorf("Apply() = %v with a failing write, want ErrRolledBack", err)
+	}
+	if _, data, err := b.Get(timeout); err != nil || string(data) != "\x01\x00" {
+		t.Errorf("Timeout = %x, %v after rolling back, want 0100", data, err)
+	}
+
+	if err := New(b, path).Apply([]Change{
+		{Desc: timeout, Attributes: attrs, Data: []byte{5, 0}},
+		{Desc: next, Attributes: attrs, Data: []byte{1, 0}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, data, err := b.Get(next); err != nil || string(data) != "\x01\x00" {
+		t.Errorf("BootNext = %x, %v after Apply(), want 0100", data, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("journal left behind: %v", err)
+	}
+}
+
+func TestApplyAuthenticated(t *testing.T) {
+	b := efivarfs.Dir(corpustest.Copy(t, "ami-desktop"))
+	path := filepath.Join(t.TempDir(), "journal.json")
+	db := efivarfs.VariableDescriptor{Name: "db", GUID: &efivarfs.ImageSecurityDatabase}
+	timeout := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	for _, changes := range [][]Change{
+		{{Desc: timeout, Attributes: attrs, Data: []byte{5, 0}}, {Desc: db, Remove: true}},
+		{{Desc: db, Attributes: attrs, Data: []byte{1}}},
+		{{Desc: timeout, Attributes: attrs | efivarfs.AttributeTimeBasedAuthenticatedWriteAccess, Data: []byte{5, 0}}},
+	} {
+		if err := New(b, path).Apply(changes); !errors.Is(err, ErrAuthenticated) {
+			t.Errorf("Apply(%v) = %v, want ErrAuthenticated", changes, err)
+		}
+	}
+	if _, data, err := b.Get(timeout); err != nil || string(data) != "\x01\x00" {
+		t.Errorf("Timeout = %x, %v, want it unchanged", data, err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("journal written: %v", err)
+	}
+}
+
+// journalBackend captures the journal at path when fail is written and
+// then fails, like a crash in the middle of Apply.
+type journalBackend struct {
+	efivarfs.Backend
+	path    string
+	fail    string
+	journal []byte
+}
+
+func (b *journalBackend) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	if desc.Name == b.fail && b.journal == nil {
+		var err error
+		if b.journal, err = os.ReadFile(b.path); err != nil {
+			return err
+		}
+		return efivarfs.ErrNoSpace
+	}
+	return b.Backend.Set(desc, attrs, data)
+}
+
+// journal is the file Apply writes for the changes of TestJournalFormat.
+const journal = `[` +
+	`{"name":"Timeout","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","attributes":7,"data":"BQA=","old":{"attributes":7,"data":"AQA="}},` +
+	`{"name":"PlatformLang","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","attributes":71,"data":"LVVT"},` +
+	`{"name":"BootNext","guid":"8be4df61-93ca-11d2-aa0d-00e098032b8c","remove":true,"attributes":0}]`
+
+func TestJournalFormat(t *testing.T) {
+	dir := corpustest.Copy(t, "ami-desktop")
+	path := filepath.Join(t.TempDir(), "journal.json")
+	timeout := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	lang := efivarfs.VariableDescriptor{Name: "PlatformLang", GUID: &efivarfs.GlobalVariable}
+	next := efivarfs.VariableDescriptor{Name: "BootNext", GUID: &efivarfs.GlobalVariable}
+	b := &journalBackend{Backend: efivarfs.Dir(dir), path: path, fail: "PlatformLang"}
+	err := New(b, path).Apply([]Change{
+		{Desc: timeout, Attributes: attrs, Data: []byte{5, 0}},
+		{Desc: lang, Attributes: attrs | efivarfs.AttributeAppendWrite, Data: []byte("-US")},
+		{Desc: next, Remove: true},
+	})
+	if !errors.Is(err, ErrRolledBack) {
+		t.Fatalf("Apply() = %v, want ErrRolledBack", err)
+	}
+	if string(b.journal) != journal {
+		t.Errorf("journal = %s, want %s", b.journal, journal)
+	}
+
+	// Recover an Apply that died after appending to PlatformLang
+	if err := os.WriteFile(path, []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := efivarfs.Dir(dir).Set(lang, attrs, []byte("en-US")); err != nil {
+		t.Fatal(err)
+	}
+	if err := New(efivarfs.Dir(dir), path).Recover(); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		desc efivarfs.VariableDescriptor
+		want string
+	}{
+		{timeout, "\x05\x00"},
+		{lang, "-US"},
+	} {
+		if _, data, err := efivarfs.Dir(dir).Get(tt.desc); err != nil || string(data) != tt.want {
+			t.Errorf("%s = %q, %v after Recover(), want %q", tt.desc, data, err, tt.want)
+		}
+	}
+	if _, _, err := efivarfs.Dir(dir).Get(next); !errors.Is(err, efivarfs.ErrVarNotExist) {
+		t.Errorf("BootNext = %v after Recover(), want it removed", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("journal left behind: %v", err)
+	}
+}
+
+func TestApplyPending(t *testing.T) {
+	dir := corpustest.Copy(t, "ami-desktop")
+	path := filepath.Join(t.TempDir(), "journal.json")
+	if err := os.WriteFile(path, []byte(journal), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j := New(efivarfs.Dir(dir), path)
+	next := efivarfs.VariableDescriptor{Name: "BootNext", GUID: &efivarfs.GlobalVariable}
+	if err := j.Apply([]Change{{Desc: next, Attributes: attrs, Data: []byte{2, 0}}}); !errors.Is(err, ErrPending) {
+		t.Errorf("Apply() = %v with a pending journal, want ErrPending", err)
+	}
+	if buf, err := os.ReadFile(path); err != nil || string(buf) != journal {
+		t.Errorf("pending journal = %s, %v, want it unchanged", buf, err)
+	}
+
+	if err := j.Recover(); err != nil {
+		t.Fatal(err)
+	}
+	if err := j.Apply([]Change{{Desc: next, Attributes: attrs, Data: []byte{2, 0}}}); err != nil {
+		t.Errorf("Apply() = %v after Recover()", err)
+	}
+}
@@ -0,0 +1,80 @@
+package pretty
+
+import (
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// OptionalDataDecoder renders the optional data of load options written
+// by a particular loader or firmware. ok is false if data isn't of its
+// kind.
+type OptionalDataDecoder func(data []byte) (s string, ok bool)
+
+// optionalDataDecoders are tried in order by OptionalData.
+var optionalDataDecoders = []OptionalDataDecoder{windowsData, edk2Data, argumentsData}
+
+// RegisterOptionalData adds d to the decoders of OptionalData, which
+// tries it before the ones registered earlier and the built-in ones.
+func RegisterOptionalData(d OptionalDataDecoder) {
+	mu.Lock()
+	defer mu.Unlock()
+	optionalDataDecoders = append([]OptionalDataDecoder{d}, optionalDataDecoders...)
+}
+
+// OptionalData renders the optional data of a load option with the
+// first decoder recognizing it, or as hex. Windows Boot Manager entries
+// are shown with the BCD object they refer to and the UTF-16 arguments
+// of Linux, systemd-boot or shim entries as text.
+func OptionalData(data []byte) string {
+	mu.RLock()
+	decoders := optionalDataDecoders
+	mu.RUnlock()
+	for _, d := range decoders {
+		if s, ok := d(data); ok {
+			return s
+		}
+	}
+	return hex.EncodeToString(data)
+}
+
+// windowsData decodes the optional data of Windows Boot Manager.
+func windowsData(data []byte) (string, bool) {
+	w, err := bootmgr.ParseWindowsOptionalData(data)
+	if err != nil {
+		return "", false
+	}
+	return fmt.Sprintf("Windows Boot Manager, BCD object {%s}", w.BCDObject), true
+}
+
+// edk2AutoCreated marks the boot options EDK II based firmware like OVMF
+// creates for the devices it finds, which it removes again once they
+// are gone.
+var edk2AutoCreated = guid.MustParse("8108ac4e-9f11-4d59-850e-e21a522c59b2")
+
+// edk2Data decodes the optional data of EDK II boot options.
+func edk2Data(data []byte) (string, bool) {
+	if len(data) != 16 || efivarfs.DecodeGUID(data) != edk2AutoCreated {
+		return "", false
+	}
+	return "created by the firmware for a device found", true
+}
+
+// argumentsData decodes UTF-16 arguments. Shim takes the path of the
+// loader to chain to, e.g. \grubx64.efi, everything else is taken as
+// command line, like by the Linux EFI stub and systemd-boot.
+func argumentsData(data []byte) (string, bool) {
+	args, ok := (&bootmgr.LoadOption{OptionalData: data}).Arguments()
+	if !ok {
+		return "", false
+	}
+	if !strings.ContainsAny(args, " =") && strings.EqualFold(path.Ext(strings.ReplaceAll(args, `\`, "/")), ".efi") {
+		return fmt.Sprintf("loader %s", args), true
+	}
+	return fmt.Sprintf("arguments %q", args), true
+}
@@ -0,0 +1,151 @@
+// Package pretty renders the content of EFI variables for humans.
+//
+// Renderers are registered per variable and looked up by descriptor.
+// The package comes with renderers for the variables of the UEFI
+// specification, e.g. BootOrder as list of boot entries, SecureBoot as
+// enabled or disabled and db as the subjects of its certificates, and
+// others can be added with Register.
+package pretty
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// ErrMalformed is caused by variable data a renderer can't make sense of
+var ErrMalformed = errors.New("malformed variable data")
+
+// Renderer returns a human readable representation of the data of a
+// variable. It may span multiple lines but has no trailing newline.
+type Renderer func(attrs efivarfs.VariableAttributes, data []byte) (string, error)
+
+// key identifies a renderer. Names ending in #### stand for the numbered
+// variables with four hex digits in their place.
+type key struct {
+	name string
+	guid guid.UUID
+}
+
+var (
+	mu        sync.RWMutex
+	renderers = map[key]Renderer{}
+)
+
+// Register makes r the renderer for the variable name of vendor g,
+// replacing an existing one. A name ending in #### registers r for all
+// numbered variables with four hex digits in its place, e.g. Boot####
+// for Boot0000 to BootFFFF.
+func Register(name string, g guid.UUID, r Renderer) {
+	mu.Lock()
+	defer mu.Unlock()
+	renderers[key{name, g}] = r
+}
+
+// Lookup returns the renderer registered for desc. Renderers are
+// registered per vendor, so there is none for a desc without GUID.
+func Lookup(desc efivarfs.VariableDescriptor) (Renderer, bool) {
+	if desc.GUID == nil {
+		return nil, false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	if r, ok := renderers[key{desc.Name, *desc.GUID}]; ok {
+		return r, true
+	}
+	if _, ok := number(desc.Name); ok {
+		r, ok := renderers[key{desc.Name[:len(desc.Name)-4] + "####", *desc.GUID}]
+		return r, ok
+	}
+	return nil, false
+}
+
+// Render renders data with the renderer registered for desc. Variables
+// without one are rendered as hex dump.
+func Render(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) (string, error) {
+	r, ok := Lookup(desc)
+	if !ok {
+		return Hex(attrs, data)
+	}
+	s, err := r(attrs, data)
+	if err != nil {
+		return "", fmt.Errorf("rendering %s: %w", desc, err)
+	}
+	return s, nil
+}
+
+// Hex is the Renderer used for unknown variables, a hex dump.
+func Hex(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	s := hex.Dump(data)
+	if len(s) > 0 {
+		s = s[:len(s)-1]
+	}
+	return s, nil
+}
+
+// number returns the value of the four hex digits at the end of the
+// name of a numbered variable like Boot0001.
+func number(name string) (uint16, bool) {
+	if len(name) < 4 {
+		return 0, false
+	}
+	var n uint16
+	for _, c := range name[len(name)-4:] {
+		switch {
+		case '0' <= c && c <= '9':
+			n = n<<4 | uint16(c-'0')
+		case 'A' <= c && c <= 'F':
+			n = n<<4 | uint16(c-'A'+10)
+		default:
+			return 0, false
+		}
+	}
+	return n, true
+}
+
+// summaryLength is the length Summary shortens its result to.
+const summaryLength = 60
+
+// Summary returns a one line summary of the data of a variable, the
+// rendering of its renderer with the lines joined by "; " and shortened
+// to fit on a line. Variables without renderer or whose data the
+// renderer can't handle are summarized by the start of their data.
+func Summary(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) string {
+	var s string
+	if r, ok := Lookup(desc); ok {
+		if out, err := r(attrs, data); err == nil {
+			s = strings.Join(strings.Split(out, "\n"), "; ")
+		}
+	}
+	if s == "" {
+		s = raw(data)
+	}
+	if utf8.RuneCountInString(s) > summaryLength {
+		s = string([]rune(s)[:summaryLength-3]) + "..."
+	}
+	return s
+}
+
+// raw summarizes data without renderer, as text if it is printable and
+// as hex otherwise.
+func raw(data []byte) string {
+	text := strings.TrimRight(string(data), "\x00")
+	printable := utf8.ValidString(text)
+	for _, r := range text {
+		if !unicode.IsPrint(r) {
+			printable = false
+			break
+		}
+	}
+	if printable && text != "" {
+		return fmt.Sprintf("%q", text)
+	}
+	return hex.EncodeToString(data)
+}
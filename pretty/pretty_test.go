@@ -0,0 +1,88 @@
+package pretty
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestRender(t *testing.T) {
+	b := efivarfs.Dir("../testdata/corpus/ami-desktop")
+	for _, tt := range []struct {
+		name string
+		want string
+	}{
+		{"Boot0000", "Description: Windows Boot Manager\n" +
+			"Active: yes\n" +
+			`Device path: HD(1,GPT,c0ffee00-1234-4bcd-9ef0-123456789abc,0x800,0x32000)/File(\EFI\Microsoft\Boot\bootmgfw.efi)` + "\n" +
+			"Optional data: Windows Boot Manager, BCD object {9dea862c-5cdd-4e70-acc1-f32b344d4795}"},
+		{"Boot0001", "Description: UEFI: SanDisk, Partition 1\n" +
+			"Active: yes\n" +
+			"Device path: PciRoot(0x0)/Pci(0x14,0x0)/USB(0x4,0x0)/HD(1,MBR,0x4d2c7e1a,0x800,0x1dcd000)\n" +
+			"Optional data: 414d424f"},
+		{"BootOrder", "Boot0000, Boot0001, Boot0002"},
+		{"SecureBoot", "enabled"},
+		{"Timeout", "1 second"},
+		{"PK", "X.509: CN=Example Platform Key 2019,O=Example Corporation"},
+	} {
+		desc := efivarfs.VariableDescriptor{Name: tt.name, GUID: &efivarfs.GlobalVariable}
+		attrs, data, err := b.Get(desc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := Render(desc, attrs, data); err != nil || got != tt.want {
+			t.Errorf("Render(%s) = %q, %v, want %q", desc, got, err, tt.want)
+		}
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if _, ok := Lookup(efivarfs.VariableDescriptor{Name: "BootOrder"}); ok {
+		t.Error("Lookup() found a renderer for a descriptor without GUID")
+	}
+	if _, ok := Lookup(efivarfs.VariableDescriptor{Name: "BootFFFF", GUID: &efivarfs.GlobalVariable}); !ok {
+		t.Error("Lookup() found no renderer for BootFFFF")
+	}
+	if _, ok := Lookup(efivarfs.VariableDescriptor{Name: "Bootffff", GUID: &efivarfs.GlobalVariable}); ok {
+		t.Error("Lookup() found a renderer for Bootffff, numbers are upper case hex")
+	}
+	unknown := efivarfs.VariableDescriptor{Name: "Unknown", GUID: &efivarfs.GlobalVariable}
+	if s, err := Render(unknown, 0, []byte("abc")); err != nil || s != "00000000  61 62 63                                          |abc|" {
+		t.Errorf("Render() = %q, %v of a variable without renderer, want a hex dump", s, err)
+	}
+}
+
+func TestRenderers(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		r    Renderer
+		data []byte
+		want string
+	}{
+		{"Flags", Flags, []byte{1, 0, 0, 0, 0, 0, 0, 0}, "0x0000000000000001"},
+		{"Flags zero", Flags, make([]byte, 8), "0x0000000000000000"},
+		{"Bool invalid", Bool, []byte{2}, "invalid value 2"},
+		{"Timeout", Timeout, []byte{0xff, 0xff}, "wait for user input"},
+		{"ASCII", ASCII, []byte("en-US\x00"), "en-US"},
+	} {
+		if got, err := tt.r(0, tt.data); err != nil || got != tt.want {
+			t.Errorf("%s: got %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		name string
+		r    Renderer
+		data []byte
+	}{
+		{"Flags", Flags, []byte{1}},
+		{"Bool", Bool, nil},
+		{"LoadOption too short", LoadOption, []byte{1, 0, 0, 0}},
+		{"LoadOption unterminated", LoadOption, []byte{1, 0, 0, 0, 0, 0, 'a', 0}},
+		{"LoadOption device path beyond data", LoadOption, []byte{1, 0, 0, 0, 4, 0, 0, 0}},
+	} {
+		if got, err := tt.r(0, tt.data); !errors.Is(err, ErrMalformed) {
+			t.Errorf("%s: got %q, %v, want ErrMalformed", tt.name, got, err)
+		}
+	}
+}
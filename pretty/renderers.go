@@ -0,0 +1,180 @@
+package pretty
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/bootmgr"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/secureboot"
+)
+
+func init() {
+	g := efivarfs.GlobalVariable
+	for _, name := range []string{"BootOrder", "DriverOrder", "SysPrepOrder", "OsRecoveryOrder"} {
+		Register(name, g, order(strings.TrimSuffix(name, "Order")))
+	}
+	Register("BootNext", g, order("Boot"))
+	Register("BootCurrent", g, order("Boot"))
+	for _, name := range []string{"Boot####", "Driver####", "SysPrep####", "PlatformRecovery####"} {
+		Register(name, g, LoadOption)
+	}
+	for _, name := range []string{"SecureBoot", "SetupMode", "AuditMode", "DeployedMode", "VendorKeys"} {
+		Register(name, g, Bool)
+	}
+	Register("Timeout", g, Timeout)
+	for _, name := range []string{"Lang", "PlatformLang", "LangCodes", "PlatformLangCodes"} {
+		Register(name, g, ASCII)
+	}
+	for _, name := range []string{"OsIndications", "OsIndicationsSupported"} {
+		Register(name, g, OsIndications)
+	}
+	for _, name := range []string{"PK", "KEK", "PKDefault", "KEKDefault", "dbDefault", "dbxDefault"} {
+		Register(name, g, SignatureDatabase)
+	}
+	Register("SignatureSupport", g, SignatureSupport)
+	for _, name := range []string{"db", "dbx", "dbt", "dbr"} {
+		Register(name, efivarfs.ImageSecurityDatabase, SignatureDatabase)
+	}
+}
+
+// order returns a Renderer for lists of 16 bit option numbers like
+// BootOrder, printing them as variable names with the given prefix.
+func order(prefix string) Renderer {
+	return func(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+		if len(data)%2 != 0 {
+			return "", fmt.Errorf("odd length %d: %w", len(data), ErrMalformed)
+		}
+		names := make([]string, 0, len(data)/2)
+		for i := 0; i < len(data); i += 2 {
+			names = append(names, fmt.Sprintf("%s%04X", prefix, binary.LittleEndian.Uint16(data[i:])))
+		}
+		return strings.Join(names, ", "), nil
+	}
+}
+
+// Bool renders a single byte flag like SecureBoot as enabled or disabled.
+func Bool(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	if len(data) != 1 {
+		return "", fmt.Errorf("length %d instead of 1: %w", len(data), ErrMalformed)
+	}
+	switch data[0] {
+	case 0:
+		return "disabled", nil
+	case 1:
+		return "enabled", nil
+	}
+	return fmt.Sprintf("invalid value %d", data[0]), nil
+}
+
+// Timeout renders the 16 bit boot manager timeout in seconds.
+func Timeout(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	if len(data) != 2 {
+		return "", fmt.Errorf("length %d instead of 2: %w", len(data), ErrMalformed)
+	}
+	switch t := binary.LittleEndian.Uint16(data); t {
+	case 0xffff:
+		return "wait for user input", nil
+	case 1:
+		return "1 second", nil
+	default:
+		return fmt.Sprintf("%d seconds", t), nil
+	}
+}
+
+// ASCII renders NUL terminated ASCII strings like PlatformLang.
+func ASCII(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	return strings.TrimRight(string(data), "\x00"), nil
+}
+
+// Flags renders 64 bit bitmasks like OsIndications in hex.
+func Flags(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	if len(data) != 8 {
+		return "", fmt.Errorf("length %d instead of 8: %w", len(data), ErrMalformed)
+	}
+	return fmt.Sprintf("0x%016x", binary.LittleEndian.Uint64(data)), nil
+}
+
+// OsIndications renders OsIndications and OsIndicationsSupported with
+// the names of the set bits.
+func OsIndications(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	o, err := bootmgr.ParseOsIndications(data)
+	if err != nil {
+		return "", fmt.Errorf("length %d instead of 8: %w", len(data), ErrMalformed)
+	}
+	return fmt.Sprintf("0x%016x (%s)", uint64(o), o), nil
+}
+
+// LoadOption renders an EFI_LOAD_OPTION like Boot0001 with its
+// description, whether it is active, its device path in the text form
+// of the UEFI specification and the optional data as rendered by
+// OptionalData.
+func LoadOption(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	o, err := bootmgr.ParseLoadOption(data)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrMalformed)
+	}
+	active := "no"
+	if o.Attributes&bootmgr.LoadOptionActive != 0 {
+		active = "yes"
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Description: %s\n", o.Description)
+	fmt.Fprintf(&b, "Active: %s\n", active)
+	fmt.Fprintf(&b, "Device path: %s", o.FilePath)
+	if len(o.OptionalData) > 0 {
+		fmt.Fprintf(&b, "\nOptional data: %s", OptionalData(o.OptionalData))
+	}
+	return b.String(), nil
+}
+
+// SignatureSupport renders the signature types listed in
+// SignatureSupport by name.
+func SignatureSupport(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	types, err := secureboot.ParseSignatureTypes(data)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrMalformed)
+	}
+	return types.String(), nil
+}
+
+// SignatureDatabase renders signature databases like db by listing the
+// subjects of their certificates and their hashes.
+func SignatureDatabase(_ efivarfs.VariableAttributes, data []byte) (string, error) {
+	db, err := secureboot.ParseSignatureDatabase(data)
+	if err != nil {
+		return "", fmt.Errorf("%v: %w", err, ErrMalformed)
+	}
+	hashTypes := map[guid.UUID]string{
+		secureboot.CertSHA1GUID:       "SHA-1",
+		secureboot.CertSHA256GUID:     "SHA-256",
+		secureboot.CertSHA384GUID:     "SHA-384",
+		secureboot.CertSHA512GUID:     "SHA-512",
+		secureboot.CertX509SHA256GUID: "X.509 SHA-256",
+	}
+	var lines []string
+	for _, l := range db {
+		for _, s := range l.Signatures {
+			switch typ, isHash := hashTypes[l.Type]; {
+			case l.Type == secureboot.CertX509GUID:
+				cert, err := secureboot.ParseCertificates(s.Data)
+				if err != nil || len(cert) != 1 {
+					lines = append(lines, "X.509: unparsable certificate")
+					continue
+				}
+				lines = append(lines, "X.509: "+cert[0].Subject.String())
+			case isHash:
+				lines = append(lines, typ+": "+hex.EncodeToString(s.Data))
+			default:
+				lines = append(lines, fmt.Sprintf("%s: %d bytes", l.Type, len(s.Data)))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return "empty", nil
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,70 @@
+package pretty
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// bytesPerLine is the number of bytes per line of C and Go source.
+const bytesPerLine = 12
+
+// C renders a variable as C header declaring its data as byte array
+// and its attributes as macro, both named after the variable, for
+// embedding known-good payloads into firmware test fixtures.
+func C(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) string {
+	id := identifier(desc.Name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "/* %s */\n", desc)
+	fmt.Fprintf(&b, "#define %s_ATTRIBUTES 0x%08x /* %s */\n", strings.ToUpper(id), uint32(attrs), attrs)
+	fmt.Fprintf(&b, "static const unsigned char %s[%d] = {\n", id, len(data))
+	byteLines(&b, data)
+	b.WriteString("};\n")
+	return b.String()
+}
+
+// Go renders a variable as Go source declaring its data as []byte
+// literal and its attributes as efivarfs.VariableAttributes, the
+// counterpart of C for tests written in Go.
+func Go(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) string {
+	id := identifier(desc.Name)
+	var b strings.Builder
+	fmt.Fprintf(&b, "// %s\n", desc)
+	fmt.Fprintf(&b, "var %sAttributes = efivarfs.VariableAttributes(%#x) // %s\n\n", id, uint32(attrs), attrs)
+	fmt.Fprintf(&b, "var %s = []byte{\n", id)
+	byteLines(&b, data)
+	b.WriteString("}\n")
+	return b.String()
+}
+
+// byteLines writes data as indented lines of comma separated hex bytes.
+func byteLines(b *strings.Builder, data []byte) {
+	for len(data) > 0 {
+		line := data[:min(bytesPerLine, len(data))]
+		data = data[len(line):]
+		b.WriteByte('\t')
+		for i, c := range line {
+			if i > 0 {
+				b.WriteByte(' ')
+			}
+			fmt.Fprintf(b, "0x%02x,", c)
+		}
+		b.WriteByte('\n')
+	}
+}
+
+// identifier turns a variable name into an identifier valid in C and Go
+// by replacing all but ASCII letters, digits and underscores.
+func identifier(name string) string {
+	id := []byte(name)
+	for i, c := range id {
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9') {
+			id[i] = '_'
+		}
+	}
+	if len(id) == 0 || '0' <= id[0] && id[0] <= '9' {
+		id = append([]byte{'_'}, id...)
+	}
+	return string(id)
+}
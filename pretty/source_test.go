@@ -0,0 +1,77 @@
+package pretty
+
+import (
+	"bytes"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strconv"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestSource(t *testing.T) {
+	desc := efivarfs.VariableDescriptor{Name: "Boot-0001", GUID: &efivarfs.GlobalVariable}
+	attrs := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+	data := []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 0xfe, 0xff}
+
+	wantC := `/* Boot-0001-8be4df61-93ca-11d2-aa0d-00e098032b8c */
+#define BOOT_0001_ATTRIBUTES 0x00000007 /* NV|BS|RT */
+static const unsigned char Boot_0001[14] = {
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+	0xfe, 0xff,
+};
+`
+	if got := C(desc, attrs, data); got != wantC {
+		t.Errorf("C() = %s, want %s", got, wantC)
+	}
+
+	wantGo := `// Boot-0001-8be4df61-93ca-11d2-aa0d-00e098032b8c
+var Boot_0001Attributes = efivarfs.VariableAttributes(0x7) // NV|BS|RT
+
+var Boot_0001 = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b,
+	0xfe, 0xff,
+}
+`
+	if got := Go(desc, attrs, data); got != wantGo {
+		t.Errorf("Go() = %s, want %s", got, wantGo)
+	}
+
+	if got := parseGo(t, Go(desc, attrs, data)); !bytes.Equal(got, data) {
+		t.Errorf("Go() declares %x, want %x", got, data)
+	}
+
+	for name, want := range map[string]string{"": "_", "1st": "_1st", "a.b c": "a_b_c"} {
+		if got := identifier(name); got != want {
+			t.Errorf("identifier(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+// parseGo returns the bytes of the []byte literal declared by src, the
+// output of Go.
+func parseGo(t *testing.T, src string) []byte {
+	t.Helper()
+	f, err := parser.ParseFile(token.NewFileSet(), "", "package p\n"+src, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b []byte
+	ast.Inspect(f, func(n ast.Node) bool {
+		lit, ok := n.(*ast.CompositeLit)
+		if !ok {
+			return true
+		}
+		for _, e := range lit.Elts {
+			v, err := strconv.ParseUint(e.(*ast.BasicLit).Value, 0, 8)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b = append(b, byte(v))
+		}
+		return false
+	})
+	return b
+}
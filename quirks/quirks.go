@@ -0,0 +1,148 @@
+// Package quirks adjusts the access to EFI variables for firmware with
+// known bugs, e.g. boards that stop booting once the variable store is
+// full. Quirks are matched against the DMI vendor and product of the
+// running system and applied by wrapping a Backend.
+package quirks
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/sysinfo"
+)
+
+// ErrInsufficientSpace is caused by writes that would leave less free
+// variable storage than a quirk requires.
+var ErrInsufficientSpace = errors.New("write would leave too little free variable storage")
+
+// Quirk describes a firmware bug and how to work around it.
+type Quirk struct {
+	// Name identifies the quirk in logs and diagnostics
+	Name string
+	// Vendor and Product are path.Match patterns matched against the
+	// system or board vendor and the product or board name, an empty
+	// pattern matches everything
+	Vendor, Product string
+
+	// MinFreeSpace refuses writes that would leave less than this many
+	// bytes of variable storage free, as reported by the backend
+	MinFreeSpace int64
+	// Attributes, if set, returns the attributes to write desc with
+	// instead of attrs, for firmware accepting only certain ones
+	Attributes func(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes) efivarfs.VariableAttributes
+}
+
+// Matches reports whether q applies to the system identified by info.
+func (q *Quirk) Matches(info sysinfo.Info) bool {
+	return (match(q.Vendor, info.SysVendor) || match(q.Vendor, info.BoardVendor)) &&
+		(match(q.Product, info.ProductName) || match(q.Product, info.BoardName))
+}
+
+func match(pattern, s string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+var (
+	mu       sync.Mutex
+	registry = []Quirk{
+		{
+			// Some Samsung laptops no longer boot once their
+			// variable store runs full. Like the kernel unless
+			// booted with efi_no_storage_paranoia, writes have to
+			// leave at least 5 KiB free.
+			Name:         "samsung-nvram-full",
+			Vendor:       "SAMSUNG ELECTRONICS CO., LTD.",
+			MinFreeSpace: 5 << 10,
+		},
+	}
+)
+
+// Register adds q to the known quirks, e.g. for boards of a fleet
+// found to need a workaround.
+func Register(q Quirk) {
+	mu.Lock()
+	defer mu.Unlock()
+	registry = append(registry, q)
+}
+
+// Match returns the registered quirks applying to the system identified
+// by info.
+func Match(info sysinfo.Info) []Quirk {
+	mu.Lock()
+	defer mu.Unlock()
+	var qs []Quirk
+	for _, q := range registry {
+		if q.Matches(info) {
+			qs = append(qs, q)
+		}
+	}
+	return qs
+}
+
+// Detect returns the registered quirks applying to the running system.
+func Detect() ([]Quirk, error) {
+	info, err := sysinfo.Read()
+	if err != nil {
+		return nil, err
+	}
+	return Match(info), nil
+}
+
+// Apply returns a Backend passing all operations to b with the
+// workarounds of qs applied. b is returned as is if qs is empty.
+func Apply(b efivarfs.Backend, qs []Quirk) efivarfs.Backend {
+	if len(qs) == 0 {
+		return b
+	}
+	return &backend{Backend: b, quirks: qs}
+}
+
+// backend is a Backend decorator applying quirks.
+type backend struct {
+	efivarfs.Backend
+	quirks []Quirk
+}
+
+func (b *backend) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	for _, q := range b.quirks {
+		if q.Attributes != nil {
+			attrs = q.Attributes(desc, attrs)
+		}
+		if q.MinFreeSpace > 0 {
+			if err := b.checkSpace(q, int64(len(data))); err != nil {
+				return err
+			}
+		}
+	}
+	return b.Backend.Set(desc, attrs, data)
+}
+
+// Capabilities returns the capabilities of the wrapped backend.
+func (b *backend) Capabilities() efivarfs.Capabilities {
+	switch c := b.Backend.(type) {
+	case *efivarfs.Client:
+		caps, _ := c.Capabilities()
+		return caps
+	case efivarfs.CapabilityReporter:
+		return c.Capabilities()
+	}
+	return efivarfs.Capabilities{Write: true, Delete: true, Append: true}
+}
+
+// checkSpace returns ErrInsufficientSpace if writing size bytes would
+// leave less than q.MinFreeSpace free. Backends not reporting their free
+// space pass.
+func (b *backend) checkSpace(q Quirk, size int64) error {
+	free := b.Capabilities().MaxVariableSize
+	if free > 0 && free-size < q.MinFreeSpace {
+		return fmt.Errorf("%s: writing %d bytes with %d bytes free: %w", q.Name, size, free, ErrInsufficientSpace)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package quirks
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/sysinfo"
+)
+
+// small is a backend reporting little free space.
+type small struct {
+	efivarfs.Backend
+}
+
+func (small) Capabilities() efivarfs.Capabilities {
+	return efivarfs.Capabilities{Write: true, MaxVariableSize: 8 << 10}
+}
+
+func TestQuirks(t *testing.T) {
+	sysDMI := sysinfo.SysDMI
+	mu.Lock()
+	saved := registry
+	mu.Unlock()
+	t.Cleanup(func() {
+		sysinfo.SysDMI = sysDMI
+		mu.Lock()
+		registry = saved
+		mu.Unlock()
+	})
+
+	sysinfo.SysDMI = t.TempDir()
+	for name, value := range map[string]string{
+		"sys_vendor":   "SAMSUNG ELECTRONICS CO., LTD.\n",
+		"product_name": "900X3C\n",
+	} {
+		if err := os.WriteFile(filepath.Join(sysinfo.SysDMI, name), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	Register(Quirk{
+		Name:    "attributes",
+		Vendor:  "SAMSUNG*",
+		Product: "900X*",
+		Attributes: func(_ efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes) efivarfs.VariableAttributes {
+			return attrs | efivarfs.AttributeRuntimeAccess
+		},
+	})
+	Register(Quirk{Name: "other", Product: "NP300*"})
+	qs, err := Detect()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(qs) != 2 || qs[0].Name != "samsung-nvram-full" || qs[1].Name != "attributes" {
+		t.Fatalf("Detect() = %v", qs)
+	}
+
+	b := Apply(small{efivarfs.Dir(t.TempDir())}, qs)
+	desc := efivarfs.VariableDescriptor{Name: "Test", GUID: &efivarfs.GlobalVariable}
+	attrs := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess
+	if err := b.Set(desc, attrs, make([]byte, 1<<10)); err != nil {
+		t.Fatal(err)
+	}
+	if got, _, err := b.Get(desc); err != nil || got&efivarfs.AttributeRuntimeAccess == 0 {
+		t.Errorf("Get() = %v, %v, want runtime access added", got, err)
+	}
+	if err := b.Set(desc, attrs, make([]byte, 4<<10)); !errors.Is(err, ErrInsufficientSpace) {
+		t.Errorf("Set() = %v, want ErrInsufficientSpace", err)
+	}
+}
@@ -0,0 +1,125 @@
+package remote
+
+import (
+	"context"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultTimeout limits the duration of each call of a Client. Writes
+// to NVRAM can be slow, so it is rather generous.
+const DefaultTimeout = 30 * time.Second
+
+// Client is an efivarfs.Backend operating on the variables of a remote
+// machine running the EFIVars service.
+type Client struct {
+	conn *grpc.ClientConn
+	// Timeout limits the duration of each call, zero disables it
+	Timeout time.Duration
+}
+
+// Dial connects to the EFIVars service at target. The options are passed
+// to grpc.Dial and have to include the transport credentials.
+func Dial(target string, opts ...grpc.DialOption) (*Client, error) {
+	conn, err := grpc.Dial(target, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return NewClient(conn), nil
+}
+
+// NewClient returns a Client using an existing connection.
+func NewClient(conn *grpc.ClientConn) *Client {
+	return &Client{conn: conn, Timeout: DefaultTimeout}
+}
+
+// Close tears down the connection of the client.
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// invoke calls method on the service and maps the status back to the
+// errors of the efivarfs package.
+func (c *Client) invoke(method string, req, resp message) error {
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
+	var trailer metadata.MD
+	err := c.conn.Invoke(ctx, "/"+serviceName+"/"+method, req, resp, grpc.ForceCodec(codec{}), grpc.Trailer(&trailer))
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	if kind := trailer.Get(errorKey); len(kind) > 0 {
+		for _, e := range errorCodes {
+			if kind[0] == e.kind {
+				return &remoteError{msg: st.Message(), err: e.err}
+			}
+		}
+	}
+	// Older servers don't send the kind, fall back to the first error
+	// with the same code
+	for _, e := range errorCodes {
+		if st.Code() == e.code {
+			return &remoteError{msg: st.Message(), err: e.err}
+		}
+	}
+	return &remoteError{msg: st.Message()}
+}
+
+// remoteError carries the message of the server while still matching the
+// corresponding error of the efivarfs package with errors.Is.
+type remoteError struct {
+	msg string
+	err error
+}
+
+func (e *remoteError) Error() string { return "remote: " + e.msg }
+func (e *remoteError) Unwrap() error { return e.err }
+
+// Get reads a variable of the remote machine.
+func (c *Client) Get(desc efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	resp := &getResponse{}
+	if err := c.invoke("Get", &getRequest{Desc: newDescriptor(desc)}, resp); err != nil {
+		return 0, nil, err
+	}
+	return efivarfs.VariableAttributes(resp.Attributes), resp.Data, nil
+}
+
+// Set writes a variable of the remote machine.
+func (c *Client) Set(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte) error {
+	req := &setRequest{Desc: newDescriptor(desc), Attributes: uint32(attrs), Data: data}
+	return c.invoke("Set", req, &empty{})
+}
+
+// Remove deletes a variable of the remote machine.
+func (c *Client) Remove(desc efivarfs.VariableDescriptor) error {
+	return c.invoke("Remove", &removeRequest{Desc: newDescriptor(desc)}, &empty{})
+}
+
+// List returns the descriptors of all variables of the remote machine.
+func (c *Client) List() ([]efivarfs.VariableDescriptor, error) {
+	resp := &listResponse{}
+	if err := c.invoke("List", &empty{}, resp); err != nil {
+		return nil, err
+	}
+	var list []efivarfs.VariableDescriptor
+	for _, d := range resp.Descriptors {
+		desc, err := d.toVariable()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, desc)
+	}
+	return list, nil
+}
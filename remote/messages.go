@@ -0,0 +1,279 @@
+package remote
+
+import (
+	"fmt"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// message is implemented by all messages of efivar.proto.
+type message interface {
+	marshal() []byte
+	unmarshal(b []byte) error
+}
+
+// codec encodes messages in the protobuf wire format. It is forced on
+// the server and the calls of the client only, so the global proto
+// codec of grpc stays untouched.
+type codec struct{}
+
+func (codec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(message)
+	if !ok {
+		return nil, fmt.Errorf("remote: cannot marshal %T", v)
+	}
+	return m.marshal(), nil
+}
+
+func (codec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(message)
+	if !ok {
+		return fmt.Errorf("remote: cannot unmarshal into %T", v)
+	}
+	return m.unmarshal(data)
+}
+
+// Name returns "proto" as the messages are wire compatible with
+// clients generated from efivar.proto.
+func (codec) Name() string {
+	return "proto"
+}
+
+// field is a decoded field of a protobuf message.
+type field struct {
+	num   protowire.Number
+	bytes []byte
+	value uint64
+}
+
+// fields splits b into its fields. Unknown wire types are rejected.
+func fields(b []byte) ([]field, error) {
+	var out []field
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := field{num: num}
+		switch typ {
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		b = b[n:]
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func appendBytes(b []byte, num protowire.Number, v []byte) []byte {
+	if len(v) == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func appendVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+type descriptor struct {
+	Name string
+	GUID string
+}
+
+func newDescriptor(d efivarfs.VariableDescriptor) *descriptor {
+	return &descriptor{Name: d.Name, GUID: d.GUID.String()}
+}
+
+func (d *descriptor) toVariable() (efivarfs.VariableDescriptor, error) {
+	if d == nil {
+		return efivarfs.VariableDescriptor{}, fmt.Errorf("missing descriptor")
+	}
+	g, err := guid.Parse(d.GUID)
+	if err != nil {
+		return efivarfs.VariableDescriptor{}, err
+	}
+	return efivarfs.VariableDescriptor{Name: d.Name, GUID: &g}, nil
+}
+
+func (d *descriptor) marshal() []byte {
+	b := appendBytes(nil, 1, []byte(d.Name))
+	return appendBytes(b, 2, []byte(d.GUID))
+}
+
+func (d *descriptor) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			d.Name = string(f.bytes)
+		case 2:
+			d.GUID = string(f.bytes)
+		}
+	}
+	return nil
+}
+
+// unmarshalDescriptor decodes an embedded Descriptor message.
+func unmarshalDescriptor(b []byte) (*descriptor, error) {
+	d := &descriptor{}
+	return d, d.unmarshal(b)
+}
+
+type getRequest struct {
+	Desc *descriptor
+}
+
+func (m *getRequest) marshal() []byte {
+	return appendBytes(nil, 1, m.Desc.marshal())
+}
+
+func (m *getRequest) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.num == 1 {
+			if m.Desc, err = unmarshalDescriptor(f.bytes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+type getResponse struct {
+	Attributes uint32
+	Data       []byte
+}
+
+func (m *getResponse) marshal() []byte {
+	b := appendVarint(nil, 1, uint64(m.Attributes))
+	return appendBytes(b, 2, m.Data)
+}
+
+func (m *getResponse) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			m.Attributes = uint32(f.value)
+		case 2:
+			m.Data = f.bytes
+		}
+	}
+	return nil
+}
+
+type setRequest struct {
+	Desc       *descriptor
+	Attributes uint32
+	Data       []byte
+}
+
+func (m *setRequest) marshal() []byte {
+	b := appendBytes(nil, 1, m.Desc.marshal())
+	b = appendVarint(b, 2, uint64(m.Attributes))
+	return appendBytes(b, 3, m.Data)
+}
+
+func (m *setRequest) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		switch f.num {
+		case 1:
+			if m.Desc, err = unmarshalDescriptor(f.bytes); err != nil {
+				return err
+			}
+		case 2:
+			m.Attributes = uint32(f.value)
+		case 3:
+			m.Data = f.bytes
+		}
+	}
+	return nil
+}
+
+type removeRequest struct {
+	Desc *descriptor
+}
+
+func (m *removeRequest) marshal() []byte {
+	return appendBytes(nil, 1, m.Desc.marshal())
+}
+
+func (m *removeRequest) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.num == 1 {
+			if m.Desc, err = unmarshalDescriptor(f.bytes); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// empty is used for SetResponse, RemoveResponse and ListRequest.
+type empty struct{}
+
+func (*empty) marshal() []byte          { return nil }
+func (*empty) unmarshal(b []byte) error { _, err := fields(b); return err }
+
+type listResponse struct {
+	Descriptors []*descriptor
+}
+
+func (m *listResponse) marshal() []byte {
+	var b []byte
+	for _, d := range m.Descriptors {
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, d.marshal())
+	}
+	return b
+}
+
+func (m *listResponse) unmarshal(b []byte) error {
+	fs, err := fields(b)
+	if err != nil {
+		return err
+	}
+	for _, f := range fs {
+		if f.num == 1 {
+			d, err := unmarshalDescriptor(f.bytes)
+			if err != nil {
+				return err
+			}
+			m.Descriptors = append(m.Descriptors, d)
+		}
+	}
+	return nil
+}
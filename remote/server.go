@@ -0,0 +1,190 @@
+// Package remote exposes an efivarfs.Backend over gRPC and provides a
+// Backend talking to such a service, so EFI variables of lab machines
+// can be managed remotely. Only the server needs privileges to access
+// the variables, which makes it a clean trust boundary. Servers are
+// read-only unless created with NewWritableServer.
+package remote
+
+import (
+	"context"
+	"errors"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const serviceName = "efivar.v1.EFIVars"
+
+// errorKey is the trailer carrying the kind of error, as several errors
+// share a status code.
+const errorKey = "efivar-error"
+
+// server implements the EFIVars service on top of a Backend.
+type server struct {
+	b efivarfs.Backend
+}
+
+// NewServer returns a gRPC server serving the variables of b read-only,
+// Set and Remove fail with efivarfs.ErrReadOnlyBackend. Further
+// options, e.g. TLS credentials, are passed to grpc.NewServer.
+func NewServer(b efivarfs.Backend, opts ...grpc.ServerOption) *grpc.Server {
+	return NewWritableServer(efivarfs.ReadOnly(b), opts...)
+}
+
+// NewWritableServer is like NewServer but lets clients modify the
+// variables of b. The service doesn't authenticate clients itself, so
+// opts have to include credentials doing so, e.g. TLS with client
+// certificates.
+func NewWritableServer(b efivarfs.Backend, opts ...grpc.ServerOption) *grpc.Server {
+	s := grpc.NewServer(append(opts, grpc.ForceServerCodec(codec{}))...)
+	s.RegisterService(&serviceDesc, &server{b: b})
+	return s
+}
+
+// errorCodes maps the errors of the efivarfs package to gRPC status codes.
+// The kind is sent in the errorKey trailer to map them back on the
+// client side.
+var errorCodes = []struct {
+	err  error
+	code codes.Code
+	kind string
+}{
+	{efivarfs.ErrVarNotExist, codes.NotFound, "not-exist"},
+	{efivarfs.ErrVarPermission, codes.PermissionDenied, "permission"},
+	{efivarfs.ErrReadOnlyBackend, codes.FailedPrecondition, "read-only-backend"},
+	{efivarfs.ErrReadOnlyFilesystem, codes.FailedPrecondition, "read-only-filesystem"},
+	{efivarfs.ErrReadOnlyVariable, codes.PermissionDenied, "read-only-variable"},
+	{efivarfs.ErrSetVariableUnsupported, codes.Unimplemented, "set-variable-unsupported"},
+	{efivarfs.ErrPolicyDenied, codes.PermissionDenied, "policy-denied"},
+	{efivarfs.ErrNoSpace, codes.ResourceExhausted, "no-space"},
+	{efivarfs.ErrFsNotMounted, codes.Unavailable, "not-mounted"},
+	{efivarfs.ErrVarsUnavailable, codes.Unavailable, "unavailable"},
+}
+
+// toStatus converts a backend error into a gRPC status error and sets
+// the errorKey trailer of ctx.
+func toStatus(ctx context.Context, err error) error {
+	if err == nil {
+		return nil
+	}
+	for _, e := range errorCodes {
+		if errors.Is(err, e.err) {
+			grpc.SetTrailer(ctx, metadata.Pairs(errorKey, e.kind))
+			return status.Error(e.code, err.Error())
+		}
+	}
+	return status.Error(codes.Unknown, err.Error())
+}
+
+func (s *server) get(ctx context.Context, req *getRequest) (*getResponse, error) {
+	desc, err := req.Desc.toVariable()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	attrs, data, err := s.b.Get(desc)
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	return &getResponse{Attributes: uint32(attrs), Data: data}, nil
+}
+
+func (s *server) set(ctx context.Context, req *setRequest) (*empty, error) {
+	desc, err := req.Desc.toVariable()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &empty{}, toStatus(ctx, s.b.Set(desc, efivarfs.VariableAttributes(req.Attributes), req.Data))
+}
+
+func (s *server) remove(ctx context.Context, req *removeRequest) (*empty, error) {
+	desc, err := req.Desc.toVariable()
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return &empty{}, toStatus(ctx, s.b.Remove(desc))
+}
+
+func (s *server) list(ctx context.Context, _ *empty) (*listResponse, error) {
+	list, err := s.b.List()
+	if err != nil {
+		return nil, toStatus(ctx, err)
+	}
+	resp := &listResponse{}
+	for _, d := range list {
+		resp.Descriptors = append(resp.Descriptors, newDescriptor(d))
+	}
+	return resp, nil
+}
+
+// The handlers below follow what protoc-gen-go-grpc generates.
+
+func getHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &getRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*server).get(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Get"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*server).get(ctx, req.(*getRequest))
+	})
+}
+
+func setHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &setRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*server).set(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Set"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*server).set(ctx, req.(*setRequest))
+	})
+}
+
+func removeHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &removeRequest{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*server).remove(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/Remove"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*server).remove(ctx, req.(*removeRequest))
+	})
+}
+
+func listHandler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	req := &empty{}
+	if err := dec(req); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(*server).list(ctx, req)
+	}
+	info := &grpc.UnaryServerInfo{Server: srv, FullMethod: "/" + serviceName + "/List"}
+	return interceptor(ctx, req, info, func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(*server).list(ctx, req.(*empty))
+	})
+}
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{
+		{MethodName: "Get", Handler: getHandler},
+		{MethodName: "Set", Handler: setHandler},
+		{MethodName: "Remove", Handler: removeHandler},
+		{MethodName: "List", Handler: listHandler},
+	},
+	Metadata: "efivar.proto",
+}
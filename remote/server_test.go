@@ -0,0 +1,100 @@
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// testClient returns a Client connected to s over an in-memory listener.
+func testClient(t *testing.T, s *grpc.Server) *Client {
+	t.Helper()
+	l := bufconn.Listen(1 << 16)
+	go s.Serve(l)
+	t.Cleanup(s.Stop)
+	c, err := Dial("bufconn",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return l.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c
+}
+
+func TestServer(t *testing.T) {
+	b := efivarfs.Dir(t.TempDir())
+	desc := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	attrs := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+	if err := b.Set(desc, attrs, []byte{5, 0}); err != nil {
+		t.Fatal(err)
+	}
+
+	c := testClient(t, NewServer(b))
+	if a, data, err := c.Get(desc); err != nil || a != attrs || string(data) != "\x05\x00" {
+		t.Errorf("Get() = %s, %x, %v", a, data, err)
+	}
+	if err := c.Set(desc, attrs, []byte{1, 0}); !errors.Is(err, efivarfs.ErrReadOnlyBackend) {
+		t.Errorf("Set() = %v on a read-only server, want ErrReadOnlyBackend", err)
+	}
+	if err := c.Remove(desc); !errors.Is(err, efivarfs.ErrReadOnlyBackend) {
+		t.Errorf("Remove() = %v on a read-only server, want ErrReadOnlyBackend", err)
+	}
+
+	c = testClient(t, NewWritableServer(b))
+	if err := c.Set(desc, attrs, []byte{1, 0}); err != nil {
+		t.Errorf("Set() = %v on a writable server", err)
+	}
+	if _, data, err := b.Get(desc); err != nil || string(data) != "\x01\x00" {
+		t.Errorf("Timeout = %x, %v after Set()", data, err)
+	}
+	if err := c.Remove(desc); err != nil {
+		t.Errorf("Remove() = %v on a writable server", err)
+	}
+	if _, err := c.List(); err != nil {
+		t.Errorf("List() = %v", err)
+	}
+}
+
+// failingBackend fails every operation with err.
+type failingBackend struct {
+	err error
+}
+
+func (b failingBackend) Get(efivarfs.VariableDescriptor) (efivarfs.VariableAttributes, []byte, error) {
+	return 0, nil, b.err
+}
+
+func (b failingBackend) Set(efivarfs.VariableDescriptor, efivarfs.VariableAttributes, []byte) error {
+	return b.err
+}
+
+func (b failingBackend) Remove(efivarfs.VariableDescriptor) error {
+	return b.err
+}
+
+func (b failingBackend) List() ([]efivarfs.VariableDescriptor, error) {
+	return nil, b.err
+}
+
+func TestServerErrors(t *testing.T) {
+	desc := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	for _, e := range errorCodes {
+		t.Run(e.kind, func(t *testing.T) {
+			c := testClient(t, NewWritableServer(failingBackend{fmt.Errorf("Timeout: %w", e.err)}))
+			err := c.Set(desc, efivarfs.AttributeNonVolatile, []byte{1})
+			for _, o := range errorCodes {
+				if errors.Is(err, o.err) != (o.err == e.err) {
+					t.Errorf("errors.Is(%v, %v) = %v", err, o.err, !(o.err == e.err))
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+// Package rest exposes an efivarfs.Backend as a small authenticated
+// REST API with JSON bodies:
+//
+//	GET    /v1/variables            list all variables
+//	GET    /v1/variables/Name-GUID  read a variable
+//	PUT    /v1/variables/Name-GUID  write a variable
+//	DELETE /v1/variables/Name-GUID  delete a variable
+//
+// Every request has to carry the token in an "Authorization: Bearer"
+// header, so the handler is to be served over TLS unless it only
+// listens on a loopback address.
+package rest
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+const prefix = "/v1/variables"
+
+// Descriptor identifies a variable in requests and responses.
+type Descriptor struct {
+	Name string `json:"name"`
+	GUID string `json:"guid"`
+}
+
+// Variable is the body of read responses and write requests. Data is
+// base64 encoded in JSON.
+type Variable struct {
+	Descriptor
+	Attributes efivarfs.VariableAttributes `json:"attributes"`
+	Data       []byte                      `json:"data"`
+}
+
+// Error is the body of all failed requests.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// maxBody is the largest request body accepted. Variables are limited
+// in size by the firmware anyway.
+const maxBody = 1 << 20
+
+// handler serves the API.
+type handler struct {
+	b     efivarfs.Backend
+	token []byte
+}
+
+// NewHandler returns an http.Handler serving the variables of b to
+// clients presenting token. An empty token is rejected, as an
+// unauthenticated API would give everyone on the network control over
+// the boot configuration.
+func NewHandler(b efivarfs.Backend, token string) (http.Handler, error) {
+	if token == "" {
+		return nil, errors.New("an authentication token is required")
+	}
+	return &handler{b: b, token: []byte(token)}, nil
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.authorized(r) {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="efivar"`)
+		writeError(w, http.StatusUnauthorized, errors.New("unauthorized"))
+		return
+	}
+
+	if r.URL.Path == prefix || r.URL.Path == prefix+"/" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+			return
+		}
+		h.list(w)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, prefix+"/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	desc, err := efivarfs.ParseFileName(strings.TrimPrefix(r.URL.Path, prefix+"/"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		h.get(w, desc)
+	case http.MethodPut:
+		h.set(w, r, desc)
+	case http.MethodDelete:
+		h.remove(w, desc)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("method %s not allowed", r.Method))
+	}
+}
+
+// authorized checks the bearer token in constant time.
+func (h *handler) authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(auth, "Bearer ")), h.token) == 1
+}
+
+func (h *handler) list(w http.ResponseWriter) {
+	list, err := h.b.List()
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	out := []Descriptor{}
+	for _, d := range list {
+		out = append(out, Descriptor{Name: d.Name, GUID: d.GUID.String()})
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (h *handler) get(w http.ResponseWriter, desc efivarfs.VariableDescriptor) {
+	attrs, data, err := h.b.Get(desc)
+	if err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, Variable{
+		Descriptor: Descriptor{Name: desc.Name, GUID: desc.GUID.String()},
+		Attributes: attrs,
+		Data:       data,
+	})
+}
+
+func (h *handler) set(w http.ResponseWriter, r *http.Request, desc efivarfs.VariableDescriptor) {
+	var v Variable
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBody))
+	if err := dec.Decode(&v); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, err)
+			return
+		}
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := h.b.Set(desc, v.Attributes, v.Data); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) remove(w http.ResponseWriter, desc efivarfs.VariableDescriptor) {
+	if err := h.b.Remove(desc); err != nil {
+		writeBackendError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeBackendError maps the errors of the efivarfs package to status codes.
+func writeBackendError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, efivarfs.ErrVarNotExist):
+		code = http.StatusNotFound
+	case errors.Is(err, efivarfs.ErrVarPermission), errors.Is(err, efivarfs.ErrReadOnlyBackend),
+		errors.Is(err, efivarfs.ErrReadOnlyFilesystem), errors.Is(err, efivarfs.ErrPolicyDenied),
+		errors.Is(err, efivarfs.ErrReadOnlyVariable):
+		code = http.StatusForbidden
+	case errors.Is(err, efivarfs.ErrSetVariableUnsupported):
+		code = http.StatusNotImplemented
+	case errors.Is(err, efivarfs.ErrNoSpace):
+		code = http.StatusInsufficientStorage
+	case errors.Is(err, efivarfs.ErrInvalidAttributes), errors.Is(err, efivarfs.ErrInvalidName):
+		code = http.StatusBadRequest
+	case errors.Is(err, efivarfs.ErrVariableTooLarge):
+		code = http.StatusRequestEntityTooLarge
+	case errors.Is(err, efivarfs.ErrRateLimited):
+		code = http.StatusTooManyRequests
+	case errors.Is(err, efivarfs.ErrFsNotMounted), errors.Is(err, efivarfs.ErrVarsUnavailable):
+		code = http.StatusServiceUnavailable
+	}
+	writeError(w, code, err)
+}
+
+func writeError(w http.ResponseWriter, code int, err error) {
+	writeJSON(w, code, Error{Error: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,120 @@
+package rest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+const token = "secret"
+
+// do sends a request with body encoded as JSON unless it is nil and
+// returns the status code and response body.
+func do(t *testing.T, h http.Handler, method, path, auth string, body any) (int, []byte) {
+	t.Helper()
+	var r *http.Request
+	if body != nil {
+		buf, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = httptest.NewRequest(method, path, bytes.NewReader(buf))
+	} else {
+		r = httptest.NewRequest(method, path, nil)
+	}
+	if auth != "" {
+		r.Header.Set("Authorization", auth)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w.Code, w.Body.Bytes()
+}
+
+func TestHandler(t *testing.T) {
+	b := efivarfs.Dir(t.TempDir())
+	h, err := NewHandler(b, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bearer := "Bearer " + token
+	timeout := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	path := prefix + "/" + timeout.String()
+	attrs := efivarfs.AttributeNonVolatile | efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+
+	for _, auth := range []string{"", "Bearer wrong", token, "Basic " + token} {
+		if code, _ := do(t, h, http.MethodGet, prefix, auth, nil); code != http.StatusUnauthorized {
+			t.Errorf("GET with Authorization %q = %d, want 401", auth, code)
+		}
+	}
+
+	v := Variable{Attributes: attrs, Data: []byte{5, 0}}
+	if code, body := do(t, h, http.MethodPut, path, bearer, v); code != http.StatusNoContent {
+		t.Fatalf("PUT = %d %s", code, body)
+	}
+	code, body := do(t, h, http.MethodGet, path, bearer, nil)
+	var got Variable
+	if err := json.Unmarshal(body, &got); code != http.StatusOK || err != nil {
+		t.Fatalf("GET = %d %s", code, body)
+	}
+	if got.Name != "Timeout" || got.GUID != efivarfs.GlobalVariable.String() || got.Attributes != attrs || !bytes.Equal(got.Data, v.Data) {
+		t.Errorf("GET = %+v, want %+v", got, v)
+	}
+	code, body = do(t, h, http.MethodGet, prefix, bearer, nil)
+	var list []Descriptor
+	if err := json.Unmarshal(body, &list); code != http.StatusOK || err != nil || len(list) != 1 || list[0] != got.Descriptor {
+		t.Errorf("GET list = %d %s", code, body)
+	}
+	if code, body := do(t, h, http.MethodDelete, path, bearer, nil); code != http.StatusNoContent {
+		t.Errorf("DELETE = %d %s", code, body)
+	}
+	if code, _ := do(t, h, http.MethodGet, path, bearer, nil); code != http.StatusNotFound {
+		t.Errorf("GET after DELETE = %d, want 404", code)
+	}
+
+	for _, tt := range []struct {
+		method, path string
+		body         any
+		want         int
+	}{
+		{http.MethodGet, prefix + "/Timeout", nil, http.StatusBadRequest},
+		{http.MethodGet, prefix + "/-" + efivarfs.GlobalVariable.String(), nil, http.StatusBadRequest},
+		{http.MethodPut, path, "not a variable", http.StatusBadRequest},
+		{http.MethodPut, path, Variable{Attributes: attrs, Data: make([]byte, maxBody)}, http.StatusRequestEntityTooLarge},
+		{http.MethodPost, prefix, nil, http.StatusMethodNotAllowed},
+		{http.MethodGet, "/v2/variables", nil, http.StatusNotFound},
+	} {
+		if code, body := do(t, h, tt.method, tt.path, bearer, tt.body); code != tt.want {
+			t.Errorf("%s %s = %d %s, want %d", tt.method, tt.path, code, body, tt.want)
+		}
+	}
+}
+
+func TestBackendErrors(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int
+	}{
+		{efivarfs.ErrVarNotExist, http.StatusNotFound},
+		{efivarfs.ErrReadOnlyBackend, http.StatusForbidden},
+		{efivarfs.ErrReadOnlyVariable, http.StatusForbidden},
+		{efivarfs.ErrPolicyDenied, http.StatusForbidden},
+		{efivarfs.ErrSetVariableUnsupported, http.StatusNotImplemented},
+		{efivarfs.ErrNoSpace, http.StatusInsufficientStorage},
+		{efivarfs.ErrVariableTooLarge, http.StatusRequestEntityTooLarge},
+		{efivarfs.ErrRateLimited, http.StatusTooManyRequests},
+		{efivarfs.ErrFsNotMounted, http.StatusServiceUnavailable},
+		{fmt.Errorf("unexpected"), http.StatusInternalServerError},
+	} {
+		w := httptest.NewRecorder()
+		writeBackendError(w, fmt.Errorf("set Timeout: %w", tt.err))
+		if w.Code != tt.want || !strings.Contains(w.Body.String(), tt.err.Error()) {
+			t.Errorf("writeBackendError(%v) = %d %s, want %d", tt.err, w.Code, w.Body, tt.want)
+		}
+	}
+}
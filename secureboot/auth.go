@@ -0,0 +1,246 @@
+package secureboot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+	"unicode/utf16"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+// CertPKCS7GUID is the certificate type of WIN_CERTIFICATE_UEFI_GUID
+// structures holding a PKCS #7 SignedData.
+var CertPKCS7GUID = guid.MustParse("4aafd29d-68df-49ee-8aa9-347d375665a7")
+
+const (
+	// winCertRevision is the WIN_CERTIFICATE revision used by UEFI
+	winCertRevision = 0x0200
+	// winCertTypeEFIGUID is the type of WIN_CERTIFICATE_UEFI_GUID
+	winCertTypeEFIGUID = 0x0EF1
+
+	// AuthenticatedAttributes are the attributes all Secure Boot key
+	// databases are written with.
+	AuthenticatedAttributes = efivarfs.AttributeNonVolatile |
+		efivarfs.AttributeBootserviceAccess |
+		efivarfs.AttributeRuntimeAccess |
+		efivarfs.AttributeTimeBasedAuthenticatedWriteAccess
+)
+
+// Signer holds the key and certificate used to sign authenticated
+// variable updates, e.g. the PK to update KEK or the KEK to update db.
+type Signer struct {
+	Certificate *x509.Certificate
+	// Key is the RSA key of Certificate, either an *rsa.PrivateKey or
+	// a crypto.Signer backed by e.g. PKCS #11, a TPM or a cloud KMS,
+	// so the key never has to be in memory. Firmware only verifies
+	// RSA signatures.
+	Key crypto.Signer
+	// Format selects the encoding of the signatures, FormatSpec if zero
+	Format PKCS7Format
+	// Intermediates are embedded in the signatures, so firmware can
+	// chain Certificate up to a trusted certificate through them
+	Intermediates []*x509.Certificate
+}
+
+// PKCS7Format selects the encoding of the PKCS #7 signature of
+// authenticated variable updates. Firmware differs in which variants it
+// accepts: EDK II verifies with OpenSSL and adds a missing ContentInfo
+// itself, so it takes all of them, while firmware decoding the structure
+// on its own may only take the one the UEFI specification describes.
+type PKCS7Format struct {
+	// ContentInfo wraps the SignedData in a ContentInfo
+	ContentInfo bool
+	// Attached embeds the signed data, which the firmware ignores as it
+	// builds the signed content from the variable itself
+	Attached bool
+	// SignedAttributes signs the content type and message digest
+	// attributes rather than the content directly
+	SignedAttributes bool
+}
+
+var (
+	// FormatSpec is a detached SignedData without ContentInfo and signed
+	// attributes as the UEFI specification describes, which is also
+	// what sign-efi-sig-list of efitools creates.
+	FormatSpec = PKCS7Format{}
+	// FormatOpenSSL is a detached SignedData wrapped in a ContentInfo
+	// with signed attributes, as `openssl smime -sign -binary -outform
+	// DER` creates, for firmware that was only tested with updates
+	// signed that way.
+	FormatOpenSSL = PKCS7Format{ContentInfo: true, SignedAttributes: true}
+)
+
+// ErrKeyMismatch is returned when the key of a Signer doesn't belong to
+// its certificate, which firmware would only report as failed write.
+var ErrKeyMismatch = errors.New("signing key doesn't match the certificate")
+
+// sign returns the PKCS #7 signature of s over content in the format of
+// s.
+func (s *Signer) sign(content []byte) ([]byte, error) {
+	if pub, ok := s.Key.Public().(interface{ Equal(crypto.PublicKey) bool }); !ok || !pub.Equal(s.Certificate.PublicKey) {
+		return nil, fmt.Errorf("%w: %q", ErrKeyMismatch, s.Certificate.Subject)
+	}
+	return pkcs7.Sign(content, s.Certificate, s.Key, pkcs7.SignOptions{
+		ContentInfo:      s.Format.ContentInfo,
+		Attached:         s.Format.Attached,
+		SignedAttributes: s.Format.SignedAttributes,
+		Certificates:     s.Intermediates,
+	})
+}
+
+// signedContent returns the data covered by the signature of an
+// authenticated variable update, where descriptor is the timestamp or
+// the other parts of the authentication descriptor that are signed.
+func signedContent(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, descriptor, data []byte) []byte {
+	var buf bytes.Buffer
+	for _, c := range utf16.Encode([]rune(desc.Name)) {
+		binary.Write(&buf, binary.LittleEndian, c)
+	}
+	g := efivarfs.EncodeGUID(*desc.GUID)
+	buf.Write(g[:])
+	binary.Write(&buf, binary.LittleEndian, attrs)
+	buf.Write(descriptor)
+	buf.Write(data)
+	return buf.Bytes()
+}
+
+// winCertificate returns a WIN_CERTIFICATE_UEFI_GUID of certType
+// holding data.
+func winCertificate(certType guid.UUID, data []byte) []byte {
+	b := make([]byte, 8+16, 8+16+len(data))
+	binary.LittleEndian.PutUint32(b[0:], uint32(len(b)+len(data)))
+	binary.LittleEndian.PutUint16(b[4:], winCertRevision)
+	binary.LittleEndian.PutUint16(b[6:], winCertTypeEFIGUID)
+	g := efivarfs.EncodeGUID(certType)
+	copy(b[8:], g[:])
+	return append(b, data...)
+}
+
+// SignedUpdate returns the payload for writing data to the time based
+// authenticated variable desc: an EFI_VARIABLE_AUTHENTICATION_2
+// descriptor followed by data. If signer is nil the descriptor carries
+// no signature, which is what firmware expects while in Setup Mode.
+// An empty data deletes the variable.
+func SignedUpdate(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte, timestamp time.Time, signer *Signer) ([]byte, error) {
+	ts, err := NewEFITime(timestamp).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	var sig []byte
+	if signer != nil {
+		sig, err = signer.sign(signedContent(desc, attrs, ts, data))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	buf.Write(ts)
+	buf.Write(winCertificate(CertPKCS7GUID, sig))
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+var (
+	// ErrMalformedAuthPayload is returned for invalid authentication descriptors
+	ErrMalformedAuthPayload = errors.New("malformed EFI_VARIABLE_AUTHENTICATION_2")
+
+	// ErrTimestampNotNewer is returned if an update isn't newer than the
+	// variable it is supposed to replace, firmware would reject it
+	ErrTimestampNotNewer = errors.New("timestamp is not newer than the current one")
+
+	// ErrUntrustedSigner is returned if the payload isn't signed by any
+	// of the trusted certificates
+	ErrUntrustedSigner = errors.New("payload is not signed by a trusted certificate")
+)
+
+// AuthPayload is a parsed authenticated variable update, like the
+// content of an .auth file created by sign-efi-sig-list.
+type AuthPayload struct {
+	// Timestamp is the time of the update
+	Timestamp time.Time
+	// CertType is the type of Signature, usually CertPKCS7GUID
+	CertType guid.UUID
+	// Signature is the DER encoded PKCS #7 SignedData
+	Signature []byte
+	// Data is the new content of the variable
+	Data []byte
+
+	rawTimestamp []byte
+}
+
+// ParseAuthPayload splits an authenticated variable update into its
+// EFI_VARIABLE_AUTHENTICATION_2 descriptor and the variable data.
+func ParseAuthPayload(b []byte) (*AuthPayload, error) {
+	if len(b) < EFITimeSize+8+16 {
+		return nil, ErrMalformedAuthPayload
+	}
+	var ts EFITime
+	if err := ts.UnmarshalBinary(b); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedAuthPayload, err)
+	}
+	if err := ts.ValidForAuth(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedAuthPayload, err)
+	}
+	cert := b[EFITimeSize:]
+	length := binary.LittleEndian.Uint32(cert[0:4])
+	if binary.LittleEndian.Uint16(cert[4:6]) != winCertRevision ||
+		binary.LittleEndian.Uint16(cert[6:8]) != winCertTypeEFIGUID ||
+		length < 8+16 || uint64(length) > uint64(len(cert)) {
+		return nil, ErrMalformedAuthPayload
+	}
+	return &AuthPayload{
+		Timestamp:    ts.Time(),
+		CertType:     efivarfs.DecodeGUID(cert[8:24]),
+		Signature:    cert[24:length],
+		Data:         cert[length:],
+		rawTimestamp: b[:EFITimeSize],
+	}, nil
+}
+
+// VerifyAuthPayload checks an authenticated update of desc before it is
+// written, so failures can be reported with more detail than the EPERM
+// the firmware rejection turns into. The PKCS #7 signature has to be
+// made by one of trusted (usually the PK or KEK certificates) or by a
+// certificate that chains up to one of them, with the certificates
+// embedded in the signature serving as intermediates only.
+//
+// As the kernel doesn't expose the timestamp of authenticated variables
+// the caller has to provide the timestamp of the current content, e.g.
+// from the .auth file it was written with. A zero current skips the
+// check, as do appends which don't require monotonic timestamps.
+func VerifyAuthPayload(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, payload []byte, trusted []*x509.Certificate, current time.Time) (*AuthPayload, error) {
+	p, err := ParseAuthPayload(payload)
+	if err != nil {
+		return nil, err
+	}
+	if p.CertType != CertPKCS7GUID {
+		return nil, fmt.Errorf("%w: unsupported certificate type %v", ErrMalformedAuthPayload, p.CertType)
+	}
+	if attrs&efivarfs.AttributeAppendWrite == 0 && !current.IsZero() && !p.Timestamp.After(current) {
+		return nil, fmt.Errorf("%w: %v <= %v", ErrTimestampNotNewer, p.Timestamp, current)
+	}
+
+	sd, err := pkcs7.Parse(p.Signature)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := sd.Verify(signedContent(desc, attrs, p.rawTimestamp, p.Data), trusted...)
+	if err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+	for _, s := range signers {
+		if sd.Chain(s, trusted) == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUntrustedSigner, s.Subject)
+		}
+	}
+	return p, nil
+}
@@ -0,0 +1,204 @@
+package secureboot
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// EnhancedAuthenticatedAttributes are the attributes of variables
+// written with SignedUpdate3.
+const EnhancedAuthenticatedAttributes = efivarfs.AttributeNonVolatile |
+	efivarfs.AttributeBootserviceAccess |
+	efivarfs.AttributeRuntimeAccess |
+	efivarfs.AttributeEnhancedAuthenticatedAccess
+
+// Auth3Type is the type of an EFI_VARIABLE_AUTHENTICATION_3 descriptor,
+// which selects how the firmware prevents replaying old updates.
+type Auth3Type uint8
+
+const (
+	// Auth3Timestamp requires every update to carry a newer timestamp,
+	// like EFI_VARIABLE_AUTHENTICATION_2
+	Auth3Timestamp Auth3Type = 1
+	// Auth3Nonce requires every update to carry a nonce that wasn't
+	// used for the variable before
+	Auth3Nonce Auth3Type = 2
+)
+
+const (
+	// auth3Version is the version of EFI_VARIABLE_AUTHENTICATION_3
+	auth3Version = 1
+	// auth3HeaderSize is the size of the packed header of
+	// EFI_VARIABLE_AUTHENTICATION_3
+	auth3HeaderSize = 10
+	// auth3UpdateCert is the flag announcing a NewCert
+	auth3UpdateCert = 0x00000001
+)
+
+// Auth3 describes the EFI_VARIABLE_AUTHENTICATION_3 descriptor of an
+// update of a variable with AttributeEnhancedAuthenticatedAccess.
+type Auth3 struct {
+	// Type selects whether Timestamp or Nonce is used
+	Type Auth3Type
+	// Timestamp is the time of the update for Auth3Timestamp
+	Timestamp time.Time
+	// Nonce is the nonce of the update for Auth3Nonce, it must not be
+	// empty
+	Nonce []byte
+	// NewCert, if not nil, replaces the certificate the variable is
+	// bound to, so later updates have to be signed with its key
+	NewCert *x509.Certificate
+}
+
+// ErrMalformedAuth3Payload is returned for invalid
+// EFI_VARIABLE_AUTHENTICATION_3 descriptors
+var ErrMalformedAuth3Payload = errors.New("malformed EFI_VARIABLE_AUTHENTICATION_3")
+
+// secondary returns the timestamp or nonce structure following the
+// header of a.
+func (a Auth3) secondary() ([]byte, error) {
+	switch a.Type {
+	case Auth3Timestamp:
+		return NewEFITime(a.Timestamp).MarshalBinary()
+	case Auth3Nonce:
+		if len(a.Nonce) == 0 {
+			return nil, fmt.Errorf("%w: empty nonce", ErrMalformedAuth3Payload)
+		}
+		b := binary.LittleEndian.AppendUint32(nil, uint32(len(a.Nonce)))
+		return append(b, a.Nonce...), nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %d", ErrMalformedAuth3Payload, a.Type)
+}
+
+// SignedUpdate3 returns the payload for writing data to the enhanced
+// authenticated variable desc: an EFI_VARIABLE_AUTHENTICATION_3
+// descriptor described by a followed by data. The signature of signer
+// covers the name, vendor and attributes of the variable, the timestamp
+// or nonce, the new certificate if any and data. If signer is nil the
+// descriptor carries no signature. An empty data deletes the variable.
+func SignedUpdate3(desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, data []byte, a Auth3, signer *Signer) ([]byte, error) {
+	secondary, err := a.secondary()
+	if err != nil {
+		return nil, err
+	}
+	var flags uint32
+	var newCert []byte
+	if a.NewCert != nil {
+		flags |= auth3UpdateCert
+		newCert = winCertificate(CertX509GUID, a.NewCert.Raw)
+	}
+
+	var sig []byte
+	if signer != nil {
+		signed := append(append([]byte{}, secondary...), newCert...)
+		sig, err = signer.sign(signedContent(desc, attrs, signed, data))
+		if err != nil {
+			return nil, err
+		}
+	}
+	signingCert := winCertificate(CertPKCS7GUID, sig)
+
+	var buf bytes.Buffer
+	buf.WriteByte(auth3Version)
+	buf.WriteByte(byte(a.Type))
+	metadataSize := auth3HeaderSize + len(secondary) + len(newCert) + len(signingCert)
+	binary.Write(&buf, binary.LittleEndian, uint32(metadataSize))
+	binary.Write(&buf, binary.LittleEndian, flags)
+	buf.Write(secondary)
+	buf.Write(newCert)
+	buf.Write(signingCert)
+	buf.Write(data)
+	return buf.Bytes(), nil
+}
+
+// Auth3Payload is a parsed update of an enhanced authenticated
+// variable.
+type Auth3Payload struct {
+	Auth3
+	// CertType is the type of Signature, usually CertPKCS7GUID
+	CertType guid.UUID
+	// Signature is the DER encoded PKCS #7 SignedData
+	Signature []byte
+	// Data is the new content of the variable
+	Data []byte
+}
+
+// ParseAuth3Payload splits an update of an enhanced authenticated
+// variable into its EFI_VARIABLE_AUTHENTICATION_3 descriptor and the
+// variable data.
+func ParseAuth3Payload(b []byte) (*Auth3Payload, error) {
+	if len(b) < auth3HeaderSize || b[0] != auth3Version {
+		return nil, ErrMalformedAuth3Payload
+	}
+	p := &Auth3Payload{Auth3: Auth3{Type: Auth3Type(b[1])}}
+	metadataSize := binary.LittleEndian.Uint32(b[2:])
+	flags := binary.LittleEndian.Uint32(b[6:])
+	if metadataSize < auth3HeaderSize || uint64(metadataSize) > uint64(len(b)) {
+		return nil, ErrMalformedAuth3Payload
+	}
+	meta := b[auth3HeaderSize:metadataSize]
+	switch p.Type {
+	case Auth3Timestamp:
+		var ts EFITime
+		if err := ts.UnmarshalBinary(meta); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedAuth3Payload, err)
+		}
+		p.Timestamp = ts.Time()
+		meta = meta[EFITimeSize:]
+	case Auth3Nonce:
+		if len(meta) < 4 {
+			return nil, ErrMalformedAuth3Payload
+		}
+		n := binary.LittleEndian.Uint32(meta)
+		if n == 0 || uint64(n) > uint64(len(meta)-4) {
+			return nil, ErrMalformedAuth3Payload
+		}
+		p.Nonce = meta[4 : 4+n]
+		meta = meta[4+n:]
+	default:
+		return nil, fmt.Errorf("%w: unknown type %d", ErrMalformedAuth3Payload, p.Type)
+	}
+	if flags&auth3UpdateCert != 0 {
+		certType, cert, rest, err := parseWinCertificate(meta)
+		if err != nil {
+			return nil, err
+		}
+		if certType != CertX509GUID {
+			return nil, fmt.Errorf("%w: new certificate of type %v", ErrMalformedAuth3Payload, certType)
+		}
+		if p.NewCert, err = x509.ParseCertificate(cert); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedAuth3Payload, err)
+		}
+		meta = rest
+	}
+	var err error
+	if p.CertType, p.Signature, meta, err = parseWinCertificate(meta); err != nil {
+		return nil, err
+	}
+	if len(meta) != 0 {
+		return nil, fmt.Errorf("%w: %d bytes after the signing certificate", ErrMalformedAuth3Payload, len(meta))
+	}
+	p.Data = b[metadataSize:]
+	return p, nil
+}
+
+// parseWinCertificate splits a WIN_CERTIFICATE_UEFI_GUID off b.
+func parseWinCertificate(b []byte) (certType guid.UUID, data, rest []byte, err error) {
+	if len(b) < 8+16 {
+		return guid.UUID{}, nil, nil, ErrMalformedAuth3Payload
+	}
+	length := binary.LittleEndian.Uint32(b[0:4])
+	if binary.LittleEndian.Uint16(b[4:6]) != winCertRevision ||
+		binary.LittleEndian.Uint16(b[6:8]) != winCertTypeEFIGUID ||
+		length < 8+16 || uint64(length) > uint64(len(b)) {
+		return guid.UUID{}, nil, nil, ErrMalformedAuth3Payload
+	}
+	return efivarfs.DecodeGUID(b[8:24]), b[24:length], b[length:], nil
+}
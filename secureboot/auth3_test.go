@@ -0,0 +1,72 @@
+package secureboot
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+func TestSignedUpdate3(t *testing.T) {
+	signer, next := testSigner(t, "old"), testSigner(t, "new")
+	desc := efivarfs.VariableDescriptor{Name: "Config", GUID: &efivarfs.LoaderVendor}
+	data := []byte("payload")
+	for _, a := range []Auth3{
+		{Type: Auth3Timestamp, Timestamp: time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)},
+		{Type: Auth3Nonce, Nonce: []byte{1, 2, 3, 4}},
+		{Type: Auth3Nonce, Nonce: []byte{5}, NewCert: next.Certificate},
+	} {
+		b, err := SignedUpdate3(desc, EnhancedAuthenticatedAttributes, data, a, signer)
+		if err != nil {
+			t.Fatalf("SignedUpdate3(%+v) = %v", a, err)
+		}
+		p, err := ParseAuth3Payload(b)
+		if err != nil {
+			t.Fatalf("ParseAuth3Payload() = %v for %+v", err, a)
+		}
+		if p.Type != a.Type || !p.Timestamp.Equal(a.Timestamp) || !bytes.Equal(p.Nonce, a.Nonce) ||
+			!bytes.Equal(p.Data, data) || p.CertType != CertPKCS7GUID {
+			t.Errorf("ParseAuth3Payload() = %+v, want %+v", p, a)
+		}
+		if (p.NewCert == nil) != (a.NewCert == nil) || p.NewCert != nil && !p.NewCert.Equal(a.NewCert) {
+			t.Errorf("ParseAuth3Payload() has new certificate %v, want %v", p.NewCert, a.NewCert)
+		}
+
+		// The signature covers everything between the header and the
+		// signing certificate.
+		secondary, _ := a.secondary()
+		if a.NewCert != nil {
+			secondary = append(secondary, winCertificate(CertX509GUID, a.NewCert.Raw)...)
+		}
+		sd, err := pkcs7.Parse(p.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := sd.Verify(signedContent(desc, EnhancedAuthenticatedAttributes, secondary, data), signer.Certificate); err != nil {
+			t.Errorf("signature of %+v doesn't verify: %v", a, err)
+		}
+
+		for _, n := range []int{0, 9, len(b) - len(data) - 1} {
+			if _, err := ParseAuth3Payload(b[:n]); !errors.Is(err, ErrMalformedAuth3Payload) {
+				t.Errorf("ParseAuth3Payload() = %v for %d of %d bytes, want ErrMalformedAuth3Payload", err, n, len(b))
+			}
+		}
+	}
+
+	if _, err := SignedUpdate3(desc, EnhancedAuthenticatedAttributes, data, Auth3{Type: Auth3Nonce}, signer); !errors.Is(err, ErrMalformedAuth3Payload) {
+		t.Errorf("SignedUpdate3() = %v without nonce, want ErrMalformedAuth3Payload", err)
+	}
+	b, err := SignedUpdate3(desc, EnhancedAuthenticatedAttributes, nil, Auth3{Type: Auth3Nonce, Nonce: []byte{9}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// version, type, metadata size, flags, nonce size and nonce, and an
+	// empty WIN_CERTIFICATE_UEFI_GUID
+	if want := "010227000000000000000100000009180000000002f10e9dd2af4adf68ee498aa9347d375665a7"; hex.EncodeToString(b) != want {
+		t.Errorf("SignedUpdate3() = %s, want %s", hex.EncodeToString(b), want)
+	}
+}
@@ -0,0 +1,150 @@
+package secureboot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+// testSigner returns a Signer with a self-signed certificate for cn.
+func testSigner(t *testing.T, cn string) *Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{Certificate: cert, Key: key}
+}
+
+func TestSignerFormat(t *testing.T) {
+	signer := testSigner(t, "PK")
+	desc := efivarfs.VariableDescriptor{Name: "KEK", GUID: &efivarfs.GlobalVariable}
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, f := range []PKCS7Format{FormatSpec, FormatOpenSSL, {Attached: true}, {ContentInfo: true, Attached: true, SignedAttributes: true}} {
+		signer.Format = f
+		b, err := SignedUpdate(desc, AuthenticatedAttributes, []byte("KEK"), ts, signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		p, err := VerifyAuthPayload(desc, AuthenticatedAttributes, b, []*x509.Certificate{signer.Certificate}, time.Time{})
+		if err != nil {
+			t.Errorf("VerifyAuthPayload() = %v for %+v", err, f)
+			continue
+		}
+		// A SignedData starts with a SEQUENCE holding its version, a
+		// ContentInfo with a SEQUENCE holding the signedData OID.
+		if wrapped := bytes.Contains(p.Signature[:20], []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x02}); wrapped != f.ContentInfo {
+			t.Errorf("signature of %+v wrapped in ContentInfo: %v", f, wrapped)
+		}
+		sd, err := pkcs7.Parse(p.Signature)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if attached := sd.Content != nil; attached != f.Attached {
+			t.Errorf("signature of %+v has content attached: %v", f, attached)
+		}
+	}
+}
+
+// hsmKey hides the private key behind crypto.Signer like the keys of
+// PKCS #11 or KMS libraries.
+type hsmKey struct {
+	key *rsa.PrivateKey
+}
+
+func (k hsmKey) Public() crypto.PublicKey {
+	return k.key.Public()
+}
+
+func (k hsmKey) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	return k.key.Sign(rand, digest, opts)
+}
+
+func TestExternalSigner(t *testing.T) {
+	signer := testSigner(t, "KEK")
+	desc := efivarfs.VariableDescriptor{Name: "db", GUID: &efivarfs.ImageSecurityDatabase}
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	hsm := &Signer{Certificate: signer.Certificate, Key: hsmKey{signer.Key.(*rsa.PrivateKey)}}
+	b, err := SignedUpdate(desc, AuthenticatedAttributes, []byte("db"), ts, hsm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuthPayload(desc, AuthenticatedAttributes, b, []*x509.Certificate{signer.Certificate}, time.Time{}); err != nil {
+		t.Errorf("VerifyAuthPayload() = %v", err)
+	}
+
+	other := testSigner(t, "other")
+	if _, err := SignedUpdate(desc, AuthenticatedAttributes, nil, ts, &Signer{Certificate: signer.Certificate, Key: other.Key}); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("SignedUpdate() = %v with the key of another certificate, want ErrKeyMismatch", err)
+	}
+	ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{SerialNumber: big.NewInt(2), Subject: pkix.Name{CommonName: "EC"}}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &ec.PublicKey, ec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	if _, err := SignedUpdate(desc, AuthenticatedAttributes, nil, ts, &Signer{Certificate: cert, Key: ec}); err == nil {
+		t.Error("SignedUpdate() succeeded with an ECDSA key")
+	}
+}
+
+func TestVerifyAuthPayloadChain(t *testing.T) {
+	desc := efivarfs.VariableDescriptor{Name: "db", GUID: &efivarfs.ImageSecurityDatabase}
+	ts := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ca := testIssue(t, "KEK CA", nil, true)
+	intermediate := testIssue(t, "Intermediate CA", ca, true)
+	leaf := testIssue(t, "Leaf", intermediate, false)
+	attacker := testIssue(t, "Attacker", nil, false)
+	trusted := []*x509.Certificate{ca.Certificate}
+
+	for _, tt := range []struct {
+		name    string
+		signer  *Signer
+		trusted bool
+	}{
+		{"intermediate embedded", &Signer{Certificate: leaf.Certificate, Key: leaf.Key, Intermediates: []*x509.Certificate{intermediate.Certificate}}, true},
+		{"intermediate missing", leaf, false},
+		{"trusted certificate only embedded", &Signer{Certificate: attacker.Certificate, Key: attacker.Key, Intermediates: []*x509.Certificate{ca.Certificate}}, false},
+		{"issued certificate only embedded", &Signer{Certificate: attacker.Certificate, Key: attacker.Key, Intermediates: []*x509.Certificate{intermediate.Certificate, leaf.Certificate}}, false},
+	} {
+		b, err := SignedUpdate(desc, AuthenticatedAttributes, []byte("db"), ts, tt.signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = VerifyAuthPayload(desc, AuthenticatedAttributes, b, trusted, time.Time{})
+		if tt.trusted && err != nil || !tt.trusted && !errors.Is(err, ErrUntrustedSigner) {
+			t.Errorf("%s: VerifyAuthPayload() = %v, want trusted %v", tt.name, err, tt.trusted)
+		}
+	}
+}
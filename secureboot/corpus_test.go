@@ -0,0 +1,86 @@
+package secureboot
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	guid "github.com/google/uuid"
+)
+
+const corpus = "../testdata/corpus"
+
+// TestCorpus parses the signature databases of the corpus, checks
+// their content and that they are encoded exactly as the firmware
+// stored them.
+func TestCorpus(t *testing.T) {
+	const (
+		global = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+		isdb   = "d719b2cb-3d3a-4596-a3bc-dad00e67656f"
+		shim   = "605dab50-e046-4300-abb6-3dd810dd8b23"
+	)
+	for _, tt := range []struct {
+		file   string
+		certs  int
+		hashes int
+	}{
+		{"ami-desktop/PK-" + global, 1, 0},
+		{"ami-desktop/KEK-" + global, 2, 0},
+		{"ami-desktop/db-" + isdb, 2, 0},
+		{"ami-desktop/dbx-" + isdb, 0, 220},
+		{"insyde-laptop/db-" + isdb, 3, 0},
+		{"insyde-laptop/dbx-" + isdb, 0, 1},
+		{"insyde-laptop/MokListRT-" + shim, 1, 2},
+		{"dell-server/PK-" + global, 1, 0},
+		{"dell-server/dbx-" + isdb, 0, 217},
+	} {
+		t.Run(tt.file, func(t *testing.T) {
+			b, err := os.ReadFile(filepath.Join(corpus, tt.file))
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := b[4:]
+			db, err := ParseSignatureDatabase(want)
+			if err != nil {
+				t.Fatal(err)
+			}
+			certs, err := db.Certificates()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(certs) != tt.certs {
+				t.Errorf("got %d certificates, want %d", len(certs), tt.certs)
+			}
+			if hashes := db.Hashes(CertSHA256GUID); len(hashes) != tt.hashes {
+				t.Errorf("got %d hashes, want %d", len(hashes), tt.hashes)
+			}
+			got, err := db.MarshalBinary()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("MarshalBinary() differs from corpus")
+			}
+		})
+	}
+}
+
+// TestCorpusOwners checks that lists of the same type but different
+// owners are kept apart, as they are in dbx updates.
+func TestCorpusOwners(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join(corpus, "ami-desktop", "dbx-d719b2cb-3d3a-4596-a3bc-dad00e67656f"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := ParseSignatureDatabase(b[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(db) != 3 {
+		t.Fatalf("got %d signature lists, want 3", len(db))
+	}
+	if owner := db[1].Signatures[0].Owner; owner != (guid.UUID{}) {
+		t.Errorf("owner of second list %s, want zero GUID", owner)
+	}
+}
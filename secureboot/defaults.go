@@ -0,0 +1,150 @@
+package secureboot
+
+import (
+	"fmt"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Descriptors of the keys the platform vendor ships, which the firmware
+// setup usually offers to restore
+var (
+	PKDefault  = efivarfs.NewDescriptor("PKDefault", efivarfs.GlobalVariable)
+	KEKDefault = efivarfs.NewDescriptor("KEKDefault", efivarfs.GlobalVariable)
+	DBDefault  = efivarfs.NewDescriptor("dbDefault", efivarfs.GlobalVariable)
+	DBXDefault = efivarfs.NewDescriptor("dbxDefault", efivarfs.GlobalVariable)
+)
+
+// defaults are the databases in the order they are restored in with
+// the variables holding their defaults. PK comes last, as enrolling it
+// ends Setup Mode.
+var defaults = []struct {
+	desc, def efivarfs.VariableDescriptor
+}{
+	{DBX, DBXDefault},
+	{DB, DBDefault},
+	{KEK, KEKDefault},
+	{PK, PKDefault},
+}
+
+// Defaults are the keys shipped with the platform.
+type Defaults struct {
+	// PK, KEK, DB and DBX are empty if the firmware provides no default
+	PK, KEK, DB, DBX SignatureDatabase
+}
+
+// ReadDefaults reads the default keys from b.
+func ReadDefaults(b efivarfs.ReadBackend) (*Defaults, error) {
+	d := &Defaults{}
+	for _, v := range []struct {
+		desc efivarfs.VariableDescriptor
+		db   *SignatureDatabase
+	}{
+		{PKDefault, &d.PK},
+		{KEKDefault, &d.KEK},
+		{DBDefault, &d.DB},
+		{DBXDefault, &d.DBX},
+	} {
+		db, err := readDatabase(b, v.desc)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", v.desc.Name, err)
+		}
+		*v.db = db
+	}
+	return d, nil
+}
+
+// RestoreOptions controls which databases RestoreDefaults enrolls.
+type RestoreOptions struct {
+	// Backend holds the variables, the efivarfs of the running system
+	// if nil
+	Backend efivarfs.Backend
+
+	// Signer is the owner of the enrolled PK. It signs the updates of
+	// PK and KEK and may be nil if the platform is in Setup Mode.
+	// Firmware requiring PK to be signed by its own key can't restore
+	// PKDefault without it.
+	Signer *Signer
+	// KEKSigner holds a key enrolled in KEK. It signs the updates of db
+	// and dbx, which firmware only accepts from a KEK, and may be nil if
+	// the platform is in Setup Mode or neither is restored.
+	KEKSigner *Signer
+
+	// Databases selects the databases to restore, e.g. DB and DBX. All
+	// of PK, KEK, db and dbx are restored if it is empty.
+	Databases []efivarfs.VariableDescriptor
+}
+
+// RestoreDefaults re-enrolls the keys the platform shipped with from
+// PKDefault, KEKDefault, dbDefault and dbxDefault, the counterpart of the
+// "restore factory keys" option of firmware setups. Databases without
+// default are left untouched. PK is written last, as it ends Setup Mode.
+// Outside of Setup Mode the signers of all selected databases with a
+// default are required before anything is written. It returns the
+// databases that were written.
+func RestoreDefaults(opts RestoreOptions) ([]efivarfs.VariableDescriptor, error) {
+	b := opts.Backend
+	if b == nil {
+		var err error
+		if b, err = efivarfs.Probe(); err != nil {
+			return nil, err
+		}
+	}
+	selected := make(map[string]bool)
+	for _, desc := range opts.Databases {
+		if !isDefaultable(desc) {
+			return nil, fmt.Errorf("%s has no default", desc.Name)
+		}
+		selected[desc.Name] = true
+	}
+	setupMode, err := inSetupMode(b)
+	if err != nil {
+		return nil, fmt.Errorf("reading SetupMode: %w", err)
+	}
+	pk, kek := opts.Signer, opts.KEKSigner
+	if setupMode {
+		pk, kek = nil, nil
+	}
+
+	var restore []efivarfs.VariableDescriptor
+	dbs := make(map[string]SignatureDatabase)
+	for _, d := range defaults {
+		if len(selected) > 0 && !selected[d.desc.Name] {
+			continue
+		}
+		db, err := readDatabase(b, d.def)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", d.def.Name, err)
+		}
+		if len(db) == 0 {
+			continue
+		}
+		if !setupMode {
+			if err := checkSigner(d.desc, pk, kek); err != nil {
+				return nil, err
+			}
+		}
+		restore = append(restore, d.desc)
+		dbs[d.desc.Name] = db
+	}
+
+	var restored []efivarfs.VariableDescriptor
+	for _, desc := range restore {
+		if err := Write(b, desc, dbs[desc.Name], signerFor(desc, pk, kek)); err != nil {
+			return restored, fmt.Errorf("writing %s: %w", desc.Name, err)
+		}
+		restored = append(restored, desc)
+	}
+	return restored, nil
+}
+
+// isDefaultable reports whether desc is one of the databases with a
+// default.
+func isDefaultable(desc efivarfs.VariableDescriptor) bool {
+	for _, d := range defaults {
+		if d.desc.Equal(desc) {
+			return true
+		}
+	}
+	return false
+}
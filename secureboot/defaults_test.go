@@ -0,0 +1,107 @@
+package secureboot
+
+import (
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestRestoreDefaults(t *testing.T) {
+	const (
+		global = "8be4df61-93ca-11d2-aa0d-00e098032b8c"
+		isdb   = "d719b2cb-3d3a-4596-a3bc-dad00e67656f"
+	)
+	for _, tt := range []struct {
+		name      string
+		databases []efivarfs.VariableDescriptor
+		want      []string
+	}{
+		{"all", nil, []string{"dbx", "db"}},
+		{"db", []efivarfs.VariableDescriptor{DB}, []string{"db"}},
+		{"kek", []efivarfs.VariableDescriptor{KEK}, nil},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			for src, dst := range map[string]string{
+				"ovmf/SetupMode-" + global: "SetupMode-" + global,
+				"ami-desktop/db-" + isdb:   "dbDefault-" + global,
+				"ami-desktop/dbx-" + isdb:  "dbxDefault-" + global,
+			} {
+				b, err := os.ReadFile(filepath.Join(corpus, src))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, dst), b, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			var got []string
+			c := efivarfs.NewClient(efivarfs.Dir(dir), efivarfs.WithDryRun(func(c efivarfs.Change) {
+				got = append(got, c.Desc.Name)
+			}))
+			restored, err := RestoreDefaults(RestoreOptions{Backend: c, Databases: tt.databases})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(restored) != len(tt.want) || len(got) != len(tt.want) {
+				t.Fatalf("restored %v, wrote %v, want %v", restored, got, tt.want)
+			}
+			for i, name := range tt.want {
+				if restored[i].Name != name || got[i] != name {
+					t.Errorf("restored %v, wrote %v, want %v", restored, got, tt.want)
+				}
+			}
+		})
+	}
+	if _, err := RestoreDefaults(RestoreOptions{Backend: efivarfs.Dir(t.TempDir()), Databases: []efivarfs.VariableDescriptor{SetupMode}}); err == nil {
+		t.Error("restoring SetupMode succeeded")
+	}
+}
+
+func TestRestoreDefaultsUserMode(t *testing.T) {
+	pk := testSigner(t, "PK")
+	kek := testSigner(t, "KEK")
+	dir := userModeDir(t)
+	for _, def := range []efivarfs.VariableDescriptor{KEKDefault, DBDefault} {
+		db := SignatureDatabase{NewX509SignatureList(efivarfs.GlobalVariable, kek.Certificate)}
+		b, err := db.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := dir.Set(def, efivarfs.AttributeBootserviceAccess|efivarfs.AttributeRuntimeAccess, b); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	c := efivarfs.NewClient(dir, efivarfs.WithDryRun(func(c efivarfs.Change) {
+		got = append(got, c.Desc.Name)
+		signer := pk.Certificate
+		if c.Desc.Equal(DB) {
+			signer = kek.Certificate
+		}
+		if _, err := VerifyAuthPayload(c.Desc, c.Attributes, c.Data, []*x509.Certificate{signer}, time.Time{}); err != nil {
+			t.Errorf("update of %s: %v", c.Desc.Name, err)
+		}
+	}))
+	restored, err := RestoreDefaults(RestoreOptions{Backend: c, Signer: pk, KEKSigner: kek})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(restored) != 2 || len(got) != 2 || got[0] != "db" || got[1] != "KEK" {
+		t.Errorf("restored %v, wrote %v, want db and KEK", restored, got)
+	}
+
+	got = nil
+	if _, err := RestoreDefaults(RestoreOptions{Backend: c, Signer: pk}); err == nil || len(got) != 0 {
+		t.Errorf("RestoreDefaults() = %v and wrote %v without KEK signer", err, got)
+	}
+	// The KEK signer isn't needed if only KEK is restored
+	if _, err := RestoreDefaults(RestoreOptions{Backend: c, Signer: pk, Databases: []efivarfs.VariableDescriptor{KEK}}); err != nil {
+		t.Errorf("RestoreDefaults(KEK) = %v without KEK signer", err)
+	}
+}
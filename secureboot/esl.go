@@ -0,0 +1,261 @@
+// Package secureboot implements the data structures used by the UEFI
+// Secure Boot variables PK, KEK, db and dbx.
+package secureboot
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Signature types as defined by the UEFI specification, section 32.4.1
+var (
+	CertSHA256GUID     = guid.MustParse("c1c41626-504c-4092-aca9-41f936934328")
+	CertRSA2048GUID    = guid.MustParse("3c5766e8-269c-4e34-aa14-ed776e85b3b6")
+	CertSHA1GUID       = guid.MustParse("826ca512-cf10-4ac9-b187-be01496631bd")
+	CertSHA384GUID     = guid.MustParse("ff3e5307-9fd0-48c9-85f1-8ad56c701e01")
+	CertSHA512GUID     = guid.MustParse("093e0fae-a6c4-4f50-9f1b-d41e2b89c19a")
+	CertX509GUID       = guid.MustParse("a5c059a1-94e4-4aa7-87b5-ab155c2bf072")
+	CertX509SHA256GUID = guid.MustParse("3bd2a492-96c0-4079-b420-fcf98ef103ed")
+)
+
+// signatureListHeaderSize is the size of the fixed part of an
+// EFI_SIGNATURE_LIST
+const signatureListHeaderSize = 16 + 4 + 4 + 4
+
+var (
+	// ErrMalformedSignatureList is returned when parsing invalid signature lists
+	ErrMalformedSignatureList = errors.New("malformed EFI signature list")
+
+	// ErrMixedSignatureSize is returned if signatures of different sizes
+	// are put into a single signature list
+	ErrMixedSignatureSize = errors.New("all signatures of a list must have the same size")
+)
+
+// SignatureData is a single EFI_SIGNATURE_DATA entry.
+type SignatureData struct {
+	// Owner identifies the agent which added the signature
+	Owner guid.UUID
+	// Data is the signature itself, e.g. a DER certificate or a hash
+	Data []byte
+}
+
+// SignatureList is an EFI_SIGNATURE_LIST, a set of signatures of the
+// same type and size.
+type SignatureList struct {
+	// Type identifies the format of the signatures, e.g. CertX509GUID
+	Type guid.UUID
+	// Header is the optional type specific list header
+	Header []byte
+	// Signatures holds the entries of the list
+	Signatures []SignatureData
+}
+
+// SignatureDatabase is the content of a signature database variable
+// like db or dbx: a concatenation of signature lists.
+type SignatureDatabase []SignatureList
+
+// NewX509SignatureList returns a signature list holding the DER encoding
+// of cert. Certificates usually differ in size, which is why each of them
+// ends up in a list of its own.
+func NewX509SignatureList(owner guid.UUID, cert *x509.Certificate) SignatureList {
+	return SignatureList{
+		Type:       CertX509GUID,
+		Signatures: []SignatureData{{Owner: owner, Data: cert.Raw}},
+	}
+}
+
+// NewSHA256SignatureList returns a single signature list holding all
+// given SHA-256 hashes.
+func NewSHA256SignatureList(owner guid.UUID, hashes ...[sha256.Size]byte) SignatureList {
+	l := SignatureList{Type: CertSHA256GUID}
+	for _, h := range hashes {
+		l.Signatures = append(l.Signatures, SignatureData{Owner: owner, Data: append([]byte(nil), h[:]...)})
+	}
+	return l
+}
+
+// ParseCertificates returns all certificates contained in data, which
+// may either be a single DER certificate or any number of PEM blocks.
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	if !bytes.Contains(data, []byte("-----BEGIN")) {
+		cert, err := x509.ParseCertificate(data)
+		if err != nil {
+			return nil, err
+		}
+		return []*x509.Certificate{cert}, nil
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return certs, nil
+}
+
+// signatureSize returns the common size of all signatures in l and
+// makes sure there are no differently sized ones.
+func (l *SignatureList) signatureSize() (int, error) {
+	if len(l.Signatures) == 0 {
+		return 0, nil
+	}
+	size := len(l.Signatures[0].Data)
+	for _, s := range l.Signatures[1:] {
+		if len(s.Data) != size {
+			return 0, ErrMixedSignatureSize
+		}
+	}
+	return 16 + size, nil
+}
+
+// MarshalBinary encodes l as EFI_SIGNATURE_LIST.
+func (l *SignatureList) MarshalBinary() ([]byte, error) {
+	sigSize, err := l.signatureSize()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	typ := efivarfs.EncodeGUID(l.Type)
+	buf.Write(typ[:])
+	// Computed with 64 bits, as the product overflows int on 32 bit
+	// systems.
+	size := uint64(signatureListHeaderSize) + uint64(len(l.Header)) + uint64(sigSize)*uint64(len(l.Signatures))
+	if size > math.MaxUint32 {
+		return nil, fmt.Errorf("signature list of %d bytes: %w", size, ErrMalformedSignatureList)
+	}
+	hdr := []uint32{
+		uint32(size),
+		uint32(len(l.Header)),
+		uint32(sigSize),
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, hdr); err != nil {
+		return nil, err
+	}
+	buf.Write(l.Header)
+	for _, s := range l.Signatures {
+		owner := efivarfs.EncodeGUID(s.Owner)
+		buf.Write(owner[:])
+		buf.Write(s.Data)
+	}
+	return buf.Bytes(), nil
+}
+
+// MarshalBinary concatenates the encodings of all lists of db.
+func (db SignatureDatabase) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	for i := range db {
+		b, err := db[i].MarshalBinary()
+		if err != nil {
+			return nil, fmt.Errorf("signature list %d: %w", i, err)
+		}
+		buf.Write(b)
+	}
+	return buf.Bytes(), nil
+}
+
+// ParseSignatureDatabase decodes a concatenation of signature lists as
+// found in the db, dbx, KEK and PK variables.
+func ParseSignatureDatabase(b []byte) (SignatureDatabase, error) {
+	var db SignatureDatabase
+	for len(b) > 0 {
+		if len(b) < signatureListHeaderSize {
+			return nil, ErrMalformedSignatureList
+		}
+		listSize := binary.LittleEndian.Uint32(b[16:20])
+		headerSize := binary.LittleEndian.Uint32(b[20:24])
+		sigSize := binary.LittleEndian.Uint32(b[24:28])
+		if uint64(listSize) > uint64(len(b)) ||
+			uint64(listSize) < signatureListHeaderSize+uint64(headerSize) ||
+			sigSize < 16 ||
+			(uint64(listSize)-signatureListHeaderSize-uint64(headerSize))%uint64(sigSize) != 0 {
+			return nil, ErrMalformedSignatureList
+		}
+
+		l := SignatureList{
+			Type:   efivarfs.DecodeGUID(b[0:16]),
+			Header: append([]byte(nil), b[signatureListHeaderSize:signatureListHeaderSize+headerSize]...),
+		}
+		sigs := b[signatureListHeaderSize+headerSize : listSize]
+		for len(sigs) > 0 {
+			l.Signatures = append(l.Signatures, SignatureData{
+				Owner: efivarfs.DecodeGUID(sigs[0:16]),
+				Data:  append([]byte(nil), sigs[16:sigSize]...),
+			})
+			sigs = sigs[sigSize:]
+		}
+		db = append(db, l)
+		b = b[listSize:]
+	}
+	return db, nil
+}
+
+// Certificates returns all X.509 certificates stored in db.
+func (db SignatureDatabase) Certificates() ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for _, l := range db {
+		if l.Type != CertX509GUID {
+			continue
+		}
+		for _, s := range l.Signatures {
+			cert, err := x509.ParseCertificate(s.Data)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+	return certs, nil
+}
+
+// Hashes returns all signatures of db with the given hash type, e.g.
+// CertSHA256GUID.
+func (db SignatureDatabase) Hashes(typ guid.UUID) [][]byte {
+	var hashes [][]byte
+	for _, l := range db {
+		if l.Type != typ {
+			continue
+		}
+		for _, s := range l.Signatures {
+			hashes = append(hashes, s.Data)
+		}
+	}
+	return hashes
+}
+
+// NewCertificateDatabase builds a signature database from PEM or DER
+// encoded certificates, assigning owner to every entry.
+func NewCertificateDatabase(owner guid.UUID, data ...[]byte) (SignatureDatabase, error) {
+	var db SignatureDatabase
+	for _, d := range data {
+		certs, err := ParseCertificates(d)
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range certs {
+			db = append(db, NewX509SignatureList(owner, c))
+		}
+	}
+	return db, nil
+}
@@ -0,0 +1,85 @@
+package secureboot
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+
+	guid "github.com/google/uuid"
+)
+
+// TestParseSignatureDatabaseBounds feeds sizes that overflow int, or the
+// sum of the header sizes, on 32 bit systems.
+func TestParseSignatureDatabaseBounds(t *testing.T) {
+	list := func(listSize, headerSize, sigSize uint32) []byte {
+		b := make([]byte, signatureListHeaderSize+16+32)
+		binary.LittleEndian.PutUint32(b[16:], listSize)
+		binary.LittleEndian.PutUint32(b[20:], headerSize)
+		binary.LittleEndian.PutUint32(b[24:], sigSize)
+		return b
+	}
+	if _, err := ParseSignatureDatabase(list(signatureListHeaderSize+48, 0, 48)); err != nil {
+		t.Errorf("ParseSignatureDatabase() = %v for a valid list", err)
+	}
+	for _, tt := range []struct {
+		name string
+		b    []byte
+	}{
+		{"truncated", make([]byte, signatureListHeaderSize-1)},
+		{"list size beyond data", list(0xffffffff, 0, 48)},
+		{"list size below header", list(signatureListHeaderSize-1, 0, 48)},
+		{"header size overflowing", list(signatureListHeaderSize+48, 0xfffffff0, 48)},
+		{"signature size overflowing", list(signatureListHeaderSize+48, 0, 0xffffffff)},
+		{"signature size below owner", list(signatureListHeaderSize+48, 0, 8)},
+		{"partial signature", list(signatureListHeaderSize+48, 0, 40)},
+	} {
+		if _, err := ParseSignatureDatabase(tt.b); !errors.Is(err, ErrMalformedSignatureList) {
+			t.Errorf("%s: ParseSignatureDatabase() = %v, want ErrMalformedSignatureList", tt.name, err)
+		}
+	}
+}
+
+func TestSignatureDatabaseRoundTrip(t *testing.T) {
+	owner := guid.MustParse("77fa9abd-0359-4d32-bd60-28f4e78f784b")
+	var h1, h2 [32]byte
+	for i := range h1 {
+		h1[i], h2[i] = 0xaa, 0xbb
+	}
+	db := SignatureDatabase{
+		NewSHA256SignatureList(owner, h1, h2),
+		{Type: CertX509GUID, Header: []byte{1, 2}, Signatures: []SignatureData{{Owner: owner, Data: []byte{0x30, 0x00}}}},
+	}
+	want, err := hex.DecodeString(
+		// EFI_CERT_SHA256_GUID, 124 bytes, no header, 48 byte signatures
+		"2616c4c14c509240aca941f936934328" + "7c000000" + "00000000" + "30000000" +
+			"bd9afa775903324dbd6028f4e78f784b" + strings.Repeat("aa", 32) +
+			"bd9afa775903324dbd6028f4e78f784b" + strings.Repeat("bb", 32) +
+			// EFI_CERT_X509_GUID, 48 bytes, 2 byte header, 18 byte signature
+			"a159c0a5e494a74a87b5ab155c2bf072" + "30000000" + "02000000" + "12000000" + "0102" +
+			"bd9afa775903324dbd6028f4e78f784b" + "3000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := db.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("MarshalBinary() = %x, want %x", got, want)
+	}
+	parsed, err := ParseSignatureDatabase(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parsed) != 2 || parsed[0].Type != CertSHA256GUID || len(parsed[0].Signatures) != 2 ||
+		parsed[0].Signatures[1].Owner != owner || !bytes.Equal(parsed[0].Signatures[1].Data, h2[:]) ||
+		parsed[1].Type != CertX509GUID || !bytes.Equal(parsed[1].Header, []byte{1, 2}) {
+		t.Errorf("ParseSignatureDatabase() = %+v", parsed)
+	}
+	if again, err := parsed.MarshalBinary(); err != nil || !bytes.Equal(again, want) {
+		t.Errorf("MarshalBinary() = %x, %v after parsing, want %x", again, err, want)
+	}
+}
@@ -0,0 +1,24 @@
+package secureboot
+
+import "encoding/binary"
+
+// testImage returns a minimal PE32+ image with one section, which fn
+// may modify, given the offsets of the optional header and the section.
+func testImage(fn func(b []byte, opt, section int)) []byte {
+	b := make([]byte, 0x200)
+	b[0], b[1] = 'M', 'Z'
+	binary.LittleEndian.PutUint32(b[0x3c:], 0x40)
+	copy(b[0x40:], "PE\x00\x00")
+	coff := 0x44
+	binary.LittleEndian.PutUint16(b[coff+2:], 1)
+	binary.LittleEndian.PutUint16(b[coff+16:], 240)
+	opt := coff + 20
+	binary.LittleEndian.PutUint16(b[opt:], 0x20b)
+	binary.LittleEndian.PutUint32(b[opt+60:], 0x180)
+	binary.LittleEndian.PutUint32(b[opt+108:], 16)
+	section := opt + 240
+	binary.LittleEndian.PutUint32(b[section+16:], 0x80)
+	binary.LittleEndian.PutUint32(b[section+20:], 0x180)
+	fn(b, opt, section)
+	return b
+}
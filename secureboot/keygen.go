@@ -0,0 +1,117 @@
+package secureboot
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"time"
+)
+
+// KeyOptions configure GenerateSigner.
+type KeyOptions struct {
+	// CommonName is the subject of the certificate, e.g. "Example PK"
+	CommonName string
+	// Organization is added to the subject if not empty
+	Organization string
+	// Bits is the size of the RSA key, 2048 if 0. Only 2048 and 3072
+	// are allowed, as firmware often supports nothing else.
+	Bits int
+	// Validity is how long the certificate is valid, 10 years if 0.
+	// NotAfter is capped at the end of 2049, as dates from 2050 on are
+	// encoded as GeneralizedTime, which some firmware can't parse.
+	Validity time.Duration
+	// Now is the start of the validity, the current time if zero. It is
+	// backdated by a day against clocks lagging behind.
+	Now time.Time
+}
+
+// maxNotAfter is the last time encoded as UTCTime.
+var maxNotAfter = time.Date(2049, 12, 31, 23, 59, 59, 0, time.UTC)
+
+// GenerateSigner generates an RSA key and a self-signed certificate for
+// it, suited for PK, KEK or db: it may sign code, authenticated variable
+// updates and the certificates of further keys, and it carries a
+// subject key identifier.
+func GenerateSigner(opts KeyOptions) (*Signer, error) {
+	if opts.CommonName == "" {
+		return nil, fmt.Errorf("generating key: no common name")
+	}
+	bits := opts.Bits
+	switch bits {
+	case 0:
+		bits = 2048
+	case 2048, 3072:
+	default:
+		return nil, fmt.Errorf("generating key: unsupported size of %d bits, use 2048 or 3072", bits)
+	}
+	validity := opts.Validity
+	if validity == 0 {
+		validity = 10 * 365 * 24 * time.Hour
+	}
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	notBefore := now.Add(-24 * time.Hour).UTC().Truncate(time.Second)
+	notAfter := now.Add(validity).UTC().Truncate(time.Second)
+	if notAfter.After(maxNotAfter) {
+		notAfter = maxNotAfter
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 127))
+	if err != nil {
+		return nil, err
+	}
+	pub, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	skid := sha1.Sum(pub)
+	subject := pkix.Name{CommonName: opts.CommonName}
+	if opts.Organization != "" {
+		subject.Organization = []string{opts.Organization}
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial.Add(serial, big.NewInt(1)),
+		Subject:               subject,
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		SubjectKeyId:          skid[:],
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &Signer{Certificate: cert, Key: key}, nil
+}
+
+// MarshalPEM returns the PEM encoded certificate and PKCS #1 key of s,
+// the formats sbsign and sign-efi-sig-list take, which fails for keys
+// other than *rsa.PrivateKey. The DER encoding of the certificate, which
+// firmware setup menus usually import, is Certificate.Raw.
+func (s *Signer) MarshalPEM() (cert, key []byte, err error) {
+	cert = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.Certificate.Raw})
+	k, ok := s.Key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf("key of type %T can't be exported", s.Key)
+	}
+	key = pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(k)})
+	return cert, key, nil
+}
@@ -0,0 +1,56 @@
+package secureboot
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestGenerateSigner(t *testing.T) {
+	now := time.Date(2045, 6, 1, 0, 0, 0, 0, time.UTC)
+	s, err := GenerateSigner(KeyOptions{CommonName: "Test PK", Organization: "Example", Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := s.Certificate
+	if c.Subject.CommonName != "Test PK" || c.Subject.Organization[0] != "Example" || c.Subject.String() != c.Issuer.String() {
+		t.Errorf("certificate for %s issued by %s", c.Subject, c.Issuer)
+	}
+	if !c.NotBefore.Before(now) || !c.NotAfter.Equal(maxNotAfter) {
+		t.Errorf("certificate valid from %v to %v, want until the end of 2049", c.NotBefore, c.NotAfter)
+	}
+	if c.PublicKey.(*rsa.PublicKey).N.BitLen() != 2048 || len(c.SubjectKeyId) == 0 ||
+		c.KeyUsage&x509.KeyUsageDigitalSignature == 0 || len(c.ExtKeyUsage) != 1 || c.ExtKeyUsage[0] != x509.ExtKeyUsageCodeSigning {
+		t.Errorf("certificate has unexpected key or extensions: %+v", c)
+	}
+
+	certPEM, keyPEM, err := s.MarshalPEM()
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := ParseCertificates(certPEM)
+	if err != nil || !certs[0].Equal(c) {
+		t.Errorf("ParseCertificates() = %v, %v, want the generated certificate", certs, err)
+	}
+	block, _ := pem.Decode(keyPEM)
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err != nil || !key.Equal(s.Key) {
+		t.Errorf("ParsePKCS1PrivateKey() = %v, want the generated key", err)
+	}
+
+	desc := efivarfs.VariableDescriptor{Name: "PK", GUID: &efivarfs.GlobalVariable}
+	b, err := SignedUpdate(desc, AuthenticatedAttributes, c.Raw, now, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := VerifyAuthPayload(desc, AuthenticatedAttributes, b, []*x509.Certificate{c}, time.Time{}); err != nil {
+		t.Errorf("VerifyAuthPayload() = %v for an update signed with the generated key", err)
+	}
+
+	if _, err := GenerateSigner(KeyOptions{CommonName: "Test PK", Bits: 1024}); err == nil {
+		t.Error("GenerateSigner() accepted a 1024 bit key")
+	}
+}
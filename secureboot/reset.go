@@ -0,0 +1,149 @@
+package secureboot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Descriptors of the Secure Boot variables
+var (
+	PK        = efivarfs.NewDescriptor("PK", efivarfs.GlobalVariable)
+	KEK       = efivarfs.NewDescriptor("KEK", efivarfs.GlobalVariable)
+	DB        = efivarfs.NewDescriptor("db", efivarfs.ImageSecurityDatabase)
+	DBX       = efivarfs.NewDescriptor("dbx", efivarfs.ImageSecurityDatabase)
+	SetupMode = efivarfs.NewDescriptor("SetupMode", efivarfs.GlobalVariable)
+
+	SecureBoot   = efivarfs.NewDescriptor("SecureBoot", efivarfs.GlobalVariable)
+	AuditMode    = efivarfs.NewDescriptor("AuditMode", efivarfs.GlobalVariable)
+	DeployedMode = efivarfs.NewDescriptor("DeployedMode", efivarfs.GlobalVariable)
+)
+
+// ResetOptions controls which keys are removed by Reset.
+type ResetOptions struct {
+	// Backend holds the variables, the efivarfs of the running system
+	// if nil
+	Backend efivarfs.Backend
+
+	// Signer is the owner of the enrolled PK. It signs the deletion of
+	// PK and KEK and may be nil if the platform is in Setup Mode.
+	Signer *Signer
+	// KEKSigner holds a key enrolled in KEK. It signs the deletion of
+	// db and dbx, which firmware only accepts from a KEK, and may be
+	// nil if the platform is in Setup Mode or neither is cleared.
+	KEKSigner *Signer
+
+	// ClearKEK, ClearDB and ClearDBX additionally delete the respective
+	// database. Without them only PK is removed, which is enough to
+	// return to Setup Mode.
+	ClearKEK bool
+	ClearDB  bool
+	ClearDBX bool
+}
+
+// InSetupMode reports whether the platform is in Setup Mode, i.e. has
+// no PK enrolled.
+func InSetupMode() (bool, error) {
+	b, err := efivarfs.Probe()
+	if err != nil {
+		return false, err
+	}
+	return inSetupMode(b)
+}
+
+func inSetupMode(b efivarfs.ReadBackend) (bool, error) {
+	_, data, err := b.Get(SetupMode)
+	if err != nil {
+		return false, err
+	}
+	return len(data) == 1 && data[0] == 1, nil
+}
+
+// Reset deletes the PK, and optionally KEK, db and dbx, which returns
+// the platform to Setup Mode. It is the counterpart of key enrollment.
+// The databases are deleted before PK, as their deletion has to be
+// signed unless the platform is in Setup Mode already: PK and KEK by
+// the PK owner, db and dbx by a KEK. The signers are checked before
+// anything is deleted.
+func Reset(opts ResetOptions) error {
+	b := opts.Backend
+	if b == nil {
+		var err error
+		if b, err = efivarfs.Probe(); err != nil {
+			return err
+		}
+	}
+	setupMode, err := inSetupMode(b)
+	if err != nil {
+		return fmt.Errorf("reading SetupMode: %w", err)
+	}
+	pk, kek := opts.Signer, opts.KEKSigner
+	if setupMode {
+		pk, kek = nil, nil
+	}
+
+	var vars []efivarfs.VariableDescriptor
+	if opts.ClearDBX {
+		vars = append(vars, DBX)
+	}
+	if opts.ClearDB {
+		vars = append(vars, DB)
+	}
+	if opts.ClearKEK {
+		vars = append(vars, KEK)
+	}
+	vars = append(vars, PK)
+
+	if !setupMode {
+		for _, desc := range vars {
+			if err := checkSigner(desc, pk, kek); err != nil {
+				return err
+			}
+		}
+	}
+	for _, desc := range vars {
+		if err := deleteAuthenticated(b, desc, signerFor(desc, pk, kek)); err != nil {
+			return fmt.Errorf("deleting %s: %w", desc.Name, err)
+		}
+	}
+	return nil
+}
+
+// signerFor returns the signer of updates of desc: pk for PK and KEK,
+// kek for db and dbx.
+func signerFor(desc efivarfs.VariableDescriptor, pk, kek *Signer) *Signer {
+	if desc.Equal(DB) || desc.Equal(DBX) {
+		return kek
+	}
+	return pk
+}
+
+// checkSigner fails if the signer signerFor returns for desc is
+// missing outside of Setup Mode.
+func checkSigner(desc efivarfs.VariableDescriptor, pk, kek *Signer) error {
+	if signerFor(desc, pk, kek) != nil {
+		return nil
+	}
+	if desc.Equal(DB) || desc.Equal(DBX) {
+		return fmt.Errorf("platform is not in setup mode, a KEK signer is required for %s", desc.Name)
+	}
+	return fmt.Errorf("platform is not in setup mode, a PK signer is required for %s", desc.Name)
+}
+
+// deleteAuthenticated removes a time based authenticated variable by
+// writing an authentication descriptor without any data.
+func deleteAuthenticated(b efivarfs.Backend, desc efivarfs.VariableDescriptor, signer *Signer) error {
+	if _, _, err := b.Get(desc); err != nil {
+		if errors.Is(err, efivarfs.ErrVarNotExist) {
+			return nil
+		}
+		return err
+	}
+	payload, err := SignedUpdate(desc, AuthenticatedAttributes, nil, time.Now(), signer)
+	if err != nil {
+		return err
+	}
+	return b.Set(desc, AuthenticatedAttributes, payload)
+}
@@ -0,0 +1,69 @@
+package secureboot
+
+import (
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// userModeDir returns a directory with all Secure Boot databases
+// enrolled and SetupMode cleared.
+func userModeDir(t *testing.T) efivarfs.Backend {
+	t.Helper()
+	dir := efivarfs.Dir(t.TempDir())
+	attrs := efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+	if err := dir.Set(SetupMode, attrs, []byte{0}); err != nil {
+		t.Fatal(err)
+	}
+	for _, desc := range []efivarfs.VariableDescriptor{PK, KEK, DB, DBX} {
+		if err := dir.Set(desc, AuthenticatedAttributes, []byte(desc.Name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResetUserMode(t *testing.T) {
+	pk := testSigner(t, "PK")
+	kek := testSigner(t, "KEK")
+	signers := map[string]*x509.Certificate{
+		"PK":  pk.Certificate,
+		"KEK": pk.Certificate,
+		"db":  kek.Certificate,
+		"dbx": kek.Certificate,
+	}
+
+	var got []string
+	c := efivarfs.NewClient(userModeDir(t), efivarfs.WithDryRun(func(c efivarfs.Change) {
+		got = append(got, c.Desc.Name)
+		if _, err := VerifyAuthPayload(c.Desc, c.Attributes, c.Data, []*x509.Certificate{signers[c.Desc.Name]}, time.Time{}); err != nil {
+			t.Errorf("deletion of %s: %v", c.Desc.Name, err)
+		}
+	}))
+	opts := ResetOptions{Backend: c, Signer: pk, KEKSigner: kek, ClearKEK: true, ClearDB: true, ClearDBX: true}
+	if err := Reset(opts); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 4 || got[0] != "dbx" || got[1] != "db" || got[2] != "KEK" || got[3] != "PK" {
+		t.Errorf("deleted %v, want dbx, db, KEK, PK", got)
+	}
+
+	for _, tt := range []struct {
+		name string
+		opts ResetOptions
+	}{
+		{"without KEK signer", ResetOptions{Signer: pk, ClearDB: true}},
+		{"without PK signer", ResetOptions{KEKSigner: kek, ClearDBX: true}},
+	} {
+		got = nil
+		tt.opts.Backend = c
+		if err := Reset(tt.opts); err == nil {
+			t.Errorf("%s: Reset() succeeded", tt.name)
+		}
+		if len(got) != 0 {
+			t.Errorf("%s: deleted %v before failing", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,229 @@
+package secureboot
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/system-transparency/efivar/authenticode"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// SbatLevelRT is the runtime copy of the SBAT revocations shim applies,
+// which it keeps in the boot services only variable SbatLevel.
+var SbatLevelRT = efivarfs.NewDescriptor("SbatLevelRT", efivarfs.ShimLock)
+
+var (
+	// ErrMalformedSBAT is returned for SBAT data that isn't valid CSV
+	// with a numeric generation in the second column
+	ErrMalformedSBAT = errors.New("malformed SBAT data")
+
+	// ErrNoSBAT is returned for images without .sbat section, which
+	// shim refuses to load
+	ErrNoSBAT = errors.New("image has no .sbat section")
+
+	// ErrSBATRevoked is wrapped by the *SBATRevokedError returned for
+	// images shim would refuse to load
+	ErrSBATRevoked = errors.New("revoked by SBAT")
+)
+
+// SBATEntry is a line of SBAT data: a component and its generation,
+// which is increased whenever a vulnerability requires revoking all
+// earlier builds. The vendor fields are only present in the .sbat
+// sections of images.
+type SBATEntry struct {
+	Component     string
+	Generation    int
+	VendorName    string
+	VendorPackage string
+	VendorVersion string
+	VendorURL     string
+}
+
+// SBATLevel holds the revocations of SbatLevel, the minimum generation
+// of each component shim accepts. The first entry is the version of
+// the SBAT format itself, component "sbat".
+type SBATLevel struct {
+	// Datestamp identifies the revocations, e.g. "2023012900"
+	Datestamp string
+	Entries   []SBATEntry
+}
+
+// parseSBAT parses SBAT CSV, which ends at the first NUL as variables
+// and sections are padded with them.
+func parseSBAT(data []byte) ([][]string, error) {
+	if i := bytes.IndexByte(data, 0); i >= 0 {
+		data = data[:i]
+	}
+	r := csv.NewReader(bytes.NewReader(data))
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	var records [][]string
+	for {
+		rec, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrMalformedSBAT, err)
+		}
+		if len(rec) < 2 || rec[0] == "" {
+			return nil, fmt.Errorf("%w: line %q", ErrMalformedSBAT, strings.Join(rec, ","))
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: no entries", ErrMalformedSBAT)
+	}
+	return records, nil
+}
+
+// parseEntry returns the entry of rec, which parseSBAT checked to have
+// at least two fields.
+func parseEntry(rec []string) (SBATEntry, error) {
+	gen, err := strconv.Atoi(strings.TrimSpace(rec[1]))
+	if err != nil || gen < 0 {
+		return SBATEntry{}, fmt.Errorf("%w: generation %q of %s", ErrMalformedSBAT, rec[1], rec[0])
+	}
+	e := SBATEntry{Component: rec[0], Generation: gen}
+	for i, f := range []*string{&e.VendorName, &e.VendorPackage, &e.VendorVersion, &e.VendorURL} {
+		if len(rec) > i+2 {
+			*f = rec[i+2]
+		}
+	}
+	return e, nil
+}
+
+// ParseSBATLevel decodes the content of SbatLevel or SbatLevelRT, e.g.
+// "sbat,1,2023012900\nshim,2\ngrub,3\ngrub.debian,4\n".
+func ParseSBATLevel(data []byte) (*SBATLevel, error) {
+	records, err := parseSBAT(data)
+	if err != nil {
+		return nil, err
+	}
+	if records[0][0] != "sbat" {
+		return nil, fmt.Errorf("%w: first entry is %s instead of sbat", ErrMalformedSBAT, records[0][0])
+	}
+	l := &SBATLevel{}
+	if len(records[0]) > 2 {
+		l.Datestamp = records[0][2]
+	}
+	for _, rec := range records {
+		// Revocations have no vendor fields, the third field of the
+		// first entry is the datestamp.
+		e, err := parseEntry(rec[:2])
+		if err != nil {
+			return nil, err
+		}
+		l.Entries = append(l.Entries, e)
+	}
+	return l, nil
+}
+
+// ReadSBATLevel reads the revocations from SbatLevelRT in b. It returns
+// nil if the variable doesn't exist, e.g. as the system wasn't booted
+// through shim.
+func ReadSBATLevel(b efivarfs.ReadBackend) (*SBATLevel, error) {
+	_, data, err := b.Get(SbatLevelRT)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseSBATLevel(data)
+}
+
+// ParseSBAT decodes the content of the .sbat section of an image, e.g.
+// "sbat,1,SBAT Version,sbat,1,https://github.com/rhboot/shim/blob/main/SBAT.md\n".
+func ParseSBAT(data []byte) ([]SBATEntry, error) {
+	records, err := parseSBAT(data)
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]SBATEntry, len(records))
+	for i, rec := range records {
+		if entries[i], err = parseEntry(rec); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// ImageSBAT returns the entries of the .sbat section of a PE/COFF
+// image, or an error wrapping ErrNoSBAT if it has none.
+func ImageSBAT(image []byte) ([]SBATEntry, error) {
+	img, err := authenticode.Parse(image)
+	if err != nil {
+		return nil, err
+	}
+	section := img.Section(".sbat")
+	if section == nil {
+		return nil, ErrNoSBAT
+	}
+	return ParseSBAT(section)
+}
+
+// SBATRevokedError lists the entries of an image that are older than
+// the generation SbatLevel requires.
+type SBATRevokedError struct {
+	// Revoked are the entries of the image
+	Revoked []SBATEntry
+	// Required are the matching entries of SbatLevel
+	Required []SBATEntry
+}
+
+func (e *SBATRevokedError) Error() string {
+	s := make([]string, len(e.Revoked))
+	for i := range e.Revoked {
+		s[i] = fmt.Sprintf("%s generation %d < %d", e.Revoked[i].Component, e.Revoked[i].Generation, e.Required[i].Generation)
+	}
+	return fmt.Sprintf("%v: %s", ErrSBATRevoked, strings.Join(s, ", "))
+}
+
+// Is makes errors.Is(err, ErrSBATRevoked) work.
+func (e *SBATRevokedError) Is(target error) bool {
+	return target == ErrSBATRevoked
+}
+
+// Check predicts whether shim loads an image with the .sbat entries,
+// which it refuses with a *SBATRevokedError if an entry has a lower
+// generation than the entry of the same component in l. Components l
+// doesn't mention are accepted.
+func (l *SBATLevel) Check(entries []SBATEntry) error {
+	var err *SBATRevokedError
+	for _, e := range entries {
+		for _, r := range l.Entries {
+			if r.Component == e.Component && e.Generation < r.Generation {
+				if err == nil {
+					err = &SBATRevokedError{}
+				}
+				err.Revoked = append(err.Revoked, e)
+				err.Required = append(err.Required, r)
+			}
+		}
+	}
+	if err != nil {
+		return err
+	}
+	return nil
+}
+
+// CheckImageSBAT predicts whether shim loads image given the
+// revocations in SbatLevelRT of b. Without SbatLevelRT only images
+// without .sbat section are refused.
+func CheckImageSBAT(b efivarfs.ReadBackend, image []byte) error {
+	entries, err := ImageSBAT(image)
+	if err != nil {
+		return err
+	}
+	l, err := ReadSBATLevel(b)
+	if err != nil || l == nil {
+		return err
+	}
+	return l.Check(entries)
+}
@@ -0,0 +1,62 @@
+package secureboot
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestSBAT(t *testing.T) {
+	// URLs are left out to fit into the section of testImage.
+	const grub = "sbat,1,SBAT Version,sbat,1,\n" +
+		"grub,3,Free Software Foundation,grub,2.06,\n" +
+		"grub.debian,4,Debian,grub2,2.06-13,\n"
+	image := testImage(func(b []byte, _, section int) {
+		copy(b[section:], ".sbat")
+		copy(b[0x180:], grub)
+	})
+	entries, err := ImageSBAT(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 || entries[1] != (SBATEntry{"grub", 3, "Free Software Foundation", "grub", "2.06", ""}) {
+		t.Errorf("ImageSBAT() = %+v", entries)
+	}
+	if _, err := ImageSBAT(testImage(func([]byte, int, int) {})); !errors.Is(err, ErrNoSBAT) {
+		t.Errorf("ImageSBAT() = %v without .sbat section, want ErrNoSBAT", err)
+	}
+
+	dir := efivarfs.Dir(t.TempDir())
+	if err := CheckImageSBAT(dir, image); err != nil {
+		t.Errorf("CheckImageSBAT() = %v without SbatLevelRT", err)
+	}
+	for _, tt := range []struct {
+		level   string
+		revoked bool
+	}{
+		{"sbat,1,2022052400\ngrub,2\n", false},
+		{"sbat,1,2023012900\nshim,2\ngrub,3\ngrub.debian,4\n\x00", false},
+		{"sbat,1,2024010900\nshim,4\ngrub,4\ngrub.peimage,2\n", true},
+		{"sbat,1,2024010900\ngrub.debian,5\n", true},
+	} {
+		attrs := efivarfs.AttributeBootserviceAccess | efivarfs.AttributeRuntimeAccess
+		if err := dir.Set(SbatLevelRT, attrs, []byte(tt.level)); err != nil {
+			t.Fatal(err)
+		}
+		err := CheckImageSBAT(dir, image)
+		var revoked *SBATRevokedError
+		if errors.As(err, &revoked) != tt.revoked || !tt.revoked && err != nil {
+			t.Errorf("CheckImageSBAT() = %v with SbatLevel %q, want revoked %v", err, tt.level, tt.revoked)
+		}
+	}
+	l, err := ParseSBATLevel([]byte("sbat,1,2024010900\nshim,4\n"))
+	if err != nil || l.Datestamp != "2024010900" || len(l.Entries) != 2 || l.Entries[1] != (SBATEntry{Component: "shim", Generation: 4}) {
+		t.Errorf("ParseSBATLevel() = %+v, %v", l, err)
+	}
+	for _, bad := range []string{"", "grub,1\n", "sbat,one\n", "sbat,1\ngrub\n"} {
+		if _, err := ParseSBATLevel([]byte(bad)); !errors.Is(err, ErrMalformedSBAT) {
+			t.Errorf("ParseSBATLevel(%q) = %v, want ErrMalformedSBAT", bad, err)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package secureboot
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// SignatureSupport lists the signature types the firmware accepts in
+// the signature databases.
+var SignatureSupport = efivarfs.NewDescriptor("SignatureSupport", efivarfs.GlobalVariable)
+
+// ErrUnsupportedSignatureType is caused by writing signatures of a type
+// the firmware doesn't list in SignatureSupport.
+var ErrUnsupportedSignatureType = errors.New("signature type not supported by the firmware")
+
+// signatureTypeNames are the names of the signature types of the UEFI
+// specification.
+var signatureTypeNames = map[guid.UUID]string{
+	CertSHA256GUID:     "SHA256",
+	CertRSA2048GUID:    "RSA2048",
+	CertSHA1GUID:       "SHA1",
+	CertSHA384GUID:     "SHA384",
+	CertSHA512GUID:     "SHA512",
+	CertX509GUID:       "X509",
+	CertX509SHA256GUID: "X509_SHA256",
+}
+
+// SignatureTypeName returns the name of the signature type typ as used
+// by the UEFI specification, e.g. X509 for CertX509GUID, or the GUID if
+// it is unknown.
+func SignatureTypeName(typ guid.UUID) string {
+	if name, ok := signatureTypeNames[typ]; ok {
+		return name
+	}
+	return typ.String()
+}
+
+// SignatureTypes is the content of SignatureSupport: the GUIDs of the
+// signature types the firmware accepts.
+type SignatureTypes []guid.UUID
+
+// ParseSignatureTypes decodes the array of GUIDs stored in
+// SignatureSupport.
+func ParseSignatureTypes(b []byte) (SignatureTypes, error) {
+	if len(b)%16 != 0 {
+		return nil, fmt.Errorf("SignatureSupport of %d bytes is no array of GUIDs", len(b))
+	}
+	types := make(SignatureTypes, 0, len(b)/16)
+	for ; len(b) > 0; b = b[16:] {
+		types = append(types, efivarfs.DecodeGUID(b[:16]))
+	}
+	return types, nil
+}
+
+// ReadSignatureTypes reads SignatureSupport from b. It returns nil
+// without error if the firmware doesn't provide the variable.
+func ReadSignatureTypes(b efivarfs.ReadBackend) (SignatureTypes, error) {
+	_, data, err := b.Get(SignatureSupport)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseSignatureTypes(data)
+}
+
+// Has reports whether typ is one of the supported types.
+func (t SignatureTypes) Has(typ guid.UUID) bool {
+	for _, s := range t {
+		if s == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Check returns ErrUnsupportedSignatureType if db holds signatures of a
+// type not in t. A nil t, as returned for firmware not providing
+// SignatureSupport, accepts everything.
+func (t SignatureTypes) Check(db SignatureDatabase) error {
+	if t == nil {
+		return nil
+	}
+	for _, l := range db {
+		if !t.Has(l.Type) {
+			return fmt.Errorf("%s: %w", SignatureTypeName(l.Type), ErrUnsupportedSignatureType)
+		}
+	}
+	return nil
+}
+
+// String lists the names of the types separated by commas.
+func (t SignatureTypes) String() string {
+	names := make([]string, len(t))
+	for i, typ := range t {
+		names[i] = SignatureTypeName(typ)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,37 @@
+package secureboot
+
+import (
+	"errors"
+	"testing"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestSignatureTypes(t *testing.T) {
+	var b []byte
+	for _, typ := range []guid.UUID{CertSHA256GUID, CertX509GUID} {
+		e := efivarfs.EncodeGUID(typ)
+		b = append(b, e[:]...)
+	}
+	types, err := ParseSignatureTypes(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := types.String(); s != "SHA256, X509" {
+		t.Errorf("String() = %q", s)
+	}
+	if _, err := ParseSignatureTypes(b[1:]); err == nil {
+		t.Error("parsed truncated SignatureSupport")
+	}
+	hashes := SignatureDatabase{NewSHA256SignatureList(guid.Nil, [32]byte{})}
+	if err := types.Check(hashes); err != nil {
+		t.Errorf("Check(SHA256) = %v", err)
+	}
+	if err := (SignatureTypes{CertX509GUID}).Check(hashes); !errors.Is(err, ErrUnsupportedSignatureType) {
+		t.Errorf("Check(SHA256) = %v, want ErrUnsupportedSignatureType", err)
+	}
+	if err := SignatureTypes(nil).Check(hashes); err != nil {
+		t.Errorf("Check without SignatureSupport = %v", err)
+	}
+}
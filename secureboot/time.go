@@ -0,0 +1,116 @@
+package secureboot
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// EFITimeSize is the size of the encoded EFI_TIME structure
+const EFITimeSize = 16
+
+// EFI_UNSPECIFIED_TIMEZONE as defined by the UEFI specification
+const UnspecifiedTimeZone = 0x07FF
+
+var (
+	// ErrInvalidEFITime is returned when decoding malformed EFI_TIME structures
+	ErrInvalidEFITime = errors.New("invalid EFI_TIME")
+
+	// ErrNotAuthTime is returned if an EFI_TIME doesn't meet the
+	// requirements of authentication descriptors
+	ErrNotAuthTime = errors.New("EFI_TIME of authentication descriptors must have zero Pad1, Nanosecond, TimeZone, Daylight and Pad2")
+)
+
+// EFITime is the EFI_TIME structure of the UEFI specification.
+type EFITime struct {
+	Year       uint16
+	Month      uint8
+	Day        uint8
+	Hour       uint8
+	Minute     uint8
+	Second     uint8
+	Pad1       uint8
+	Nanosecond uint32
+	TimeZone   int16
+	Daylight   uint8
+	Pad2       uint8
+}
+
+// NewEFITime converts t to the form authentication descriptors require:
+// UTC with Pad1, Nanosecond, TimeZone, Daylight and Pad2 set to zero.
+func NewEFITime(t time.Time) EFITime {
+	t = t.UTC()
+	return EFITime{
+		Year:   uint16(t.Year()),
+		Month:  uint8(t.Month()),
+		Day:    uint8(t.Day()),
+		Hour:   uint8(t.Hour()),
+		Minute: uint8(t.Minute()),
+		Second: uint8(t.Second()),
+	}
+}
+
+// Time returns t as time.Time. A TimeZone other than
+// UnspecifiedTimeZone is the offset to UTC in minutes.
+func (t EFITime) Time() time.Time {
+	loc := time.UTC
+	if t.TimeZone != 0 && t.TimeZone != UnspecifiedTimeZone {
+		loc = time.FixedZone("", -int(t.TimeZone)*60)
+	}
+	return time.Date(int(t.Year), time.Month(t.Month), int(t.Day),
+		int(t.Hour), int(t.Minute), int(t.Second), int(t.Nanosecond), loc)
+}
+
+// ValidForAuth checks the requirements the specification places on the
+// timestamp of time based authenticated variables.
+func (t EFITime) ValidForAuth() error {
+	if t.Pad1 != 0 || t.Nanosecond != 0 || t.TimeZone != 0 || t.Daylight != 0 || t.Pad2 != 0 {
+		return ErrNotAuthTime
+	}
+	return nil
+}
+
+// MarshalBinary returns the 16 byte little endian encoding of t.
+func (t EFITime) MarshalBinary() ([]byte, error) {
+	b := make([]byte, EFITimeSize)
+	binary.LittleEndian.PutUint16(b[0:2], t.Year)
+	b[2] = t.Month
+	b[3] = t.Day
+	b[4] = t.Hour
+	b[5] = t.Minute
+	b[6] = t.Second
+	b[7] = t.Pad1
+	binary.LittleEndian.PutUint32(b[8:12], t.Nanosecond)
+	binary.LittleEndian.PutUint16(b[12:14], uint16(t.TimeZone))
+	b[14] = t.Daylight
+	b[15] = t.Pad2
+	return b, nil
+}
+
+// UnmarshalBinary decodes an EFI_TIME and checks its fields for
+// plausibility.
+func (t *EFITime) UnmarshalBinary(b []byte) error {
+	if len(b) < EFITimeSize {
+		return fmt.Errorf("%w: need %d bytes, got %d", ErrInvalidEFITime, EFITimeSize, len(b))
+	}
+	v := EFITime{
+		Year:       binary.LittleEndian.Uint16(b[0:2]),
+		Month:      b[2],
+		Day:        b[3],
+		Hour:       b[4],
+		Minute:     b[5],
+		Second:     b[6],
+		Pad1:       b[7],
+		Nanosecond: binary.LittleEndian.Uint32(b[8:12]),
+		TimeZone:   int16(binary.LittleEndian.Uint16(b[12:14])),
+		Daylight:   b[14],
+		Pad2:       b[15],
+	}
+	if v.Month < 1 || v.Month > 12 || v.Day < 1 || v.Day > 31 || v.Hour > 23 ||
+		v.Minute > 59 || v.Second > 59 || v.Nanosecond > 999999999 {
+		return fmt.Errorf("%w: out of range field", ErrInvalidEFITime)
+	}
+	*t = v
+	return nil
+}
@@ -0,0 +1,82 @@
+package secureboot
+
+import (
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestEFITime(t *testing.T) {
+	local := time.Date(2024, 3, 5, 8, 7, 8, 123, time.FixedZone("CEST", 2*60*60))
+	et := NewEFITime(local)
+	if err := et.ValidForAuth(); err != nil {
+		t.Errorf("ValidForAuth() = %v for NewEFITime()", err)
+	}
+	b, err := et.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	const want = "e8070305060708000000000000000000"
+	if hex.EncodeToString(b) != want {
+		t.Errorf("MarshalBinary() = %x, want %s", b, want)
+	}
+	var got EFITime
+	if err := got.UnmarshalBinary(b); err != nil || got != et {
+		t.Errorf("UnmarshalBinary() = %+v, %v, want %+v", got, err, et)
+	}
+	if tm := got.Time(); !tm.Equal(local.Truncate(time.Second)) || tm.Location() != time.UTC {
+		t.Errorf("Time() = %v, want %v in UTC", tm, local)
+	}
+
+	// Local time is UTC minus TimeZone minutes
+	et.TimeZone = 60
+	if tm := et.Time(); !tm.Equal(time.Date(2024, 3, 5, 7, 7, 8, 0, time.UTC)) {
+		t.Errorf("Time() = %v with a TimeZone of 60", tm)
+	}
+	et.TimeZone = UnspecifiedTimeZone
+	if tm := et.Time(); tm.Location() != time.UTC {
+		t.Errorf("Time() = %v with an unspecified TimeZone, want UTC", tm)
+	}
+}
+
+func TestEFITimeValidForAuth(t *testing.T) {
+	base := NewEFITime(time.Date(2024, 3, 5, 6, 7, 8, 0, time.UTC))
+	for _, fn := range []func(*EFITime){
+		func(t *EFITime) { t.Pad1 = 1 },
+		func(t *EFITime) { t.Nanosecond = 1 },
+		func(t *EFITime) { t.TimeZone = UnspecifiedTimeZone },
+		func(t *EFITime) { t.TimeZone = -60 },
+		func(t *EFITime) { t.Daylight = 1 },
+		func(t *EFITime) { t.Pad2 = 1 },
+	} {
+		et := base
+		fn(&et)
+		if err := et.ValidForAuth(); !errors.Is(err, ErrNotAuthTime) {
+			t.Errorf("ValidForAuth() = %v for %+v, want ErrNotAuthTime", err, et)
+		}
+	}
+}
+
+func TestEFITimeInvalid(t *testing.T) {
+	valid, _ := hex.DecodeString("e8070305060708000000000000000000")
+	for _, tt := range []struct {
+		name string
+		fn   func(b []byte) []byte
+	}{
+		{"short", func(b []byte) []byte { return b[:EFITimeSize-1] }},
+		{"month 0", func(b []byte) []byte { b[2] = 0; return b }},
+		{"month 13", func(b []byte) []byte { b[2] = 13; return b }},
+		{"day 0", func(b []byte) []byte { b[3] = 0; return b }},
+		{"day 32", func(b []byte) []byte { b[3] = 32; return b }},
+		{"hour 24", func(b []byte) []byte { b[4] = 24; return b }},
+		{"minute 60", func(b []byte) []byte { b[5] = 60; return b }},
+		{"second 60", func(b []byte) []byte { b[6] = 60; return b }},
+		{"nanosecond 1e9", func(b []byte) []byte { copy(b[8:], []byte{0x00, 0xca, 0x9a, 0x3b}); return b }},
+	} {
+		var et EFITime
+		if err := et.UnmarshalBinary(tt.fn(append([]byte(nil), valid...))); !errors.Is(err, ErrInvalidEFITime) {
+			t.Errorf("UnmarshalBinary() = %v with %s, want ErrInvalidEFITime", err, tt.name)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package secureboot
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Status is the Secure Boot state of a platform.
+type Status struct {
+	// SecureBoot is set if the firmware enforces Secure Boot
+	SecureBoot bool
+	// SetupMode is set if no PK is enrolled
+	SetupMode bool
+	// AuditMode and DeployedMode are the modes added by UEFI 2.5
+	AuditMode    bool
+	DeployedMode bool
+
+	// PK, KEK, DB and DBX are the enrolled keys and signatures
+	PK, KEK, DB, DBX SignatureDatabase
+}
+
+// ReadStatus reads the Secure Boot state from b. Missing variables
+// count as disabled or empty, as firmware without Secure Boot support
+// doesn't provide them at all.
+func ReadStatus(b efivarfs.ReadBackend) (*Status, error) {
+	s := &Status{}
+	for _, f := range []struct {
+		desc efivarfs.VariableDescriptor
+		flag *bool
+	}{
+		{SecureBoot, &s.SecureBoot},
+		{SetupMode, &s.SetupMode},
+		{AuditMode, &s.AuditMode},
+		{DeployedMode, &s.DeployedMode},
+	} {
+		_, data, err := b.Get(f.desc)
+		switch {
+		case errors.Is(err, efivarfs.ErrVarNotExist):
+		case err != nil:
+			return nil, fmt.Errorf("reading %s: %w", f.desc.Name, err)
+		default:
+			*f.flag = len(data) == 1 && data[0] == 1
+		}
+	}
+	for _, d := range []struct {
+		desc efivarfs.VariableDescriptor
+		db   *SignatureDatabase
+	}{
+		{PK, &s.PK},
+		{KEK, &s.KEK},
+		{DB, &s.DB},
+		{DBX, &s.DBX},
+	} {
+		db, err := readDatabase(b, d.desc)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", d.desc.Name, err)
+		}
+		*d.db = db
+	}
+	return s, nil
+}
+
+// Write replaces the signature database desc, e.g. DB, with db. The
+// update is signed by signer, which may be nil for platforms in Setup
+// Mode except when writing PK, which has to be signed by its own key.
+// Signature types missing from SignatureSupport are refused with
+// ErrUnsupportedSignatureType before anything is written.
+func Write(b efivarfs.Backend, desc efivarfs.VariableDescriptor, db SignatureDatabase, signer *Signer) error {
+	return write(b, desc, AuthenticatedAttributes, db, signer)
+}
+
+// Append adds the signatures of db to the signature database desc,
+// e.g. new hashes to DBX. Firmware skips signatures that are already
+// present. signer may be nil for platforms in Setup Mode.
+func Append(b efivarfs.Backend, desc efivarfs.VariableDescriptor, db SignatureDatabase, signer *Signer) error {
+	return write(b, desc, AuthenticatedAttributes|efivarfs.AttributeAppendWrite, db, signer)
+}
+
+func write(b efivarfs.Backend, desc efivarfs.VariableDescriptor, attrs efivarfs.VariableAttributes, db SignatureDatabase, signer *Signer) error {
+	supported, err := ReadSignatureTypes(b)
+	if err != nil {
+		return fmt.Errorf("reading SignatureSupport: %w", err)
+	}
+	if err := supported.Check(db); err != nil {
+		return err
+	}
+	data, err := db.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	payload, err := SignedUpdate(desc, attrs, data, time.Now(), signer)
+	if err != nil {
+		return err
+	}
+	return b.Set(desc, attrs, payload)
+}
+
+// WriteAuthPayload writes a payload signed elsewhere, like the .auth
+// files distributed with dbx updates, to desc. append has to match the
+// attributes the payload was signed with.
+func WriteAuthPayload(b efivarfs.Backend, desc efivarfs.VariableDescriptor, payload []byte, append bool) error {
+	if _, err := ParseAuthPayload(payload); err != nil {
+		return err
+	}
+	attrs := AuthenticatedAttributes
+	if append {
+		attrs |= efivarfs.AttributeAppendWrite
+	}
+	return b.Set(desc, attrs, payload)
+}
@@ -0,0 +1,167 @@
+package secureboot
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/system-transparency/efivar/authenticode"
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// ErrMalformedImage is returned for files that aren't valid PE/COFF images
+var ErrMalformedImage = authenticode.ErrMalformedImage
+
+// ImageVerdict is the result of checking an EFI binary against the
+// signature databases.
+type ImageVerdict struct {
+	// Hash is the SHA-256 Authenticode hash of the image
+	Hash []byte
+	// Authorized is set if the image is allowed by db, either by hash
+	// or by a certificate of a valid signature chain
+	Authorized bool
+	// Revoked is set if the image, or a certificate it was signed
+	// with, is forbidden by dbx
+	Revoked bool
+	// Reason describes why the verdict was reached
+	Reason string
+}
+
+// Allowed reports whether the firmware would execute the image.
+func (v *ImageVerdict) Allowed() bool {
+	return v.Authorized && !v.Revoked
+}
+
+// CheckImage computes the Authenticode hash of the PE/COFF image and
+// checks it against db and dbx the way firmware does on Secure Boot:
+// the image is rejected if its hash is in dbx, a signer chains up to a
+// certificate in dbx or a certificate on the path from a signer to db
+// has its TBS hash in dbx, and it is authorized if its hash is in db or
+// one of its valid signers chains up to a certificate in db. Only the
+// paths from the signers count, other certificates embedded in a
+// signature are ignored.
+func CheckImage(image []byte, db, dbx SignatureDatabase) (*ImageVerdict, error) {
+	img, err := authenticode.Parse(image)
+	if err != nil {
+		return nil, err
+	}
+	v := &ImageVerdict{Hash: img.Hash(crypto.SHA256)}
+
+	if containsHash(dbx.Hashes(CertSHA256GUID), v.Hash) {
+		v.Revoked = true
+		v.Reason = "image hash is revoked by dbx"
+		return v, nil
+	}
+
+	sigs, err := img.RawSignatures()
+	if err != nil {
+		return nil, err
+	}
+	dbCerts, err := db.Certificates()
+	if err != nil {
+		return nil, fmt.Errorf("db: %w", err)
+	}
+	dbxCerts, err := dbx.Certificates()
+	if err != nil {
+		return nil, fmt.Errorf("dbx: %w", err)
+	}
+
+	for _, raw := range sigs {
+		sig, err := authenticode.ParseSignature(raw)
+		if err != nil {
+			continue
+		}
+		signers, err := sig.Verify(img)
+		if err != nil {
+			// Invalid signatures are ignored just like firmware does
+			continue
+		}
+		for _, signer := range signers {
+			if path := sig.Chain(signer, dbxCerts); path != nil {
+				v.Revoked = true
+				v.Reason = fmt.Sprintf("signing certificate %q is revoked by %q in dbx", signer.Subject, path[len(path)-1].Subject)
+				return v, nil
+			}
+			path := sig.Chain(signer, dbCerts)
+			onPath := path
+			if onPath == nil {
+				onPath = []*x509.Certificate{signer}
+			}
+			for _, c := range onPath {
+				tbs := sha256.Sum256(c.RawTBSCertificate)
+				if containsHash(dbx.Hashes(CertX509SHA256GUID), tbs[:]) {
+					v.Revoked = true
+					v.Reason = fmt.Sprintf("certificate %q is revoked by TBS hash in dbx", c.Subject)
+					return v, nil
+				}
+			}
+			if path == nil || v.Authorized {
+				continue
+			}
+			v.Authorized = true
+			if len(path) == 1 {
+				v.Reason = fmt.Sprintf("signed by %q from db", signer.Subject)
+			} else {
+				v.Reason = fmt.Sprintf("signature chains up to %q from db", path[len(path)-1].Subject)
+			}
+		}
+	}
+	if v.Authorized {
+		return v, nil
+	}
+
+	if containsHash(db.Hashes(CertSHA256GUID), v.Hash) {
+		v.Authorized = true
+		v.Reason = "image hash is authorized by db"
+		return v, nil
+	}
+	if len(sigs) == 0 {
+		v.Reason = "image is unsigned and its hash is not in db"
+	} else {
+		v.Reason = "no signature chains up to a certificate in db"
+	}
+	return v, nil
+}
+
+// CheckImageVariables is like CheckImage but reads db and dbx from the
+// running system.
+func CheckImageVariables(image []byte) (*ImageVerdict, error) {
+	b, err := efivarfs.Probe()
+	if err != nil {
+		return nil, err
+	}
+	db, err := readDatabase(b, DB)
+	if err != nil {
+		return nil, fmt.Errorf("reading db: %w", err)
+	}
+	dbx, err := readDatabase(b, DBX)
+	if err != nil {
+		return nil, fmt.Errorf("reading dbx: %w", err)
+	}
+	return CheckImage(image, db, dbx)
+}
+
+// readDatabase reads and parses a signature database variable. A
+// missing variable is treated as an empty database.
+func readDatabase(b efivarfs.ReadBackend, desc efivarfs.VariableDescriptor) (SignatureDatabase, error) {
+	_, data, err := b.Get(desc)
+	if errors.Is(err, efivarfs.ErrVarNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ParseSignatureDatabase(data)
+}
+
+func containsHash(hashes [][]byte, h []byte) bool {
+	for _, x := range hashes {
+		if bytes.Equal(x, h) {
+			return true
+		}
+	}
+	return false
+}
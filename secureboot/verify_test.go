@@ -0,0 +1,189 @@
+package secureboot
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	guid "github.com/google/uuid"
+	"github.com/system-transparency/efivar/authenticode"
+	"github.com/system-transparency/efivar/internal/pkcs7"
+)
+
+// testIssue returns a signer with a certificate for cn issued by parent,
+// or a self-signed one if parent is nil.
+func testIssue(t *testing.T, cn string, parent *Signer, ca bool) *Signer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: cn},
+		NotBefore:             time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2034, 1, 1, 0, 0, 0, 0, time.UTC),
+		BasicConstraintsValid: true,
+		IsCA:                  ca,
+	}
+	issuer, issuerKey := tmpl, any(key)
+	if parent != nil {
+		issuer, issuerKey = parent.Certificate, parent.Key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, issuer, &key.PublicKey, issuerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Signer{Certificate: cert, Key: key}
+}
+
+// testSignImage returns testImage with an Authenticode signature by s
+// that embeds the additional certificates bag.
+func testSignImage(t *testing.T, s *Signer, bag ...*x509.Certificate) []byte {
+	t.Helper()
+	image := testImage(func([]byte, int, int) {})
+	img, err := authenticode.Parse(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var content struct {
+		Data struct {
+			Type asn1.ObjectIdentifier
+		}
+		MessageDigest struct {
+			DigestAlgorithm struct {
+				Algorithm  asn1.ObjectIdentifier
+				Parameters asn1.RawValue
+			}
+			Digest []byte
+		}
+	}
+	content.Data.Type = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 1, 15}
+	content.MessageDigest.DigestAlgorithm.Algorithm = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	content.MessageDigest.DigestAlgorithm.Parameters = asn1.NullRawValue
+	content.MessageDigest.Digest = img.Hash(crypto.SHA256)
+	der, err := asn1.Marshal(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Authenticode signs the content octets without the SEQUENCE header
+	var seq asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &seq); err != nil {
+		t.Fatal(err)
+	}
+	sig, err := pkcs7.Sign(seq.Bytes, s.Certificate, s.Key, pkcs7.SignOptions{ContentInfo: true, Attached: true, Certificates: bag})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	length := 8 + len(sig)
+	entry := make([]byte, (length+7)&^7)
+	binary.LittleEndian.PutUint32(entry[0:], uint32(length))
+	binary.LittleEndian.PutUint16(entry[4:], 0x0200)
+	binary.LittleEndian.PutUint16(entry[6:], 0x0002)
+	copy(entry[8:], sig)
+	certDir := 0x58 + 112 + 4*8
+	binary.LittleEndian.PutUint32(image[certDir:], uint32(len(image)))
+	binary.LittleEndian.PutUint32(image[certDir+4:], uint32(len(entry)))
+	return append(image, entry...)
+}
+
+func TestCheckImage(t *testing.T) {
+	ca := testIssue(t, "DB CA", nil, true)
+	intermediate := testIssue(t, "Intermediate CA", ca, true)
+	leaf := testIssue(t, "Leaf", ca, false)
+	chained := testIssue(t, "Chained Leaf", intermediate, false)
+	attacker := testIssue(t, "Attacker", nil, false)
+	unrelated := testIssue(t, "Unrelated", nil, false)
+
+	db := SignatureDatabase{NewX509SignatureList(guid.UUID{}, ca.Certificate)}
+	tbs := func(c *x509.Certificate) SignatureList {
+		h := sha256.Sum256(c.RawTBSCertificate)
+		return SignatureList{Type: CertX509SHA256GUID, Signatures: []SignatureData{{Data: h[:]}}}
+	}
+	for _, tt := range []struct {
+		name       string
+		image      []byte
+		dbx        SignatureDatabase
+		authorized bool
+		revoked    bool
+		reason     string
+	}{
+		{
+			name:  "db certificate only embedded",
+			image: testSignImage(t, attacker, ca.Certificate),
+		},
+		{
+			name:  "certificate issued by db only embedded",
+			image: testSignImage(t, attacker, leaf.Certificate),
+		},
+		{
+			name:       "issued by db",
+			image:      testSignImage(t, leaf),
+			authorized: true,
+			reason:     `chains up to "CN=DB CA" from db`,
+		},
+		{
+			name:       "intermediate embedded",
+			image:      testSignImage(t, chained, intermediate.Certificate),
+			authorized: true,
+			reason:     `chains up to "CN=DB CA" from db`,
+		},
+		{
+			name:  "intermediate missing",
+			image: testSignImage(t, chained),
+		},
+		{
+			name:       "unrelated certificate in dbx",
+			image:      testSignImage(t, leaf, unrelated.Certificate),
+			dbx:        SignatureDatabase{NewX509SignatureList(guid.UUID{}, unrelated.Certificate), tbs(unrelated.Certificate)},
+			authorized: true,
+		},
+		{
+			name:    "intermediate revoked by TBS hash",
+			image:   testSignImage(t, chained, intermediate.Certificate),
+			dbx:     SignatureDatabase{tbs(intermediate.Certificate)},
+			revoked: true,
+			reason:  `"CN=Intermediate CA" is revoked by TBS hash`,
+		},
+		{
+			name:    "signer revoked",
+			image:   testSignImage(t, leaf),
+			dbx:     SignatureDatabase{NewX509SignatureList(guid.UUID{}, leaf.Certificate)},
+			revoked: true,
+		},
+		{
+			name:    "issuer revoked",
+			image:   testSignImage(t, chained, intermediate.Certificate),
+			dbx:     SignatureDatabase{NewX509SignatureList(guid.UUID{}, intermediate.Certificate)},
+			revoked: true,
+		},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			v, err := CheckImage(tt.image, db, tt.dbx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Authorized != tt.authorized || v.Revoked != tt.revoked || !strings.Contains(v.Reason, tt.reason) {
+				t.Errorf("CheckImage() = %+v, want authorized %v, revoked %v, reason %q", v, tt.authorized, tt.revoked, tt.reason)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package sysinfo identifies the machine and firmware from the DMI
+// data the kernel exports, so variable dumps, reports and quirks can
+// record which system they belong to.
+package sysinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SysDMI holds the DMI identification of the system
+var SysDMI = "/sys/class/dmi/id"
+
+// Info identifies the system as reported by its SMBIOS tables. Fields
+// the firmware doesn't provide are empty.
+type Info struct {
+	SysVendor      string `json:"sys_vendor,omitempty"`
+	ProductName    string `json:"product_name,omitempty"`
+	ProductVersion string `json:"product_version,omitempty"`
+	BoardVendor    string `json:"board_vendor,omitempty"`
+	BoardName      string `json:"board_name,omitempty"`
+
+	BIOSVendor  string `json:"bios_vendor,omitempty"`
+	BIOSVersion string `json:"bios_version,omitempty"`
+	BIOSDate    string `json:"bios_date,omitempty"`
+	// BIOSRelease and FirmwareRelease are the major.minor releases of
+	// the system firmware and the embedded controller firmware
+	BIOSRelease     string `json:"bios_release,omitempty"`
+	FirmwareRelease string `json:"ec_firmware_release,omitempty"`
+}
+
+// Read reads the identification of the running system from SysDMI.
+func Read() (Info, error) {
+	var info Info
+	found := false
+	for _, f := range []struct {
+		name  string
+		value *string
+	}{
+		{"sys_vendor", &info.SysVendor},
+		{"product_name", &info.ProductName},
+		{"product_version", &info.ProductVersion},
+		{"board_vendor", &info.BoardVendor},
+		{"board_name", &info.BoardName},
+		{"bios_vendor", &info.BIOSVendor},
+		{"bios_version", &info.BIOSVersion},
+		{"bios_date", &info.BIOSDate},
+		{"bios_release", &info.BIOSRelease},
+		{"ec_firmware_release", &info.FirmwareRelease},
+	} {
+		b, err := os.ReadFile(filepath.Join(SysDMI, f.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return Info{}, err
+		}
+		*f.value = strings.TrimSpace(string(b))
+		found = true
+	}
+	if !found {
+		return Info{}, fmt.Errorf("%s: %w", SysDMI, os.ErrNotExist)
+	}
+	return info, nil
+}
+
+// String describes the system in a single line, e.g.
+// "LENOVO 20XW (BIOS N32ET75W 1.51)".
+func (i Info) String() string {
+	s := strings.TrimSpace(i.SysVendor + " " + i.ProductName)
+	if s == "" {
+		s = strings.TrimSpace(i.BoardVendor + " " + i.BoardName)
+	}
+	if s == "" {
+		s = "unknown system"
+	}
+	if fw := strings.TrimSpace(i.BIOSVersion + " " + i.BIOSRelease); fw != "" {
+		s += " (BIOS " + fw + ")"
+	}
+	return s
+}
@@ -0,0 +1,87 @@
+// Package watch reports changes of EFI variables by watching the
+// efivarfs directory with inotify. Only changes made through efivarfs
+// are visible, which covers everything the running system does, as the
+// firmware doesn't modify variables behind the kernel's back at runtime.
+package watch
+
+import (
+	"context"
+	"os"
+	"sync"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+// Op describes what happened to a variable.
+type Op int
+
+const (
+	// Created is reported for new variables
+	Created Op = iota + 1
+	// Modified is reported when a variable is written or appended to
+	Modified
+	// Removed is reported when a variable is deleted
+	Removed
+)
+
+func (o Op) String() string {
+	switch o {
+	case Created:
+		return "created"
+	case Modified:
+		return "modified"
+	case Removed:
+		return "removed"
+	}
+	return "unknown"
+}
+
+// Event is a single change of a variable.
+type Event struct {
+	Op   Op
+	Desc efivarfs.VariableDescriptor
+}
+
+// Watcher delivers the changes of the variables in a directory.
+type Watcher struct {
+	// Events receives all changes, it is closed by Close
+	Events <-chan Event
+	// Errors receives errors of the underlying inotify instance
+	Errors <-chan error
+
+	f         *os.File
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// Close stops watching and closes the Events channel.
+func (w *Watcher) Close() error {
+	var err error
+	w.closeOnce.Do(func() {
+		close(w.done)
+		err = w.f.Close()
+	})
+	return err
+}
+
+// Invalidate keeps c up to date with the changes reported by w until ctx
+// is done or w stops. All entries are dropped whenever w reports an
+// error like a queue overflow, as changes might have been lost, and once
+// w stops, as c isn't kept up to date anymore. The caller should then
+// switch to a Cache with a TTL or stop using c.
+func Invalidate(ctx context.Context, w *Watcher, c *efivarfs.Cache) error {
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				c.InvalidateAll()
+				return nil
+			}
+			c.Invalidate(ev.Desc)
+		case <-w.Errors:
+			c.InvalidateAll()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package watch
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"unsafe"
+
+	"github.com/system-transparency/efivar/efivarfs"
+	"golang.org/x/sys/unix"
+)
+
+const watchMask = unix.IN_CREATE | unix.IN_CLOSE_WRITE | unix.IN_DELETE | unix.IN_MOVED_TO | unix.IN_MOVED_FROM
+
+// New starts watching dir, which is usually efivarfs.MountPoint().
+func New(dir string) (*Watcher, error) {
+	fd, err := unix.InotifyInit1(unix.IN_CLOEXEC | unix.IN_NONBLOCK)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := unix.InotifyAddWatch(fd, dir, watchMask); err != nil {
+		unix.Close(fd)
+		return nil, &os.PathError{Op: "inotify_add_watch", Path: dir, Err: err}
+	}
+	events := make(chan Event)
+	errs := make(chan error, 1)
+	w := &Watcher{
+		Events: events,
+		Errors: errs,
+		// Using an os.File puts the descriptor into the runtime poller,
+		// which makes Close interrupt a pending read.
+		f:    os.NewFile(uintptr(fd), "inotify"),
+		done: make(chan struct{}),
+	}
+	go w.run(events, errs)
+	return w, nil
+}
+
+func (w *Watcher) run(events chan<- Event, errs chan<- error) {
+	defer close(events)
+	buf := make([]byte, 64*(unix.SizeofInotifyEvent+unix.NAME_MAX+1))
+	for {
+		n, err := w.f.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) {
+				select {
+				case errs <- err:
+				default:
+				}
+			}
+			return
+		}
+		for off := 0; off+unix.SizeofInotifyEvent <= n; {
+			raw := (*unix.InotifyEvent)(unsafe.Pointer(&buf[off]))
+			name := buf[off+unix.SizeofInotifyEvent : off+unix.SizeofInotifyEvent+int(raw.Len)]
+			off += unix.SizeofInotifyEvent + int(raw.Len)
+
+			if raw.Mask&unix.IN_Q_OVERFLOW != 0 {
+				select {
+				case errs <- errors.New("inotify queue overflow, events were lost"):
+				default:
+				}
+				continue
+			}
+			desc, err := efivarfs.ParseFileName(string(bytes.TrimRight(name, "\x00")))
+			if err != nil {
+				continue
+			}
+			var op Op
+			switch {
+			case raw.Mask&(unix.IN_CREATE|unix.IN_MOVED_TO) != 0:
+				op = Created
+			case raw.Mask&unix.IN_CLOSE_WRITE != 0:
+				op = Modified
+			case raw.Mask&(unix.IN_DELETE|unix.IN_MOVED_FROM) != 0:
+				op = Removed
+			default:
+				continue
+			}
+			select {
+			case events <- Event{Op: op, Desc: desc}:
+			case <-w.done:
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/system-transparency/efivar/efivarfs"
+)
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	timeout := efivarfs.VariableDescriptor{Name: "Timeout", GUID: &efivarfs.GlobalVariable}
+	path := filepath.Join(dir, timeout.String())
+	// The kernel merges identical events queued in a row, so each step
+	// is awaited before the next one.
+	for _, step := range []struct {
+		do   func() error
+		want []Op
+	}{
+		// Files that aren't variables are ignored
+		{func() error { return os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0644) }, nil},
+		{func() error { return os.WriteFile(path, []byte{7, 0, 0, 0, 5, 0}, 0644) }, []Op{Created, Modified}},
+		{func() error { return os.WriteFile(path, []byte{7, 0, 0, 0, 1, 0}, 0644) }, []Op{Modified}},
+		{func() error { return os.Remove(path) }, []Op{Removed}},
+	} {
+		if err := step.do(); err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range step.want {
+			select {
+			case ev := <-w.Events:
+				if ev.Op != want || !ev.Desc.Equal(timeout) {
+					t.Errorf("got %s %s, want %s %s", ev.Op, ev.Desc, want, timeout)
+				}
+			case err := <-w.Errors:
+				t.Fatal(err)
+			case <-time.After(5 * time.Second):
+				t.Fatalf("no %s event", want)
+			}
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case ev, ok := <-w.Events:
+		if ok {
+			t.Errorf("got %s %s after Close()", ev.Op, ev.Desc)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Events not closed by Close()")
+	}
+}
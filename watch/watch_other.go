@@ -0,0 +1,11 @@
+//go:build !linux
+
+package watch
+
+import "github.com/system-transparency/efivar/efivarfs"
+
+// New starts watching dir, which is only supported on Linux. Elsewhere
+// it always fails with efivarfs.ErrVarsUnavailable.
+func New(dir string) (*Watcher, error) {
+	return nil, efivarfs.ErrVarsUnavailable
+}